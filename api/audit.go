@@ -0,0 +1,57 @@
+package api
+
+import (
+	"cluster-sim/internal/audit"
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/logging"
+)
+
+// newAudit builds the audit backends of the audit section:
+//
+//	policy_file       which requests to record at which level
+//	log_path          JSON-lines file to write events to, - for stdout
+//	log_max_size      megabytes before the file is rotated (100)
+//	log_max_backups   rotated files to keep (10)
+//	webhook_url       endpoint to post batches of events to
+//	webhook_ca_file   CA bundle to verify an https endpoint with
+//
+// Without a policy file every request is recorded at the Metadata level.
+// It returns nil when neither a log file nor a webhook is configured.
+func newAudit(cfg config.Audit) (audit.Policy, audit.Backend) {
+	var backends audit.Union
+	if path := cfg.LogPath; path != "" {
+		b, err := audit.NewLogBackend(path, cfg.LogMaxSize, cfg.LogMaxBackups)
+		if err != nil {
+			logging.Fatal(logger, "Error opening audit log", logging.Err(err))
+		}
+		logger.Info("Writing audit events to a file", "path", path)
+		backends = append(backends, b)
+	}
+	if url := cfg.WebhookURL; url != "" {
+		b, err := audit.NewWebhookBackend(url, cfg.WebhookCAFile)
+		if err != nil {
+			logging.Fatal(logger, "Error setting up the audit webhook", logging.Err(err))
+		}
+		logger.Info("Sending audit events to a webhook", "url", url)
+		backends = append(backends, b)
+	}
+
+	policy := audit.DefaultPolicy()
+	if path := cfg.PolicyFile; path != "" {
+		var err error
+		if policy, err = audit.LoadPolicy(path); err != nil {
+			logging.Fatal(logger, "Error loading audit policy", logging.Err(err))
+		}
+		if len(backends) == 0 {
+			logger.Warn("audit.policy_file has no effect without audit.log_path or audit.webhook_url")
+		}
+	}
+
+	switch len(backends) {
+	case 0:
+		return policy, nil
+	case 1:
+		return policy, backends[0]
+	}
+	return policy, backends
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/logging"
+)
+
+// newAuth builds the authenticators and authorizer of the auth section:
+//
+//	token_file           token,user,uid,"group1,group2" lines
+//	basic_auth_file      password,user,uid,"group1,group2" lines
+//	authorization_mode   RBAC (the default) or AlwaysAllow
+//	rbac_policy_file     roles and bindings to start with
+//
+// Client certificates are accepted when clientCAFile, from newTLS, is set.
+// With no authenticator configured the API stays open and the returned
+// authenticator is nil. The RBAC store is returned even then, so its
+// routes keep working.
+func newAuth(cfg config.Auth, clientCAFile string) (auth.Authenticator, auth.Authorizer, *auth.RBAC) {
+	var authenticators auth.Union
+	if clientCAFile != "" {
+		a, err := auth.NewClientCert(clientCAFile)
+		if err != nil {
+			logging.Fatal(logger, "Error loading client CA", logging.Err(err))
+		}
+		authenticators = append(authenticators, a)
+	}
+	if path := cfg.TokenFile; path != "" {
+		a, err := auth.NewTokenFile(path)
+		if err != nil {
+			logging.Fatal(logger, "Error loading token file", logging.Err(err))
+		}
+		authenticators = append(authenticators, a)
+	}
+	if path := cfg.BasicAuthFile; path != "" {
+		a, err := auth.NewBasicAuthFile(path)
+		if err != nil {
+			logging.Fatal(logger, "Error loading basic auth file", logging.Err(err))
+		}
+		authenticators = append(authenticators, a)
+	}
+
+	var policy auth.Policy
+	if path := cfg.RBACPolicyFile; path != "" {
+		var err error
+		if policy, err = auth.LoadPolicy(path); err != nil {
+			logging.Fatal(logger, "Error loading RBAC policy", logging.Err(err))
+		}
+	}
+	rbac, err := auth.NewRBAC(policy)
+	if err != nil {
+		logging.Fatal(logger, "Invalid RBAC policy", logging.Err(err))
+	}
+
+	if len(authenticators) == 0 {
+		logger.Warn("Authentication is disabled: anyone who can reach the API server can use it")
+		return nil, auth.AlwaysAllow{}, rbac
+	}
+	switch mode := cfg.AuthorizationMode; mode {
+	case "", "RBAC":
+		logger.Info("Authentication enabled", "authenticators", len(authenticators), "authorization", "RBAC")
+		return authenticators, rbac, rbac
+	case "AlwaysAllow":
+		logger.Warn("Authentication enabled, but every user is allowed everything", "authenticators", len(authenticators), "authorization", "AlwaysAllow")
+		return authenticators, auth.AlwaysAllow{}, rbac
+	default:
+		logging.Fatal(logger, "Unknown auth.authorization_mode: use RBAC or AlwaysAllow", "mode", mode)
+		return nil, nil, nil
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"cluster-sim/internal/admission"
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/autoscaler"
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/controller"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/persistence"
+	"cluster-sim/internal/policy"
+	"cluster-sim/internal/service"
+	"cluster-sim/internal/storage"
+	"encoding/json"
+)
+
+// workloads are the controllers whose objects are saved.
+type workloads struct {
+	podGroups    *controller.PodGroupController
+	hpas         *controller.HPAController
+	jobs         *controller.JobController
+	cronJobs     *controller.CronJobController
+	daemonSets   *controller.DaemonSetController
+	statefulSets *controller.StatefulSetController
+	services     *service.Controller
+}
+
+// savedVolumes is the volumes section: volumes and claims are restored
+// together so their bindings survive.
+type savedVolumes struct {
+	Volumes []storage.PersistentVolume      `json:"volumes"`
+	Claims  []storage.PersistentVolumeClaim `json:"claims"`
+}
+
+// newStore restores the objects saved in the state file of the persistence
+// section and saves them again every save_interval. Objects from the
+// config, admission and RBAC policy files are already in place, so saved
+// ones of the same name replace them. It returns nil when no state file is
+// configured.
+func newStore(cfg config.Persistence, nm *node.NodeManager, chain *admission.Chain, rbac *auth.RBAC, clusterAutoscaler *autoscaler.Autoscaler, w workloads) *persistence.Store {
+	if cfg.StateFile == "" {
+		return nil
+	}
+	store := persistence.NewStore(cfg.StateFile)
+
+	// Namespaces, quotas and webhooks first, so restored pods are admitted
+	store.Register(persistence.List("namespaces", chain.Namespaces.List, func(name string) error {
+		_, err := chain.Namespaces.Add(name)
+		return err
+	}, func(name string) string { return name }))
+	store.Register(persistence.List("resourcequotas", func() []admission.ResourceQuota {
+		var quotas []admission.ResourceQuota
+		for _, q := range chain.Quotas.Status() {
+			quotas = append(quotas, q.ResourceQuota)
+		}
+		return quotas
+	}, chain.Quotas.Set, func(q admission.ResourceQuota) string { return q.Namespace }))
+	store.Register(persistence.List("webhooks", chain.Webhooks, chain.SetWebhook,
+		func(h admission.Webhook) string { return h.Name }))
+
+	// Roles ahead of the bindings that refer to them
+	store.Register(persistence.List("clusterroles", func() []auth.ClusterRole { return rbac.Policy().ClusterRoles },
+		rbac.SetClusterRole, func(r auth.ClusterRole) string { return r.Name }))
+	store.Register(persistence.List("roles", func() []auth.Role { return rbac.Policy().Roles },
+		rbac.SetRole, func(r auth.Role) string { return r.Namespace + "/" + r.Name }))
+	store.Register(persistence.List("clusterrolebindings", func() []auth.ClusterRoleBinding { return rbac.Policy().ClusterRoleBindings },
+		rbac.SetClusterRoleBinding, func(b auth.ClusterRoleBinding) string { return b.Name }))
+	store.Register(persistence.List("rolebindings", func() []auth.RoleBinding { return rbac.Policy().RoleBindings },
+		rbac.SetRoleBinding, func(b auth.RoleBinding) string { return b.Namespace + "/" + b.Name }))
+
+	// Node templates and groups, so the autoscaler brings nodes back
+	store.Register(persistence.List("nodetemplates", nm.ListNodeTemplates, nm.SetNodeTemplate,
+		func(t node.NodeTemplate) string { return t.Name }))
+	store.Register(persistence.List("nodegroups", func() []autoscaler.NodeGroup {
+		var groups []autoscaler.NodeGroup
+		for _, g := range clusterAutoscaler.ListNodeGroups() {
+			groups = append(groups, g.NodeGroup)
+		}
+		return groups
+	}, clusterAutoscaler.SetNodeGroup, func(g autoscaler.NodeGroup) string { return g.Name }))
+
+	// Storage and disruption budgets
+	store.Register(persistence.List("storageclasses", nm.ListStorageClasses, nm.SetStorageClass,
+		func(c storage.StorageClass) string { return c.Name }))
+	store.Register(persistence.Section{
+		Name: "volumes",
+		Save: func() interface{} {
+			saved := savedVolumes{Volumes: nm.ListVolumes(), Claims: []storage.PersistentVolumeClaim{}}
+			for _, c := range nm.ListClaims() {
+				saved.Claims = append(saved.Claims, c.PersistentVolumeClaim)
+			}
+			return saved
+		},
+		Restore: func(data json.RawMessage) error {
+			var saved savedVolumes
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return err
+			}
+			return nm.RestoreVolumes(saved.Volumes, saved.Claims)
+		},
+	})
+	store.Register(persistence.List("pdbs", func() []policy.PodDisruptionBudget {
+		var budgets []policy.PodDisruptionBudget
+		for _, b := range nm.ListPDBs() {
+			budgets = append(budgets, b.PodDisruptionBudget)
+		}
+		return budgets
+	}, nm.SetPDB, func(b policy.PodDisruptionBudget) string { return b.Name }))
+
+	// Services and the workloads that create pods
+	store.Register(persistence.List("services", func() []service.Service {
+		var services []service.Service
+		for _, s := range w.services.List() {
+			services = append(services, s.Service)
+		}
+		return services
+	}, func(s service.Service) error {
+		_, err := w.services.Set(s)
+		return err
+	}, func(s service.Service) string { return s.Name }))
+	store.Register(persistence.List("podgroups", func() []controller.PodGroup {
+		var groups []controller.PodGroup
+		for _, g := range w.podGroups.List() {
+			groups = append(groups, g.PodGroup)
+		}
+		return groups
+	}, w.podGroups.Set, func(g controller.PodGroup) string { return g.Name }))
+	store.Register(persistence.List("hpas", func() []controller.HorizontalPodAutoscaler {
+		var hpas []controller.HorizontalPodAutoscaler
+		for _, h := range w.hpas.List() {
+			hpas = append(hpas, h.HorizontalPodAutoscaler)
+		}
+		return hpas
+	}, w.hpas.Set, func(h controller.HorizontalPodAutoscaler) string { return h.Name }))
+	store.Register(persistence.List("daemonsets", func() []controller.DaemonSet {
+		var sets []controller.DaemonSet
+		for _, s := range w.daemonSets.List() {
+			sets = append(sets, s.DaemonSet)
+		}
+		return sets
+	}, w.daemonSets.Set, func(s controller.DaemonSet) string { return s.Name }))
+	store.Register(persistence.List("statefulsets", func() []controller.StatefulSet {
+		var sets []controller.StatefulSet
+		for _, s := range w.statefulSets.List() {
+			sets = append(sets, s.StatefulSet)
+		}
+		return sets
+	}, w.statefulSets.Set, func(s controller.StatefulSet) string { return s.Name }))
+	// Jobs keep their status, so finished ones do not run again
+	store.Register(persistence.List("jobs", w.jobs.List, w.jobs.Restore,
+		func(j controller.JobWithStatus) string { return j.Name }))
+	store.Register(persistence.List("cronjobs", w.cronJobs.List, w.cronJobs.Restore,
+		func(cj controller.CronJobWithStatus) string { return cj.Name }))
+
+	if err := store.Restore(); err != nil {
+		logging.Fatal(logger, "Error restoring saved state", logging.Err(err))
+	}
+	store.Start(cfg.SaveInterval)
+	logger.Info("Saving cluster state", "file", cfg.StateFile, "interval", cfg.SaveInterval)
+	return store
+}
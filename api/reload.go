@@ -0,0 +1,99 @@
+package api
+
+import (
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/health"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// saveNodeTemplates registers the node templates of the config file, which
+// were validated when it was loaded.
+func saveNodeTemplates(nm *node.NodeManager, templates []config.NodeTemplate) {
+	for _, t := range templates {
+		if err := nm.SetNodeTemplate(t.NodeTemplate()); err != nil {
+			logger.Error("Node template not saved", "template", t.Name, logging.Err(err))
+		}
+	}
+}
+
+// setupLogging applies the logging section.
+func setupLogging(cfg config.Logging) error {
+	logConfig, err := cfg.Config()
+	if err != nil {
+		return err
+	}
+	return logging.Setup(logConfig)
+}
+
+// watchReload reads the configuration again each time the server is sent
+// SIGHUP and applies the sections that changed and can change while it
+// runs. A configuration with mistakes is rejected as a whole and the one
+// in use is kept; changes to the other sections wait for a restart.
+func watchReload(flags *config.Flags, current config.Config, nodeManager *node.NodeManager, healthManager *health.HealthManager) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			next, err := config.Load(flags)
+			if err != nil {
+				logger.Error("Config not reloaded", logging.Err(err))
+				continue
+			}
+
+			var applied, pending []string
+			for _, section := range current.Changed(next) {
+				if !config.Reloadable[section] {
+					pending = append(pending, section)
+					continue
+				}
+				switch section {
+				case "node":
+					node.SetRuntimeOptions(next.Node.RuntimeOptions())
+				case "node_templates":
+					saveNodeTemplates(nodeManager, next.NodeTemplates)
+				case "health":
+					healthManager.SetOptions(next.Health.Options())
+				case "scheduler":
+					node.SetSchedulerProfiles(next.Scheduler.SchedulerProfiles(), next.Scheduler.DefaultProfile)
+				case "logging":
+					if err := setupLogging(next.Logging); err != nil {
+						logger.Error("Logging config not reloaded", logging.Err(err))
+						continue
+					}
+				}
+				applied = append(applied, section)
+			}
+			// Sections that wait for a restart stay as they are, so they
+			// are reported again on every reload until then.
+			for _, section := range pending {
+				logger.Warn("Config section changed but needs a restart", "section", section)
+			}
+			current = merge(current, next, applied)
+			logger.Info("Config reloaded", "applied", strings.Join(applied, ","))
+		}
+	}()
+}
+
+// merge returns cur with the named sections taken from next.
+func merge(cur, next config.Config, sections []string) config.Config {
+	for _, section := range sections {
+		switch section {
+		case "node":
+			cur.Node = next.Node
+		case "node_templates":
+			cur.NodeTemplates = next.NodeTemplates
+		case "health":
+			cur.Health = next.Health
+		case "scheduler":
+			cur.Scheduler = next.Scheduler
+		case "logging":
+			cur.Logging = next.Logging
+		}
+	}
+	return cur
+}
@@ -1,46 +1,316 @@
 package api
 
 import (
+	"cluster-sim/internal/admission"
+	"cluster-sim/internal/audit"
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/autoscaler"
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/controller"
+	"cluster-sim/internal/dns"
 	"cluster-sim/internal/health"
+	"cluster-sim/internal/ipam"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/network"
 	"cluster-sim/internal/node"
+	"cluster-sim/internal/service"
+	"cluster-sim/internal/tracing"
+	"context"
 	"github.com/gin-gonic/gin"
-	"log"
 	"net/http"
+	"time"
 )
 
-func StartServer(port string) {
-	r := gin.Default()
+var logger = logging.For("api")
+
+func StartServer(flags *config.Flags) {
+	// Read the config file, the environment and the flags, and refuse to
+	// start on any mistake in them
+	cfg, err := config.Load(flags)
+	if err != nil {
+		logging.Fatal(logger, "Invalid config", logging.Err(err))
+	}
+
+	// Log as text or JSON, with per-component levels
+	if err := setupLogging(cfg.Logging); err != nil {
+		logging.Fatal(logger, "Invalid logging config", logging.Err(err))
+	}
+	logging.RouteGin()
+
+	// Trace API requests, scheduling cycles, Docker calls and health checks
+	// to an OTLP collector or a file
+	shutdownTracing, err := tracing.Setup(cfg.Tracing.Config())
+	if err != nil {
+		logging.Fatal(logger, "Error setting up tracing", logging.Err(err))
+	}
+
+	// Log every request and recover from panics in handlers. The tracing
+	// middleware runs inside the request logger, so its record carries the
+	// request's trace ID
+	r := gin.New()
+	r.Use(logging.Middleware(), logging.Recovery(), tracing.Middleware())
+
+	// Serve over TLS when a certificate or the built-in CA is configured
+	tlsConfig, clientCAFile := newTLS(cfg.TLS)
+
+	// Record who did what through the API; this runs ahead of authentication
+	// so rejected requests are recorded too
+	auditPolicy, auditBackend := newAudit(cfg.Audit)
+	if auditBackend != nil {
+		r.Use(audit.Middleware(auditPolicy, auditBackend))
+	}
+
+	// Authenticate and authorize every request before it reaches a handler
+	authenticator, authorizer, rbac := newAuth(cfg.Auth, clientCAFile)
+	if authenticator != nil {
+		r.Use(auth.Middleware(authenticator, authorizer))
+	}
+
+	// Run node containers and schedule pods as configured
+	node.SetRuntimeOptions(cfg.Node.RuntimeOptions())
+	node.SetSchedulerProfiles(cfg.Scheduler.SchedulerProfiles(), cfg.Scheduler.DefaultProfile)
 
 	// Initialize NodeManager
 	nodeManager := node.NewNodeManager()
+	// Carve pod CIDRs out of the configured range
+	allocator, err := ipam.New(cfg.Network.PodCIDR, cfg.Network.NodeCIDRMaskSize)
+	if err != nil {
+		logging.Fatal(logger, "Invalid pod address range", logging.Err(err))
+	}
+	nodeManager.IPAM = allocator
+	// Register the node templates of the config file
+	saveNodeTemplates(nodeManager, cfg.NodeTemplates)
+
+	// Review every pod before it is created, with the plugins, namespaces,
+	// quotas and webhooks in the admission config file
+	admissionConfig := admission.DefaultConfig()
+	if path := cfg.Admission.ConfigFile; path != "" {
+		var err error
+		if admissionConfig, err = admission.LoadConfig(path); err != nil {
+			logging.Fatal(logger, "Error loading admission config", logging.Err(err))
+		}
+	}
+	admissionChain, err := admission.NewChain(nodeManager, admissionConfig)
+	if err != nil {
+		logging.Fatal(logger, "Invalid admission config", logging.Err(err))
+	}
+	nodeManager.Admission = admissionChain
+	// Authorize requests naming a pod in the pod's own namespace
+	auth.SetNamespaceLookup("pods", nodeManager.PodNamespace)
 
 	// Initialize Health Manager
-	healthManager := health.NewHealthManager(nodeManager)
+	healthManager := health.NewHealthManager(nodeManager, cfg.Health.Options())
 	healthManager.StartMonitoring()
+	healthManager.StartHeartbeats()
+
+	// Initialize the cluster autoscaler; it does nothing until node groups are added
+	clusterAutoscaler := autoscaler.New(nodeManager, autoscaler.DefaultOptions())
+	clusterAutoscaler.Start()
+
+	// Initialize workload controllers
+	podGroups := controller.NewPodGroupController(nodeManager)
+	podGroups.Start()
+	hpas := controller.NewHPAController(nodeManager, podGroups)
+	hpas.Start()
+	jobs := controller.NewJobController(nodeManager)
+	jobs.Start()
+	cronJobs := controller.NewCronJobController(jobs)
+	cronJobs.Start()
+	daemonSets := controller.NewDaemonSetController(nodeManager)
+	daemonSets.Start()
+	statefulSets := controller.NewStatefulSetController(nodeManager)
+	statefulSets.Start()
+
+	// Attach node containers to a dedicated network and enforce partitions,
+	// latency and loss on it; the fake network runtime keeps them to the
+	// simulator's own heartbeats and service traffic
+	if err := node.EnsureClusterNetwork(); err != nil {
+		logger.Warn("Cluster network unavailable, using Docker's default bridge", logging.Err(err))
+	}
+	var networkRuntime network.Runtime = node.DockerNetworkRuntime{}
+	if cfg.Runtime.Network == "fake" {
+		networkRuntime = network.NewFakeRuntime()
+	}
+	nodeManager.StartNetworkEnforcer(networkRuntime)
+
+	// Track the ready pods behind each service and balance synthetic traffic over them
+	services := service.NewController(nodeManager)
+	services.Start()
+
+	// Serve service and pod names to clients and node containers
+	clusterDNS := dns.NewServer(nodeManager, services)
+	if err := clusterDNS.Start(cfg.DNS.Addr); err != nil {
+		logger.Warn("Cluster DNS disabled", logging.Err(err))
+	}
+
+	// Run batch pods to completion once they are scheduled
+	nodeManager.StartPodRunner()
+	// Bind volume claims as matching volumes appear
+	nodeManager.StartVolumeBinder()
+
+	// Expose cluster state and component telemetry to Prometheus
+	metrics.Register(nodeManager.Collector())
+
+	// Put back the objects saved before the last shutdown and keep saving them
+	store := newStore(cfg.Persistence, nodeManager, admissionChain, rbac, clusterAutoscaler, workloads{
+		podGroups:    podGroups,
+		hpas:         hpas,
+		jobs:         jobs,
+		cronJobs:     cronJobs,
+		daemonSets:   daemonSets,
+		statefulSets: statefulSets,
+		services:     services,
+	})
 
 	// Register routes, binding the NodeManager
 	r.POST("/add_node", nodeManager.AddNodeHandler)
 	r.GET("/nodes", nodeManager.ListNodesHandler)
+	r.GET("/nodes/:id", nodeManager.DescribeNodeHandler)
+	r.PUT("/nodes/:id/cordon", nodeManager.CordonNodeHandler)
+	r.PUT("/nodes/:id/uncordon", nodeManager.UncordonNodeHandler)
+	r.POST("/nodes/:id/drain", nodeManager.DrainNodeHandler)
+	r.GET("/pods", nodeManager.ListPodsHandler)
+	r.GET("/pods/:id", nodeManager.DescribePodHandler)
+	r.POST("/pods/:id/eviction", nodeManager.EvictPodHandler)
+	r.DELETE("/pods/:id", nodeManager.DeletePodHandler)
+	r.POST("/pdbs", nodeManager.AddPDBHandler)
+	r.GET("/pdbs", nodeManager.ListPDBsHandler)
+	r.DELETE("/pdbs/:name", nodeManager.DeletePDBHandler)
+	r.POST("/pvcs", nodeManager.AddPVCHandler)
+	r.GET("/pvcs", nodeManager.ListPVCsHandler)
+	r.DELETE("/pvcs/:name", nodeManager.DeletePVCHandler)
+	r.POST("/pvs", nodeManager.AddPVHandler)
+	r.GET("/pvs", nodeManager.ListPVsHandler)
+	r.DELETE("/pvs/:name", nodeManager.DeletePVHandler)
+	r.POST("/storageclasses", nodeManager.AddStorageClassHandler)
+	r.GET("/storageclasses", nodeManager.ListStorageClassesHandler)
+	r.DELETE("/storageclasses/:name", nodeManager.DeleteStorageClassHandler)
+	r.POST("/nodetemplates", nodeManager.AddNodeTemplateHandler)
+	r.GET("/nodetemplates", nodeManager.ListNodeTemplatesHandler)
+	r.GET("/nodetemplates/:name", nodeManager.GetNodeTemplateHandler)
+	r.DELETE("/nodetemplates/:name", nodeManager.DeleteNodeTemplateHandler)
 	r.POST("/add_pod", nodeManager.AddPodHandler) // Added this line
 	r.PUT("/restart_node", nodeManager.RestartNodeHandler)
 	r.DELETE("/delete_node", nodeManager.DeleteNodeHandler)
+	r.GET("/autoscaler", clusterAutoscaler.StatusHandler)
+	r.PUT("/autoscaler", clusterAutoscaler.UpdateOptionsHandler)
+	r.POST("/nodegroups", clusterAutoscaler.AddNodeGroupHandler)
+	r.GET("/nodegroups", clusterAutoscaler.ListNodeGroupsHandler)
+	r.DELETE("/nodegroups/:name", clusterAutoscaler.DeleteNodeGroupHandler)
+	r.POST("/podgroups", podGroups.AddPodGroupHandler)
+	r.GET("/podgroups", podGroups.ListPodGroupsHandler)
+	r.GET("/podgroups/:name", podGroups.GetPodGroupHandler)
+	r.DELETE("/podgroups/:name", podGroups.DeletePodGroupHandler)
+	r.PUT("/podgroups/:name/scale", podGroups.ScalePodGroupHandler)
+	r.PUT("/podgroups/:name/load", podGroups.SetLoadHandler)
+	r.POST("/hpas", hpas.AddHPAHandler)
+	r.GET("/hpas", hpas.ListHPAsHandler)
+	r.DELETE("/hpas/:name", hpas.DeleteHPAHandler)
+	r.POST("/jobs", jobs.AddJobHandler)
+	r.GET("/jobs", jobs.ListJobsHandler)
+	r.GET("/jobs/:name", jobs.GetJobHandler)
+	r.DELETE("/jobs/:name", jobs.DeleteJobHandler)
+	r.POST("/cronjobs", cronJobs.AddCronJobHandler)
+	r.GET("/cronjobs", cronJobs.ListCronJobsHandler)
+	r.GET("/cronjobs/:name", cronJobs.GetCronJobHandler)
+	r.DELETE("/cronjobs/:name", cronJobs.DeleteCronJobHandler)
+	r.POST("/cronjobs/:name/trigger", cronJobs.TriggerCronJobHandler)
+	r.POST("/daemonsets", daemonSets.AddDaemonSetHandler)
+	r.GET("/daemonsets", daemonSets.ListDaemonSetsHandler)
+	r.GET("/daemonsets/:name", daemonSets.GetDaemonSetHandler)
+	r.DELETE("/daemonsets/:name", daemonSets.DeleteDaemonSetHandler)
+	r.POST("/statefulsets", statefulSets.AddStatefulSetHandler)
+	r.GET("/statefulsets", statefulSets.ListStatefulSetsHandler)
+	r.GET("/statefulsets/:name", statefulSets.GetStatefulSetHandler)
+	r.PUT("/statefulsets/:name/scale", statefulSets.ScaleStatefulSetHandler)
+	r.DELETE("/statefulsets/:name", statefulSets.DeleteStatefulSetHandler)
+	r.POST("/services", services.AddServiceHandler)
+	r.GET("/services", services.ListServicesHandler)
+	r.GET("/services/:name", services.GetServiceHandler)
+	r.DELETE("/services/:name", services.DeleteServiceHandler)
+	r.PUT("/services/:name/traffic", services.SetTrafficHandler)
+	r.POST("/services/:name/reset", services.ResetStatsHandler)
+	r.GET("/network", nodeManager.NetworkStatusHandler)
+	r.PUT("/network/links", nodeManager.SetLinkHandler)
+	r.DELETE("/network/links/:a/:b", nodeManager.HealLinkHandler)
+	r.POST("/network/partition", nodeManager.PartitionHandler)
+	r.POST("/network/heal", nodeManager.HealNetworkHandler)
+	r.GET("/ipam", nodeManager.IPAMHandler)
+	r.GET("/dns", clusterDNS.RecordsHandler)
+	r.GET("/auth/whoami", auth.WhoAmIHandler)
+	r.POST("/auth/can-i", auth.CanIHandler(authorizer))
+	r.GET("/rbac", rbac.PolicyHandler)
+	r.POST("/roles", rbac.AddRoleHandler(authorizer))
+	r.GET("/roles", rbac.ListRolesHandler)
+	r.DELETE("/roles/:name", rbac.DeleteHandler("roles"))
+	r.POST("/clusterroles", rbac.AddClusterRoleHandler(authorizer))
+	r.GET("/clusterroles", rbac.ListClusterRolesHandler)
+	r.DELETE("/clusterroles/:name", rbac.DeleteHandler("clusterroles"))
+	r.POST("/rolebindings", rbac.AddRoleBindingHandler(authorizer))
+	r.GET("/rolebindings", rbac.ListRoleBindingsHandler)
+	r.DELETE("/rolebindings/:name", rbac.DeleteHandler("rolebindings"))
+	r.POST("/clusterrolebindings", rbac.AddClusterRoleBindingHandler(authorizer))
+	r.GET("/clusterrolebindings", rbac.ListClusterRoleBindingsHandler)
+	r.DELETE("/clusterrolebindings/:name", rbac.DeleteHandler("clusterrolebindings"))
+	r.GET("/admission", admissionChain.StatusHandler)
+	r.POST("/admission/review", admissionChain.ReviewHandler)
+	r.POST("/admission/webhooks", admissionChain.AddWebhookHandler)
+	r.GET("/admission/webhooks", admissionChain.ListWebhooksHandler)
+	r.DELETE("/admission/webhooks/:name", admissionChain.DeleteWebhookHandler)
+	r.POST("/namespaces", admissionChain.AddNamespaceHandler)
+	r.GET("/namespaces", admissionChain.ListNamespacesHandler)
+	r.DELETE("/namespaces/:name", admissionChain.DeleteNamespaceHandler)
+	r.POST("/resourcequotas", admissionChain.SetQuotaHandler)
+	r.GET("/resourcequotas", admissionChain.ListQuotasHandler)
+	r.DELETE("/resourcequotas/:namespace", admissionChain.DeleteQuotaHandler)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/events", nodeManager.Events.ListEventsHandler)
+	r.GET("/loglevels", logging.ListLevelsHandler)
+	r.PUT("/loglevels/:component", logging.SetLevelHandler)
+	r.DELETE("/loglevels/:component", logging.ResetLevelHandler)
 
 	// log.Printf("API Server running on port %s\n", port)
 	// r.Run(":" + port)
+	// Apply the settings that may change without a restart on SIGHUP
+	watchReload(flags, cfg, nodeManager, healthManager)
+
 	// Create HTTP server
+	port := cfg.Server.Port
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: r,
+		Addr:      ":" + port,
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
 
 	// Run the server in a separate goroutine
 	go func() {
-		log.Printf("API Server running on port %s\n", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+		var err error
+		if tlsConfig != nil {
+			logger.Info("API Server running", "port", port, "tls", true)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			logger.Info("API Server running", "port", port, "tls", false)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatal(logger, "Server error", logging.Err(err))
 		}
 	}()
 
 	// Handle graceful shutdown
-	nodeManager.ShutdownHandler(srv)
+	nodeManager.ShutdownHandler(srv, cfg.Server.ShutdownTimeout)
+	if store != nil {
+		if err := store.Save(); err != nil {
+			logger.Error("Error saving cluster state", logging.Err(err))
+		}
+	}
+	if auditBackend != nil {
+		auditBackend.Shutdown()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Error flushing traces", logging.Err(err))
+	}
 }
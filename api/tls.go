@@ -0,0 +1,106 @@
+package api
+
+import (
+	"cluster-sim/internal/config"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/pki"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// newTLS builds the TLS configuration of the API server from the tls
+// section:
+//
+//	cert_file        server certificate, PEM
+//	key_file         its private key, PEM
+//	pki_dir          run a built-in CA in this directory
+//	hosts            extra names for the built-in server certificate
+//	client_ca_file   CA bundle that signs client certificates
+//	client_auth      request (the default) or require a client certificate
+//
+// With pki_dir the CA, a server certificate and an admin client
+// certificate are created there on first start, and the CA's certificates
+// are used unless a certificate or client CA is given explicitly. It
+// returns nil when the server should serve plain HTTP, along with the
+// client CA bundle to authenticate client certificates against, if any.
+func newTLS(cfg config.TLS) (*tls.Config, string) {
+	certFile := cfg.CertFile
+	keyFile := cfg.KeyFile
+	clientCAFile := cfg.ClientCAFile
+
+	if dir := cfg.PKIDir; dir != "" {
+		if err := pki.Bootstrap(dir, serverHosts(cfg.Hosts)); err != nil {
+			logging.Fatal(logger, "Error setting up the built-in CA", "dir", dir, logging.Err(err))
+		}
+		logger.Info("Built-in CA ready", "dir", dir, "ca", pki.CACertFile, "client_cert", pki.AdminCertFile, "client_key", pki.AdminKeyFile)
+		if certFile == "" {
+			certFile = filepath.Join(dir, pki.ServerCertFile)
+			keyFile = filepath.Join(dir, pki.ServerKeyFile)
+		}
+		if clientCAFile == "" {
+			clientCAFile = filepath.Join(dir, pki.CACertFile)
+		}
+	}
+
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			logger.Warn("tls.client_ca_file has no effect without TLS: set tls.cert_file or tls.pki_dir")
+		}
+		return nil, ""
+	}
+	if certFile == "" || keyFile == "" {
+		logging.Fatal(logger, "tls.cert_file and tls.key_file must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		logging.Fatal(logger, "Error loading the server certificate", logging.Err(err))
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			logging.Fatal(logger, "Error loading client CA", logging.Err(err))
+		}
+		config.ClientCAs = pool
+		// Requests without a certificate may still authenticate with a
+		// token or password, unless certificates are required.
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		switch mode := cfg.ClientAuth; mode {
+		case "", "request":
+		case "require":
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			logging.Fatal(logger, "Unknown tls.client_auth: use request or require", "mode", mode)
+		}
+	}
+	return config, clientCAFile
+}
+
+// serverHosts returns the names the built-in server certificate is valid
+// for: localhost, this host and extra.
+func serverHosts(extra []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	return append(hosts, extra...)
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+
+    "github.com/urfave/cli/v2"
+)
+
+type WebhookRequest struct {
+    Name           string   `json:"name"`
+    Type           string   `json:"type"`
+    URL            string   `json:"url"`
+    FailurePolicy  string   `json:"failure_policy"`
+    TimeoutSeconds int      `json:"timeout_seconds"`
+    CAFile         string   `json:"ca_file,omitempty"`
+    Namespaces     []string `json:"namespaces,omitempty"`
+}
+
+type QuotaRequest struct {
+    Namespace string `json:"namespace"`
+    Pods      int    `json:"pods"`
+    CPUs      int    `json:"cpus"`
+}
+
+var namespaceColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "PODS", value: field(".pods")},
+}
+
+// limitColumn renders a quota limit, where 0 means none.
+func limitColumn(path string) func(map[string]interface{}) string {
+    return func(obj map[string]interface{}) string {
+        if limit := field(path)(obj); limit != "0" {
+            return limit
+        }
+        return "<none>"
+    }
+}
+
+var quotaColumns = []column{
+    {header: "NAMESPACE", value: field(".namespace")},
+    {header: "PODS", value: func(obj map[string]interface{}) string {
+        return field(".used_pods")(obj) + "/" + limitColumn(".pods")(obj)
+    }},
+    {header: "CPUs", value: func(obj map[string]interface{}) string {
+        return field(".used_cpus")(obj) + "/" + limitColumn(".cpus")(obj)
+    }},
+}
+
+var webhookColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "TYPE", value: field(".type")},
+    {header: "URL", value: field(".url")},
+    {header: "FAILURE POLICY", value: field(".failure_policy")},
+    {header: "TIMEOUT", wide: true, value: func(obj map[string]interface{}) string {
+        return field(".timeout_seconds")(obj) + "s"
+    }},
+    {header: "NAMESPACES", wide: true, value: func(obj map[string]interface{}) string {
+        return orNone(joinList(obj, "namespaces"))
+    }},
+}
+
+// reviewPod runs a pod through admission without creating it and prints
+// the verdict and the pod admission would create.
+func reviewPod(c *cli.Context, request PodRequest) error {
+    body, err := api.do("POST", "/admission/review", request)
+    if err != nil {
+        return err
+    }
+    if format := optionString(c, "output"); format != "" {
+        return printItem(c, "review", body, nil)
+    }
+    var review struct {
+        Allowed bool            `json:"allowed"`
+        Reason  string          `json:"reason"`
+        Pod     json.RawMessage `json:"pod"`
+    }
+    if err := json.Unmarshal(body, &review); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    if !review.Allowed {
+        return fmt.Errorf("pod would be rejected: %s", review.Reason)
+    }
+    pod, err := json.MarshalIndent(review.Pod, "", "  ")
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Pod would be admitted as:\n%s\n", pod)
+    return nil
+}
+
+// admissionCommands manage namespaces, their quotas and the admission
+// webhooks pods are reviewed by.
+func admissionCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:      "add-namespace",
+            Usage:     "Create a namespace pods can be created in",
+            ArgsUsage: "<name>",
+            Flags:     withOutputFlags(),
+            Action: func(c *cli.Context) error {
+                if c.NArg() != 1 {
+                    return fmt.Errorf("expected exactly one namespace name")
+                }
+                body, err := api.do("POST", "/namespaces", map[string]string{"name": c.Args().First()})
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "namespace", "name", "Namespace created", body)
+            },
+        },
+        {
+            Name:      "delete-namespace",
+            Usage:     "Delete a namespace that has no pods left, along with its quota",
+            ArgsUsage: "<name>",
+            Flags:     withOutputFlags(),
+            Action: func(c *cli.Context) error {
+                if c.NArg() != 1 {
+                    return fmt.Errorf("expected exactly one namespace name")
+                }
+                body, err := api.do("DELETE", "/namespaces/"+url.PathEscape(c.Args().First()), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "namespace", "name", "Namespace deleted", body)
+            },
+        },
+        {
+            Name:  "set-quota",
+            Usage: "Cap the pods and CPUs of a namespace; pods over the quota are rejected",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "namespace",
+                    Usage:    "Namespace the quota applies to",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "pods",
+                    Usage: "Most pods the namespace may have (0 for no limit)",
+                },
+                &cli.IntFlag{
+                    Name:  "cpus",
+                    Usage: "Most CPUs the namespace's pods may request (0 for no limit)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := QuotaRequest{
+                    Namespace: c.String("namespace"),
+                    Pods:      c.Int("pods"),
+                    CPUs:      c.Int("cpus"),
+                }
+                body, err := api.do("POST", "/resourcequotas", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "resourcequota", "namespace", "Quota saved", body)
+            },
+        },
+        {
+            Name:  "delete-quota",
+            Usage: "Remove the quota of a namespace",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "namespace",
+                    Usage:    "Namespace whose quota to remove",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/resourcequotas/"+url.PathEscape(c.String("namespace")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "resourcequota", "namespace", "Quota deleted", body)
+            },
+        },
+        {
+            Name:  "add-webhook",
+            Usage: "Add or replace an admission webhook that reviews pods before they are created",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the webhook",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:     "url",
+                    Usage:    "URL AdmissionReview requests are POSTed to",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "type",
+                    Usage: "Validating, which allows or denies pods, or Mutating, which may also patch them",
+                    Value: "Validating",
+                },
+                &cli.StringFlag{
+                    Name:  "failure-policy",
+                    Usage: "Fail to reject pods when the webhook cannot be reached, Ignore to admit them",
+                    Value: "Fail",
+                },
+                &cli.IntFlag{
+                    Name:  "timeout",
+                    Usage: "Seconds to wait for the webhook (default 10, at most 30)",
+                },
+                &cli.StringFlag{
+                    Name:  "ca-file",
+                    Usage: "CA bundle on the API server's host to verify an https webhook with",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "namespace",
+                    Usage: "Only review pods in this namespace (repeatable; default all)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := WebhookRequest{
+                    Name:           c.String("name"),
+                    Type:           c.String("type"),
+                    URL:            c.String("url"),
+                    FailurePolicy:  c.String("failure-policy"),
+                    TimeoutSeconds: c.Int("timeout"),
+                    CAFile:         c.String("ca-file"),
+                    Namespaces:     c.StringSlice("namespace"),
+                }
+                body, err := api.do("POST", "/admission/webhooks", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "webhook", "name", "Webhook saved", body)
+            },
+        },
+        {
+            Name:  "delete-webhook",
+            Usage: "Delete an admission webhook",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the webhook",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/admission/webhooks/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "webhook", "name", "Webhook deleted", body)
+            },
+        },
+    }
+}
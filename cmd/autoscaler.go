@@ -0,0 +1,266 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+
+    "cluster-sim/internal/labels"
+
+    "github.com/urfave/cli/v2"
+)
+
+type Taint struct {
+    Key    string `json:"key"`
+    Value  string `json:"value,omitempty"`
+    Effect string `json:"effect"`
+}
+
+type Toleration struct {
+    Key      string `json:"key"`
+    Operator string `json:"operator,omitempty"`
+    Value    string `json:"value,omitempty"`
+    Effect   string `json:"effect,omitempty"`
+}
+
+type NodeGroupRequest struct {
+    Name    string            `json:"name"`
+    MinSize int               `json:"min_size"`
+    MaxSize int               `json:"max_size"`
+    CPUs    int               `json:"cpus"`
+    Labels  map[string]string `json:"labels,omitempty"`
+    Taints  []Taint           `json:"taints,omitempty"`
+}
+
+var nodeGroupColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "MIN", value: field(".min_size")},
+    {header: "MAX", value: field(".max_size")},
+    {header: "CURRENT", value: field(".current_size")},
+    {header: "CPUs", value: field(".cpus")},
+    {header: "UNNEEDED", wide: true, value: func(obj map[string]interface{}) string {
+        unneeded, _ := obj["unneeded_nodes"].([]interface{})
+        return fmt.Sprint(len(unneeded))
+    }},
+    {header: "TAINTS", wide: true, value: taintsColumn},
+    {header: "LABELS", wide: true, value: labelsColumn},
+}
+
+// taintsColumn renders taints as key=value:Effect,...
+func taintsColumn(obj map[string]interface{}) string {
+    taints, _ := obj["taints"].([]interface{})
+    if len(taints) == 0 {
+        return "<none>"
+    }
+    parts := make([]string, 0, len(taints))
+    for _, t := range taints {
+        m, _ := t.(map[string]interface{})
+        s := formatValue(m["key"])
+        if v := formatValue(m["value"]); v != "" {
+            s += "=" + v
+        }
+        parts = append(parts, s+":"+formatValue(m["effect"]))
+    }
+    return strings.Join(parts, ",")
+}
+
+// parseTaints parses taints written as key=value:Effect or key:Effect.
+func parseTaints(specs []string) ([]Taint, error) {
+    var taints []Taint
+    for _, spec := range specs {
+        i := strings.LastIndex(spec, ":")
+        if i < 0 {
+            return nil, fmt.Errorf("invalid taint %q, expected key=value:Effect", spec)
+        }
+        t := Taint{Effect: spec[i+1:]}
+        t.Key, t.Value, _ = strings.Cut(spec[:i], "=")
+        switch t.Effect {
+        case "NoSchedule", "PreferNoSchedule", "NoExecute":
+        default:
+            return nil, fmt.Errorf("invalid taint effect %q, expected NoSchedule, PreferNoSchedule or NoExecute", t.Effect)
+        }
+        if t.Key == "" {
+            return nil, fmt.Errorf("invalid taint %q, key is required", spec)
+        }
+        taints = append(taints, t)
+    }
+    return taints, nil
+}
+
+// parseTolerations parses tolerations written as key=value[:Effect], which
+// requires an equal value, or key[:Effect], which tolerates any value.
+func parseTolerations(specs []string) ([]Toleration, error) {
+    var tolerations []Toleration
+    for _, spec := range specs {
+        t := Toleration{}
+        rest := spec
+        if i := strings.LastIndex(spec, ":"); i >= 0 {
+            rest, t.Effect = spec[:i], spec[i+1:]
+        }
+        if key, value, hasValue := strings.Cut(rest, "="); hasValue {
+            t.Key, t.Value, t.Operator = key, value, "Equal"
+        } else {
+            t.Key, t.Operator = rest, "Exists"
+        }
+        if t.Key == "" && t.Operator == "Equal" {
+            return nil, fmt.Errorf("invalid toleration %q, key is required", spec)
+        }
+        tolerations = append(tolerations, t)
+    }
+    return tolerations, nil
+}
+
+// nodeGroupCommands manage the cluster autoscaler.
+func nodeGroupCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-nodegroup",
+            Usage: "Create or replace an autoscaled node group",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the node group",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "min",
+                    Usage: "Minimum number of nodes",
+                },
+                &cli.IntFlag{
+                    Name:     "max",
+                    Usage:    "Maximum number of nodes",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "cpus",
+                    Usage:    "Number of CPUs for each node",
+                    Required: true,
+                },
+                &cli.StringSliceFlag{
+                    Name:  "label",
+                    Usage: "Label to set on new nodes as key=value (repeatable)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "taint",
+                    Usage: "Taint to set on new nodes as key=value:Effect (repeatable)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                nodeLabels, err := labels.ParseSet(c.StringSlice("label"))
+                if err != nil {
+                    return err
+                }
+                taints, err := parseTaints(c.StringSlice("taint"))
+                if err != nil {
+                    return err
+                }
+                request := NodeGroupRequest{
+                    Name:    c.String("name"),
+                    MinSize: c.Int("min"),
+                    MaxSize: c.Int("max"),
+                    CPUs:    c.Int("cpus"),
+                    Labels:  nodeLabels,
+                    Taints:  taints,
+                }
+                body, err := api.do("POST", "/nodegroups", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "nodegroup", "name", "Node group saved", body)
+            },
+        },
+        {
+            Name:  "delete-nodegroup",
+            Usage: "Stop autoscaling a node group (its nodes keep running)",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the node group",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/nodegroups/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "nodegroup", "name", "Node group deleted", body)
+            },
+        },
+        {
+            Name:  "autoscaler",
+            Usage: "Show or change the cluster autoscaler settings",
+            Flags: withOutputFlags(
+                &cli.DurationFlag{
+                    Name:  "scan-interval",
+                    Usage: "How often the autoscaler checks the cluster, e.g. 10s",
+                },
+                &cli.DurationFlag{
+                    Name:  "scale-down-unneeded-time",
+                    Usage: "How long a node must be underutilized before it is removed, e.g. 10m",
+                },
+                &cli.Float64Flag{
+                    Name:  "scale-down-utilization-threshold",
+                    Usage: "CPU utilization (0-1) below which a node counts as underutilized",
+                },
+                &cli.BoolFlag{
+                    Name:  "scale-down-enabled",
+                    Usage: "Whether the autoscaler removes nodes (use --scale-down-enabled=false to turn off)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := map[string]interface{}{}
+                if c.IsSet("scan-interval") {
+                    request["scan_interval_seconds"] = c.Duration("scan-interval").Seconds()
+                }
+                if c.IsSet("scale-down-unneeded-time") {
+                    request["scale_down_unneeded_seconds"] = c.Duration("scale-down-unneeded-time").Seconds()
+                }
+                if c.IsSet("scale-down-utilization-threshold") {
+                    request["scale_down_utilization_threshold"] = c.Float64("scale-down-utilization-threshold")
+                }
+                if c.IsSet("scale-down-enabled") {
+                    request["scale_down_enabled"] = c.Bool("scale-down-enabled")
+                }
+
+                if len(request) > 0 {
+                    if _, err := api.do("PUT", "/autoscaler", request); err != nil {
+                        return err
+                    }
+                    if optionString(c, "output") == "" {
+                        fmt.Println("Autoscaler options updated")
+                    }
+                }
+                body, err := api.do("GET", "/autoscaler", nil)
+                if err != nil {
+                    return err
+                }
+                if format := optionString(c, "output"); format != "" && format != "table" && format != "wide" {
+                    var obj interface{}
+                    if err := json.Unmarshal(body, &obj); err != nil {
+                        return fmt.Errorf("error parsing response: %v", err)
+                    }
+                    return printData(c, "autoscaler", "name", obj, nil, nil)
+                }
+                var resp struct {
+                    Options struct {
+                        ScanIntervalSeconds           float64 `json:"scan_interval_seconds"`
+                        ScaleDownUnneededSeconds      float64 `json:"scale_down_unneeded_seconds"`
+                        ScaleDownUtilizationThreshold float64 `json:"scale_down_utilization_threshold"`
+                        ScaleDownEnabled              bool    `json:"scale_down_enabled"`
+                    } `json:"options"`
+                }
+                if err := json.Unmarshal(body, &resp); err != nil {
+                    return fmt.Errorf("error parsing response: %v", err)
+                }
+                o := resp.Options
+                fmt.Printf("Scan interval:                    %gs\n", o.ScanIntervalSeconds)
+                fmt.Printf("Scale-down enabled:               %v\n", o.ScaleDownEnabled)
+                fmt.Printf("Scale-down unneeded time:         %gs\n", o.ScaleDownUnneededSeconds)
+                fmt.Printf("Scale-down utilization threshold: %g\n", o.ScaleDownUtilizationThreshold)
+                return nil
+            },
+        },
+    }
+}
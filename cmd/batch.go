@@ -0,0 +1,326 @@
+package main
+
+import (
+    "net/url"
+    "strconv"
+
+    "cluster-sim/internal/labels"
+
+    "github.com/urfave/cli/v2"
+)
+
+type JobSpec struct {
+    Completions                *int        `json:"completions,omitempty"`
+    Parallelism                *int        `json:"parallelism,omitempty"`
+    BackoffLimit               *int        `json:"backoff_limit,omitempty"`
+    ActiveDeadlineSeconds      *int        `json:"active_deadline_seconds,omitempty"`
+    SuccessfulPodsHistoryLimit *int        `json:"successful_pods_history_limit,omitempty"`
+    FailedPodsHistoryLimit     *int        `json:"failed_pods_history_limit,omitempty"`
+    Template                   PodTemplate `json:"template"`
+}
+
+type JobRequest struct {
+    Name string `json:"name"`
+    JobSpec
+}
+
+type CronJobRequest struct {
+    Name                       string  `json:"name"`
+    Schedule                   string  `json:"schedule"`
+    ConcurrencyPolicy          string  `json:"concurrency_policy,omitempty"`
+    Suspend                    bool    `json:"suspend,omitempty"`
+    StartingDeadlineSeconds    *int    `json:"starting_deadline_seconds,omitempty"`
+    SuccessfulJobsHistoryLimit *int    `json:"successful_jobs_history_limit,omitempty"`
+    FailedJobsHistoryLimit     *int    `json:"failed_jobs_history_limit,omitempty"`
+    JobTemplate                JobSpec `json:"job_template"`
+}
+
+var jobColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "COMPLETIONS", value: func(obj map[string]interface{}) string {
+        return field(".status.succeeded")(obj) + "/" + field(".completions")(obj)
+    }},
+    {header: "ACTIVE", value: field(".status.active")},
+    {header: "FAILED", value: field(".status.failed")},
+    {header: "STATUS", value: func(obj map[string]interface{}) string {
+        condition := field(".status.condition")(obj)
+        if condition == "<none>" {
+            return "Running"
+        }
+        if reason := field(".status.reason")(obj); reason != "<none>" {
+            return condition + " (" + reason + ")"
+        }
+        return condition
+    }},
+    {header: "AGE", value: age(".status.start_time")},
+    {header: "PARALLELISM", wide: true, value: field(".parallelism")},
+    {header: "CPUs/POD", wide: true, value: field(".template.cpus")},
+    {header: "OWNER", wide: true, value: field(".owner")},
+}
+
+var cronJobColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "SCHEDULE", value: field(".schedule")},
+    {header: "SUSPEND", value: func(obj map[string]interface{}) string {
+        if obj["suspend"] == true {
+            return "True"
+        }
+        return "False"
+    }},
+    {header: "ACTIVE", value: func(obj map[string]interface{}) string {
+        status, _ := obj["status"].(map[string]interface{})
+        active, _ := status["active"].([]interface{})
+        return strconv.Itoa(len(active))
+    }},
+    {header: "LAST SCHEDULE", value: func(obj map[string]interface{}) string {
+        if field(".status.last_schedule_time")(obj) == "<none>" {
+            return "<none>"
+        }
+        return age(".status.last_schedule_time")(obj)
+    }},
+    {header: "CONCURRENCY", wide: true, value: field(".concurrency_policy")},
+    {header: "NEXT SCHEDULE", wide: true, value: field(".status.next_schedule_time")},
+}
+
+// optionalInt returns a pointer to the flag's value, or nil when it is not
+// set so the server default applies.
+func optionalInt(c *cli.Context, name string) *int {
+    if !c.IsSet(name) {
+        return nil
+    }
+    v := c.Int(name)
+    return &v
+}
+
+// jobFlags describe a job's pods and how many of them run.
+func jobFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.IntFlag{
+            Name:     "cpus",
+            Usage:    "Number of CPUs required by each pod",
+            Required: true,
+        },
+        &cli.DurationFlag{
+            Name:  "run-time",
+            Usage: "Simulated run time of each pod, e.g. 30s",
+        },
+        &cli.StringSliceFlag{
+            Name:  "command",
+            Usage: "Command each pod runs in its node container instead of a simulated run (repeat for each argument)",
+        },
+        &cli.Float64Flag{
+            Name:  "failure-rate",
+            Usage: "Chance between 0 and 1 that a simulated run fails",
+        },
+        &cli.IntFlag{
+            Name:  "completions",
+            Usage: "Number of pods that must succeed (default 1)",
+        },
+        &cli.IntFlag{
+            Name:  "parallelism",
+            Usage: "Maximum number of pods running at once (default 1)",
+        },
+        &cli.IntFlag{
+            Name:  "backoff-limit",
+            Usage: "Number of failed pods before the job fails (default 6)",
+        },
+        &cli.DurationFlag{
+            Name:  "active-deadline",
+            Usage: "Time after which a running job fails, e.g. 10m",
+        },
+        &cli.IntFlag{
+            Name:  "successful-pods-history",
+            Usage: "Number of succeeded pods to keep (default 3)",
+        },
+        &cli.IntFlag{
+            Name:  "failed-pods-history",
+            Usage: "Number of failed pods to keep (default 1)",
+        },
+        &cli.StringFlag{
+            Name:  "algorithm",
+            Usage: "Scheduling algorithm (first_fit, best_fit, worst_fit) or scheduler profile",
+        },
+        &cli.StringSliceFlag{
+            Name:  "label",
+            Usage: "Label to set on the pods as key=value (repeatable)",
+        },
+        &cli.StringSliceFlag{
+            Name:  "toleration",
+            Usage: "Taint the pods tolerate as key=value[:Effect] or key[:Effect] (repeatable)",
+        },
+    }
+}
+
+func jobSpec(c *cli.Context) (JobSpec, error) {
+    podLabels, err := labels.ParseSet(c.StringSlice("label"))
+    if err != nil {
+        return JobSpec{}, err
+    }
+    tolerations, err := parseTolerations(c.StringSlice("toleration"))
+    if err != nil {
+        return JobSpec{}, err
+    }
+    spec := JobSpec{
+        Completions:                optionalInt(c, "completions"),
+        Parallelism:                optionalInt(c, "parallelism"),
+        BackoffLimit:               optionalInt(c, "backoff-limit"),
+        SuccessfulPodsHistoryLimit: optionalInt(c, "successful-pods-history"),
+        FailedPodsHistoryLimit:     optionalInt(c, "failed-pods-history"),
+        Template: PodTemplate{
+            CPUs:        c.Int("cpus"),
+            Algorithm:   c.String("algorithm"),
+            Labels:      podLabels,
+            Tolerations: tolerations,
+            RunSeconds:  int(c.Duration("run-time").Seconds()),
+            Command:     c.StringSlice("command"),
+            FailureRate: c.Float64("failure-rate"),
+        },
+    }
+    if c.IsSet("active-deadline") {
+        seconds := int(c.Duration("active-deadline").Seconds())
+        spec.ActiveDeadlineSeconds = &seconds
+    }
+    return spec, nil
+}
+
+// batchCommands manage jobs and CronJobs.
+func batchCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-job",
+            Usage: "Start a job that runs pods to completion",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the job",
+                    Required: true,
+                },
+            }, jobFlags()...)...),
+            Action: func(c *cli.Context) error {
+                spec, err := jobSpec(c)
+                if err != nil {
+                    return err
+                }
+                body, err := api.do("POST", "/jobs", JobRequest{Name: c.String("name"), JobSpec: spec})
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "job", "name", "Job created", body)
+            },
+        },
+        {
+            Name:  "delete-job",
+            Usage: "Delete a job and its pods",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the job",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/jobs/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "job", "name", "Job deleted", body)
+            },
+        },
+        {
+            Name:  "add-cronjob",
+            Usage: "Create or replace a CronJob that starts jobs on a schedule",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the CronJob",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:     "schedule",
+                    Usage:    "Cron expression, e.g. \"0 2 * * *\" or @hourly",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "concurrency-policy",
+                    Usage: "What to do when a run is due while the last is still active: Allow, Forbid or Replace",
+                },
+                &cli.BoolFlag{
+                    Name:  "suspend",
+                    Usage: "Create the CronJob without scheduling runs",
+                },
+                &cli.DurationFlag{
+                    Name:  "starting-deadline",
+                    Usage: "How late a run may start before it is counted as missed, e.g. 2m",
+                },
+                &cli.IntFlag{
+                    Name:  "successful-history",
+                    Usage: "Number of completed jobs to keep (default 3)",
+                },
+                &cli.IntFlag{
+                    Name:  "failed-history",
+                    Usage: "Number of failed jobs to keep (default 1)",
+                },
+            }, jobFlags()...)...),
+            Action: func(c *cli.Context) error {
+                spec, err := jobSpec(c)
+                if err != nil {
+                    return err
+                }
+                request := CronJobRequest{
+                    Name:                       c.String("name"),
+                    Schedule:                   c.String("schedule"),
+                    ConcurrencyPolicy:          c.String("concurrency-policy"),
+                    Suspend:                    c.Bool("suspend"),
+                    SuccessfulJobsHistoryLimit: optionalInt(c, "successful-history"),
+                    FailedJobsHistoryLimit:     optionalInt(c, "failed-history"),
+                    JobTemplate:                spec,
+                }
+                if c.IsSet("starting-deadline") {
+                    seconds := int(c.Duration("starting-deadline").Seconds())
+                    request.StartingDeadlineSeconds = &seconds
+                }
+                body, err := api.do("POST", "/cronjobs", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "cronjob", "name", "CronJob saved", body)
+            },
+        },
+        {
+            Name:  "delete-cronjob",
+            Usage: "Delete a CronJob and the jobs it started",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the CronJob",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/cronjobs/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "cronjob", "name", "CronJob deleted", body)
+            },
+        },
+        {
+            Name:  "trigger-cronjob",
+            Usage: "Start a run of a CronJob now, outside its schedule",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the CronJob",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("POST", "/cronjobs/"+url.PathEscape(c.String("name"))+"/trigger", nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "job", "name", "Job created", body)
+            },
+        },
+    }
+}
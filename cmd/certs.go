@@ -0,0 +1,91 @@
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+
+    "cluster-sim/internal/pki"
+
+    "github.com/urfave/cli/v2"
+)
+
+// certCommands work on a built-in CA directory directly, without the API
+// server, so certificates can be made offline.
+func certCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "certs",
+            Usage: "Manage certificates of the built-in CA",
+            Subcommands: []*cli.Command{
+                {
+                    Name:  "init",
+                    Usage: "Create a CA, a server certificate and an admin client certificate in a directory, keeping those that exist and are not about to expire",
+                    Flags: []cli.Flag{
+                        &cli.StringFlag{
+                            Name:     "pki-dir",
+                            Usage:    "Directory of the CA, as in CLUSTER_SIM_PKI_DIR",
+                            Required: true,
+                        },
+                        &cli.StringSliceFlag{
+                            Name:  "host",
+                            Usage: "Name or IP address the server certificate is valid for (repeatable)",
+                            Value: cli.NewStringSlice("localhost", "127.0.0.1", "::1"),
+                        },
+                    },
+                    Action: func(c *cli.Context) error {
+                        dir := c.String("pki-dir")
+                        if err := pki.Bootstrap(dir, c.StringSlice("host")); err != nil {
+                            return err
+                        }
+                        fmt.Printf("CA ready in %s\n", dir)
+                        return nil
+                    },
+                },
+                {
+                    Name:  "create-client",
+                    Usage: "Issue a client certificate the API server authenticates as a user",
+                    Flags: []cli.Flag{
+                        &cli.StringFlag{
+                            Name:     "pki-dir",
+                            Usage:    "Directory of the CA, as in CLUSTER_SIM_PKI_DIR",
+                            Required: true,
+                        },
+                        &cli.StringFlag{
+                            Name:     "name",
+                            Usage:    "User name, the certificate's common name",
+                            Required: true,
+                        },
+                        &cli.StringSliceFlag{
+                            Name:  "group",
+                            Usage: "Group of the user, an organization of the certificate (repeatable)",
+                        },
+                        &cli.StringFlag{
+                            Name:  "out-dir",
+                            Usage: "Directory to write <name>.crt and <name>.key to (default: the CA directory)",
+                        },
+                    },
+                    Action: func(c *cli.Context) error {
+                        ca, err := pki.LoadCA(c.String("pki-dir"))
+                        if err != nil {
+                            return fmt.Errorf("error loading CA: %v", err)
+                        }
+                        name := c.String("name")
+                        if strings.ContainsAny(name, `/\`) {
+                            return fmt.Errorf("name %q must not contain a path separator", name)
+                        }
+                        out := c.String("out-dir")
+                        if out == "" {
+                            out = c.String("pki-dir")
+                        }
+                        if err := ca.IssueClient(out, name+".crt", name+".key", name, c.StringSlice("group")); err != nil {
+                            return err
+                        }
+                        fmt.Printf("Wrote %s and %s\n", filepath.Join(out, name+".crt"), filepath.Join(out, name+".key"))
+                        return nil
+                    },
+                },
+            },
+        },
+    }
+}
@@ -1,27 +1,41 @@
 package main
 
 import (
-    "bytes"
+    "bufio"
     "encoding/json"
     "fmt"
-    "io/ioutil"
-    "net/http"
+    "net/url"
     "os"
-    "strings"
+    "path/filepath"
+    "strconv"
+    "text/tabwriter"
+    "time"
+
+    "cluster-sim/internal/labels"
 
     "github.com/urfave/cli/v2"
 )
 
+// localCommands do not talk to the API server, so they run without
+// resolving a context.
+var localCommands = map[string]bool{"config": true, "certs": true}
+
 type Node struct {
     ID       string   `json:"id"`
     CPUs     int      `json:"cpus"`
     UsedCPUs int      `json:"used_cpus"`
     Status   string   `json:"status"`
     Pods     []string `json:"pods"`
+    MemoryMiB int     `json:"memory_mib"`
+    Template string   `json:"template"`
 }
 
 type NodeRequest struct {
-    CPUs int `json:"cpus"`
+    CPUs   int               `json:"cpus,omitempty"`
+    Labels map[string]string `json:"labels,omitempty"`
+    Taints []Taint           `json:"taints,omitempty"`
+    Template string          `json:"template,omitempty"`
+    Count  int               `json:"count,omitempty"`
 }
 
 type DeleteNodeRequest struct {
@@ -33,190 +47,536 @@ type RestartNodeRequest struct {
 }
 
 type PodRequest struct {
+    Namespace string `json:"namespace,omitempty"`
     CPUs int `json:"cpus"`
     Algorithm string `json:"algorithm"`
+    Labels map[string]string `json:"labels,omitempty"`
+    GracePeriodSeconds int `json:"termination_grace_period_seconds"`
+    Tolerations []Toleration `json:"tolerations,omitempty"`
+    Claims []string `json:"claims,omitempty"`
+}
+
+type DrainRequest struct {
+    TimeoutSeconds     int  `json:"timeout_seconds"`
+    Force              bool `json:"force"`
+    DryRun             bool `json:"dry_run"`
+    GracePeriodSeconds int  `json:"grace_period_seconds"`
+}
+
+type EvictionRequest struct {
+    GracePeriodSeconds int `json:"grace_period_seconds"`
 }
 
+type PDBRequest struct {
+    Name           string      `json:"name"`
+    Selector       string      `json:"selector"`
+    MinAvailable   interface{} `json:"min_available,omitempty"`
+    MaxUnavailable interface{} `json:"max_unavailable,omitempty"`
+}
+
+// parseIntOrPercent sends "2" as a number and "50%" as a string.
+func parseIntOrPercent(s string) interface{} {
+    if n, err := strconv.Atoi(s); err == nil {
+        return n
+    }
+    return s
+}
+
+type DrainResult struct {
+    NodeID      string            `json:"node_id"`
+    DryRun      bool              `json:"dry_run"`
+    Evicted     []string          `json:"evicted"`
+    Rescheduled map[string]string `json:"rescheduled"`
+    Pending     []string          `json:"pending"`
+    Blocked     []string          `json:"blocked"`
+}
+
+type Event struct {
+    InvolvedObject struct {
+        Kind string `json:"kind"`
+        Name string `json:"name"`
+    } `json:"involved_object"`
+    Type          string    `json:"type"`
+    Reason        string    `json:"reason"`
+    Message       string    `json:"message"`
+    Count         int       `json:"count"`
+    LastTimestamp time.Time `json:"last_timestamp"`
+}
+
+type Pod struct {
+    ID     string `json:"id"`
+    CPUs   int    `json:"cpus"`
+    NodeID string `json:"node_id"`
+    Status string `json:"status"`
+}
+
+type Condition struct {
+    Type    string `json:"type"`
+    Status  string `json:"status"`
+    Reason  string `json:"reason"`
+    Message string `json:"message"`
+}
+
+type NodeDescription struct {
+    Node          Node        `json:"node"`
+    Conditions    []Condition `json:"conditions"`
+    CapacityCPUs  int         `json:"capacity_cpus"`
+    CapacityMemoryMiB int     `json:"capacity_memory_mib"`
+    AllocatedCPUs int         `json:"allocated_cpus"`
+    Pods          []Pod       `json:"pods"`
+    Events        []Event     `json:"events"`
+}
+
+type PodDescription struct {
+    Pod        Pod         `json:"pod"`
+    Conditions []Condition `json:"conditions"`
+    Events     []Event     `json:"events"`
+    Scheduling []struct {
+        NodeID string `json:"node_id"`
+        Fits   bool   `json:"fits"`
+        Reason string `json:"reason"`
+    } `json:"scheduling"`
+}
+
+func printConditions(conditions []Condition) {
+    fmt.Println("Conditions:")
+    fmt.Printf("  %-14s %-8s %-20s %s\n", "TYPE", "STATUS", "REASON", "MESSAGE")
+    for _, cond := range conditions {
+        fmt.Printf("  %-14s %-8s %-20s %s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+    }
+}
+
+func printDescribedEvents(events []Event) {
+    fmt.Println("Events:")
+    if len(events) == 0 {
+        fmt.Println("  <none>")
+        return
+    }
+    for _, ev := range events {
+        fmt.Printf("  %-8s %-18s %-10s x%-4d %s\n", ev.Type, ev.Reason,
+            time.Since(ev.LastTimestamp).Round(time.Second), ev.Count, ev.Message)
+    }
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+func percent(part, total int) int {
+    if total == 0 {
+        return 0
+    }
+    return part * 100 / total
+}
+
+// eventWatchWidths are the widths of the event columns when events are
+// streamed. Longer values push the rest of their row to the right.
+var eventWatchWidths = map[string]int{
+    "LAST SEEN": 9,
+    "TYPE":      7,
+    "REASON":    22,
+    "OBJECT":    36,
+    "COUNT":     5,
+    "MESSAGE":   60,
+}
+
+// printWatchEvent prints one streamed event. Tables only get a header for the first one.
+func printWatchEvent(c *cli.Context, line []byte, first bool) error {
+    format := optionString(c, "output")
+    if format == "" || format == "table" || format == "wide" {
+        var obj map[string]interface{}
+        if err := json.Unmarshal(line, &obj); err != nil {
+            return fmt.Errorf("error parsing event: %v", err)
+        }
+        header := first && !optionBool(c, "no-headers")
+        printStreamRow(obj, eventColumns, eventWatchWidths, format == "wide", header)
+        return nil
+    }
+    return printItem(c, "event", line, eventColumns)
+}
+
+// api is the client for the selected context, set up before any command runs.
+var api *apiClient
+
 func main() {
     app := &cli.App{
         Name:  "cluster-cli",
         Usage: "CLI tool for managing the cluster",
+        Flags: append([]cli.Flag{
+            &cli.StringFlag{
+                Name:    "server",
+                Usage:   "API server URL, overriding the one in the context",
+                EnvVars: []string{"CLUSTER_SIM_SERVER"},
+            },
+            &cli.StringFlag{
+                Name:  "context",
+                Usage: "Name of the config context to use",
+            },
+            &cli.StringFlag{
+                Name:  "config",
+                Usage: "Path to the client config file",
+                Value: defaultConfigPath(),
+            },
+            &cli.StringFlag{
+                Name:    "certificate-authority",
+                Usage:   "CA bundle to verify the API server with, overriding the one in the context",
+                EnvVars: []string{"CLUSTER_SIM_CA_FILE"},
+            },
+            &cli.StringFlag{
+                Name:    "client-certificate",
+                Usage:   "Client certificate to present, overriding the one in the context",
+                EnvVars: []string{"CLUSTER_SIM_CLIENT_CERT_FILE"},
+            },
+            &cli.StringFlag{
+                Name:    "client-key",
+                Usage:   "Key of the client certificate, overriding the one in the context",
+                EnvVars: []string{"CLUSTER_SIM_CLIENT_KEY_FILE"},
+            },
+            &cli.BoolFlag{
+                Name:  "insecure-skip-tls-verify",
+                Usage: "Do not verify the API server's certificate",
+            },
+        }, outputFlags()...),
+        Before: func(c *cli.Context) error {
+            // config and certs work on local files only. They must not
+            // depend on a valid context, since config is how one is fixed.
+            if localCommands[c.Args().First()] {
+                return nil
+            }
+            cfg, err := loadConfig(c.String("config"))
+            if err != nil {
+                return err
+            }
+            ctx, err := cfg.resolveContext(c.String("context"), c.String("server"))
+            if err != nil {
+                return err
+            }
+            for flag, field := range map[string]*string{
+                "certificate-authority": &ctx.CertificateAuthority,
+                "client-certificate":    &ctx.ClientCertificate,
+                "client-key":            &ctx.ClientKey,
+            } {
+                if c.IsSet(flag) {
+                    *field = c.String(flag)
+                }
+            }
+            if c.IsSet("insecure-skip-tls-verify") {
+                ctx.InsecureSkipTLSVerify = c.Bool("insecure-skip-tls-verify")
+            }
+            api, err = newAPIClient(ctx)
+            return err
+        },
         Commands: []*cli.Command{
             {
                 Name:  "nodes",
                 Usage: "List all nodes in the cluster",
-                Action: func(c *cli.Context) error {
-                    resp, err := http.Get("http://localhost:8080/nodes")
-                    if err != nil {
-                        return fmt.Errorf("error sending request: %v", err)
-                    }
-                    defer resp.Body.Close()
-
-                    body, err := ioutil.ReadAll(resp.Body)
-                    if err != nil {
-                        return fmt.Errorf("error reading response: %v", err)
-                    }
-
-                    if resp.StatusCode != http.StatusOK {
-                        return fmt.Errorf("server returned error: %s", string(body))
-                    }
-
-                    var nodes []Node
-                    if err := json.Unmarshal(body, &nodes); err != nil {
-                        return fmt.Errorf("error parsing response: %v", err)
-                    }
-
-                    fmt.Printf("\n%-40s %-8s %-8s %-10s %-20s\n", "NODE ID", "CPUs", "USED", "STATUS", "PODS")
-                    fmt.Println(strings.Repeat("-", 90))
-
-                    for _, node := range nodes {
-                        pods := strings.Join(node.Pods, ", ")
-                        if pods == "" {
-                            pods = "none"
-                        }
-                        fmt.Printf("%-40s %-8d %-8d %-10s %-20s\n",
-                            node.ID, node.CPUs, node.UsedCPUs, node.Status, pods)
-                    }
-                    fmt.Println()
-
-                    return nil
-                },
+                Flags: withOutputFlags(),
+                Action: listNodes,
             },
+            getCommand(),
             {
                 Name:  "add-node",
                 Usage: "Add a new node to the cluster",
-                Flags: []cli.Flag{
+                Flags: withOutputFlags(
                     &cli.IntFlag{
-                        Name:     "cpus",
-                        Usage:    "Number of CPUs for the node",
-                        Required: true,
+                        Name:  "cpus",
+                        Usage: "Number of CPUs for the node (required without --template, overrides the template's)",
                     },
-                },
+                    &cli.StringFlag{
+                        Name:  "template",
+                        Usage: "Node template to create the node from",
+                    },
+                    &cli.IntFlag{
+                        Name:  "count",
+                        Usage: "Number of nodes to create alike, in parallel",
+                        Value: 1,
+                    },
+                    &cli.StringSliceFlag{
+                        Name:  "label",
+                        Usage: "Label to set on the node as key=value (repeatable)",
+                    },
+                    &cli.StringSliceFlag{
+                        Name:  "taint",
+                        Usage: "Taint to set on the node as key=value:Effect (repeatable)",
+                    },
+                ),
                 Action: func(c *cli.Context) error {
-                    request := NodeRequest{
-                        CPUs: c.Int("cpus"),
+                    if c.Int("cpus") <= 0 && c.String("template") == "" {
+                        return fmt.Errorf("--cpus or --template is required")
                     }
-
-                    jsonData, err := json.Marshal(request)
+                    if c.Int("count") < 1 {
+                        return fmt.Errorf("--count must be at least 1")
+                    }
+                    nodeLabels, err := labels.ParseSet(c.StringSlice("label"))
                     if err != nil {
-                        return fmt.Errorf("error marshaling request: %v", err)
+                        return err
                     }
-
-                    resp, err := http.Post("http://localhost:8080/add_node", "application/json", bytes.NewBuffer(jsonData))
+                    taints, err := parseTaints(c.StringSlice("taint"))
                     if err != nil {
-                        return fmt.Errorf("error sending request: %v", err)
+                        return err
+                    }
+                    request := NodeRequest{
+                        CPUs: c.Int("cpus"),
+                        Labels: nodeLabels,
+                        Taints: taints,
+                        Template: c.String("template"),
+                        Count: c.Int("count"),
                     }
-                    defer resp.Body.Close()
 
-                    body, err := ioutil.ReadAll(resp.Body)
+                    body, err := api.do("POST", "/add_node", request)
                     if err != nil {
-                        return fmt.Errorf("error reading response: %v", err)
+                        return err
                     }
 
-                    if resp.StatusCode != http.StatusOK {
-                        return fmt.Errorf("server returned error: %s", string(body))
+                    if request.Count > 1 {
+                        return printAddNodesResult(c, body)
                     }
-
-                    fmt.Printf("Node added successfully: %s\n", string(body))
-                    return nil
+                    return printResult(c, "node", "node_id", "Node added successfully", body)
                 },
             },
             {
                 Name:  "delete-node",
                 Usage: "Delete a node from the cluster",
-                Flags: []cli.Flag{
+                Flags: withOutputFlags(
                     &cli.StringFlag{
                         Name:     "node-id",
                         Usage:    "ID of the node to delete",
                         Required: true,
                     },
-                },
+                ),
                 Action: func(c *cli.Context) error {
                     request := DeleteNodeRequest{
                         NodeID: c.String("node-id"),
                     }
 
-                    jsonData, err := json.Marshal(request)
-                    if err != nil {
-                        return fmt.Errorf("error marshaling request: %v", err)
-                    }
-
-                    req, err := http.NewRequest("DELETE", "http://localhost:8080/delete_node", bytes.NewBuffer(jsonData))
-                    if err != nil {
-                        return fmt.Errorf("error creating request: %v", err)
-                    }
-                    req.Header.Set("Content-Type", "application/json")
-
-                    client := &http.Client{}
-                    resp, err := client.Do(req)
-                    if err != nil {
-                        return fmt.Errorf("error sending request: %v", err)
-                    }
-                    defer resp.Body.Close()
-
-                    body, err := ioutil.ReadAll(resp.Body)
+                    body, err := api.do("DELETE", "/delete_node", request)
                     if err != nil {
-                        return fmt.Errorf("error reading response: %v", err)
-                    }
-
-                    if resp.StatusCode != http.StatusOK {
-                        return fmt.Errorf("server returned error: %s", string(body))
+                        return err
                     }
 
-                    fmt.Printf("Node deleted successfully: %s\n", string(body))
-                    return nil
+                    return printResult(c, "node", "node_id", "Node deleted successfully", body)
                 },
             },
             {
                 Name:  "restart-node",
                 Usage: "Restart a node in the cluster",
-                Flags: []cli.Flag{
+                Flags: withOutputFlags(
                     &cli.StringFlag{
                         Name:     "node-id",
                         Usage:    "ID of the node to restart",
                         Required: true,
                     },
-                },
+                ),
                 Action: func(c *cli.Context) error {
                     request := RestartNodeRequest{
                         NodeID: c.String("node-id"),
                     }
 
-                    jsonData, err := json.Marshal(request)
+                    body, err := api.do("PUT", "/restart_node", request)
                     if err != nil {
-                        return fmt.Errorf("error marshaling request: %v", err)
+                        return err
                     }
 
-                    req, err := http.NewRequest("PUT", "http://localhost:8080/restart_node", bytes.NewBuffer(jsonData))
+                    return printResult(c, "node", "node_id", "Node restarted successfully", body)
+                },
+            },
+            {
+                Name:  "cordon",
+                Usage: "Mark a node unschedulable",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "node-id",
+                        Usage:    "ID of the node to cordon",
+                        Required: true,
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("PUT", "/nodes/"+url.PathEscape(c.String("node-id"))+"/cordon", nil)
                     if err != nil {
-                        return fmt.Errorf("error creating request: %v", err)
+                        return err
                     }
-                    req.Header.Set("Content-Type", "application/json")
-
-                    client := &http.Client{}
-                    resp, err := client.Do(req)
+                    return printResult(c, "node", "node_id", "Node cordoned", body)
+                },
+            },
+            {
+                Name:  "uncordon",
+                Usage: "Mark a node schedulable again",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "node-id",
+                        Usage:    "ID of the node to uncordon",
+                        Required: true,
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("PUT", "/nodes/"+url.PathEscape(c.String("node-id"))+"/uncordon", nil)
                     if err != nil {
-                        return fmt.Errorf("error sending request: %v", err)
+                        return err
                     }
-                    defer resp.Body.Close()
-
-                    body, err := ioutil.ReadAll(resp.Body)
+                    return printResult(c, "node", "node_id", "Node uncordoned", body)
+                },
+            },
+            {
+                Name:  "drain",
+                Usage: "Cordon a node and evict its pods one by one",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "node-id",
+                        Usage:    "ID of the node to drain",
+                        Required: true,
+                    },
+                    &cli.DurationFlag{
+                        Name:  "timeout",
+                        Usage: "Give up after this long (0 waits forever)",
+                    },
+                    &cli.BoolFlag{
+                        Name:  "force",
+                        Usage: "Evict pods even if no other node can take them",
+                    },
+                    &cli.BoolFlag{
+                        Name:  "dry-run",
+                        Usage: "Only show which pods would be evicted",
+                    },
+                    &cli.IntFlag{
+                        Name:  "grace-period",
+                        Usage: "Seconds each pod gets to terminate (-1 uses the pod's own value)",
+                        Value: -1,
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    request := DrainRequest{
+                        TimeoutSeconds:     int(c.Duration("timeout").Seconds()),
+                        Force:              c.Bool("force"),
+                        DryRun:             c.Bool("dry-run"),
+                        GracePeriodSeconds: c.Int("grace-period"),
+                    }
+                    body, err := api.do("POST", "/nodes/"+url.PathEscape(c.String("node-id"))+"/drain", request)
                     if err != nil {
-                        return fmt.Errorf("error reading response: %v", err)
+                        return err
                     }
 
-                    if resp.StatusCode != http.StatusOK {
-                        return fmt.Errorf("server returned error: %s", string(body))
+                    format := optionString(c, "output")
+                    if format != "" && format != "table" && format != "wide" {
+                        return printResult(c, "node", "node_id", "", body)
+                    }
+                    var resp struct {
+                        Result DrainResult `json:"result"`
+                    }
+                    if err := json.Unmarshal(body, &resp); err != nil {
+                        return fmt.Errorf("error parsing response: %v", err)
+                    }
+                    for _, podID := range resp.Result.Evicted {
+                        switch target := resp.Result.Rescheduled[podID]; {
+                        case resp.Result.DryRun && contains(resp.Result.Blocked, podID):
+                            fmt.Printf("pod/%s would be evicted once its disruption budget allows\n", podID)
+                        case resp.Result.DryRun:
+                            fmt.Printf("pod/%s would be evicted\n", podID)
+                        case target == "":
+                            fmt.Printf("pod/%s evicted, left Pending\n", podID)
+                        default:
+                            fmt.Printf("pod/%s evicted, rescheduled to %s\n", podID, target)
+                        }
+                    }
+                    if resp.Result.DryRun {
+                        fmt.Printf("node/%s drained (dry run)\n", resp.Result.NodeID)
+                    } else {
+                        fmt.Printf("node/%s drained\n", resp.Result.NodeID)
                     }
-
-                    fmt.Printf("Node restarted successfully: %s\n", string(body))
                     return nil
                 },
             },
+            {
+                Name:  "evict",
+                Usage: "Evict a pod, honoring PodDisruptionBudgets",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "pod-id",
+                        Usage:    "ID of the pod to evict",
+                        Required: true,
+                    },
+                    &cli.IntFlag{
+                        Name:  "grace-period",
+                        Usage: "Seconds the pod gets to terminate (-1 uses the pod's own value)",
+                        Value: -1,
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    request := EvictionRequest{GracePeriodSeconds: c.Int("grace-period")}
+                    body, err := api.do("POST", "/pods/"+url.PathEscape(c.String("pod-id"))+"/eviction", request)
+                    if err != nil {
+                        return err
+                    }
+                    return printResult(c, "pod", "pod_id", "Pod evicted", body)
+                },
+            },
+            {
+                Name:  "add-pdb",
+                Usage: "Create or replace a PodDisruptionBudget",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "name",
+                        Usage:    "Name of the budget",
+                        Required: true,
+                    },
+                    &cli.StringFlag{
+                        Name:     "pod-selector",
+                        Usage:    "Label selector of the pods the budget covers, e.g. app=web",
+                        Required: true,
+                    },
+                    &cli.StringFlag{
+                        Name:  "min-available",
+                        Usage: "Pods that must stay available, as a count or percentage",
+                    },
+                    &cli.StringFlag{
+                        Name:  "max-unavailable",
+                        Usage: "Pods that may be unavailable, as a count or percentage",
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    request := PDBRequest{
+                        Name:     c.String("name"),
+                        Selector: c.String("pod-selector"),
+                    }
+                    if c.IsSet("min-available") {
+                        request.MinAvailable = parseIntOrPercent(c.String("min-available"))
+                    }
+                    if c.IsSet("max-unavailable") {
+                        request.MaxUnavailable = parseIntOrPercent(c.String("max-unavailable"))
+                    }
+                    body, err := api.do("POST", "/pdbs", request)
+                    if err != nil {
+                        return err
+                    }
+                    return printResult(c, "pdb", "name", "PodDisruptionBudget saved", body)
+                },
+            },
+            {
+                Name:  "delete-pdb",
+                Usage: "Delete a PodDisruptionBudget",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:     "name",
+                        Usage:    "Name of the budget",
+                        Required: true,
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("DELETE", "/pdbs/"+url.PathEscape(c.String("name")), nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printResult(c, "pdb", "name", "PodDisruptionBudget deleted", body)
+                },
+            },
             {
                 Name:  "add-pod",
                 Usage: "Add a new pod to the cluster",
-                Flags: []cli.Flag{
+                Flags: withOutputFlags(
                     &cli.IntFlag{
                         Name:     "cpus",
                         Usage:    "Number of CPUs required for the pod",
@@ -224,53 +584,361 @@ func main() {
                     },
                     &cli.StringFlag{
                         Name:     "algorithm",
-                        Usage:    "Scheduling algorithm (first_fit, best_fit, worst_fit)",
+                        Usage:    "Scheduling algorithm (first_fit, best_fit, worst_fit) or scheduler profile",
                     },
-                },
+                    &cli.StringSliceFlag{
+                        Name:  "label",
+                        Usage: "Label to set on the pod as key=value (repeatable)",
+                    },
+                    &cli.IntFlag{
+                        Name:  "grace-period",
+                        Usage: "Seconds the pod gets to shut down when evicted",
+                    },
+                    &cli.StringSliceFlag{
+                        Name:  "toleration",
+                        Usage: "Taint the pod tolerates as key=value[:Effect] or key[:Effect] (repeatable)",
+                    },
+                    &cli.StringSliceFlag{
+                        Name:  "claim",
+                        Usage: "Persistent volume claim the pod mounts (repeatable)",
+                    },
+                    &cli.StringFlag{
+                        Name:  "namespace",
+                        Usage: "Namespace of the pod (default: the context's namespace, or \"default\")",
+                    },
+                    &cli.BoolFlag{
+                        Name:  "dry-run",
+                        Usage: "Only run the pod through admission and show the pod it would create",
+                    },
+                ),
                 Action: func(c *cli.Context) error {
-                    algorithm := c.String("algorithm")
-                    validAlgorithms := map[string]bool{
-                        "first_fit": true,
-                        "best_fit":  true,
-                        "worst_fit": true,
+                    podLabels, err := labels.ParseSet(c.StringSlice("label"))
+                    if err != nil {
+                        return err
                     }
-                    if algorithm == "" {
-                        algorithm = "first_fit" // Default to first_fit if not specified
+                    tolerations, err := parseTolerations(c.StringSlice("toleration"))
+                    if err != nil {
+                        return err
                     }
-                    if !validAlgorithms[algorithm] {
-                        return fmt.Errorf("invalid algorithm: %s. Valid options are: first_fit, best_fit, worst_fit", algorithm)
+                    namespace := c.String("namespace")
+                    if namespace == "" {
+                        namespace = api.ctx.Namespace
                     }
                     request := PodRequest{
+                        Namespace: namespace,
                         CPUs: c.Int("cpus"),
                         Algorithm: c.String("algorithm"),
+                        Labels: podLabels,
+                        GracePeriodSeconds: c.Int("grace-period"),
+                        Tolerations: tolerations,
+                        Claims: c.StringSlice("claim"),
                     }
 
-                    jsonData, err := json.Marshal(request)
+                    if c.Bool("dry-run") {
+                        return reviewPod(c, request)
+                    }
+                    body, err := api.do("POST", "/add_pod", request)
                     if err != nil {
-                        return fmt.Errorf("error marshaling request: %v", err)
+                        return err
                     }
 
-                    resp, err := http.Post("http://localhost:8080/add_pod", "application/json", bytes.NewBuffer(jsonData))
-                    if err != nil {
-                        return fmt.Errorf("error sending request: %v", err)
+                    return printResult(c, "pod", "pod_id", "Pod added successfully", body)
+                },
+            },
+            {
+                Name:  "describe",
+                Usage: "Show details of a node, pod or service",
+                Subcommands: []*cli.Command{
+                    {
+                        Name:      "node",
+                        Usage:     "Show details of a node",
+                        ArgsUsage: "<node-id>",
+                        Flags:     withOutputFlags(),
+                        Action: func(c *cli.Context) error {
+                            if c.NArg() != 1 {
+                                return fmt.Errorf("expected exactly one node ID")
+                            }
+                            body, err := api.do("GET", "/nodes/"+url.PathEscape(c.Args().First()), nil)
+                            if err != nil {
+                                return err
+                            }
+                            if format := optionString(c, "output"); format != "" {
+                                return printItem(c, "node", body, nil)
+                            }
+                            var desc NodeDescription
+                            if err := json.Unmarshal(body, &desc); err != nil {
+                                return fmt.Errorf("error parsing response: %v", err)
+                            }
+
+                            fmt.Printf("Name:        %s\n", desc.Node.ID)
+                            fmt.Printf("Status:      %s\n", desc.Node.Status)
+                            if desc.Node.Template != "" {
+                                fmt.Printf("Template:    %s\n", desc.Node.Template)
+                            }
+                            if desc.CapacityMemoryMiB > 0 {
+                                fmt.Printf("Capacity:    %d CPUs, %d MiB memory\n", desc.CapacityCPUs, desc.CapacityMemoryMiB)
+                            } else {
+                                fmt.Printf("Capacity:    %d CPUs\n", desc.CapacityCPUs)
+                            }
+                            fmt.Printf("Allocated:   %d CPUs (%d%%)\n", desc.AllocatedCPUs, percent(desc.AllocatedCPUs, desc.CapacityCPUs))
+                            printConditions(desc.Conditions)
+                            fmt.Printf("Pods:        (%d in total)\n", len(desc.Pods))
+                            for _, p := range desc.Pods {
+                                fmt.Printf("  %-45s %-3d CPUs  %s\n", p.ID, p.CPUs, p.Status)
+                            }
+                            printDescribedEvents(desc.Events)
+                            return nil
+                        },
+                    },
+                    {
+                        Name:      "pod",
+                        Usage:     "Show details of a pod, including why it is pending",
+                        ArgsUsage: "<pod-id>",
+                        Flags:     withOutputFlags(),
+                        Action: func(c *cli.Context) error {
+                            if c.NArg() != 1 {
+                                return fmt.Errorf("expected exactly one pod ID")
+                            }
+                            body, err := api.do("GET", "/pods/"+url.PathEscape(c.Args().First()), nil)
+                            if err != nil {
+                                return err
+                            }
+                            if format := optionString(c, "output"); format != "" {
+                                return printItem(c, "pod", body, nil)
+                            }
+                            var desc PodDescription
+                            if err := json.Unmarshal(body, &desc); err != nil {
+                                return fmt.Errorf("error parsing response: %v", err)
+                            }
+
+                            node := desc.Pod.NodeID
+                            if node == "" {
+                                node = "<none>"
+                            }
+                            fmt.Printf("Name:        %s\n", desc.Pod.ID)
+                            fmt.Printf("Status:      %s\n", desc.Pod.Status)
+                            fmt.Printf("Node:        %s\n", node)
+                            fmt.Printf("Requests:    %d CPUs\n", desc.Pod.CPUs)
+                            printConditions(desc.Conditions)
+                            if len(desc.Scheduling) > 0 {
+                                fmt.Println("Scheduling:")
+                                for _, fit := range desc.Scheduling {
+                                    verdict := "fits"
+                                    if !fit.Fits {
+                                        verdict = "rejected: " + fit.Reason
+                                    }
+                                    fmt.Printf("  %-45s %s\n", fit.NodeID, verdict)
+                                }
+                            }
+                            printDescribedEvents(desc.Events)
+                            return nil
+                        },
+                    },
+                    describeServiceCommand(),
+                },
+            },
+            {
+                Name:  "config",
+                Usage: "Manage client contexts",
+                Subcommands: []*cli.Command{
+                    {
+                        Name:  "get-contexts",
+                        Usage: "List the contexts in the config file",
+                        Action: func(c *cli.Context) error {
+                            cfg, err := loadConfig(c.String("config"))
+                            if err != nil {
+                                return err
+                            }
+                            w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+                            fmt.Fprintln(w, "CURRENT\tNAME\tSERVER\tNAMESPACE")
+                            for _, ctx := range cfg.Contexts {
+                                current := ""
+                                if ctx.Name == cfg.CurrentContext {
+                                    current = "*"
+                                }
+                                fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, ctx.Name, ctx.Server, ctx.Namespace)
+                            }
+                            return w.Flush()
+                        },
+                    },
+                    {
+                        Name:  "current-context",
+                        Usage: "Print the current context",
+                        Action: func(c *cli.Context) error {
+                            cfg, err := loadConfig(c.String("config"))
+                            if err != nil {
+                                return err
+                            }
+                            if cfg.CurrentContext == "" {
+                                return fmt.Errorf("current-context is not set")
+                            }
+                            fmt.Println(cfg.CurrentContext)
+                            return nil
+                        },
+                    },
+                    {
+                        Name:      "use-context",
+                        Usage:     "Set the current context",
+                        ArgsUsage: "<name>",
+                        Action: func(c *cli.Context) error {
+                            if c.NArg() != 1 {
+                                return fmt.Errorf("expected exactly one context name")
+                            }
+                            path := c.String("config")
+                            cfg, err := loadConfig(path)
+                            if err != nil {
+                                return err
+                            }
+                            name := c.Args().First()
+                            if cfg.context(name) == nil {
+                                return fmt.Errorf("context %q not found in config", name)
+                            }
+                            cfg.CurrentContext = name
+                            if err := cfg.save(path); err != nil {
+                                return err
+                            }
+                            fmt.Printf("Switched to context %q.\n", name)
+                            return nil
+                        },
+                    },
+                    {
+                        Name:      "set-context",
+                        Usage:     "Create or update a context",
+                        ArgsUsage: "<name>",
+                        Flags: []cli.Flag{
+                            &cli.StringFlag{Name: "server", Usage: "API server URL"},
+                            &cli.StringFlag{Name: "namespace", Usage: "Default namespace"},
+                            &cli.StringFlag{Name: "token", Usage: "Bearer token"},
+                            &cli.StringFlag{Name: "username", Usage: "Basic auth username"},
+                            &cli.StringFlag{Name: "password", Usage: "Basic auth password"},
+                            &cli.StringFlag{Name: "certificate-authority", Usage: "CA bundle to verify the API server with"},
+                            &cli.StringFlag{Name: "client-certificate", Usage: "Client certificate to present"},
+                            &cli.StringFlag{Name: "client-key", Usage: "Key of the client certificate"},
+                            &cli.BoolFlag{Name: "insecure-skip-tls-verify", Usage: "Do not verify the API server's certificate"},
+                        },
+                        Action: func(c *cli.Context) error {
+                            if c.NArg() != 1 {
+                                return fmt.Errorf("expected exactly one context name")
+                            }
+                            path := c.String("config")
+                            cfg, err := loadConfig(path)
+                            if err != nil {
+                                return err
+                            }
+                            name := c.Args().First()
+                            ctx := Context{Name: name, Server: defaultServer}
+                            if existing := cfg.context(name); existing != nil {
+                                ctx = *existing
+                            }
+                            // Only overwrite the fields that were given.
+                            for flag, field := range map[string]*string{
+                                "server":    &ctx.Server,
+                                "namespace": &ctx.Namespace,
+                                "token":     &ctx.Token,
+                                "username":  &ctx.Username,
+                                "password":  &ctx.Password,
+                            } {
+                                if c.IsSet(flag) {
+                                    *field = c.String(flag)
+                                }
+                            }
+                            // Store file paths absolute so the context works from any directory.
+                            for flag, field := range map[string]*string{
+                                "certificate-authority": &ctx.CertificateAuthority,
+                                "client-certificate":    &ctx.ClientCertificate,
+                                "client-key":            &ctx.ClientKey,
+                            } {
+                                if c.IsSet(flag) && c.String(flag) != "" {
+                                    abs, err := filepath.Abs(c.String(flag))
+                                    if err != nil {
+                                        return err
+                                    }
+                                    *field = abs
+                                }
+                            }
+                            if c.IsSet("insecure-skip-tls-verify") {
+                                ctx.InsecureSkipTLSVerify = c.Bool("insecure-skip-tls-verify")
+                            }
+                            cfg.setContext(ctx)
+                            if cfg.CurrentContext == "" {
+                                cfg.CurrentContext = name
+                            }
+                            if err := cfg.save(path); err != nil {
+                                return err
+                            }
+                            fmt.Printf("Context %q set.\n", name)
+                            return nil
+                        },
+                    },
+                },
+            },
+            {
+                Name:  "events",
+                Usage: "List cluster events",
+                Flags: withOutputFlags(
+                    &cli.StringFlag{
+                        Name:  "involved-object",
+                        Usage: "Only show events about this node or pod ID",
+                    },
+                    &cli.StringFlag{
+                        Name:  "kind",
+                        Usage: "Only show events about this kind of object (Node, Pod)",
+                    },
+                    &cli.BoolFlag{
+                        Name:  "watch",
+                        Usage: "Keep streaming new events after listing existing ones",
+                    },
+                ),
+                Action: func(c *cli.Context) error {
+                    query := url.Values{}
+                    if c.String("involved-object") != "" {
+                        query.Set("involvedObject", c.String("involved-object"))
+                    }
+                    if c.String("kind") != "" {
+                        query.Set("kind", c.String("kind"))
+                    }
+                    if !c.Bool("watch") {
+                        body, err := api.do("GET", "/events?"+query.Encode(), nil)
+                        if err != nil {
+                            return err
+                        }
+                        return printItems(c, "event", body, eventColumns)
                     }
-                    defer resp.Body.Close()
 
-                    body, err := ioutil.ReadAll(resp.Body)
+                    query.Set("watch", "true")
+                    resp, err := api.open("GET", "/events?"+query.Encode(), nil)
                     if err != nil {
-                        return fmt.Errorf("error reading response: %v", err)
+                        return err
                     }
+                    defer resp.Body.Close()
 
-                    if resp.StatusCode != http.StatusOK {
-                        return fmt.Errorf("server returned error: %s", string(body))
+                    // The server streams one JSON event per line until interrupted.
+                    // Each one is printed on its own, with the table header only once.
+                    scanner := bufio.NewScanner(resp.Body)
+                    first := true
+                    for scanner.Scan() {
+                        line := append([]byte{}, scanner.Bytes()...)
+                        if err := printWatchEvent(c, line, first); err != nil {
+                            return err
+                        }
+                        first = false
                     }
-
-                    fmt.Printf("Pod added successfully: %s\n", string(body))
-                    return nil
+                    return scanner.Err()
                 },
             },
         },
     }
+    app.Commands = append(app.Commands, nodeGroupCommands()...)
+    app.Commands = append(app.Commands, nodeTemplateCommands()...)
+    app.Commands = append(app.Commands, workloadCommands()...)
+    app.Commands = append(app.Commands, batchCommands()...)
+    app.Commands = append(app.Commands, storageCommands()...)
+    app.Commands = append(app.Commands, serviceCommands()...)
+    app.Commands = append(app.Commands, networkCommands()...)
+    app.Commands = append(app.Commands, rbacCommands()...)
+    app.Commands = append(app.Commands, certCommands()...)
+    app.Commands = append(app.Commands, admissionCommands()...)
+    app.Commands = append(app.Commands, logLevelCommands()...)
 
     if err := app.Run(os.Args); err != nil {
         fmt.Fprintf(os.Stderr, "Error: %v\n", err)
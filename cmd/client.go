@@ -0,0 +1,121 @@
+package main
+
+import (
+    "bytes"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// apiClient sends requests to the API server of the selected context.
+type apiClient struct {
+    ctx  Context
+    http *http.Client
+}
+
+// newAPIClient returns a client for ctx, loading its CA bundle and client
+// certificate if it has them.
+func newAPIClient(ctx Context) (*apiClient, error) {
+    tlsConfig := &tls.Config{InsecureSkipVerify: ctx.InsecureSkipTLSVerify}
+    if ctx.CertificateAuthority != "" {
+        data, err := os.ReadFile(ctx.CertificateAuthority)
+        if err != nil {
+            return nil, fmt.Errorf("error reading certificate authority: %v", err)
+        }
+        tlsConfig.RootCAs = x509.NewCertPool()
+        if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+            return nil, fmt.Errorf("no PEM certificates found in %s", ctx.CertificateAuthority)
+        }
+    }
+    if ctx.ClientCertificate != "" || ctx.ClientKey != "" {
+        if ctx.ClientCertificate == "" || ctx.ClientKey == "" {
+            return nil, fmt.Errorf("a client certificate needs both --client-certificate and --client-key")
+        }
+        cert, err := tls.LoadX509KeyPair(ctx.ClientCertificate, ctx.ClientKey)
+        if err != nil {
+            return nil, fmt.Errorf("error loading client certificate: %v", err)
+        }
+        tlsConfig.Certificates = []tls.Certificate{cert}
+    }
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = tlsConfig
+    return &apiClient{ctx: ctx, http: &http.Client{Transport: transport}}, nil
+}
+
+// newRequest builds a request against path, attaching credentials from the context.
+func (a *apiClient) newRequest(method, path string, body interface{}) (*http.Request, error) {
+    var reader io.Reader
+    if body != nil {
+        jsonData, err := json.Marshal(body)
+        if err != nil {
+            return nil, fmt.Errorf("error marshaling request: %v", err)
+        }
+        reader = bytes.NewBuffer(jsonData)
+    }
+
+    req, err := http.NewRequest(method, strings.TrimRight(a.ctx.Server, "/")+path, reader)
+    if err != nil {
+        return nil, fmt.Errorf("error creating request: %v", err)
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if a.ctx.Token != "" {
+        req.Header.Set("Authorization", "Bearer "+a.ctx.Token)
+    } else if a.ctx.Username != "" {
+        req.SetBasicAuth(a.ctx.Username, a.ctx.Password)
+    }
+    return req, nil
+}
+
+// open sends the request and returns the response for the caller to stream.
+// Non-200 responses are turned into errors.
+func (a *apiClient) open(method, path string, body interface{}) (*http.Response, error) {
+    req, err := a.newRequest(method, path, body)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := a.http.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("error sending request: %v", err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        defer resp.Body.Close()
+        respBody, _ := ioutil.ReadAll(resp.Body)
+        return nil, fmt.Errorf("server returned error: %s", string(respBody))
+    }
+    return resp, nil
+}
+
+// do sends the request and returns the response body.
+func (a *apiClient) do(method, path string, body interface{}) ([]byte, error) {
+    resp, err := a.open(method, path, body)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    respBody, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return nil, fmt.Errorf("error reading response: %v", err)
+    }
+    return respBody, nil
+}
+
+// getJSON fetches path and decodes the response into out.
+func (a *apiClient) getJSON(path string, out interface{}) error {
+    body, err := a.do("GET", path, nil)
+    if err != nil {
+        return err
+    }
+    if err := json.Unmarshal(body, out); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    return nil
+}
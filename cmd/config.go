@@ -0,0 +1,126 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "gopkg.in/yaml.v3"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// Context is a named API server the CLI can talk to, with the credentials
+// and namespace to use there. Over https the server is verified against
+// CertificateAuthority, or the system roots if it is empty, and the client
+// certificate and key, if given, are presented to it.
+type Context struct {
+    Name                  string `yaml:"name"`
+    Server                string `yaml:"server"`
+    Namespace             string `yaml:"namespace,omitempty"`
+    Token                 string `yaml:"token,omitempty"`
+    Username              string `yaml:"username,omitempty"`
+    Password              string `yaml:"password,omitempty"`
+    CertificateAuthority  string `yaml:"certificate-authority,omitempty"`
+    ClientCertificate     string `yaml:"client-certificate,omitempty"`
+    ClientKey             string `yaml:"client-key,omitempty"`
+    InsecureSkipTLSVerify bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+}
+
+// ClientConfig is the on-disk client configuration, similar to a kubeconfig.
+type ClientConfig struct {
+    CurrentContext string    `yaml:"current-context"`
+    Contexts       []Context `yaml:"contexts"`
+}
+
+// defaultConfigPath returns $CLUSTER_SIM_CONFIG or ~/.cluster-sim/config.
+func defaultConfigPath() string {
+    if path := os.Getenv("CLUSTER_SIM_CONFIG"); path != "" {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return ".cluster-sim-config"
+    }
+    return filepath.Join(home, ".cluster-sim", "config")
+}
+
+// loadConfig reads the config at path. A missing file yields an empty config.
+func loadConfig(path string) (*ClientConfig, error) {
+    cfg := &ClientConfig{}
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return cfg, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading config %s: %v", path, err)
+    }
+    if err := yaml.Unmarshal(data, cfg); err != nil {
+        return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+    }
+    return cfg, nil
+}
+
+// save writes the config to path, creating its directory if needed.
+func (cfg *ClientConfig) save(path string) error {
+    data, err := yaml.Marshal(cfg)
+    if err != nil {
+        return fmt.Errorf("error encoding config: %v", err)
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+        return fmt.Errorf("error creating config directory: %v", err)
+    }
+    // The file may hold credentials, so keep it private.
+    return os.WriteFile(path, data, 0o600)
+}
+
+// context returns the context with the given name, or nil.
+func (cfg *ClientConfig) context(name string) *Context {
+    for i := range cfg.Contexts {
+        if cfg.Contexts[i].Name == name {
+            return &cfg.Contexts[i]
+        }
+    }
+    return nil
+}
+
+// setContext adds ctx or replaces the context with the same name.
+func (cfg *ClientConfig) setContext(ctx Context) {
+    if existing := cfg.context(ctx.Name); existing != nil {
+        *existing = ctx
+        return
+    }
+    cfg.Contexts = append(cfg.Contexts, ctx)
+}
+
+// resolveContext picks the context to use: the --context flag, then
+// $CLUSTER_SIM_CONTEXT, then current-context. The server URL can be
+// overridden by --server or $CLUSTER_SIM_SERVER, in that order.
+func (cfg *ClientConfig) resolveContext(contextName, server string) (Context, error) {
+    if contextName == "" {
+        contextName = os.Getenv("CLUSTER_SIM_CONTEXT")
+    }
+    if contextName == "" {
+        contextName = cfg.CurrentContext
+    }
+
+    resolved := Context{Name: contextName, Server: defaultServer}
+    if contextName != "" {
+        ctx := cfg.context(contextName)
+        if ctx == nil {
+            return Context{}, fmt.Errorf("context %q not found in config", contextName)
+        }
+        resolved = *ctx
+    }
+
+    if env := os.Getenv("CLUSTER_SIM_SERVER"); env != "" {
+        resolved.Server = env
+    }
+    if server != "" {
+        resolved.Server = server
+    }
+    if resolved.Server == "" {
+        resolved.Server = defaultServer
+    }
+    return resolved, nil
+}
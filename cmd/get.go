@@ -0,0 +1,473 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/urfave/cli/v2"
+)
+
+var nodeColumns = []column{
+    {header: "NODE ID", value: field(".id")},
+    {header: "CPUs", value: field(".cpus")},
+    {header: "USED", value: field(".used_cpus")},
+    {header: "STATUS", value: func(obj map[string]interface{}) string {
+        status := field(".status")(obj)
+        if obj["unschedulable"] == true {
+            status += ",SchedulingDisabled"
+        }
+        return status
+    }},
+    {header: "PODS", value: func(obj map[string]interface{}) string {
+        pods, _ := obj["pods"].([]interface{})
+        if len(pods) == 0 {
+            return "none"
+        }
+        ids := make([]string, 0, len(pods))
+        for _, p := range pods {
+            ids = append(ids, formatValue(p))
+        }
+        return strings.Join(ids, ", ")
+    }},
+    {header: "POD CIDR", wide: true, value: field(".pod_cidr")},
+    {header: "MEMORY", wide: true, value: mebibytes(".memory_mib")},
+    {header: "TEMPLATE", wide: true, value: func(obj map[string]interface{}) string {
+        if t := field(".template")(obj); t != "" {
+            return t
+        }
+        return "<none>"
+    }},
+    {header: "TAINTS", wide: true, value: taintsColumn},
+    {header: "LABELS", wide: true, value: labelsColumn},
+    {header: "AGE", wide: true, value: age(".created_at")},
+}
+
+var podColumns = []column{
+    {header: "POD ID", value: field(".id")},
+    {header: "NAMESPACE", wide: true, value: field(".namespace")},
+    {header: "CPUs", value: field(".cpus")},
+    {header: "STATUS", value: field(".status")},
+    {header: "NODE", value: field(".node_id")},
+    {header: "IP", wide: true, value: field(".ip")},
+    {header: "LABELS", wide: true, value: labelsColumn},
+}
+
+var eventColumns = []column{
+    {header: "LAST SEEN", value: age(".last_timestamp")},
+    {header: "TYPE", value: field(".type")},
+    {header: "REASON", value: field(".reason")},
+    {header: "OBJECT", value: func(obj map[string]interface{}) string {
+        return field(".involved_object.kind")(obj) + "/" + field(".involved_object.name")(obj)
+    }},
+    {header: "COUNT", value: field(".count")},
+    {header: "MESSAGE", value: field(".message")},
+    {header: "FIRST SEEN", wide: true, value: age(".first_timestamp")},
+}
+
+var pdbColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "SELECTOR", value: field(".selector")},
+    {header: "MIN AVAILABLE", value: orNA(".min_available")},
+    {header: "MAX UNAVAILABLE", value: orNA(".max_unavailable")},
+    {header: "ALLOWED DISRUPTIONS", value: field(".status.disruptions_allowed")},
+    {header: "HEALTHY", wide: true, value: func(obj map[string]interface{}) string {
+        return field(".status.current_healthy")(obj) + "/" + field(".status.expected_pods")(obj)
+    }},
+}
+
+// orNA renders the value at path, or N/A when it is not set.
+func orNA(path string) func(map[string]interface{}) string {
+    return func(obj map[string]interface{}) string {
+        if v := field(path)(obj); v != "<none>" {
+            return v
+        }
+        return "N/A"
+    }
+}
+
+// age renders the time elapsed since the RFC 3339 timestamp at path.
+func age(path string) func(map[string]interface{}) string {
+    return func(obj map[string]interface{}) string {
+        t, err := time.Parse(time.RFC3339Nano, field(path)(obj))
+        if err != nil {
+            return "<unknown>"
+        }
+        return time.Since(t).Round(time.Second).String()
+    }
+}
+
+func listNodes(c *cli.Context) error {
+    body, err := api.do("GET", "/nodes"+selectorQuery(c), nil)
+    if err != nil {
+        return err
+    }
+    return printItems(c, "node", body, nodeColumns)
+}
+
+func listPods(c *cli.Context) error {
+    query := url.Values{}
+    if sel := optionString(c, "selector"); sel != "" {
+        query.Set("labelSelector", sel)
+    }
+    if namespace := listNamespace(c); namespace != "" {
+        query.Set("namespace", namespace)
+    }
+    path := "/pods"
+    if len(query) > 0 {
+        path += "?" + query.Encode()
+    }
+    body, err := api.do("GET", path, nil)
+    if err != nil {
+        return err
+    }
+    return printItems(c, "pod", body, podColumns)
+}
+
+// getCommand lists resources of a kind, e.g. "get pods -o wide".
+func getCommand() *cli.Command {
+    return &cli.Command{
+        Name:  "get",
+        Usage: "List resources (nodes, pods, events, pdbs, nodegroups, nodetemplates, podgroups, daemonsets, statefulsets, pvcs, pvs, storageclasses, services, endpoints, dnsrecords, links, podcidrs, hpas, jobs, cronjobs, roles, clusterroles, rolebindings, clusterrolebindings, namespaces, resourcequotas, webhooks)",
+        Subcommands: []*cli.Command{
+            {
+                Name:    "nodes",
+                Aliases: []string{"node", "no"},
+                Usage:   "List all nodes in the cluster",
+                Flags:   withOutputFlags(),
+                Action:  listNodes,
+            },
+            {
+                Name:    "pods",
+                Aliases: []string{"pod", "po"},
+                Usage:   "List the pods of a namespace, or of the cluster",
+                Flags:   withOutputFlags(listNamespaceFlags()...),
+                Action:  listPods,
+            },
+            {
+                Name:    "events",
+                Aliases: []string{"event", "ev"},
+                Usage:   "List cluster events",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/events", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItems(c, "event", body, eventColumns)
+                },
+            },
+            {
+                Name:    "pdbs",
+                Aliases: []string{"pdb", "poddisruptionbudgets"},
+                Usage:   "List PodDisruptionBudgets and how many disruptions they allow",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/pdbs", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "pdb", "name", body, pdbColumns)
+                },
+            },
+            {
+                Name:    "nodegroups",
+                Aliases: []string{"nodegroup", "ng"},
+                Usage:   "List autoscaled node groups and their current size",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/nodegroups", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "nodegroup", "name", body, nodeGroupColumns)
+                },
+            },
+            {
+                Name:    "nodetemplates",
+                Aliases: []string{"nodetemplate", "nt"},
+                Usage:   "List node templates",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/nodetemplates", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "nodetemplate", "name", body, nodeTemplateColumns)
+                },
+            },
+            {
+                Name:    "podgroups",
+                Aliases: []string{"podgroup", "pg"},
+                Usage:   "List pod groups and their replicas",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/podgroups", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "podgroup", "name", body, podGroupColumns)
+                },
+            },
+            {
+                Name:    "daemonsets",
+                Aliases: []string{"daemonset", "ds"},
+                Usage:   "List DaemonSets and how many nodes run their pods",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/daemonsets", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "daemonset", "name", body, daemonSetColumns)
+                },
+            },
+            {
+                Name:    "statefulsets",
+                Aliases: []string{"statefulset", "sts"},
+                Usage:   "List StatefulSets and their pods",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/statefulsets", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "statefulset", "name", body, statefulSetColumns)
+                },
+            },
+            {
+                Name:    "pvcs",
+                Aliases: []string{"pvc", "persistentvolumeclaims"},
+                Usage:   "List persistent volume claims and the pods using them",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/pvcs", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "pvc", "name", body, pvcColumns)
+                },
+            },
+            {
+                Name:    "pvs",
+                Aliases: []string{"pv", "persistentvolumes"},
+                Usage:   "List persistent volumes and the claims bound to them",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/pvs", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "pv", "name", body, pvColumns)
+                },
+            },
+            {
+                Name:    "storageclasses",
+                Aliases: []string{"storageclass", "sc"},
+                Usage:   "List storage classes",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/storageclasses", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "storageclass", "name", body, storageClassColumns)
+                },
+            },
+            {
+                Name:    "services",
+                Aliases: []string{"service", "svc"},
+                Usage:   "List services, their endpoints and request counts",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/services", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "service", "name", body, serviceColumns)
+                },
+            },
+            {
+                Name:    "endpoints",
+                Aliases: []string{"endpoint", "ep"},
+                Usage:   "List the pods behind each service",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/services", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "endpoints", "name", body, endpointsColumns)
+                },
+            },
+            {
+                Name:    "dnsrecords",
+                Aliases: []string{"dnsrecord", "dns"},
+                Usage:   "List the records served by the cluster DNS",
+                Flags:   withOutputFlags(),
+                Action:  listDNSRecords,
+            },
+            {
+                Name:    "links",
+                Aliases: []string{"link", "network"},
+                Usage:   "List network links that are partitioned, delayed or lossy",
+                Flags:   withOutputFlags(),
+                Action:  listLinks,
+            },
+            {
+                Name:    "podcidrs",
+                Aliases: []string{"podcidr", "ipam"},
+                Usage:   "List the pod CIDR of each node and how many of its addresses are in use",
+                Flags:   withOutputFlags(),
+                Action:  listPodCIDRs,
+            },
+            {
+                Name:    "hpas",
+                Aliases: []string{"hpa", "horizontalpodautoscalers"},
+                Usage:   "List horizontal pod autoscalers and their status",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/hpas", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "hpa", "name", body, hpaColumns)
+                },
+            },
+            {
+                Name:    "jobs",
+                Aliases: []string{"job"},
+                Usage:   "List jobs and their completions",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/jobs", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "job", "name", body, jobColumns)
+                },
+            },
+            {
+                Name:    "cronjobs",
+                Aliases: []string{"cronjob", "cj"},
+                Usage:   "List CronJobs and their schedules",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/cronjobs", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "cronjob", "name", body, cronJobColumns)
+                },
+            },
+            {
+                Name:    "roles",
+                Aliases: []string{"role"},
+                Usage:   "List Roles",
+                Flags:   withOutputFlags(listNamespaceFlags()...),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/roles"+listQuery(c), nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "role", "name", body, namespacedRoleColumns)
+                },
+            },
+            {
+                Name:    "clusterroles",
+                Aliases: []string{"clusterrole"},
+                Usage:   "List ClusterRoles",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/clusterroles", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "clusterrole", "name", body, roleColumns)
+                },
+            },
+            {
+                Name:    "rolebindings",
+                Aliases: []string{"rolebinding"},
+                Usage:   "List RoleBindings and the subjects they grant a role to",
+                Flags:   withOutputFlags(listNamespaceFlags()...),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/rolebindings"+listQuery(c), nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "rolebinding", "name", body, namespacedBindingColumns)
+                },
+            },
+            {
+                Name:    "clusterrolebindings",
+                Aliases: []string{"clusterrolebinding"},
+                Usage:   "List ClusterRoleBindings and the subjects they grant a role to",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/clusterrolebindings", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "clusterrolebinding", "name", body, bindingColumns)
+                },
+            },
+            {
+                Name:    "namespaces",
+                Aliases: []string{"namespace", "ns"},
+                Usage:   "List namespaces and how many pods they have",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/namespaces", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "namespace", "name", body, namespaceColumns)
+                },
+            },
+            {
+                Name:    "resourcequotas",
+                Aliases: []string{"resourcequota", "quota", "quotas"},
+                Usage:   "List namespace quotas and how much of them is used",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/resourcequotas", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "resourcequota", "namespace", body, quotaColumns)
+                },
+            },
+            {
+                Name:    "webhooks",
+                Aliases: []string{"webhook"},
+                Usage:   "List admission webhooks",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/admission/webhooks", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "webhook", "name", body, webhookColumns)
+                },
+            },
+            {
+                Name:    "loglevels",
+                Aliases: []string{"loglevel"},
+                Usage:   "List the log level of each server component",
+                Flags:   withOutputFlags(),
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/loglevels", nil)
+                    if err != nil {
+                        return err
+                    }
+                    return printItemsBy(c, "loglevel", "component", body, logLevelColumns)
+                },
+            },
+        },
+        Action: func(c *cli.Context) error {
+            return fmt.Errorf("specify a resource: nodes, pods, events, pdbs, nodegroups, nodetemplates, podgroups, daemonsets, statefulsets, pvcs, pvs, storageclasses, services, endpoints, dnsrecords, links, podcidrs, hpas, jobs, cronjobs, roles, clusterroles, rolebindings, clusterrolebindings, namespaces, resourcequotas, webhooks or loglevels")
+        },
+    }
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// This file implements the subset of kubectl's JSONPath templates that is
+// useful for scripting against the simulator:
+//
+//   {.items[*].id}                      field access, [*] and [n] indexing
+//   {.labels.app}                       nested maps
+//   {range .items[*]}{.id}{"\n"}{end}   iteration with quoted literals
+//
+// Data is the generic result of json.Unmarshal into interface{}.
+
+type jsonPathNode struct {
+    literal  string          // plain text, printed as is
+    path     string          // expression inside {}, evaluated against the current object
+    rangeOf  string          // non-empty for {range ...}
+    children []*jsonPathNode // body of a range
+}
+
+// parseJSONPath turns a template into a tree of nodes.
+func parseJSONPath(template string) ([]*jsonPathNode, error) {
+    var stack [][]*jsonPathNode
+    var rangeNodes []*jsonPathNode
+    current := []*jsonPathNode{}
+
+    for len(template) > 0 {
+        open := strings.Index(template, "{")
+        if open < 0 {
+            current = append(current, &jsonPathNode{literal: template})
+            break
+        }
+        if open > 0 {
+            current = append(current, &jsonPathNode{literal: template[:open]})
+        }
+        end := strings.Index(template[open:], "}")
+        if end < 0 {
+            return nil, fmt.Errorf("unclosed { in jsonpath template")
+        }
+        expr := strings.TrimSpace(template[open+1 : open+end])
+        template = template[open+end+1:]
+
+        switch {
+        case strings.HasPrefix(expr, "range "):
+            n := &jsonPathNode{rangeOf: strings.TrimSpace(strings.TrimPrefix(expr, "range "))}
+            current = append(current, n)
+            stack = append(stack, current)
+            rangeNodes = append(rangeNodes, n)
+            current = []*jsonPathNode{}
+        case expr == "end":
+            if len(stack) == 0 {
+                return nil, fmt.Errorf("{end} without matching {range}")
+            }
+            rangeNodes[len(rangeNodes)-1].children = current
+            current = stack[len(stack)-1]
+            stack = stack[:len(stack)-1]
+            rangeNodes = rangeNodes[:len(rangeNodes)-1]
+        case strings.HasPrefix(expr, `"`):
+            lit, err := strconv.Unquote(expr)
+            if err != nil {
+                return nil, fmt.Errorf("invalid literal %s in jsonpath template", expr)
+            }
+            current = append(current, &jsonPathNode{literal: lit})
+        default:
+            current = append(current, &jsonPathNode{path: expr})
+        }
+    }
+    if len(stack) != 0 {
+        return nil, fmt.Errorf("{range} without matching {end}")
+    }
+    return current, nil
+}
+
+// executeJSONPath renders the parsed template against data.
+func executeJSONPath(nodes []*jsonPathNode, data interface{}) (string, error) {
+    var b strings.Builder
+    for _, n := range nodes {
+        switch {
+        case n.rangeOf != "":
+            items, err := evalJSONPath(n.rangeOf, data)
+            if err != nil {
+                return "", err
+            }
+            for _, item := range items {
+                out, err := executeJSONPath(n.children, item)
+                if err != nil {
+                    return "", err
+                }
+                b.WriteString(out)
+            }
+        case n.path != "":
+            values, err := evalJSONPath(n.path, data)
+            if err != nil {
+                return "", err
+            }
+            parts := make([]string, 0, len(values))
+            for _, v := range values {
+                parts = append(parts, formatValue(v))
+            }
+            b.WriteString(strings.Join(parts, " "))
+        default:
+            b.WriteString(n.literal)
+        }
+    }
+    return b.String(), nil
+}
+
+// evalJSONPath evaluates a path such as ".items[*].labels.app" and returns
+// every value it selects. Missing fields select nothing.
+func evalJSONPath(path string, data interface{}) ([]interface{}, error) {
+    path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), "@")
+    values := []interface{}{data}
+
+    for len(path) > 0 {
+        var next []interface{}
+        switch path[0] {
+        case '.':
+            path = path[1:]
+            end := strings.IndexAny(path, ".[")
+            if end < 0 {
+                end = len(path)
+            }
+            field := path[:end]
+            path = path[end:]
+            if field == "" {
+                continue
+            }
+            for _, v := range values {
+                if m, ok := v.(map[string]interface{}); ok {
+                    if fv, exists := m[field]; exists {
+                        next = append(next, fv)
+                    }
+                }
+            }
+        case '[':
+            end := strings.Index(path, "]")
+            if end < 0 {
+                return nil, fmt.Errorf("unclosed [ in jsonpath %q", path)
+            }
+            index := path[1:end]
+            path = path[end+1:]
+            for _, v := range values {
+                switch typed := v.(type) {
+                case []interface{}:
+                    if index == "*" {
+                        next = append(next, typed...)
+                        continue
+                    }
+                    i, err := strconv.Atoi(index)
+                    if err != nil {
+                        return nil, fmt.Errorf("invalid index [%s] in jsonpath", index)
+                    }
+                    if i < 0 {
+                        i += len(typed)
+                    }
+                    if i >= 0 && i < len(typed) {
+                        next = append(next, typed[i])
+                    }
+                case map[string]interface{}:
+                    if index == "*" {
+                        keys := make([]string, 0, len(typed))
+                        for k := range typed {
+                            keys = append(keys, k)
+                        }
+                        sort.Strings(keys)
+                        for _, k := range keys {
+                            next = append(next, typed[k])
+                        }
+                    } else if fv, exists := typed[strings.Trim(index, `'"`)]; exists {
+                        next = append(next, fv)
+                    }
+                }
+            }
+        default:
+            return nil, fmt.Errorf("unexpected %q in jsonpath, expected . or [", path[0])
+        }
+        values = next
+    }
+    return values, nil
+}
+
+// formatValue prints scalars plainly and everything else as compact JSON-ish text.
+func formatValue(v interface{}) string {
+    switch typed := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return typed
+    case float64:
+        return strconv.FormatFloat(typed, 'f', -1, 64)
+    case bool:
+        return strconv.FormatBool(typed)
+    default:
+        return toJSON(typed)
+    }
+}
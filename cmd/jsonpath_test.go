@@ -0,0 +1,86 @@
+package main
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+const jsonPathTestData = `{
+    "items": [
+        {"id": "pod-1", "cpus": 2, "ready": true, "labels": {"app": "web"}},
+        {"id": "pod-2", "cpus": 0.5, "ready": false, "labels": {"app": "db", "tier": "data"}}
+    ],
+    "node": {"name": "node-1", "taints": null}
+}`
+
+func TestJSONPath(t *testing.T) {
+    var data interface{}
+    if err := json.Unmarshal([]byte(jsonPathTestData), &data); err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name     string
+        template string
+        want     string
+    }{
+        {"field", "{.node.name}", "node-1"},
+        {"root prefix", "{$.node.name}", "node-1"},
+        {"all items", "{.items[*].id}", "pod-1 pod-2"},
+        {"index", "{.items[1].id}", "pod-2"},
+        {"negative index", "{.items[-1].id}", "pod-2"},
+        {"index out of range", "{.items[5].id}", ""},
+        {"missing field", "{.items[*].missing}", ""},
+        {"nested map", "{.items[*].labels.app}", "web db"},
+        {"bracketed key", "{.items[0].labels['app']}", "web"},
+        {"map values in key order", "{.items[1].labels[*]}", "db data"},
+        {"numbers", "{.items[*].cpus}", "2 0.5"},
+        {"booleans", "{.items[*].ready}", "true false"},
+        {"null", "{.node.taints}", ""},
+        {"object as JSON", "{.items[0].labels}", `{"app":"web"}`},
+        {"text around", "node: {.node.name}!", "node: node-1!"},
+        {"range", `{range .items[*]}{.id}={.labels.app}{"\n"}{end}`, "pod-1=web\npod-2=db\n"},
+        {"nested range", `{range .items[*]}{.id}:{range .labels[*]}[{@}]{end};{end}`, "pod-1:[web];pod-2:[db][data];"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nodes, err := parseJSONPath(tt.template)
+            if err != nil {
+                t.Fatalf("parseJSONPath(%q): %v", tt.template, err)
+            }
+            got, err := executeJSONPath(nodes, data)
+            if err != nil {
+                t.Fatalf("executeJSONPath(%q): %v", tt.template, err)
+            }
+            if got != tt.want {
+                t.Errorf("%q = %q, want %q", tt.template, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestJSONPathErrors(t *testing.T) {
+    tests := []struct {
+        name     string
+        template string
+    }{
+        {"unclosed brace", "{.items"},
+        {"end without range", "{end}"},
+        {"range without end", "{range .items[*]}{.id}"},
+        {"bad literal", `{"unterminated}`},
+        {"unclosed bracket", "{.items[0}"},
+        {"bad index", "{.items[x]}"},
+        {"bad start", "{items}"},
+    }
+    data := map[string]interface{}{"items": []interface{}{"a"}}
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nodes, err := parseJSONPath(tt.template)
+            if err == nil {
+                _, err = executeJSONPath(nodes, data)
+            }
+            if err == nil {
+                t.Errorf("%q succeeded, want an error", tt.template)
+            }
+        })
+    }
+}
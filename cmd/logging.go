@@ -0,0 +1,59 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+
+    "github.com/urfave/cli/v2"
+)
+
+var logLevelColumns = []column{
+    {header: "COMPONENT", value: field(".component")},
+    {header: "LEVEL", value: field(".level")},
+    {header: "SOURCE", value: func(obj map[string]interface{}) string {
+        if field(".default")(obj) == "true" {
+            return "default"
+        }
+        return "own"
+    }},
+}
+
+// logLevelCommands change how much each server component logs, without a
+// restart.
+func logLevelCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:      "set-log-level",
+            Usage:     "Set the log level of a server component, or of all components without their own with \"default\"",
+            ArgsUsage: "<component> <debug|info|warn|error>",
+            Flags:     withOutputFlags(),
+            Action: func(c *cli.Context) error {
+                if c.NArg() != 2 {
+                    return fmt.Errorf("expected a component and a level")
+                }
+                path := "/loglevels/" + url.PathEscape(c.Args().Get(0))
+                body, err := api.do("PUT", path, map[string]string{"level": c.Args().Get(1)})
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "loglevel", "component", "Log level set", body)
+            },
+        },
+        {
+            Name:      "reset-log-level",
+            Usage:     "Make a server component log at the default level again",
+            ArgsUsage: "<component>",
+            Flags:     withOutputFlags(),
+            Action: func(c *cli.Context) error {
+                if c.NArg() != 1 {
+                    return fmt.Errorf("expected exactly one component")
+                }
+                body, err := api.do("DELETE", "/loglevels/"+url.PathEscape(c.Args().First()), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "loglevel", "component", "Log level reset", body)
+            },
+        },
+    }
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+
+    "github.com/urfave/cli/v2"
+)
+
+// controlPlane names the API server's end of a link.
+const controlPlane = "control-plane"
+
+type Link struct {
+    A             string  `json:"a"`
+    B             string  `json:"b"`
+    Partitioned   bool    `json:"partitioned"`
+    LatencyMillis int     `json:"latency_ms,omitempty"`
+    LossRate      float64 `json:"loss_rate,omitempty"`
+}
+
+var linkColumns = []column{
+    {header: "A", value: field(".a")},
+    {header: "B", value: field(".b")},
+    {header: "PARTITIONED", value: field(".partitioned")},
+    {header: "LATENCY", value: func(obj map[string]interface{}) string {
+        return field(".latency_ms")(obj) + "ms"
+    }},
+    {header: "LOSS", value: field(".loss_rate")},
+}
+
+// listLinks prints the links that are partitioned, delayed or lossy.
+func listLinks(c *cli.Context) error {
+    body, err := api.do("GET", "/network", nil)
+    if err != nil {
+        return err
+    }
+    var network struct {
+        Links []interface{} `json:"links"`
+    }
+    if err := json.Unmarshal(body, &network); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    if sortBy := optionString(c, "sort-by"); sortBy != "" {
+        if err := sortItems(network.Links, sortBy); err != nil {
+            return err
+        }
+    }
+    list := map[string]interface{}{"kind": "List", "items": network.Links}
+    return printData(c, "link", "a", list, network.Links, linkColumns)
+}
+
+// linkEndFlags name the two ends of a link.
+func linkEndFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.StringFlag{
+            Name:     "a",
+            Usage:    "Node ID at one end of the link, or \"" + controlPlane + "\"",
+            Required: true,
+        },
+        &cli.StringFlag{
+            Name:  "b",
+            Usage: "Node ID at the other end of the link",
+            Value: controlPlane,
+        },
+    }
+}
+
+// networkCommands partition, delay and heal the links between the control
+// plane and the nodes.
+func networkCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "set-link",
+            Usage: "Partition, delay or make lossy the link between two nodes, or a node and the control plane",
+            Flags: withOutputFlags(append(linkEndFlags(),
+                &cli.BoolFlag{
+                    Name:  "partition",
+                    Usage: "Drop all traffic over the link",
+                },
+                &cli.DurationFlag{
+                    Name:  "latency",
+                    Usage: "Delay added in each direction, e.g. 200ms",
+                },
+                &cli.Float64Flag{
+                    Name:  "loss",
+                    Usage: "Fraction of packets lost, between 0 and 1",
+                },
+            )...),
+            Action: func(c *cli.Context) error {
+                link := Link{
+                    A:             c.String("a"),
+                    B:             c.String("b"),
+                    Partitioned:   c.Bool("partition"),
+                    LatencyMillis: int(c.Duration("latency").Milliseconds()),
+                    LossRate:      c.Float64("loss"),
+                }
+                body, err := api.do("PUT", "/network/links", link)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "link", "name", "Link saved", body)
+            },
+        },
+        {
+            Name:  "heal-link",
+            Usage: "Make the link between two endpoints healthy again",
+            Flags: withOutputFlags(linkEndFlags()...),
+            Action: func(c *cli.Context) error {
+                path := "/network/links/" + url.PathEscape(c.String("a")) + "/" + url.PathEscape(c.String("b"))
+                body, err := api.do("DELETE", path, nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "link", "name", "Link healed", body)
+            },
+        },
+        {
+            Name:  "partition",
+            Usage: "Cut nodes off from the control plane and every other node",
+            Flags: withOutputFlags(
+                &cli.StringSliceFlag{
+                    Name:     "node-id",
+                    Usage:    "Node on the minority side of the partition (repeatable)",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := map[string][]string{"nodes": c.StringSlice("node-id")}
+                body, err := api.do("POST", "/network/partition", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "nodes", "nodes", "Nodes partitioned", body)
+            },
+        },
+        {
+            Name:  "heal-network",
+            Usage: "Make every link healthy again",
+            Flags: withOutputFlags(),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("POST", "/network/heal", nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "links", "healed", "Network healed", body)
+            },
+        },
+    }
+}
+
+var podCIDRColumns = []column{
+    {header: "NODE ID", value: field(".node_id")},
+    {header: "POD CIDR", value: field(".cidr")},
+    {header: "ALLOCATED", value: field(".allocated")},
+    {header: "CAPACITY", value: field(".capacity")},
+}
+
+// listPodCIDRs prints the pod CIDR of each node and the addresses taken from it.
+func listPodCIDRs(c *cli.Context) error {
+    body, err := api.do("GET", "/ipam", nil)
+    if err != nil {
+        return err
+    }
+    var ipam struct {
+        Nodes []interface{} `json:"nodes"`
+    }
+    if err := json.Unmarshal(body, &ipam); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    if sortBy := optionString(c, "sort-by"); sortBy != "" {
+        if err := sortItems(ipam.Nodes, sortBy); err != nil {
+            return err
+        }
+    }
+    list := map[string]interface{}{"kind": "List", "items": ipam.Nodes}
+    return printData(c, "podcidr", "node_id", list, ipam.Nodes, podCIDRColumns)
+}
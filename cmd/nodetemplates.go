@@ -0,0 +1,203 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+
+    "cluster-sim/internal/labels"
+
+    "github.com/urfave/cli/v2"
+)
+
+type ContainerResources struct {
+    CPULimit       float64 `json:"cpu_limit,omitempty"`
+    MemoryLimitMiB int     `json:"memory_limit_mib,omitempty"`
+}
+
+type NodeTemplateRequest struct {
+    Name               string             `json:"name"`
+    Image              string             `json:"image,omitempty"`
+    Command            []string           `json:"command,omitempty"`
+    Resources          ContainerResources `json:"resources,omitempty"`
+    CPUs               int                `json:"cpus"`
+    MemoryMiB          int                `json:"memory_mib,omitempty"`
+    Labels             map[string]string  `json:"labels,omitempty"`
+    Taints             []Taint            `json:"taints,omitempty"`
+    StartupDelayMillis int                `json:"startup_delay_ms,omitempty"`
+}
+
+// AddNodesResult is the response to adding more than one node at once.
+type AddNodesResult struct {
+    Message   string   `json:"message"`
+    Requested int      `json:"requested"`
+    NodeIDs   []string `json:"node_ids"`
+    Failed    []string `json:"failed"`
+}
+
+var nodeTemplateColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "CPUs", value: field(".cpus")},
+    {header: "MEMORY", value: mebibytes(".memory_mib")},
+    {header: "IMAGE", value: func(obj map[string]interface{}) string {
+        if image := field(".image")(obj); image != "<none>" {
+            return image
+        }
+        return "<default>"
+    }},
+    {header: "STARTUP DELAY", wide: true, value: func(obj map[string]interface{}) string {
+        ms, _ := obj["startup_delay_ms"].(float64)
+        return (time.Duration(ms) * time.Millisecond).String()
+    }},
+    {header: "CPU LIMIT", wide: true, value: field(".resources.cpu_limit")},
+    {header: "MEMORY LIMIT", wide: true, value: mebibytes(".resources.memory_limit_mib")},
+    {header: "TAINTS", wide: true, value: taintsColumn},
+    {header: "LABELS", wide: true, value: labelsColumn},
+}
+
+// mebibytes renders a size in MiB, or <none> when it is not set.
+func mebibytes(path string) func(map[string]interface{}) string {
+    return func(obj map[string]interface{}) string {
+        if v := field(path)(obj); v != "<none>" && v != "0" {
+            return v + "Mi"
+        }
+        return "<none>"
+    }
+}
+
+// printAddNodesResult prints the nodes a bulk add-node created and the
+// errors of those it could not, and fails when any could not be created.
+func printAddNodesResult(c *cli.Context, body []byte) error {
+    var result AddNodesResult
+    if err := json.Unmarshal(body, &result); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    switch format := optionString(c, "output"); format {
+    case "", "table", "wide":
+        for _, id := range result.NodeIDs {
+            fmt.Printf("Node added successfully: node/%s\n", id)
+        }
+        for _, msg := range result.Failed {
+            fmt.Printf("Node not added: %s\n", msg)
+        }
+    case "name":
+        for _, id := range result.NodeIDs {
+            fmt.Printf("node/%s\n", id)
+        }
+    default:
+        var obj interface{}
+        if err := json.Unmarshal(body, &obj); err != nil {
+            return fmt.Errorf("error parsing response: %v", err)
+        }
+        if err := printData(c, "node", "node_id", obj, nil, nil); err != nil {
+            return err
+        }
+    }
+    if len(result.Failed) > 0 {
+        return fmt.Errorf("%d of %d nodes could not be added", len(result.Failed), result.Requested)
+    }
+    return nil
+}
+
+// nodeTemplateCommands manage the machine types nodes can be created from.
+func nodeTemplateCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-nodetemplate",
+            Usage: "Create or replace a node template",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the node template",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "cpus",
+                    Usage:    "Number of CPUs of each node",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "memory",
+                    Usage: "Memory of each node in MiB",
+                },
+                &cli.StringFlag{
+                    Name:  "image",
+                    Usage: "Image of the node containers (default: the server's node image)",
+                },
+                &cli.StringFlag{
+                    Name:  "command",
+                    Usage: "Command of the node containers, split on spaces (default: the server's node command)",
+                },
+                &cli.Float64Flag{
+                    Name:  "cpu-limit",
+                    Usage: "CPUs each node container may use, e.g. 0.5 (default: unlimited)",
+                },
+                &cli.IntFlag{
+                    Name:  "memory-limit",
+                    Usage: "Memory each node container may use in MiB (default: unlimited)",
+                },
+                &cli.DurationFlag{
+                    Name:  "startup-delay",
+                    Usage: "How long a new node takes to register, e.g. 5s",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "label",
+                    Usage: "Label to set on new nodes as key=value (repeatable)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "taint",
+                    Usage: "Taint to set on new nodes as key=value:Effect (repeatable)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                nodeLabels, err := labels.ParseSet(c.StringSlice("label"))
+                if err != nil {
+                    return err
+                }
+                taints, err := parseTaints(c.StringSlice("taint"))
+                if err != nil {
+                    return err
+                }
+                request := NodeTemplateRequest{
+                    Name:    c.String("name"),
+                    Image:   c.String("image"),
+                    Command: strings.Fields(c.String("command")),
+                    Resources: ContainerResources{
+                        CPULimit:       c.Float64("cpu-limit"),
+                        MemoryLimitMiB: c.Int("memory-limit"),
+                    },
+                    CPUs:               c.Int("cpus"),
+                    MemoryMiB:          c.Int("memory"),
+                    Labels:             nodeLabels,
+                    Taints:             taints,
+                    StartupDelayMillis: int(c.Duration("startup-delay").Milliseconds()),
+                }
+                body, err := api.do("POST", "/nodetemplates", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "nodetemplate", "name", "Node template saved", body)
+            },
+        },
+        {
+            Name:  "delete-nodetemplate",
+            Usage: "Delete a node template (its nodes keep running)",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the node template",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/nodetemplates/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "nodetemplate", "name", "Node template deleted", body)
+            },
+        },
+    }
+}
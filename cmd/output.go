@@ -0,0 +1,308 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    "text/template"
+
+    "github.com/urfave/cli/v2"
+    "gopkg.in/yaml.v3"
+)
+
+// outputFlags are accepted both globally and by every command, so
+// "cluster-cli -o json get pods" and "cluster-cli get pods -o json" both work.
+func outputFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.StringFlag{
+            Name:    "output",
+            Aliases: []string{"o"},
+            Usage:   "Output format: table, wide, json, yaml, name, jsonpath=<template>, go-template=<template>",
+        },
+        &cli.BoolFlag{
+            Name:  "no-headers",
+            Usage: "Do not print table headers",
+        },
+        &cli.StringFlag{
+            Name:  "sort-by",
+            Usage: "Sort list output by a JSONPath expression, e.g. .cpus",
+        },
+        &cli.StringFlag{
+            Name:    "selector",
+            Aliases: []string{"l"},
+            Usage:   "Label selector to filter on, e.g. app=web,tier!=db",
+        },
+    }
+}
+
+// withOutputFlags appends the shared output flags to a command's own flags.
+func withOutputFlags(flags ...cli.Flag) []cli.Flag {
+    return append(flags, outputFlags()...)
+}
+
+// optionString returns the value of a flag set on this command or any parent.
+func optionString(c *cli.Context, name string) string {
+    for _, ctx := range c.Lineage() {
+        if ctx != nil && ctx.IsSet(name) {
+            return ctx.String(name)
+        }
+    }
+    return ""
+}
+
+func optionBool(c *cli.Context, name string) bool {
+    for _, ctx := range c.Lineage() {
+        if ctx != nil && ctx.IsSet(name) {
+            return ctx.Bool(name)
+        }
+    }
+    return false
+}
+
+// column describes one column of table output.
+type column struct {
+    header string
+    wide   bool // only shown with -o wide
+    value  func(obj map[string]interface{}) string
+}
+
+// field returns a column value function reading a JSONPath from the object.
+func field(path string) func(map[string]interface{}) string {
+    return func(obj map[string]interface{}) string {
+        values, err := evalJSONPath(path, obj)
+        if err != nil || len(values) == 0 {
+            return "<none>"
+        }
+        parts := make([]string, 0, len(values))
+        for _, v := range values {
+            parts = append(parts, formatValue(v))
+        }
+        return strings.Join(parts, ",")
+    }
+}
+
+// labelsColumn renders the labels map as k=v,k=v.
+func labelsColumn(obj map[string]interface{}) string {
+    m, ok := obj["labels"].(map[string]interface{})
+    if !ok || len(m) == 0 {
+        return "<none>"
+    }
+    pairs := make([]string, 0, len(m))
+    for k, v := range m {
+        pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+    }
+    sort.Strings(pairs)
+    return strings.Join(pairs, ",")
+}
+
+func toJSON(v interface{}) string {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Sprint(v)
+    }
+    return string(data)
+}
+
+// printItems prints a list returned by the API server in the selected format.
+// kind is used for "-o name" (e.g. "node/<id>").
+func printItems(c *cli.Context, kind string, body []byte, columns []column) error {
+    return printItemsBy(c, kind, "id", body, columns)
+}
+
+// printItemsBy is printItems for objects identified by a field other than "id".
+func printItemsBy(c *cli.Context, kind, idField string, body []byte, columns []column) error {
+    var items []interface{}
+    if err := json.Unmarshal(body, &items); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    if sortBy := optionString(c, "sort-by"); sortBy != "" {
+        if err := sortItems(items, sortBy); err != nil {
+            return err
+        }
+    }
+    list := map[string]interface{}{"kind": "List", "items": items}
+    return printData(c, kind, idField, list, items, columns)
+}
+
+// printItem prints a single object returned by the API server.
+func printItem(c *cli.Context, kind string, body []byte, columns []column) error {
+    var obj interface{}
+    if err := json.Unmarshal(body, &obj); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    return printData(c, kind, "id", obj, []interface{}{obj}, columns)
+}
+
+// printData renders data (for structured formats) or rows (for tables and names).
+func printData(c *cli.Context, kind, idField string, data interface{}, rows []interface{}, columns []column) error {
+    format := optionString(c, "output")
+    switch {
+    case format == "" || format == "table" || format == "wide":
+        return printTable(rows, columns, format == "wide", optionBool(c, "no-headers"))
+    case format == "json":
+        out, err := json.MarshalIndent(data, "", "    ")
+        if err != nil {
+            return err
+        }
+        fmt.Println(string(out))
+    case format == "yaml":
+        out, err := yaml.Marshal(data)
+        if err != nil {
+            return err
+        }
+        fmt.Print(string(out))
+    case format == "name":
+        for _, row := range rows {
+            if obj, ok := row.(map[string]interface{}); ok {
+                fmt.Printf("%s/%v\n", kind, obj[idField])
+            }
+        }
+    case strings.HasPrefix(format, "jsonpath="):
+        nodes, err := parseJSONPath(strings.TrimPrefix(format, "jsonpath="))
+        if err != nil {
+            return err
+        }
+        out, err := executeJSONPath(nodes, data)
+        if err != nil {
+            return err
+        }
+        fmt.Println(out)
+    case strings.HasPrefix(format, "go-template="):
+        tmpl, err := template.New("output").Parse(strings.TrimPrefix(format, "go-template="))
+        if err != nil {
+            return fmt.Errorf("error parsing template: %v", err)
+        }
+        if err := tmpl.Execute(os.Stdout, data); err != nil {
+            return fmt.Errorf("error executing template: %v", err)
+        }
+    default:
+        return fmt.Errorf("unknown output format %q", format)
+    }
+    return nil
+}
+
+func printTable(rows []interface{}, columns []column, wide, noHeaders bool) error {
+    w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+    var headers []string
+    for _, col := range columns {
+        if !col.wide || wide {
+            headers = append(headers, col.header)
+        }
+    }
+    if !noHeaders {
+        fmt.Fprintln(w, strings.Join(headers, "\t"))
+    }
+    for _, row := range rows {
+        obj, ok := row.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        var cells []string
+        for _, col := range columns {
+            if !col.wide || wide {
+                cells = append(cells, col.value(obj))
+            }
+        }
+        fmt.Fprintln(w, strings.Join(cells, "\t"))
+    }
+    return w.Flush()
+}
+
+// printStreamRow prints one row of a table whose rows arrive one at a time,
+// such as a watch. A tabwriter can only align rows it has seen, so each cell
+// is padded to the width given for its column instead, or to its header when
+// that is wider. The last cell is not padded. header prints the header first.
+func printStreamRow(obj map[string]interface{}, columns []column, widths map[string]int, wide, header bool) {
+    var shown []column
+    for _, col := range columns {
+        if !col.wide || wide {
+            shown = append(shown, col)
+        }
+    }
+    line := func(cell func(column) string) string {
+        var b strings.Builder
+        for i, col := range shown {
+            value := cell(col)
+            if i == len(shown)-1 {
+                b.WriteString(value)
+                break
+            }
+            width := widths[col.header]
+            if width < len(col.header) {
+                width = len(col.header)
+            }
+            fmt.Fprintf(&b, "%-*s   ", width, value)
+        }
+        return b.String()
+    }
+    if header {
+        fmt.Println(line(func(col column) string { return col.header }))
+    }
+    fmt.Println(line(func(col column) string { return col.value(obj) }))
+}
+
+// sortItems orders items by the value at path, numerically when both values are numbers.
+func sortItems(items []interface{}, path string) error {
+    path = strings.TrimSuffix(strings.TrimPrefix(path, "{"), "}")
+    keys := make([]interface{}, len(items))
+    for i, item := range items {
+        values, err := evalJSONPath(path, item)
+        if err != nil {
+            return err
+        }
+        if len(values) > 0 {
+            keys[i] = values[0]
+        }
+    }
+    idx := make([]int, len(items))
+    for i := range idx {
+        idx[i] = i
+    }
+    sort.SliceStable(idx, func(a, b int) bool {
+        ka, kb := keys[idx[a]], keys[idx[b]]
+        fa, aNum := ka.(float64)
+        fb, bNum := kb.(float64)
+        if aNum && bNum {
+            return fa < fb
+        }
+        return formatValue(ka) < formatValue(kb)
+    })
+    sorted := make([]interface{}, len(items))
+    for i, j := range idx {
+        sorted[i] = items[j]
+    }
+    copy(items, sorted)
+    return nil
+}
+
+// printResult reports the outcome of a mutating command. Structured formats
+// print the server's response; the default prints message followed by the
+// affected object, e.g. "Pod added successfully: pod/<id>".
+func printResult(c *cli.Context, kind, idField, message string, body []byte) error {
+    var obj map[string]interface{}
+    if err := json.Unmarshal(body, &obj); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    format := optionString(c, "output")
+    switch format {
+    case "", "table", "wide":
+        fmt.Printf("%s: %s/%v\n", message, kind, obj[idField])
+        return nil
+    case "name":
+        fmt.Printf("%s/%v\n", kind, obj[idField])
+        return nil
+    }
+    return printData(c, kind, idField, obj, []interface{}{obj}, nil)
+}
+
+// selectorQuery returns "?labelSelector=..." for the -l flag, or "".
+func selectorQuery(c *cli.Context) string {
+    if sel := optionString(c, "selector"); sel != "" {
+        return "?labelSelector=" + url.QueryEscape(sel)
+    }
+    return ""
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+
+    "github.com/urfave/cli/v2"
+)
+
+type PolicyRule struct {
+    Verbs         []string `json:"verbs"`
+    Resources     []string `json:"resources"`
+    ResourceNames []string `json:"resource_names,omitempty"`
+}
+
+type RoleRequest struct {
+    Name      string       `json:"name"`
+    Namespace string       `json:"namespace,omitempty"`
+    Rules     []PolicyRule `json:"rules"`
+}
+
+type Subject struct {
+    Kind string `json:"kind"`
+    Name string `json:"name"`
+}
+
+type RoleRef struct {
+    Kind string `json:"kind"`
+    Name string `json:"name"`
+}
+
+type RoleBindingRequest struct {
+    Name      string    `json:"name"`
+    Namespace string    `json:"namespace,omitempty"`
+    RoleRef   RoleRef   `json:"role_ref"`
+    Subjects  []Subject `json:"subjects"`
+}
+
+// rulesColumn renders rules as "verbs on resources" joined by "; ".
+func rulesColumn(obj map[string]interface{}) string {
+    parts := []string{}
+    for _, r := range listField(obj, "rules") {
+        rule, _ := r.(map[string]interface{})
+        part := joinList(rule, "verbs") + " on " + joinList(rule, "resources")
+        if names := joinList(rule, "resource_names"); names != "" {
+            part += " named " + names
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, "; ")
+}
+
+// joinList joins the list stored under key with commas.
+func joinList(obj map[string]interface{}, key string) string {
+    items := listField(obj, key)
+    values := make([]string, 0, len(items))
+    for _, item := range items {
+        values = append(values, formatValue(item))
+    }
+    return strings.Join(values, ",")
+}
+
+var roleColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "RULES", value: rulesColumn},
+}
+
+// namespacedRoleColumns and namespacedBindingColumns list Roles and
+// RoleBindings, which each live in a namespace.
+var namespacedRoleColumns = append([]column{
+    {header: "NAMESPACE", value: field(".namespace")},
+}, roleColumns...)
+
+var namespacedBindingColumns = append([]column{
+    {header: "NAMESPACE", value: field(".namespace")},
+}, bindingColumns...)
+
+var bindingColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "ROLE", value: func(obj map[string]interface{}) string {
+        return field(".role_ref.kind")(obj) + "/" + field(".role_ref.name")(obj)
+    }},
+    {header: "SUBJECTS", value: func(obj map[string]interface{}) string {
+        parts := []string{}
+        for _, s := range listField(obj, "subjects") {
+            subject, _ := s.(map[string]interface{})
+            parts = append(parts, field(".kind")(subject)+"/"+field(".name")(subject))
+        }
+        return strings.Join(parts, ",")
+    }},
+}
+
+// namespaceFlag picks the namespace of a Role, a RoleBinding or a pod.
+func namespaceFlag(of string) cli.Flag {
+    return &cli.StringFlag{
+        Name:  "namespace",
+        Usage: "Namespace of the " + of + " (default: the context's namespace, or \"default\")",
+    }
+}
+
+// namespaceOf returns the namespace given with --namespace, or else the
+// context's. It is "" when neither is set, which the server takes as
+// "default".
+func namespaceOf(c *cli.Context) string {
+    if namespace := c.String("namespace"); namespace != "" {
+        return namespace
+    }
+    return api.ctx.Namespace
+}
+
+// listNamespaceFlags pick the namespace to list the objects of.
+func listNamespaceFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.StringFlag{
+            Name:  "namespace",
+            Usage: "Only list objects in this namespace (default: the context's namespace, or all of them)",
+        },
+        &cli.BoolFlag{
+            Name:    "all-namespaces",
+            Aliases: []string{"A"},
+            Usage:   "List objects in every namespace, even when the context has one",
+        },
+    }
+}
+
+// listNamespace returns the namespace a list asks for, as chosen by
+// listNamespaceFlags, or "" for every namespace. Listing every namespace
+// needs access across the cluster.
+func listNamespace(c *cli.Context) string {
+    if c.Bool("all-namespaces") {
+        return ""
+    }
+    return namespaceOf(c)
+}
+
+// listQuery returns the query selecting the namespace of listNamespace.
+func listQuery(c *cli.Context) string {
+    if namespace := listNamespace(c); namespace != "" {
+        return "?" + url.Values{"namespace": {namespace}}.Encode()
+    }
+    return ""
+}
+
+// roleFlags describe a role with a single rule.
+func roleFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.StringFlag{
+            Name:     "name",
+            Usage:    "Name of the role",
+            Required: true,
+        },
+        &cli.StringSliceFlag{
+            Name:     "verb",
+            Usage:    "Verb the role allows: get, list, watch, create, update, patch, delete or * (repeatable)",
+            Required: true,
+        },
+        &cli.StringSliceFlag{
+            Name:     "resource",
+            Usage:    "Resource the role applies to, e.g. pods, nodes/drain or * (repeatable)",
+            Required: true,
+        },
+        &cli.StringSliceFlag{
+            Name:  "resource-name",
+            Usage: "Limit the role to objects with this name (repeatable)",
+        },
+    }
+}
+
+func roleRequest(c *cli.Context) RoleRequest {
+    return RoleRequest{
+        Name: c.String("name"),
+        Rules: []PolicyRule{{
+            Verbs:         c.StringSlice("verb"),
+            Resources:     c.StringSlice("resource"),
+            ResourceNames: c.StringSlice("resource-name"),
+        }},
+    }
+}
+
+// bindingFlags describe who a binding grants a role to.
+func bindingFlags(roleFlags ...cli.Flag) []cli.Flag {
+    return append([]cli.Flag{
+        &cli.StringFlag{
+            Name:     "name",
+            Usage:    "Name of the binding",
+            Required: true,
+        },
+        &cli.StringSliceFlag{
+            Name:  "user",
+            Usage: "User to grant the role to (repeatable)",
+        },
+        &cli.StringSliceFlag{
+            Name:  "group",
+            Usage: "Group to grant the role to (repeatable)",
+        },
+    }, roleFlags...)
+}
+
+func subjects(c *cli.Context) []Subject {
+    var list []Subject
+    for _, name := range c.StringSlice("user") {
+        list = append(list, Subject{Kind: "User", Name: name})
+    }
+    for _, name := range c.StringSlice("group") {
+        list = append(list, Subject{Kind: "Group", Name: name})
+    }
+    return list
+}
+
+// rbacCommands manage the roles and bindings the API server authorizes
+// requests with.
+func rbacCommands() []*cli.Command {
+    commands := []*cli.Command{
+        authCommand(),
+        {
+            Name:  "add-role",
+            Usage: "Create or replace a Role, which grants access to the resources of one namespace",
+            Flags: withOutputFlags(append(roleFlags(), namespaceFlag("role"))...),
+            Action: func(c *cli.Context) error {
+                request := roleRequest(c)
+                request.Namespace = namespaceOf(c)
+                body, err := api.do("POST", "/roles", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "role", "name", "Role saved", body)
+            },
+        },
+        {
+            Name:  "add-clusterrole",
+            Usage: "Create or replace a ClusterRole, which can grant access to every resource",
+            Flags: withOutputFlags(roleFlags()...),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("POST", "/clusterroles", roleRequest(c))
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "clusterrole", "name", "ClusterRole saved", body)
+            },
+        },
+        {
+            Name:  "add-rolebinding",
+            Usage: "Grant a Role, or a ClusterRole, in one namespace to users and groups",
+            Flags: withOutputFlags(bindingFlags(
+                &cli.StringFlag{Name: "role", Usage: "Role to grant"},
+                &cli.StringFlag{Name: "clusterrole", Usage: "ClusterRole to grant instead of a Role"},
+                namespaceFlag("binding"),
+            )...),
+            Action: func(c *cli.Context) error {
+                ref := RoleRef{Kind: "Role", Name: c.String("role")}
+                if c.IsSet("clusterrole") {
+                    ref = RoleRef{Kind: "ClusterRole", Name: c.String("clusterrole")}
+                }
+                request := RoleBindingRequest{Name: c.String("name"), Namespace: namespaceOf(c), RoleRef: ref, Subjects: subjects(c)}
+                body, err := api.do("POST", "/rolebindings", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "rolebinding", "name", "RoleBinding saved", body)
+            },
+        },
+        {
+            Name:  "add-clusterrolebinding",
+            Usage: "Grant a ClusterRole across the cluster to users and groups",
+            Flags: withOutputFlags(bindingFlags(
+                &cli.StringFlag{Name: "clusterrole", Usage: "ClusterRole to grant", Required: true},
+            )...),
+            Action: func(c *cli.Context) error {
+                request := RoleBindingRequest{
+                    Name:     c.String("name"),
+                    RoleRef:  RoleRef{Kind: "ClusterRole", Name: c.String("clusterrole")},
+                    Subjects: subjects(c),
+                }
+                body, err := api.do("POST", "/clusterrolebindings", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "clusterrolebinding", "name", "ClusterRoleBinding saved", body)
+            },
+        },
+    }
+    for _, kind := range []string{"role", "clusterrole", "rolebinding", "clusterrolebinding"} {
+        kind := kind
+        namespaced := kind == "role" || kind == "rolebinding"
+        flags := []cli.Flag{
+            &cli.StringFlag{
+                Name:     "name",
+                Usage:    "Name of the " + kind,
+                Required: true,
+            },
+        }
+        if namespaced {
+            flags = append(flags, namespaceFlag(kind))
+        }
+        commands = append(commands, &cli.Command{
+            Name:  "delete-" + kind,
+            Usage: "Delete a " + kind,
+            Flags: withOutputFlags(flags...),
+            Action: func(c *cli.Context) error {
+                path := "/" + kind + "s/" + url.PathEscape(c.String("name"))
+                if namespace := namespaceOf(c); namespaced && namespace != "" {
+                    path += "?" + url.Values{"namespace": {namespace}}.Encode()
+                }
+                body, err := api.do("DELETE", path, nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, kind, "name", "Deleted", body)
+            },
+        })
+    }
+    return commands
+}
+
+// authCommand asks the API server who the CLI is authenticated as and what
+// it may do.
+func authCommand() *cli.Command {
+    return &cli.Command{
+        Name:  "auth",
+        Usage: "Inspect authentication and authorization",
+        Subcommands: []*cli.Command{
+            {
+                Name:  "whoami",
+                Usage: "Show the user and groups the API server authenticates the CLI as",
+                Action: func(c *cli.Context) error {
+                    body, err := api.do("GET", "/auth/whoami", nil)
+                    if err != nil {
+                        return err
+                    }
+                    var identity struct {
+                        Authenticated bool `json:"authenticated"`
+                        User          struct {
+                            Name   string   `json:"name"`
+                            Groups []string `json:"groups"`
+                        } `json:"user"`
+                    }
+                    if err := json.Unmarshal(body, &identity); err != nil {
+                        return fmt.Errorf("error parsing response: %v", err)
+                    }
+                    if !identity.Authenticated {
+                        fmt.Println("Authentication is disabled on the API server")
+                        return nil
+                    }
+                    fmt.Printf("Username: %s\nGroups:   %s\n", identity.User.Name, orNone(strings.Join(identity.User.Groups, ", ")))
+                    return nil
+                },
+            },
+            {
+                Name:      "can-i",
+                Usage:     "Check whether the CLI's user may perform an action, e.g. \"auth can-i delete nodes\"",
+                ArgsUsage: "<verb> <resource[/subresource]> [name]",
+                Flags:     []cli.Flag{namespaceFlag("action")},
+                Action: func(c *cli.Context) error {
+                    if c.NArg() < 2 || c.NArg() > 3 {
+                        return fmt.Errorf("expected a verb, a resource and optionally a name")
+                    }
+                    resource, subresource, _ := strings.Cut(c.Args().Get(1), "/")
+                    request := map[string]string{
+                        "verb":        c.Args().Get(0),
+                        "resource":    resource,
+                        "subresource": subresource,
+                        "name":        c.Args().Get(2),
+                        "namespace":   namespaceOf(c),
+                    }
+                    body, err := api.do("POST", "/auth/can-i", request)
+                    if err != nil {
+                        return err
+                    }
+                    var review struct {
+                        Allowed bool   `json:"allowed"`
+                        Reason  string `json:"reason"`
+                    }
+                    if err := json.Unmarshal(body, &review); err != nil {
+                        return fmt.Errorf("error parsing response: %v", err)
+                    }
+                    if !review.Allowed {
+                        fmt.Println("no")
+                        return cli.Exit("", 1)
+                    }
+                    fmt.Println("yes")
+                    return nil
+                },
+            },
+        },
+    }
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/urfave/cli/v2"
+)
+
+type ServicePort struct {
+    Name       string `json:"name,omitempty"`
+    Protocol   string `json:"protocol,omitempty"`
+    Port       int    `json:"port"`
+    TargetPort int    `json:"target_port,omitempty"`
+}
+
+type Traffic struct {
+    RequestsPerSecond float64 `json:"requests_per_second"`
+    DurationMillis    int     `json:"duration_ms,omitempty"`
+    SourcePod         string  `json:"source_pod,omitempty"`
+}
+
+type ServiceRequest struct {
+    Name          string        `json:"name"`
+    Namespace     string        `json:"namespace,omitempty"`
+    Selector      string        `json:"selector"`
+    Ports         []ServicePort `json:"ports"`
+    LoadBalancing string        `json:"load_balancing,omitempty"`
+    Traffic       Traffic       `json:"traffic"`
+    ClusterIP     string        `json:"cluster_ip,omitempty"`
+}
+
+var serviceColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "CLUSTER-IP", value: field(".cluster_ip")},
+    {header: "PORT(S)", value: func(obj map[string]interface{}) string {
+        ports, _ := obj["ports"].([]interface{})
+        parts := make([]string, 0, len(ports))
+        for _, p := range ports {
+            port, _ := p.(map[string]interface{})
+            parts = append(parts, field(".port")(port)+"/"+field(".protocol")(port))
+        }
+        return strings.Join(parts, ",")
+    }},
+    {header: "ENDPOINTS", value: func(obj map[string]interface{}) string {
+        return field(".ready_endpoints")(obj) + "/" + strconv.Itoa(len(listField(obj, "endpoints")))
+    }},
+    {header: "RPS", value: field(".traffic.requests_per_second")},
+    {header: "REQUESTS", value: field(".requests.total")},
+    {header: "FAILED", value: field(".requests.failed")},
+    {header: "NAMESPACE", wide: true, value: field(".namespace")},
+    {header: "SELECTOR", wide: true, value: field(".selector")},
+    {header: "LOAD BALANCING", wide: true, value: field(".load_balancing")},
+    {header: "FAILURES", wide: true, value: func(obj map[string]interface{}) string {
+        requests, _ := obj["requests"].(map[string]interface{})
+        failures, _ := requests["failures"].(map[string]interface{})
+        if len(failures) == 0 {
+            return "<none>"
+        }
+        pairs := make([]string, 0, len(failures))
+        for reason, n := range failures {
+            pairs = append(pairs, fmt.Sprintf("%s=%v", reason, n))
+        }
+        sort.Strings(pairs)
+        return strings.Join(pairs, ",")
+    }},
+}
+
+// endpointsColumns show one row per service, like "kubectl get endpoints".
+var endpointsColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "ENDPOINTS", value: func(obj map[string]interface{}) string {
+        var ready []string
+        for _, ep := range listField(obj, "endpoints") {
+            ep, _ := ep.(map[string]interface{})
+            if ep["ready"] == true {
+                ready = append(ready, field(".pod_id")(ep))
+            }
+        }
+        if len(ready) == 0 {
+            return "<none>"
+        }
+        return strings.Join(ready, ",")
+    }},
+    {header: "NOT READY", wide: true, value: func(obj map[string]interface{}) string {
+        var notReady []string
+        for _, ep := range listField(obj, "endpoints") {
+            ep, _ := ep.(map[string]interface{})
+            if ep["ready"] != true {
+                notReady = append(notReady, field(".pod_id")(ep))
+            }
+        }
+        if len(notReady) == 0 {
+            return "<none>"
+        }
+        return strings.Join(notReady, ",")
+    }},
+}
+
+var dnsRecordColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "TYPE", value: field(".type")},
+    {header: "VALUE", value: func(obj map[string]interface{}) string {
+        if obj["type"] == "SRV" {
+            return field(".target")(obj) + ":" + field(".port")(obj)
+        }
+        return field(".ip")(obj)
+    }},
+}
+
+// listDNSRecords prints the records served by the cluster DNS.
+func listDNSRecords(c *cli.Context) error {
+    body, err := api.do("GET", "/dns", nil)
+    if err != nil {
+        return err
+    }
+    var zone struct {
+        Records []interface{} `json:"records"`
+    }
+    if err := json.Unmarshal(body, &zone); err != nil {
+        return fmt.Errorf("error parsing response: %v", err)
+    }
+    if sortBy := optionString(c, "sort-by"); sortBy != "" {
+        if err := sortItems(zone.Records, sortBy); err != nil {
+            return err
+        }
+    }
+    list := map[string]interface{}{"kind": "List", "items": zone.Records}
+    return printData(c, "dnsrecord", "name", list, zone.Records, dnsRecordColumns)
+}
+
+// listField returns the list stored under key, or nil.
+func listField(obj map[string]interface{}, key string) []interface{} {
+    list, _ := obj[key].([]interface{})
+    return list
+}
+
+// parseServicePorts parses ports given as [name=]port[:targetPort][/protocol],
+// e.g. "80", "http=80:8080" or "53/UDP".
+func parseServicePorts(specs []string) ([]ServicePort, error) {
+    ports := make([]ServicePort, 0, len(specs))
+    for _, spec := range specs {
+        var p ServicePort
+        rest := spec
+        if name, value, ok := strings.Cut(rest, "="); ok {
+            p.Name, rest = name, value
+        }
+        if value, protocol, ok := strings.Cut(rest, "/"); ok {
+            rest, p.Protocol = value, strings.ToUpper(protocol)
+        }
+        port, target, hasTarget := strings.Cut(rest, ":")
+        var err error
+        if p.Port, err = strconv.Atoi(port); err != nil {
+            return nil, fmt.Errorf("invalid port %q, expected [name=]port[:targetPort][/protocol]", spec)
+        }
+        if hasTarget {
+            if p.TargetPort, err = strconv.Atoi(target); err != nil {
+                return nil, fmt.Errorf("invalid port %q, expected [name=]port[:targetPort][/protocol]", spec)
+            }
+        }
+        ports = append(ports, p)
+    }
+    return ports, nil
+}
+
+// trafficFlags describe the synthetic requests sent to a service.
+func trafficFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.Float64Flag{
+            Name:  "rps",
+            Usage: "Synthetic requests per second sent to the service",
+        },
+        &cli.DurationFlag{
+            Name:  "request-duration",
+            Usage: "How long each request holds its connection, e.g. 250ms (default 100ms)",
+        },
+        &cli.StringFlag{
+            Name:  "source-pod",
+            Usage: "Pod sending the requests, so they cross its node's links (default: from outside through the control plane)",
+        },
+    }
+}
+
+func traffic(c *cli.Context) Traffic {
+    return Traffic{
+        RequestsPerSecond: c.Float64("rps"),
+        DurationMillis:    int(c.Duration("request-duration").Milliseconds()),
+        SourcePod:         c.String("source-pod"),
+    }
+}
+
+// serviceCommands manage services and the traffic sent to them.
+func serviceCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-service",
+            Usage: "Create or replace a service balancing requests over the pods it selects",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the service",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "namespace",
+                    Usage: "Namespace qualifying the service's DNS name (default \"default\")",
+                },
+                &cli.StringFlag{
+                    Name:     "selector",
+                    Usage:    "Label selector for the service's pods, e.g. app=web",
+                    Required: true,
+                },
+                &cli.StringSliceFlag{
+                    Name:     "port",
+                    Usage:    "Port as [name=]port[:targetPort][/protocol] (repeatable)",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "load-balancing",
+                    Usage: "Load balancing algorithm (round_robin, least_connections, random)",
+                },
+                &cli.StringFlag{
+                    Name:  "cluster-ip",
+                    Usage: "Cluster IP to use instead of allocating one from 10.96.0.0/16",
+                },
+            }, trafficFlags()...)...),
+            Action: func(c *cli.Context) error {
+                ports, err := parseServicePorts(c.StringSlice("port"))
+                if err != nil {
+                    return err
+                }
+                request := ServiceRequest{
+                    Name:          c.String("name"),
+                    Namespace:     c.String("namespace"),
+                    Selector:      c.String("selector"),
+                    Ports:         ports,
+                    LoadBalancing: c.String("load-balancing"),
+                    Traffic:       traffic(c),
+                    ClusterIP:     c.String("cluster-ip"),
+                }
+                body, err := api.do("POST", "/services", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "service", "name", "Service saved", body)
+            },
+        },
+        {
+            Name:  "delete-service",
+            Usage: "Delete a service",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the service",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/services/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "service", "name", "Service deleted", body)
+            },
+        },
+        {
+            Name:  "set-traffic",
+            Usage: "Set the synthetic traffic sent to a service",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the service",
+                    Required: true,
+                },
+            }, trafficFlags()...)...),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("PUT", "/services/"+url.PathEscape(c.String("name"))+"/traffic", traffic(c))
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "service", "name", "Service traffic updated", body)
+            },
+        },
+        {
+            Name:  "reset-service-stats",
+            Usage: "Clear the request counters of a service",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the service",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("POST", "/services/"+url.PathEscape(c.String("name"))+"/reset", nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "service", "name", "Service stats reset", body)
+            },
+        },
+    }
+}
+
+type ServiceDescription struct {
+    ServiceRequest
+    Endpoints []struct {
+        PodID  string `json:"pod_id"`
+        NodeID string `json:"node_id"`
+        Ready  bool   `json:"ready"`
+    } `json:"endpoints"`
+    ReadyEndpoints int `json:"ready_endpoints"`
+    Requests       struct {
+        Total     int64            `json:"total"`
+        Succeeded int64            `json:"succeeded"`
+        Failed    int64            `json:"failed"`
+        InFlight  int              `json:"in_flight"`
+        Failures  map[string]int64 `json:"failures"`
+    } `json:"requests"`
+    Pods []struct {
+        PodID             string `json:"pod_id"`
+        NodeID            string `json:"node_id"`
+        Requests          int64  `json:"requests"`
+        Failures          int64  `json:"failures"`
+        ActiveConnections int    `json:"active_connections"`
+    } `json:"pods"`
+}
+
+// describeServiceCommand shows a service's endpoints and the requests each
+// of its pods handled.
+func describeServiceCommand() *cli.Command {
+    return &cli.Command{
+        Name:      "service",
+        Aliases:   []string{"svc"},
+        Usage:     "Show a service's endpoints and per-pod request counts",
+        ArgsUsage: "<name>",
+        Flags:     withOutputFlags(),
+        Action: func(c *cli.Context) error {
+            if c.NArg() != 1 {
+                return fmt.Errorf("expected exactly one service name")
+            }
+            body, err := api.do("GET", "/services/"+url.PathEscape(c.Args().First()), nil)
+            if err != nil {
+                return err
+            }
+            if format := optionString(c, "output"); format != "" {
+                return printItem(c, "service", body, nil)
+            }
+            var desc ServiceDescription
+            if err := json.Unmarshal(body, &desc); err != nil {
+                return fmt.Errorf("error parsing response: %v", err)
+            }
+
+            fmt.Printf("Name:           %s\n", desc.Name)
+            fmt.Printf("Namespace:      %s\n", desc.Namespace)
+            fmt.Printf("DNS name:       %s.%s.svc.cluster.local\n", desc.Name, desc.Namespace)
+            fmt.Printf("Selector:       %s\n", desc.Selector)
+            fmt.Printf("Cluster IP:     %s\n", desc.ClusterIP)
+            for _, p := range desc.Ports {
+                fmt.Printf("Port:           %s %d/%s -> %d\n", orNone(p.Name), p.Port, p.Protocol, p.TargetPort)
+            }
+            fmt.Printf("Load balancing: %s\n", desc.LoadBalancing)
+            fmt.Printf("Traffic:        %g req/s, %dms each\n", desc.Traffic.RequestsPerSecond, desc.Traffic.DurationMillis)
+            fmt.Printf("Endpoints:      %d ready of %d\n", desc.ReadyEndpoints, len(desc.Endpoints))
+            fmt.Printf("Requests:       %d total, %d succeeded, %d failed, %d in flight\n",
+                desc.Requests.Total, desc.Requests.Succeeded, desc.Requests.Failed, desc.Requests.InFlight)
+            reasons := make([]string, 0, len(desc.Requests.Failures))
+            for reason := range desc.Requests.Failures {
+                reasons = append(reasons, reason)
+            }
+            sort.Strings(reasons)
+            for _, reason := range reasons {
+                fmt.Printf("  %-20s %d\n", reason, desc.Requests.Failures[reason])
+            }
+            ready := make(map[string]bool)
+            for _, ep := range desc.Endpoints {
+                ready[ep.PodID] = ep.Ready
+            }
+            fmt.Printf("Pods:           (%d with traffic)\n", len(desc.Pods))
+            for _, p := range desc.Pods {
+                state := "gone"
+                if r, ok := ready[p.PodID]; ok && r {
+                    state = "ready"
+                } else if ok {
+                    state = "not ready"
+                }
+                fmt.Printf("  %-45s %-9s %8d requests %6d failed %4d active  on %s\n",
+                    p.PodID, state, p.Requests, p.Failures, p.ActiveConnections, p.NodeID)
+            }
+            return nil
+        },
+    }
+}
+
+// orNone renders an empty string as <none>.
+func orNone(s string) string {
+    if s == "" {
+        return "<none>"
+    }
+    return s
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+    "net/url"
+
+    "github.com/urfave/cli/v2"
+)
+
+type PVRequest struct {
+    Name          string   `json:"name"`
+    CapacityGiB   int      `json:"capacity_gib"`
+    AccessModes   []string `json:"access_modes,omitempty"`
+    StorageClass  string   `json:"storage_class,omitempty"`
+    Node          string   `json:"node,omitempty"`
+    ReclaimPolicy string   `json:"reclaim_policy,omitempty"`
+}
+
+type PVCRequest struct {
+    Name         string   `json:"name"`
+    StorageGiB   int      `json:"storage_gib"`
+    AccessModes  []string `json:"access_modes,omitempty"`
+    StorageClass string   `json:"storage_class,omitempty"`
+}
+
+type StorageClassRequest struct {
+    Name              string `json:"name"`
+    VolumeBindingMode string `json:"volume_binding_mode,omitempty"`
+}
+
+var pvColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "CAPACITY", value: func(obj map[string]interface{}) string {
+        return field(".capacity_gib")(obj) + "Gi"
+    }},
+    {header: "ACCESS MODES", value: field(".access_modes[*]")},
+    {header: "RECLAIM POLICY", value: field(".reclaim_policy")},
+    {header: "STATUS", value: field(".phase")},
+    {header: "CLAIM", value: field(".claim_ref")},
+    {header: "STORAGECLASS", value: field(".storage_class")},
+    {header: "NODE", wide: true, value: field(".node")},
+    {header: "AGE", wide: true, value: age(".created_at")},
+}
+
+var pvcColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "STATUS", value: field(".phase")},
+    {header: "VOLUME", value: field(".volume_name")},
+    {header: "CAPACITY", value: func(obj map[string]interface{}) string {
+        return field(".storage_gib")(obj) + "Gi"
+    }},
+    {header: "ACCESS MODES", value: field(".access_modes[*]")},
+    {header: "STORAGECLASS", value: field(".storage_class")},
+    {header: "USED BY", wide: true, value: field(".used_by[*]")},
+    {header: "OWNER", wide: true, value: field(".owner")},
+    {header: "AGE", wide: true, value: age(".created_at")},
+}
+
+var storageClassColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "VOLUMEBINDINGMODE", value: field(".volume_binding_mode")},
+}
+
+// storageCommands manage persistent volumes, claims and storage classes.
+func storageCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-pv",
+            Usage: "Create a persistent volume",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the volume",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "capacity",
+                    Usage:    "Capacity in GiB",
+                    Required: true,
+                },
+                &cli.StringSliceFlag{
+                    Name:  "access-mode",
+                    Usage: "ReadWriteOnce (default), ReadOnlyMany or ReadWriteMany (repeatable)",
+                },
+                &cli.StringFlag{
+                    Name:  "storage-class",
+                    Usage: "Storage class of the volume",
+                },
+                &cli.StringFlag{
+                    Name:  "node",
+                    Usage: "Make this a local volume on the node with this ID",
+                },
+                &cli.StringFlag{
+                    Name:  "reclaim-policy",
+                    Usage: "What happens when its claim is deleted: Retain (default) or Delete",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := PVRequest{
+                    Name:          c.String("name"),
+                    CapacityGiB:   c.Int("capacity"),
+                    AccessModes:   c.StringSlice("access-mode"),
+                    StorageClass:  c.String("storage-class"),
+                    Node:          c.String("node"),
+                    ReclaimPolicy: c.String("reclaim-policy"),
+                }
+                body, err := api.do("POST", "/pvs", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "pv", "name", "PersistentVolume created", body)
+            },
+        },
+        {
+            Name:  "delete-pv",
+            Usage: "Delete a persistent volume that is not bound",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the volume",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/pvs/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "pv", "name", "PersistentVolume deleted", body)
+            },
+        },
+        {
+            Name:  "add-pvc",
+            Usage: "Create a persistent volume claim",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the claim",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "storage",
+                    Usage:    "Requested size in GiB",
+                    Required: true,
+                },
+                &cli.StringSliceFlag{
+                    Name:  "access-mode",
+                    Usage: "ReadWriteOnce (default), ReadOnlyMany or ReadWriteMany (repeatable)",
+                },
+                &cli.StringFlag{
+                    Name:  "storage-class",
+                    Usage: "Storage class to take a volume from",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := PVCRequest{
+                    Name:         c.String("name"),
+                    StorageGiB:   c.Int("storage"),
+                    AccessModes:  c.StringSlice("access-mode"),
+                    StorageClass: c.String("storage-class"),
+                }
+                body, err := api.do("POST", "/pvcs", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "pvc", "name", "PersistentVolumeClaim created", body)
+            },
+        },
+        {
+            Name:  "delete-pvc",
+            Usage: "Delete a persistent volume claim that no pod uses",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the claim",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/pvcs/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "pvc", "name", "PersistentVolumeClaim deleted", body)
+            },
+        },
+        {
+            Name:  "add-storageclass",
+            Usage: "Create or replace a storage class",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the storage class",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "binding-mode",
+                    Usage: "When claims are bound: Immediate (default) or WaitForFirstConsumer",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := StorageClassRequest{
+                    Name:              c.String("name"),
+                    VolumeBindingMode: c.String("binding-mode"),
+                }
+                body, err := api.do("POST", "/storageclasses", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "storageclass", "name", "StorageClass saved", body)
+            },
+        },
+        {
+            Name:  "delete-storageclass",
+            Usage: "Delete a storage class",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the storage class",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/storageclasses/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "storageclass", "name", "StorageClass deleted", body)
+            },
+        },
+    }
+}
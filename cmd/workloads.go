@@ -0,0 +1,688 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "cluster-sim/internal/labels"
+
+    "github.com/urfave/cli/v2"
+)
+
+type PodTemplate struct {
+    CPUs               int               `json:"cpus"`
+    Algorithm          string            `json:"algorithm,omitempty"`
+    Labels             map[string]string `json:"labels,omitempty"`
+    Tolerations        []Toleration      `json:"tolerations,omitempty"`
+    GracePeriodSeconds int               `json:"termination_grace_period_seconds"`
+    RunSeconds         int               `json:"run_seconds,omitempty"`
+    Command            []string          `json:"command,omitempty"`
+    FailureRate        float64           `json:"failure_rate,omitempty"`
+}
+
+type LoadModel struct {
+    Type          string  `json:"type,omitempty"`
+    Load          float64 `json:"load"`
+    Amplitude     float64 `json:"amplitude,omitempty"`
+    PeriodSeconds int     `json:"period_seconds,omitempty"`
+}
+
+type PodGroupRequest struct {
+    Name     string      `json:"name"`
+    Replicas int         `json:"replicas"`
+    Template PodTemplate `json:"template"`
+    Load     LoadModel   `json:"load"`
+}
+
+type DaemonSetRequest struct {
+    Name           string      `json:"name"`
+    NodeSelector   string      `json:"node_selector,omitempty"`
+    Template       PodTemplate `json:"template"`
+    UpdateStrategy struct {
+        Type           string `json:"type,omitempty"`
+        MaxUnavailable int    `json:"max_unavailable,omitempty"`
+    } `json:"update_strategy"`
+}
+
+type VolumeClaimTemplate struct {
+    Name         string `json:"name"`
+    StorageClass string `json:"storage_class,omitempty"`
+    StorageGiB   int    `json:"storage_gib"`
+}
+
+type StatefulSetRequest struct {
+    Name                string      `json:"name"`
+    Replicas            int         `json:"replicas"`
+    Template            PodTemplate `json:"template"`
+    PodManagementPolicy string      `json:"pod_management_policy,omitempty"`
+    UpdateStrategy      struct {
+        Type      string `json:"type,omitempty"`
+        Partition int    `json:"partition,omitempty"`
+    } `json:"update_strategy"`
+    VolumeClaimTemplates []VolumeClaimTemplate `json:"volume_claim_templates,omitempty"`
+}
+
+type ScalingPolicy struct {
+    Type          string `json:"type"`
+    Value         int    `json:"value"`
+    PeriodSeconds int    `json:"period_seconds"`
+}
+
+type ScalingRules struct {
+    StabilizationWindowSeconds *int            `json:"stabilization_window_seconds,omitempty"`
+    SelectPolicy               string          `json:"select_policy,omitempty"`
+    Policies                   []ScalingPolicy `json:"policies,omitempty"`
+}
+
+type HPARequest struct {
+    Name              string `json:"name"`
+    Target            string `json:"target"`
+    MinReplicas       int    `json:"min_replicas"`
+    MaxReplicas       int    `json:"max_replicas"`
+    TargetUtilization int    `json:"target_utilization"`
+    Behavior          struct {
+        ScaleUp   *ScalingRules `json:"scale_up,omitempty"`
+        ScaleDown *ScalingRules `json:"scale_down,omitempty"`
+    } `json:"behavior"`
+}
+
+var podGroupColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "DESIRED", value: field(".replicas")},
+    {header: "CURRENT", value: field(".current_replicas")},
+    {header: "READY", value: field(".ready_replicas")},
+    {header: "LOAD", value: field(".current_load")},
+    {header: "UTILIZATION", value: func(obj map[string]interface{}) string {
+        return field(".average_utilization")(obj) + "%"
+    }},
+    {header: "CPUs/POD", wide: true, value: field(".template.cpus")},
+    {header: "LABELS", wide: true, value: func(obj map[string]interface{}) string {
+        template, _ := obj["template"].(map[string]interface{})
+        return labelsColumn(template)
+    }},
+}
+
+var daemonSetColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "DESIRED", value: field(".desired_number_scheduled")},
+    {header: "CURRENT", value: field(".current_number_scheduled")},
+    {header: "READY", value: field(".number_ready")},
+    {header: "UP-TO-DATE", value: field(".updated_number_scheduled")},
+    {header: "NODE SELECTOR", value: func(obj map[string]interface{}) string {
+        if sel := field(".node_selector")(obj); sel != "<none>" {
+            return sel
+        }
+        return "<all>"
+    }},
+    {header: "MISSCHEDULED", wide: true, value: field(".number_misscheduled")},
+    {header: "STRATEGY", wide: true, value: field(".update_strategy.type")},
+    {header: "CPUs/POD", wide: true, value: field(".template.cpus")},
+}
+
+var statefulSetColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "READY", value: func(obj map[string]interface{}) string {
+        return field(".ready_replicas")(obj) + "/" + field(".replicas")(obj)
+    }},
+    {header: "UP-TO-DATE", value: field(".updated_replicas")},
+    {header: "POLICY", value: field(".pod_management_policy")},
+    {header: "PODS", wide: true, value: field(".pods[*]")},
+    {header: "STRATEGY", wide: true, value: field(".update_strategy.type")},
+    {header: "PARTITION", wide: true, value: field(".update_strategy.partition")},
+    {header: "REVISION", wide: true, value: field(".update_revision")},
+}
+
+// parseVolumeClaims parses claim templates written as data=10Gi.
+func parseVolumeClaims(specs []string, storageClass string) ([]VolumeClaimTemplate, error) {
+    var templates []VolumeClaimTemplate
+    for _, spec := range specs {
+        name, size, ok := strings.Cut(spec, "=")
+        gib, err := strconv.Atoi(strings.TrimSuffix(size, "Gi"))
+        if !ok || name == "" || err != nil {
+            return nil, fmt.Errorf("invalid volume claim %q, expected <name>=<size>Gi", spec)
+        }
+        templates = append(templates, VolumeClaimTemplate{Name: name, StorageClass: storageClass, StorageGiB: gib})
+    }
+    return templates, nil
+}
+
+var hpaColumns = []column{
+    {header: "NAME", value: field(".name")},
+    {header: "TARGET", value: func(obj map[string]interface{}) string {
+        return "podgroup/" + field(".target")(obj)
+    }},
+    {header: "UTILIZATION", value: func(obj map[string]interface{}) string {
+        current := "<unknown>"
+        if v := field(".status.current_utilization")(obj); v != "<none>" {
+            current = v + "%"
+        }
+        return current + "/" + field(".target_utilization")(obj) + "%"
+    }},
+    {header: "MIN", value: field(".min_replicas")},
+    {header: "MAX", value: field(".max_replicas")},
+    {header: "REPLICAS", value: field(".status.current_replicas")},
+    {header: "DESIRED", wide: true, value: field(".status.desired_replicas")},
+    {header: "LAST SCALE", wide: true, value: func(obj map[string]interface{}) string {
+        if field(".status.last_scale_time")(obj) == "<none>" {
+            return "<none>"
+        }
+        return age(".status.last_scale_time")(obj)
+    }},
+}
+
+// parseScalingPolicies parses policies written as Pods=4/15s or Percent=100/1m.
+func parseScalingPolicies(specs []string) ([]ScalingPolicy, error) {
+    var policies []ScalingPolicy
+    for _, spec := range specs {
+        kind, rest, ok := strings.Cut(spec, "=")
+        value, period, ok2 := strings.Cut(rest, "/")
+        if !ok || !ok2 {
+            return nil, fmt.Errorf("invalid policy %q, expected Pods=<n>/<period> or Percent=<n>/<period>", spec)
+        }
+        n, err := strconv.Atoi(value)
+        if err != nil {
+            return nil, fmt.Errorf("invalid policy value in %q", spec)
+        }
+        d, err := time.ParseDuration(period)
+        if err != nil {
+            return nil, fmt.Errorf("invalid policy period in %q: %v", spec, err)
+        }
+        policies = append(policies, ScalingPolicy{Type: kind, Value: n, PeriodSeconds: int(d.Seconds())})
+    }
+    return policies, nil
+}
+
+// scalingRules builds the rules for one direction from its flags, or nil
+// when none are set so the server defaults apply.
+func scalingRules(c *cli.Context, direction string) (*ScalingRules, error) {
+    window, selectPolicy, policy := direction+"-stabilization", direction+"-select", direction+"-policy"
+    if !c.IsSet(window) && !c.IsSet(selectPolicy) && !c.IsSet(policy) {
+        return nil, nil
+    }
+    rules := &ScalingRules{SelectPolicy: c.String(selectPolicy)}
+    if c.IsSet(window) {
+        seconds := int(c.Duration(window).Seconds())
+        rules.StabilizationWindowSeconds = &seconds
+    }
+    policies, err := parseScalingPolicies(c.StringSlice(policy))
+    if err != nil {
+        return nil, err
+    }
+    rules.Policies = policies
+    return rules, nil
+}
+
+// scalingFlags are the behavior flags for one direction ("scale-up" or "scale-down").
+func scalingFlags(direction string) []cli.Flag {
+    return []cli.Flag{
+        &cli.DurationFlag{
+            Name:  direction + "-stabilization",
+            Usage: "Stabilization window for " + direction + ", e.g. 5m",
+        },
+        &cli.StringFlag{
+            Name:  direction + "-select",
+            Usage: "Which " + direction + " policy wins: Max, Min or Disabled",
+        },
+        &cli.StringSliceFlag{
+            Name:  direction + "-policy",
+            Usage: "Limit for " + direction + " as Pods=<n>/<period> or Percent=<n>/<period> (repeatable)",
+        },
+    }
+}
+
+// loadFlags describe a load model.
+func loadFlags() []cli.Flag {
+    return []cli.Flag{
+        &cli.Float64Flag{
+            Name:  "load",
+            Usage: "Simulated load in CPUs, spread over the running pods (the mean for a sine model)",
+        },
+        &cli.StringFlag{
+            Name:  "model",
+            Usage: "Load model: constant or sine",
+            Value: "constant",
+        },
+        &cli.Float64Flag{
+            Name:  "amplitude",
+            Usage: "Amplitude of a sine load, in CPUs",
+        },
+        &cli.DurationFlag{
+            Name:  "period",
+            Usage: "Period of a sine load, e.g. 10m",
+        },
+    }
+}
+
+func loadModel(c *cli.Context) LoadModel {
+    return LoadModel{
+        Type:          c.String("model"),
+        Load:          c.Float64("load"),
+        Amplitude:     c.Float64("amplitude"),
+        PeriodSeconds: int(c.Duration("period").Seconds()),
+    }
+}
+
+// workloadCommands manage pod groups, DaemonSets, StatefulSets and
+// horizontal pod autoscalers.
+func workloadCommands() []*cli.Command {
+    return []*cli.Command{
+        {
+            Name:  "add-podgroup",
+            Usage: "Create or replace a group of identical pods",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the pod group",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "replicas",
+                    Usage: "Number of pods",
+                    Value: 1,
+                },
+                &cli.IntFlag{
+                    Name:     "cpus",
+                    Usage:    "Number of CPUs required by each pod",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "algorithm",
+                    Usage: "Scheduling algorithm (first_fit, best_fit, worst_fit) or scheduler profile",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "label",
+                    Usage: "Label to set on the pods as key=value (repeatable)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "toleration",
+                    Usage: "Taint the pods tolerate as key=value[:Effect] or key[:Effect] (repeatable)",
+                },
+            }, loadFlags()...)...),
+            Action: func(c *cli.Context) error {
+                podLabels, err := labels.ParseSet(c.StringSlice("label"))
+                if err != nil {
+                    return err
+                }
+                tolerations, err := parseTolerations(c.StringSlice("toleration"))
+                if err != nil {
+                    return err
+                }
+                request := PodGroupRequest{
+                    Name:     c.String("name"),
+                    Replicas: c.Int("replicas"),
+                    Template: PodTemplate{
+                        CPUs:        c.Int("cpus"),
+                        Algorithm:   c.String("algorithm"),
+                        Labels:      podLabels,
+                        Tolerations: tolerations,
+                    },
+                    Load: loadModel(c),
+                }
+                body, err := api.do("POST", "/podgroups", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "podgroup", "name", "Pod group saved", body)
+            },
+        },
+        {
+            Name:  "delete-podgroup",
+            Usage: "Delete a pod group and its pods",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the pod group",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/podgroups/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "podgroup", "name", "Pod group deleted", body)
+            },
+        },
+        {
+            Name:  "scale",
+            Usage: "Change the number of pods in a pod group",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the pod group",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "replicas",
+                    Usage:    "New number of pods",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := map[string]int{"replicas": c.Int("replicas")}
+                body, err := api.do("PUT", "/podgroups/"+url.PathEscape(c.String("name"))+"/scale", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "podgroup", "name", "Pod group scaled", body)
+            },
+        },
+        {
+            Name:  "set-load",
+            Usage: "Set the simulated load of a pod group",
+            Flags: withOutputFlags(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the pod group",
+                    Required: true,
+                },
+            }, loadFlags()...)...),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("PUT", "/podgroups/"+url.PathEscape(c.String("name"))+"/load", loadModel(c))
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "podgroup", "name", "Pod group load updated", body)
+            },
+        },
+        {
+            Name:  "delete-pod",
+            Usage: "Delete a pod",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "pod-id",
+                    Usage:    "ID of the pod to delete",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/pods/"+url.PathEscape(c.String("pod-id")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "pod", "pod_id", "Pod deleted", body)
+            },
+        },
+        {
+            Name:  "add-daemonset",
+            Usage: "Create or replace a DaemonSet that runs one pod on every matching node",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the DaemonSet",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "cpus",
+                    Usage:    "Number of CPUs required by each pod",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "node-selector",
+                    Usage: "Label selector for the nodes to run on, e.g. pool=general (default all nodes)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "label",
+                    Usage: "Label to set on the pods as key=value (repeatable)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "toleration",
+                    Usage: "Taint the pods tolerate as key=value[:Effect] or key[:Effect] (repeatable)",
+                },
+                &cli.StringFlag{
+                    Name:  "update-strategy",
+                    Usage: "How a changed template is rolled out: RollingUpdate or OnDelete",
+                },
+                &cli.IntFlag{
+                    Name:  "max-unavailable",
+                    Usage: "Nodes that may be without a running pod during a rolling update (default 1)",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                podLabels, err := labels.ParseSet(c.StringSlice("label"))
+                if err != nil {
+                    return err
+                }
+                tolerations, err := parseTolerations(c.StringSlice("toleration"))
+                if err != nil {
+                    return err
+                }
+                request := DaemonSetRequest{
+                    Name:         c.String("name"),
+                    NodeSelector: c.String("node-selector"),
+                    Template: PodTemplate{
+                        CPUs:        c.Int("cpus"),
+                        Labels:      podLabels,
+                        Tolerations: tolerations,
+                    },
+                }
+                request.UpdateStrategy.Type = c.String("update-strategy")
+                request.UpdateStrategy.MaxUnavailable = c.Int("max-unavailable")
+                body, err := api.do("POST", "/daemonsets", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "daemonset", "name", "DaemonSet saved", body)
+            },
+        },
+        {
+            Name:  "delete-daemonset",
+            Usage: "Delete a DaemonSet and its pods",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the DaemonSet",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/daemonsets/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "daemonset", "name", "DaemonSet deleted", body)
+            },
+        },
+        {
+            Name:  "add-statefulset",
+            Usage: "Create or replace a StatefulSet of pods with stable names and claims",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the StatefulSet; pods are named <name>-0, <name>-1, ...",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "replicas",
+                    Usage: "Number of pods",
+                    Value: 1,
+                },
+                &cli.IntFlag{
+                    Name:     "cpus",
+                    Usage:    "Number of CPUs required by each pod",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:  "algorithm",
+                    Usage: "Scheduling algorithm (first_fit, best_fit, worst_fit) or scheduler profile",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "label",
+                    Usage: "Label to set on the pods as key=value (repeatable)",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "toleration",
+                    Usage: "Taint the pods tolerate as key=value[:Effect] or key[:Effect] (repeatable)",
+                },
+                &cli.StringFlag{
+                    Name:  "pod-management-policy",
+                    Usage: "OrderedReady to start and stop pods one at a time, or Parallel",
+                },
+                &cli.StringFlag{
+                    Name:  "update-strategy",
+                    Usage: "How a changed template is rolled out: RollingUpdate or OnDelete",
+                },
+                &cli.IntFlag{
+                    Name:  "partition",
+                    Usage: "Only roll out a changed template to pods with at least this ordinal",
+                },
+                &cli.StringSliceFlag{
+                    Name:  "volume-claim",
+                    Usage: "Claim made for every pod as <name>=<size>Gi, e.g. data=10Gi (repeatable)",
+                },
+                &cli.StringFlag{
+                    Name:  "storage-class",
+                    Usage: "Storage class of the volume claims",
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                podLabels, err := labels.ParseSet(c.StringSlice("label"))
+                if err != nil {
+                    return err
+                }
+                tolerations, err := parseTolerations(c.StringSlice("toleration"))
+                if err != nil {
+                    return err
+                }
+                claims, err := parseVolumeClaims(c.StringSlice("volume-claim"), c.String("storage-class"))
+                if err != nil {
+                    return err
+                }
+                request := StatefulSetRequest{
+                    Name:     c.String("name"),
+                    Replicas: c.Int("replicas"),
+                    Template: PodTemplate{
+                        CPUs:        c.Int("cpus"),
+                        Algorithm:   c.String("algorithm"),
+                        Labels:      podLabels,
+                        Tolerations: tolerations,
+                    },
+                    PodManagementPolicy:  c.String("pod-management-policy"),
+                    VolumeClaimTemplates: claims,
+                }
+                request.UpdateStrategy.Type = c.String("update-strategy")
+                request.UpdateStrategy.Partition = c.Int("partition")
+                body, err := api.do("POST", "/statefulsets", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "statefulset", "name", "StatefulSet saved", body)
+            },
+        },
+        {
+            Name:  "scale-statefulset",
+            Usage: "Change the number of pods in a StatefulSet",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the StatefulSet",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "replicas",
+                    Usage:    "New number of pods",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                request := map[string]int{"replicas": c.Int("replicas")}
+                body, err := api.do("PUT", "/statefulsets/"+url.PathEscape(c.String("name"))+"/scale", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "statefulset", "name", "StatefulSet scaled", body)
+            },
+        },
+        {
+            Name:  "delete-statefulset",
+            Usage: "Delete a StatefulSet and its pods, keeping their volume claims",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the StatefulSet",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/statefulsets/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "statefulset", "name", "StatefulSet deleted", body)
+            },
+        },
+        {
+            Name:  "add-hpa",
+            Usage: "Create or replace a horizontal pod autoscaler for a pod group",
+            Flags: withOutputFlags(append(append([]cli.Flag{
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the autoscaler",
+                    Required: true,
+                },
+                &cli.StringFlag{
+                    Name:     "target",
+                    Usage:    "Pod group to scale",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:  "min",
+                    Usage: "Minimum number of replicas",
+                    Value: 1,
+                },
+                &cli.IntFlag{
+                    Name:     "max",
+                    Usage:    "Maximum number of replicas",
+                    Required: true,
+                },
+                &cli.IntFlag{
+                    Name:     "cpu-percent",
+                    Usage:    "Target average CPU utilization, in percent of requests",
+                    Required: true,
+                },
+            }, scalingFlags("scale-up")...), scalingFlags("scale-down")...)...),
+            Action: func(c *cli.Context) error {
+                request := HPARequest{
+                    Name:              c.String("name"),
+                    Target:            c.String("target"),
+                    MinReplicas:       c.Int("min"),
+                    MaxReplicas:       c.Int("max"),
+                    TargetUtilization: c.Int("cpu-percent"),
+                }
+                var err error
+                if request.Behavior.ScaleUp, err = scalingRules(c, "scale-up"); err != nil {
+                    return err
+                }
+                if request.Behavior.ScaleDown, err = scalingRules(c, "scale-down"); err != nil {
+                    return err
+                }
+                body, err := api.do("POST", "/hpas", request)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "hpa", "name", "Horizontal pod autoscaler saved", body)
+            },
+        },
+        {
+            Name:  "delete-hpa",
+            Usage: "Delete a horizontal pod autoscaler",
+            Flags: withOutputFlags(
+                &cli.StringFlag{
+                    Name:     "name",
+                    Usage:    "Name of the autoscaler",
+                    Required: true,
+                },
+            ),
+            Action: func(c *cli.Context) error {
+                body, err := api.do("DELETE", "/hpas/"+url.PathEscape(c.String("name")), nil)
+                if err != nil {
+                    return err
+                }
+                return printResult(c, "hpa", "name", "Horizontal pod autoscaler deleted", body)
+            },
+        },
+    }
+}
@@ -0,0 +1,259 @@
+// Package admission reviews pods before they are created. Mutating plugins
+// run first and may change the pod, validating plugins then see the final
+// pod and may reject it, as in Kubernetes admission control. The built-in
+// plugins are followed by the webhooks of each kind, which are called over
+// HTTP with AdmissionReview payloads.
+package admission
+
+import (
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is returned when a namespace, quota or webhook does not exist.
+var ErrNotFound = errors.New("not found")
+
+var logger = logging.For("admission")
+
+// Names of the built-in plugins, in the order they run.
+const (
+	PluginNamespaceDefault   = "NamespaceDefault"
+	PluginDefaultRequests    = "DefaultRequests"
+	PluginDefaultTolerations = "DefaultTolerations"
+	PluginPositiveResources  = "PositiveResources"
+	PluginNamespaceExists    = "NamespaceExists"
+	PluginResourceQuota      = "ResourceQuota"
+)
+
+// Attributes describe the request a pod is reviewed for.
+type Attributes struct {
+	Operation string     // CREATE
+	Namespace string     // after defaulting
+	Name      string     // "" when the ID is generated
+	User      *auth.User // nil for pods created by controllers
+	DryRun    bool
+}
+
+// Mutator may change a pod before it is created.
+type Mutator interface {
+	Name() string
+	Mutate(spec *node.PodSpec, attrs Attributes) error
+}
+
+// Validator may reject a pod. It sees the pod after every mutator ran.
+type Validator interface {
+	Name() string
+	Validate(spec node.PodSpec, attrs Attributes) error
+}
+
+// Config sets up the chain. It is loaded from CLUSTER_SIM_ADMISSION_CONFIG.
+type Config struct {
+	// Disabled names built-in plugins that do not run.
+	Disabled []string `yaml:"disabled" json:"disabled,omitempty"`
+	// DefaultCPUs is what DefaultRequests gives a pod that requests none.
+	DefaultCPUs int `yaml:"default_cpus" json:"default_cpus"`
+	// DefaultTolerations are added to every pod that lacks them.
+	DefaultTolerations []pod.Toleration `yaml:"default_tolerations" json:"default_tolerations,omitempty"`
+	// Namespaces exist besides default and kube-system.
+	Namespaces     []string        `yaml:"namespaces" json:"namespaces,omitempty"`
+	ResourceQuotas []ResourceQuota `yaml:"resource_quotas" json:"resource_quotas,omitempty"`
+	Webhooks       []Webhook       `yaml:"webhooks" json:"webhooks,omitempty"`
+}
+
+// DefaultConfig gives pods without a request one CPU.
+func DefaultConfig() Config {
+	return Config{DefaultCPUs: 1}
+}
+
+// LoadConfig reads a YAML config, starting from the defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Chain runs the built-in plugins and the webhooks. It implements
+// node.PodAdmitter.
+type Chain struct {
+	nm         *node.NodeManager
+	mu         sync.RWMutex // Protects webhooks; the plugins guard their own state
+	mutators   []Mutator
+	validators []Validator
+	webhooks   map[string]*Webhook
+
+	Namespaces *Namespaces
+	Quotas     *Quotas
+}
+
+// NewChain builds the chain described by cfg.
+func NewChain(nm *node.NodeManager, cfg Config) (*Chain, error) {
+	disabled := make(map[string]bool, len(cfg.Disabled))
+	for _, name := range cfg.Disabled {
+		switch name {
+		case PluginNamespaceDefault, PluginDefaultRequests, PluginDefaultTolerations,
+			PluginPositiveResources, PluginNamespaceExists, PluginResourceQuota:
+			disabled[name] = true
+		default:
+			return nil, fmt.Errorf("unknown admission plugin %q", name)
+		}
+	}
+	if cfg.DefaultCPUs < 0 {
+		return nil, fmt.Errorf("default_cpus must not be negative")
+	}
+
+	ch := &Chain{
+		nm:         nm,
+		webhooks:   make(map[string]*Webhook),
+		Namespaces: NewNamespaces(cfg.Namespaces...),
+		Quotas:     NewQuotas(nm),
+	}
+	for _, q := range cfg.ResourceQuotas {
+		if err := ch.Quotas.Set(q); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range []Mutator{
+		namespaceDefault{},
+		defaultRequests{cpus: cfg.DefaultCPUs},
+		defaultTolerations{tolerations: cfg.DefaultTolerations},
+	} {
+		if !disabled[m.Name()] {
+			ch.mutators = append(ch.mutators, m)
+		}
+	}
+	for _, v := range []Validator{
+		positiveResources{},
+		namespaceExists{namespaces: ch.Namespaces},
+		resourceQuota{quotas: ch.Quotas},
+	} {
+		if !disabled[v.Name()] {
+			ch.validators = append(ch.validators, v)
+		}
+	}
+	for _, w := range cfg.Webhooks {
+		if err := ch.SetWebhook(w); err != nil {
+			return nil, err
+		}
+	}
+	return ch, nil
+}
+
+// Plugins returns the names of the built-in plugins that run, in order.
+func (ch *Chain) Plugins() (mutating, validating []string) {
+	for _, m := range ch.mutators {
+		mutating = append(mutating, m.Name())
+	}
+	for _, v := range ch.validators {
+		validating = append(validating, v.Name())
+	}
+	return mutating, validating
+}
+
+// AdmitPod implements node.PodAdmitter.
+func (ch *Chain) AdmitPod(spec *node.PodSpec, user *auth.User) error {
+	return ch.Review(spec, user, false)
+}
+
+// Review runs spec through the chain, changing it as the mutators do. A dry
+// run tells webhooks so and has no other effect.
+func (ch *Chain) Review(spec *node.PodSpec, user *auth.User, dryRun bool) error {
+	attrs := Attributes{Operation: "CREATE", Name: spec.Name, User: user, DryRun: dryRun}
+	mutatingHooks, validatingHooks := ch.sortedWebhooks()
+	// The pod was authorized in the namespace it asked for, so mutators may
+	// fill in the default but not move it elsewhere.
+	requested := spec.Namespace
+	if requested == "" {
+		requested = DefaultNamespace
+	}
+
+	for _, m := range ch.mutators {
+		attrs.Namespace = spec.Namespace
+		if err := m.Mutate(spec, attrs); err != nil {
+			return fmt.Errorf("%s: %v", m.Name(), err)
+		}
+	}
+	for _, w := range mutatingHooks {
+		attrs.Namespace = spec.Namespace
+		if err := w.Mutate(spec, attrs); err != nil {
+			return err
+		}
+	}
+	if spec.Namespace != "" && spec.Namespace != requested {
+		return fmt.Errorf("admission may not move a pod from namespace %q to %q", requested, spec.Namespace)
+	}
+	attrs.Namespace = spec.Namespace
+	for _, v := range ch.validators {
+		if err := v.Validate(*spec, attrs); err != nil {
+			return fmt.Errorf("%s: %v", v.Name(), err)
+		}
+	}
+	for _, w := range validatingHooks {
+		if err := w.Validate(*spec, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedWebhooks returns the webhooks of each type by name.
+func (ch *Chain) sortedWebhooks() (mutating, validating []*Webhook) {
+	for _, w := range ch.Webhooks() {
+		w := w
+		if w.Type == TypeMutating {
+			mutating = append(mutating, &w)
+		} else {
+			validating = append(validating, &w)
+		}
+	}
+	return mutating, validating
+}
+
+// SetWebhook adds a webhook or replaces the one of the same name.
+func (ch *Chain) SetWebhook(w Webhook) error {
+	if err := w.init(); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.webhooks[w.Name] = &w
+	ch.mu.Unlock()
+	logger.Info("Admission webhook saved", "webhook", w.Name, "type", w.Type, "url", w.URL, "failure_policy", w.FailurePolicy)
+	return nil
+}
+
+// DeleteWebhook removes a webhook.
+func (ch *Chain) DeleteWebhook(name string) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if _, exists := ch.webhooks[name]; !exists {
+		return fmt.Errorf("webhook %s %w", name, ErrNotFound)
+	}
+	delete(ch.webhooks, name)
+	return nil
+}
+
+// Webhooks returns every webhook by name.
+func (ch *Chain) Webhooks() []Webhook {
+	ch.mu.RLock()
+	hooks := make([]Webhook, 0, len(ch.webhooks))
+	for _, w := range ch.webhooks {
+		hooks = append(hooks, *w)
+	}
+	ch.mu.RUnlock()
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Name < hooks[j].Name })
+	return hooks
+}
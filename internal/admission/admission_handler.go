@@ -0,0 +1,168 @@
+// All the gin handlers are here for admission package
+package admission
+
+import (
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/node"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to show the admission plugins and webhooks that run
+func (ch *Chain) StatusHandler(c *gin.Context) {
+	mutating, validating := ch.Plugins()
+	c.JSON(http.StatusOK, gin.H{
+		"mutating_plugins":   mutating,
+		"validating_plugins": validating,
+		"webhooks":           ch.Webhooks(),
+	})
+}
+
+// API Handler to run a pod through admission without creating it, showing
+// the pod as the mutators leave it
+func (ch *Chain) ReviewHandler(c *gin.Context) {
+	var spec node.PodSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := ch.Review(&spec, auth.RequestUser(c), true); err != nil {
+		c.JSON(http.StatusOK, gin.H{"allowed": false, "reason": err.Error(), "pod": spec})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"allowed": true, "pod": spec})
+}
+
+// API Handler to add or replace an admission webhook
+func (ch *Chain) AddWebhookHandler(c *gin.Context) {
+	var w Webhook
+	if err := c.ShouldBindJSON(&w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := ch.SetWebhook(w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook saved", "name": w.Name})
+}
+
+// API Handler to list admission webhooks
+func (ch *Chain) ListWebhooksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ch.Webhooks())
+}
+
+// API Handler to delete an admission webhook
+func (ch *Chain) DeleteWebhookHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := ch.DeleteWebhook(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.InfoContext(c.Request.Context(), "Admission webhook deleted", "webhook", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted", "name": name})
+}
+
+// API Handler to list namespaces
+func (ch *Chain) ListNamespacesHandler(c *gin.Context) {
+	names := ch.Namespaces.List()
+	pods := make(map[string]int, len(names))
+	ch.nm.Mu.Lock()
+	for _, p := range ch.nm.Pods {
+		pods[PodNamespace(p)]++
+	}
+	ch.nm.Mu.Unlock()
+
+	list := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		list = append(list, gin.H{"name": name, "pods": pods[name]})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// API Handler to create a namespace
+func (ch *Chain) AddNamespaceHandler(c *gin.Context) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	created, err := ch.Namespaces.Add(request.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusConflict, gin.H{"error": "namespace already exists", "name": request.Name})
+		return
+	}
+	logger.InfoContext(c.Request.Context(), "Namespace created", "namespace", request.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "Namespace created", "name": request.Name})
+}
+
+// API Handler to delete a namespace that has no pods left
+func (ch *Chain) DeleteNamespaceHandler(c *gin.Context) {
+	name := c.Param("name")
+	ch.nm.Mu.Lock()
+	pods := 0
+	for _, p := range ch.nm.Pods {
+		if PodNamespace(p) == name {
+			pods++
+		}
+	}
+	ch.nm.Mu.Unlock()
+	if pods > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("namespace %s still has %d pods", name, pods)})
+		return
+	}
+	if err := ch.Namespaces.Delete(name); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ch.Quotas.Delete(name)
+	logger.InfoContext(c.Request.Context(), "Namespace deleted", "namespace", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Namespace deleted", "name": name})
+}
+
+// API Handler to set the quota of a namespace
+func (ch *Chain) SetQuotaHandler(c *gin.Context) {
+	var quota ResourceQuota
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !ch.Namespaces.Exists(quota.Namespace) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("namespace %q does not exist", quota.Namespace)})
+		return
+	}
+	if err := ch.Quotas.Set(quota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logger.InfoContext(c.Request.Context(), "Resource quota set", "namespace", quota.Namespace, "pods", quota.Pods, "cpus", quota.CPUs)
+	c.JSON(http.StatusOK, gin.H{"message": "Quota saved", "namespace": quota.Namespace})
+}
+
+// API Handler to list quotas and how much of them is used
+func (ch *Chain) ListQuotasHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ch.Quotas.Status())
+}
+
+// API Handler to remove the quota of a namespace
+func (ch *Chain) DeleteQuotaHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	if err := ch.Quotas.Delete(namespace); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Quota deleted", "namespace": namespace})
+}
@@ -0,0 +1,288 @@
+package admission
+
+import (
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultNamespace is where pods without a namespace go.
+	DefaultNamespace = "default"
+	// SystemNamespace holds what the cluster itself runs.
+	SystemNamespace = "kube-system"
+)
+
+// namespaceDefault puts pods without a namespace in the default one.
+type namespaceDefault struct{}
+
+func (namespaceDefault) Name() string { return PluginNamespaceDefault }
+
+func (namespaceDefault) Mutate(spec *node.PodSpec, attrs Attributes) error {
+	if spec.Namespace == "" {
+		spec.Namespace = DefaultNamespace
+	}
+	return nil
+}
+
+// defaultRequests gives pods that request no CPUs the default request.
+type defaultRequests struct {
+	cpus int
+}
+
+func (defaultRequests) Name() string { return PluginDefaultRequests }
+
+func (d defaultRequests) Mutate(spec *node.PodSpec, attrs Attributes) error {
+	if spec.CPUs == 0 {
+		spec.CPUs = d.cpus
+	}
+	return nil
+}
+
+// defaultTolerations adds the configured tolerations to every pod that
+// does not have them yet.
+type defaultTolerations struct {
+	tolerations []pod.Toleration
+}
+
+func (defaultTolerations) Name() string { return PluginDefaultTolerations }
+
+func (d defaultTolerations) Mutate(spec *node.PodSpec, attrs Attributes) error {
+	for _, t := range d.tolerations {
+		if !hasToleration(spec.Tolerations, t) {
+			spec.Tolerations = append(spec.Tolerations, t)
+		}
+	}
+	return nil
+}
+
+func hasToleration(tolerations []pod.Toleration, t pod.Toleration) bool {
+	for _, existing := range tolerations {
+		if reflect.DeepEqual(existing, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// positiveResources rejects pods whose requests and settings cannot work.
+type positiveResources struct{}
+
+func (positiveResources) Name() string { return PluginPositiveResources }
+
+func (positiveResources) Validate(spec node.PodSpec, attrs Attributes) error {
+	switch {
+	case spec.CPUs <= 0:
+		return fmt.Errorf("cpus must be positive, got %d", spec.CPUs)
+	case spec.GracePeriodSeconds < 0:
+		return fmt.Errorf("termination_grace_period_seconds must not be negative, got %d", spec.GracePeriodSeconds)
+	case spec.RunSeconds < 0:
+		return fmt.Errorf("run_seconds must not be negative, got %d", spec.RunSeconds)
+	case spec.FailureRate < 0 || spec.FailureRate > 1:
+		return fmt.Errorf("failure_rate must be between 0 and 1, got %g", spec.FailureRate)
+	}
+	return nil
+}
+
+// namespaceExists rejects pods in namespaces that were not created.
+type namespaceExists struct {
+	namespaces *Namespaces
+}
+
+func (namespaceExists) Name() string { return PluginNamespaceExists }
+
+func (n namespaceExists) Validate(spec node.PodSpec, attrs Attributes) error {
+	if !n.namespaces.Exists(spec.Namespace) {
+		return fmt.Errorf("namespace %q does not exist", spec.Namespace)
+	}
+	return nil
+}
+
+// resourceQuota rejects pods that would take a namespace over its quota.
+type resourceQuota struct {
+	quotas *Quotas
+}
+
+func (resourceQuota) Name() string { return PluginResourceQuota }
+
+func (r resourceQuota) Validate(spec node.PodSpec, attrs Attributes) error {
+	q, exists := r.quotas.Get(spec.Namespace)
+	if !exists {
+		return nil
+	}
+	pods, cpus := r.quotas.usage(spec.Namespace)
+	if q.Pods > 0 && pods+1 > q.Pods {
+		return fmt.Errorf("exceeded quota in namespace %q: requested 1 pod, used %d of %d", spec.Namespace, pods, q.Pods)
+	}
+	if q.CPUs > 0 && cpus+spec.CPUs > q.CPUs {
+		return fmt.Errorf("exceeded quota in namespace %q: requested %d CPUs, used %d of %d", spec.Namespace, spec.CPUs, cpus, q.CPUs)
+	}
+	return nil
+}
+
+// namespaceName is a DNS label, as Kubernetes namespace names are.
+var namespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Namespaces is the set of namespaces pods may be created in. default and
+// kube-system always exist.
+type Namespaces struct {
+	mu    sync.RWMutex
+	names map[string]bool
+}
+
+// NewNamespaces returns the built-in namespaces and names.
+func NewNamespaces(names ...string) *Namespaces {
+	n := &Namespaces{names: map[string]bool{DefaultNamespace: true, SystemNamespace: true}}
+	for _, name := range names {
+		n.names[name] = true
+	}
+	return n
+}
+
+// Add creates a namespace and reports whether it is new.
+func (n *Namespaces) Add(name string) (bool, error) {
+	if len(name) > 63 || !namespaceName.MatchString(name) {
+		return false, fmt.Errorf("invalid namespace name %q: must be a lowercase DNS label", name)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.names[name] {
+		return false, nil
+	}
+	n.names[name] = true
+	return true, nil
+}
+
+// Delete removes a namespace. The built-in ones cannot be deleted.
+func (n *Namespaces) Delete(name string) error {
+	if name == DefaultNamespace || name == SystemNamespace {
+		return fmt.Errorf("namespace %s cannot be deleted", name)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.names[name] {
+		return fmt.Errorf("namespace %s %w", name, ErrNotFound)
+	}
+	delete(n.names, name)
+	return nil
+}
+
+// Exists reports whether a namespace exists.
+func (n *Namespaces) Exists(name string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.names[name]
+}
+
+// List returns the namespaces by name.
+func (n *Namespaces) List() []string {
+	n.mu.RLock()
+	names := make([]string, 0, len(n.names))
+	for name := range n.names {
+		names = append(names, name)
+	}
+	n.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// ResourceQuota caps how many pods and CPUs the pods of a namespace may
+// have. Zero means no limit. Finished pods do not count.
+type ResourceQuota struct {
+	Namespace string `yaml:"namespace" json:"namespace"`
+	Pods      int    `yaml:"pods" json:"pods"`
+	CPUs      int    `yaml:"cpus" json:"cpus"`
+}
+
+// QuotaStatus is a quota with what its namespace uses.
+type QuotaStatus struct {
+	ResourceQuota
+	UsedPods int `json:"used_pods"`
+	UsedCPUs int `json:"used_cpus"`
+}
+
+// Quotas holds the quota of each namespace.
+type Quotas struct {
+	nm     *node.NodeManager
+	mu     sync.RWMutex
+	quotas map[string]ResourceQuota
+}
+
+// NewQuotas returns an empty set of quotas over the pods of nm.
+func NewQuotas(nm *node.NodeManager) *Quotas {
+	return &Quotas{nm: nm, quotas: make(map[string]ResourceQuota)}
+}
+
+// Set adds a quota or replaces the one of its namespace.
+func (q *Quotas) Set(quota ResourceQuota) error {
+	if quota.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if quota.Pods < 0 || quota.CPUs < 0 {
+		return fmt.Errorf("quota must not be negative")
+	}
+	q.mu.Lock()
+	q.quotas[quota.Namespace] = quota
+	q.mu.Unlock()
+	return nil
+}
+
+// Get returns the quota of a namespace.
+func (q *Quotas) Get(namespace string) (ResourceQuota, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	quota, exists := q.quotas[namespace]
+	return quota, exists
+}
+
+// Delete removes the quota of a namespace.
+func (q *Quotas) Delete(namespace string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, exists := q.quotas[namespace]; !exists {
+		return fmt.Errorf("quota for namespace %s %w", namespace, ErrNotFound)
+	}
+	delete(q.quotas, namespace)
+	return nil
+}
+
+// Status returns every quota with its usage, by namespace.
+func (q *Quotas) Status() []QuotaStatus {
+	q.mu.RLock()
+	status := make([]QuotaStatus, 0, len(q.quotas))
+	for _, quota := range q.quotas {
+		status = append(status, QuotaStatus{ResourceQuota: quota})
+	}
+	q.mu.RUnlock()
+	for i := range status {
+		status[i].UsedPods, status[i].UsedCPUs = q.usage(status[i].Namespace)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Namespace < status[j].Namespace })
+	return status
+}
+
+// usage counts the pods of a namespace that have not finished and their CPUs.
+func (q *Quotas) usage(namespace string) (pods, cpus int) {
+	q.nm.Mu.Lock()
+	defer q.nm.Mu.Unlock()
+	for _, p := range q.nm.Pods {
+		if PodNamespace(p) == namespace && !p.Finished() {
+			pods++
+			cpus += p.CPUs
+		}
+	}
+	return pods, cpus
+}
+
+// PodNamespace returns a pod's namespace; pods created before admission
+// existed are in the default one.
+func PodNamespace(p pod.Pod) string {
+	if p.Namespace == "" {
+		return DefaultNamespace
+	}
+	return p.Namespace
+}
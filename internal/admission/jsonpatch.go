@@ -0,0 +1,147 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is one operation of a JSON patch (RFC 6902). The add,
+// remove, replace and test operations are supported, which is what
+// mutating webhooks use in practice.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// apply returns doc with the operation applied.
+func (op patchOperation) apply(doc interface{}) (interface{}, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%s %s: value is required", op.Op, op.Path)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", op.Op, op.Path, err)
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+	}
+	if len(tokens) == 0 {
+		switch op.Op {
+		case "test":
+			if !reflect.DeepEqual(doc, value) {
+				return nil, fmt.Errorf("test %s failed", op.Path)
+			}
+			return doc, nil
+		case "remove":
+			return nil, fmt.Errorf("cannot remove the whole document")
+		}
+		return value, nil
+	}
+	return applyAt(doc, tokens, op, value)
+}
+
+// applyAt applies op at tokens within container and returns the container.
+func applyAt(container interface{}, tokens []string, op patchOperation, value interface{}) (interface{}, error) {
+	key, rest := tokens[0], tokens[1:]
+	switch c := container.(type) {
+	case map[string]interface{}:
+		child, exists := c[key]
+		if len(rest) > 0 {
+			if !exists {
+				return nil, fmt.Errorf("%s %s: path does not exist", op.Op, op.Path)
+			}
+			updated, err := applyAt(child, rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			c[key] = updated
+			return c, nil
+		}
+		switch op.Op {
+		case "add":
+			c[key] = value
+		case "replace", "remove", "test":
+			if !exists {
+				return nil, fmt.Errorf("%s %s: path does not exist", op.Op, op.Path)
+			}
+			switch op.Op {
+			case "replace":
+				c[key] = value
+			case "remove":
+				delete(c, key)
+			case "test":
+				if !reflect.DeepEqual(child, value) {
+					return nil, fmt.Errorf("test %s failed", op.Path)
+				}
+			}
+		}
+		return c, nil
+
+	case []interface{}:
+		if len(rest) == 0 && op.Op == "add" && key == "-" {
+			return append(c, value), nil
+		}
+		i, err := strconv.Atoi(key)
+		limit := len(c)
+		if len(rest) == 0 && op.Op == "add" {
+			limit++ // adding may insert at the end
+		}
+		if err != nil || i < 0 || i >= limit {
+			return nil, fmt.Errorf("%s %s: invalid array index %q", op.Op, op.Path, key)
+		}
+		if len(rest) > 0 {
+			updated, err := applyAt(c[i], rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = updated
+			return c, nil
+		}
+		switch op.Op {
+		case "add":
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+		case "replace":
+			c[i] = value
+		case "remove":
+			c = append(c[:i], c[i+1:]...)
+		case "test":
+			if !reflect.DeepEqual(c[i], value) {
+				return nil, fmt.Errorf("test %s failed", op.Path)
+			}
+		}
+		return c, nil
+
+	case nil:
+		return nil, fmt.Errorf("%s %s: path does not exist", op.Op, op.Path)
+	default:
+		return nil, fmt.Errorf("%s %s: cannot descend into a %T", op.Op, op.Path, container)
+	}
+}
+
+// parsePointer splits a JSON pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
@@ -0,0 +1,115 @@
+package admission
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"cluster-sim/internal/node"
+)
+
+// applyPatch applies a JSON patch to a JSON document, as patchSpec does for
+// pod specs.
+func applyPatch(t *testing.T, doc, patch string) (interface{}, error) {
+	t.Helper()
+	var ops []patchOperation
+	if err := json.Unmarshal([]byte(patch), &ops); err != nil {
+		t.Fatalf("bad patch in test: %v", err)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("bad document in test: %v", err)
+	}
+	var err error
+	for _, op := range ops {
+		if v, err = op.apply(v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func TestJSONPatch(t *testing.T) {
+	const doc = `{"name":"web","labels":{"app":"web"},"ports":[80,443],"a/b":1,"m~n":2}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string // the patched document; empty if the patch must fail
+	}{
+		{"add field", `[{"op":"add","path":"/cpus","value":2}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,443],"a/b":1,"m~n":2,"cpus":2}`},
+		{"add replaces existing field", `[{"op":"add","path":"/name","value":"api"}]`,
+			`{"name":"api","labels":{"app":"web"},"ports":[80,443],"a/b":1,"m~n":2}`},
+		{"add nested", `[{"op":"add","path":"/labels/tier","value":"front"}]`,
+			`{"name":"web","labels":{"app":"web","tier":"front"},"ports":[80,443],"a/b":1,"m~n":2}`},
+		{"add to array end", `[{"op":"add","path":"/ports/-","value":8080}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,443,8080],"a/b":1,"m~n":2}`},
+		{"insert into array", `[{"op":"add","path":"/ports/0","value":22}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[22,80,443],"a/b":1,"m~n":2}`},
+		{"add at array length", `[{"op":"add","path":"/ports/2","value":22}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,443,22],"a/b":1,"m~n":2}`},
+		{"replace", `[{"op":"replace","path":"/labels/app","value":"api"}]`,
+			`{"name":"web","labels":{"app":"api"},"ports":[80,443],"a/b":1,"m~n":2}`},
+		{"replace array item", `[{"op":"replace","path":"/ports/1","value":8443}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,8443],"a/b":1,"m~n":2}`},
+		{"remove", `[{"op":"remove","path":"/labels/app"}]`,
+			`{"name":"web","labels":{},"ports":[80,443],"a/b":1,"m~n":2}`},
+		{"remove array item", `[{"op":"remove","path":"/ports/0"}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[443],"a/b":1,"m~n":2}`},
+		{"escaped keys", `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,443],"a/b":3}`},
+		{"test passes", `[{"op":"test","path":"/labels/app","value":"web"},{"op":"add","path":"/cpus","value":1}]`,
+			`{"name":"web","labels":{"app":"web"},"ports":[80,443],"a/b":1,"m~n":2,"cpus":1}`},
+		{"replace whole document", `[{"op":"replace","path":"","value":{"name":"x"}}]`, `{"name":"x"}`},
+		{"operations in order", `[{"op":"add","path":"/labels/env","value":"prod"},{"op":"replace","path":"/labels/env","value":"dev"}]`,
+			`{"name":"web","labels":{"app":"web","env":"dev"},"ports":[80,443],"a/b":1,"m~n":2}`},
+
+		{"test fails", `[{"op":"test","path":"/name","value":"api"}]`, ""},
+		{"replace missing field", `[{"op":"replace","path":"/missing","value":1}]`, ""},
+		{"remove missing field", `[{"op":"remove","path":"/missing"}]`, ""},
+		{"add under missing parent", `[{"op":"add","path":"/missing/x","value":1}]`, ""},
+		{"array index out of range", `[{"op":"replace","path":"/ports/2","value":1}]`, ""},
+		{"add past array end", `[{"op":"add","path":"/ports/3","value":1}]`, ""},
+		{"negative array index", `[{"op":"remove","path":"/ports/-1"}]`, ""},
+		{"descend into scalar", `[{"op":"add","path":"/name/x","value":1}]`, ""},
+		{"value required", `[{"op":"add","path":"/cpus"}]`, ""},
+		{"unsupported operation", `[{"op":"move","path":"/name"}]`, ""},
+		{"invalid pointer", `[{"op":"remove","path":"name"}]`, ""},
+		{"remove whole document", `[{"op":"remove","path":""}]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyPatch(t, doc, tt.patch)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("patch succeeded with %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("patch failed: %v", err)
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("patched document = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPatchSpecKeepsOwner(t *testing.T) {
+	spec := node.PodSpec{CPUs: 1, Labels: map[string]string{"app": "web"}, Owner: "PodGroup/web"}
+	patched, err := patchSpec(spec, []byte(`[{"op":"replace","path":"/cpus","value":2},{"op":"add","path":"/labels/tier","value":"front"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.CPUs != 2 || patched.Labels["tier"] != "front" || patched.Labels["app"] != "web" {
+		t.Errorf("patchSpec() = %+v, want 2 CPUs and both labels", patched)
+	}
+	if patched.Owner != spec.Owner {
+		t.Errorf("patchSpec() owner = %q, want %q", patched.Owner, spec.Owner)
+	}
+}
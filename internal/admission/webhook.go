@@ -0,0 +1,312 @@
+package admission
+
+import (
+	"bytes"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook types.
+const (
+	TypeValidating = "Validating"
+	TypeMutating   = "Mutating"
+)
+
+// What to do when a webhook cannot be called or answers nonsense.
+const (
+	FailurePolicyFail   = "Fail"   // reject the pod
+	FailurePolicyIgnore = "Ignore" // admit the pod as if the webhook allowed it
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	maxWebhookTimeout     = 30 * time.Second
+)
+
+// Webhook is an external admission plugin. Mutating webhooks may answer
+// with a JSON patch of the pod; validating ones only allow or deny it.
+type Webhook struct {
+	Name           string   `yaml:"name" json:"name"`
+	Type           string   `yaml:"type" json:"type"` // Validating (the default) or Mutating
+	URL            string   `yaml:"url" json:"url"`
+	FailurePolicy  string   `yaml:"failure_policy" json:"failure_policy"` // Fail (the default) or Ignore
+	TimeoutSeconds int      `yaml:"timeout_seconds" json:"timeout_seconds"`
+	CAFile         string   `yaml:"ca_file" json:"ca_file,omitempty"`       // CA bundle to verify an https URL with
+	Namespaces     []string `yaml:"namespaces" json:"namespaces,omitempty"` // only review pods in these; empty for all
+
+	client *http.Client
+}
+
+// init checks the webhook, fills in defaults and sets up its client.
+func (w *Webhook) init() error {
+	if w.Name == "" {
+		return fmt.Errorf("webhook name is required")
+	}
+	switch w.Type {
+	case "":
+		w.Type = TypeValidating
+	case TypeValidating, TypeMutating:
+	default:
+		return fmt.Errorf("webhook type must be %s or %s", TypeValidating, TypeMutating)
+	}
+	switch w.FailurePolicy {
+	case "":
+		w.FailurePolicy = FailurePolicyFail
+	case FailurePolicyFail, FailurePolicyIgnore:
+	default:
+		return fmt.Errorf("failure policy must be %s or %s", FailurePolicyFail, FailurePolicyIgnore)
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook URL must be an http or https URL")
+	}
+	timeout := time.Duration(w.TimeoutSeconds) * time.Second
+	if w.TimeoutSeconds <= 0 {
+		timeout = defaultWebhookTimeout
+		w.TimeoutSeconds = int(timeout.Seconds())
+	}
+	if timeout > maxWebhookTimeout {
+		return fmt.Errorf("webhook timeout must be at most %s", maxWebhookTimeout)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if w.CAFile != "" {
+		data, err := os.ReadFile(w.CAFile)
+		if err != nil {
+			return fmt.Errorf("webhook CA: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data) {
+			return fmt.Errorf("webhook CA: no PEM certificates found in %s", w.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	w.client = &http.Client{Transport: transport, Timeout: timeout}
+	return nil
+}
+
+// applies reports whether the webhook reviews pods in namespace.
+func (w *Webhook) applies(namespace string) bool {
+	if len(w.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range w.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Review is the payload sent to and returned by webhooks, shaped like a
+// Kubernetes admission.k8s.io/v1 AdmissionReview.
+type Review struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Request    *ReviewRequest  `json:"request,omitempty"`
+	Response   *ReviewResponse `json:"response,omitempty"`
+}
+
+// GroupVersionKind names the kind of the reviewed object.
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// GroupVersionResource names the resource of the reviewed object.
+type GroupVersionResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// UserInfo is who asked for the reviewed object.
+type UserInfo struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// ReviewRequest describes the pod under review.
+type ReviewRequest struct {
+	UID       string               `json:"uid"`
+	Kind      GroupVersionKind     `json:"kind"`
+	Resource  GroupVersionResource `json:"resource"`
+	Name      string               `json:"name,omitempty"`
+	Namespace string               `json:"namespace"`
+	Operation string               `json:"operation"`
+	UserInfo  UserInfo             `json:"userInfo"`
+	Object    node.PodSpec         `json:"object"`
+	DryRun    bool                 `json:"dryRun"`
+}
+
+// Status explains a denial.
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReviewResponse is a webhook's verdict. Patch is a base64 JSON patch
+// (RFC 6902) of the object, with PatchType "JSONPatch".
+type ReviewResponse struct {
+	UID       string   `json:"uid"`
+	Allowed   bool     `json:"allowed"`
+	Status    *Status  `json:"status,omitempty"`
+	Patch     []byte   `json:"patch,omitempty"`
+	PatchType string   `json:"patchType,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// call sends spec to the webhook and returns its response.
+func (w *Webhook) call(spec node.PodSpec, attrs Attributes) (*ReviewResponse, error) {
+	userInfo := UserInfo{Username: "system:controller"}
+	if attrs.User != nil {
+		userInfo = UserInfo{Username: attrs.User.Name, Groups: attrs.User.Groups}
+	}
+	review := Review{
+		APIVersion: "admission.k8s.io/v1",
+		Kind:       "AdmissionReview",
+		Request: &ReviewRequest{
+			UID:       uuid.New().String(),
+			Kind:      GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  GroupVersionResource{Version: "v1", Resource: "pods"},
+			Name:      attrs.Name,
+			Namespace: attrs.Namespace,
+			Operation: attrs.Operation,
+			UserInfo:  userInfo,
+			Object:    spec,
+			DryRun:    attrs.DryRun,
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	var answer Review
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("invalid AdmissionReview: %v", err)
+	}
+	if answer.Response == nil {
+		return nil, fmt.Errorf("AdmissionReview has no response")
+	}
+	if answer.Response.UID != review.Request.UID {
+		return nil, fmt.Errorf("response uid %q does not match request uid %q", answer.Response.UID, review.Request.UID)
+	}
+	for _, warning := range answer.Response.Warnings {
+		logger.Warn("Admission webhook warns about a pod", "webhook", w.Name, "pod", attrs.Name, "warning", warning)
+	}
+	return answer.Response, nil
+}
+
+// review calls the webhook and applies its failure policy. It returns nil
+// when the call failed and the policy ignores that.
+func (w *Webhook) review(spec node.PodSpec, attrs Attributes) (*ReviewResponse, error) {
+	resp, err := w.call(spec, attrs)
+	if err == nil {
+		if !resp.Allowed {
+			message := "no reason given"
+			if resp.Status != nil && resp.Status.Message != "" {
+				message = resp.Status.Message
+			}
+			return nil, fmt.Errorf("admission webhook %q denied the request: %s", w.Name, message)
+		}
+		return resp, nil
+	}
+	if w.FailurePolicy == FailurePolicyIgnore {
+		logger.Warn("Admission webhook failed, ignoring it", "webhook", w.Name, logging.Err(err))
+		return nil, nil
+	}
+	return nil, fmt.Errorf("failed calling admission webhook %q: %v", w.Name, err)
+}
+
+// Validate calls a validating webhook.
+func (w *Webhook) Validate(spec node.PodSpec, attrs Attributes) error {
+	if !w.applies(attrs.Namespace) {
+		return nil
+	}
+	_, err := w.review(spec, attrs)
+	return err
+}
+
+// Mutate calls a mutating webhook and applies its patch.
+func (w *Webhook) Mutate(spec *node.PodSpec, attrs Attributes) error {
+	if !w.applies(attrs.Namespace) {
+		return nil
+	}
+	resp, err := w.review(*spec, attrs)
+	if err != nil || resp == nil || len(resp.Patch) == 0 {
+		return err
+	}
+	if resp.PatchType != "JSONPatch" {
+		return w.patchFailed(fmt.Errorf("unsupported patch type %q", resp.PatchType))
+	}
+	patched, err := patchSpec(*spec, resp.Patch)
+	if err != nil {
+		return w.patchFailed(err)
+	}
+	*spec = patched
+	return nil
+}
+
+// patchFailed applies the failure policy to a patch that cannot be applied.
+func (w *Webhook) patchFailed(err error) error {
+	if w.FailurePolicy == FailurePolicyIgnore {
+		logger.Warn("Admission webhook returned a bad patch, ignoring it", "webhook", w.Name, logging.Err(err))
+		return nil
+	}
+	return fmt.Errorf("admission webhook %q returned a bad patch: %v", w.Name, err)
+}
+
+// patchSpec applies a JSON patch to the JSON form of spec.
+func patchSpec(spec node.PodSpec, patch []byte) (node.PodSpec, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return spec, err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return spec, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return spec, err
+	}
+	for _, op := range ops {
+		if doc, err = op.apply(doc); err != nil {
+			return spec, err
+		}
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return spec, err
+	}
+	var patched node.PodSpec
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return spec, err
+	}
+	// The owner is not part of the JSON form, and not a webhook's to change.
+	patched.Owner = spec.Owner
+	return patched, nil
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"bytes"
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/logging"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxBodySize is how much of a request or response body an event keeps.
+const maxBodySize = 64 << 10
+
+var logger = logging.For("audit")
+
+// ObjectRef is the object a request is about.
+type ObjectRef struct {
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	APIVersion  string `json:"apiVersion"`
+}
+
+// ResponseStatus is the outcome of a request.
+type ResponseStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Event is the record of one request, shaped like a Kubernetes
+// audit.k8s.io/v1 Event so existing tooling can read it. Events are only
+// emitted once the response is complete.
+type Event struct {
+	APIVersion               string          `json:"apiVersion"`
+	Kind                     string          `json:"kind"`
+	Level                    Level           `json:"level"`
+	AuditID                  string          `json:"auditID"`
+	Stage                    string          `json:"stage"`
+	RequestURI               string          `json:"requestURI"`
+	Verb                     string          `json:"verb"`
+	User                     auth.User       `json:"user"`
+	SourceIPs                []string        `json:"sourceIPs,omitempty"`
+	UserAgent                string          `json:"userAgent,omitempty"`
+	ObjectRef                *ObjectRef      `json:"objectRef,omitempty"`
+	ResponseStatus           ResponseStatus  `json:"responseStatus"`
+	RequestObject            json.RawMessage `json:"requestObject,omitempty"`
+	ResponseObject           json.RawMessage `json:"responseObject,omitempty"`
+	RequestReceivedTimestamp time.Time       `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time       `json:"stageTimestamp"`
+}
+
+// Backend stores audit events.
+type Backend interface {
+	// ProcessEvent stores an event. It must not block on slow sinks.
+	ProcessEvent(ev *Event)
+	// Shutdown flushes buffered events.
+	Shutdown()
+	// Name identifies the backend in metrics and logs.
+	Name() string
+}
+
+// Union sends events to several backends.
+type Union []Backend
+
+// ProcessEvent implements Backend.
+func (u Union) ProcessEvent(ev *Event) {
+	for _, b := range u {
+		b.ProcessEvent(ev)
+	}
+}
+
+// Shutdown implements Backend.
+func (u Union) Shutdown() {
+	for _, b := range u {
+		b.Shutdown()
+	}
+}
+
+// Name implements Backend.
+func (u Union) Name() string { return "union" }
+
+// bodyRecorder keeps a copy of the start of the response body.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) keep(data []byte) {
+	if room := maxBodySize - w.body.Len(); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		w.body.Write(data[:room])
+	}
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware records every request that policy does not put at level None.
+// It must run before the authentication middleware, so requests that fail
+// to authenticate are recorded too, as system:anonymous.
+func Middleware(policy Policy, backend Backend) gin.HandlerFunc {
+	captureRequest := policy.maxLevel().atLeast(LevelRequest)
+	captureResponse := policy.maxLevel().atLeast(LevelRequestResponse)
+	return func(c *gin.Context) {
+		received := time.Now()
+		var requestBody []byte
+		if captureRequest && c.Request.Body != nil {
+			// Read the whole body so the handler still gets all of it.
+			requestBody, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+		var recorder *bodyRecorder
+		// Watches stream for as long as the client stays, so their
+		// responses are never kept.
+		if captureResponse && c.Query("watch") != "true" {
+			recorder = &bodyRecorder{ResponseWriter: c.Writer}
+			c.Writer = recorder
+		}
+
+		c.Next()
+
+		user := auth.RequestUser(c)
+		ev := &Event{
+			APIVersion:               "audit.k8s.io/v1",
+			Kind:                     "Event",
+			AuditID:                  uuid.New().String(),
+			Stage:                    "ResponseComplete",
+			RequestURI:               c.Request.URL.RequestURI(),
+			User:                     *user,
+			SourceIPs:                []string{c.ClientIP()},
+			UserAgent:                c.Request.UserAgent(),
+			ResponseStatus:           ResponseStatus{Code: c.Writer.Status()},
+			RequestReceivedTimestamp: received,
+			StageTimestamp:           time.Now(),
+		}
+		if c.FullPath() != "" {
+			attrs := auth.RequestAttributes(c)
+			ev.Verb = attrs.Verb
+			ev.ObjectRef = &ObjectRef{
+				Resource:    attrs.Resource,
+				Subresource: attrs.Subresource,
+				Name:        attrs.Name,
+				Namespace:   attrs.Namespace,
+				APIVersion:  "v1",
+			}
+		} else {
+			// Paths that are not routes are recorded with the method as
+			// the verb, as Kubernetes does for non-resource URLs.
+			ev.Verb = strings.ToLower(c.Request.Method)
+		}
+		ev.Level = policy.LevelFor(user.Name, user.Groups, ev.Verb, ev.ObjectRef, c.Request.URL.Path)
+		if ev.Level == LevelNone {
+			return
+		}
+		if ev.ResponseStatus.Code >= http.StatusBadRequest {
+			ev.ResponseStatus.Message = http.StatusText(ev.ResponseStatus.Code)
+		}
+		if ev.Level.atLeast(LevelRequest) {
+			ev.RequestObject = jsonBody(requestBody)
+		}
+		if ev.Level.atLeast(LevelRequestResponse) && recorder != nil {
+			ev.ResponseObject = jsonBody(recorder.body.Bytes())
+		}
+		backend.ProcessEvent(ev)
+	}
+}
+
+// jsonBody returns a body to embed in an event: as is if it is JSON and
+// fits, otherwise as a JSON string, cut short if needed.
+func jsonBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if len(body) <= maxBodySize && json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	if len(body) > maxBodySize {
+		body = body[:maxBodySize]
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// LogBackend writes events as JSON lines to a file, or to standard output
+// when the path is "-". Once the file would grow beyond the maximum size it
+// is renamed to path.1, older files shift to path.2 and so on, and the
+// oldest beyond the number of backups kept is removed.
+type LogBackend struct {
+	path       string
+	maxSize    int64 // bytes; 0 never rotates
+	maxBackups int
+
+	mu     sync.Mutex
+	out    io.Writer
+	file   *os.File // nil when writing to standard output
+	size   int64
+	closed bool
+}
+
+// NewLogBackend opens path for appending. maxSizeMB is the size in
+// megabytes at which the file is rotated, 0 to never rotate.
+func NewLogBackend(path string, maxSizeMB, maxBackups int) (*LogBackend, error) {
+	if maxSizeMB < 0 || maxBackups < 0 {
+		return nil, fmt.Errorf("audit log size and backups must not be negative")
+	}
+	b := &LogBackend{path: path, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if path == "-" {
+		b.out = os.Stdout
+		return b, nil
+	}
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// open opens the file at path and picks up its current size.
+func (b *LogBackend) open() error {
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	b.file, b.out, b.size = f, f, info.Size()
+	return nil
+}
+
+// rotate moves the current file to path.1, shifting the older ones up, and
+// opens a new file.
+func (b *LogBackend) rotate() error {
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	b.file, b.out = nil, nil
+	if b.maxBackups == 0 {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return b.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", b.path, b.maxBackups))
+	for i := b.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", b.path, i), fmt.Sprintf("%s.%d", b.path, i+1))
+	}
+	if err := os.Rename(b.path, b.path+".1"); err != nil {
+		return err
+	}
+	return b.open()
+}
+
+// ProcessEvent implements Backend.
+func (b *LogBackend) ProcessEvent(ev *Event) {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		metrics.AuditErrors.WithLabelValues(b.Name()).Inc()
+		logger.Error("Error encoding audit event", "audit_id", ev.AuditID, logging.Err(err))
+		return
+	}
+	line = append(line, '\n')
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file != nil && b.maxSize > 0 && b.size > 0 && b.size+int64(len(line)) > b.maxSize {
+		if err := b.rotate(); err != nil {
+			logger.Error("Error rotating audit log", "path", b.path, logging.Err(err))
+		}
+	}
+	if b.closed {
+		return
+	}
+	if b.out == nil {
+		// A failed rotation left no file to write to; try again
+		if err := b.open(); err != nil {
+			metrics.AuditErrors.WithLabelValues(b.Name()).Inc()
+			return
+		}
+	}
+	n, err := b.out.Write(line)
+	b.size += int64(n)
+	if err != nil {
+		metrics.AuditErrors.WithLabelValues(b.Name()).Inc()
+		logger.Error("Error writing audit log", "path", b.path, logging.Err(err))
+		return
+	}
+	metrics.AuditEvents.WithLabelValues(b.Name(), string(ev.Level)).Inc()
+}
+
+// Shutdown implements Backend.
+func (b *LogBackend) Shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.file != nil {
+		b.file.Close()
+		b.file, b.out = nil, nil
+	}
+}
+
+// Name implements Backend.
+func (b *LogBackend) Name() string { return "log" }
@@ -0,0 +1,207 @@
+// Package audit records the requests made to the API server: who made them,
+// what they did to which object and, depending on the audit level, the
+// request and response bodies. Which requests are recorded at which level
+// is decided by a policy in the format of a Kubernetes audit.k8s.io/v1
+// Policy, and events go to a rotating JSON-lines file or an HTTP webhook.
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Level is how much of a request is recorded.
+type Level string
+
+// Levels, from least to most detail.
+const (
+	LevelNone            Level = "None"            // not recorded
+	LevelMetadata        Level = "Metadata"        // user, verb, object and response code
+	LevelRequest         Level = "Request"         // and the request body
+	LevelRequestResponse Level = "RequestResponse" // and the response body
+)
+
+// valid reports whether l is one of the levels.
+func (l Level) valid() bool {
+	switch l {
+	case LevelNone, LevelMetadata, LevelRequest, LevelRequestResponse:
+		return true
+	}
+	return false
+}
+
+// atLeast reports whether l records at least as much as other.
+func (l Level) atLeast(other Level) bool {
+	rank := map[Level]int{LevelNone: 0, LevelMetadata: 1, LevelRequest: 2, LevelRequestResponse: 3}
+	return rank[l] >= rank[other]
+}
+
+// GroupResources selects resources, e.g. "nodes" or "nodes/drain", and
+// optionally the objects among them by name. The group is accepted for
+// compatibility and only the core group, "", exists.
+type GroupResources struct {
+	Group         string   `yaml:"group" json:"group,omitempty"`
+	Resources     []string `yaml:"resources" json:"resources,omitempty"`
+	ResourceNames []string `yaml:"resourceNames" json:"resourceNames,omitempty"`
+}
+
+// PolicyRule sets the level of the requests it matches. Every list that is
+// not empty must match; "*" in Verbs matches every verb and "x/*" in
+// Resources every subresource of x. NonResourceURLs match the request path,
+// exactly or by a prefix ending in "*"; as every route of this API is also a
+// resource, they can pick out paths such as /metrics or /events.
+type PolicyRule struct {
+	Level           Level            `yaml:"level" json:"level"`
+	Users           []string         `yaml:"users" json:"users,omitempty"`
+	UserGroups      []string         `yaml:"userGroups" json:"userGroups,omitempty"`
+	Verbs           []string         `yaml:"verbs" json:"verbs,omitempty"`
+	Resources       []GroupResources `yaml:"resources" json:"resources,omitempty"`
+	Namespaces      []string         `yaml:"namespaces" json:"namespaces,omitempty"`
+	NonResourceURLs []string         `yaml:"nonResourceURLs" json:"nonResourceURLs,omitempty"`
+}
+
+// Policy picks the level of a request from the first rule that matches it.
+// Requests no rule matches are not recorded.
+type Policy struct {
+	APIVersion string       `yaml:"apiVersion" json:"apiVersion,omitempty"`
+	Kind       string       `yaml:"kind" json:"kind,omitempty"`
+	Rules      []PolicyRule `yaml:"rules" json:"rules"`
+}
+
+// DefaultPolicy records the metadata of every request.
+func DefaultPolicy() Policy {
+	return Policy{Rules: []PolicyRule{{Level: LevelMetadata}}}
+}
+
+// LoadPolicy reads a policy file.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return Policy{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return Policy{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Validate checks that every rule has a valid level.
+func (p Policy) Validate() error {
+	if p.Kind != "" && p.Kind != "Policy" {
+		return fmt.Errorf("kind must be Policy, not %s", p.Kind)
+	}
+	for i, r := range p.Rules {
+		if !r.Level.valid() {
+			return fmt.Errorf("rule %d: invalid level %q", i+1, r.Level)
+		}
+		if len(r.NonResourceURLs) > 0 && (len(r.Resources) > 0 || len(r.Namespaces) > 0) {
+			return fmt.Errorf("rule %d: nonResourceURLs cannot be combined with resources or namespaces", i+1)
+		}
+	}
+	return nil
+}
+
+// maxLevel returns the most detailed level any rule records at, so bodies
+// are only captured when some request may need them.
+func (p Policy) maxLevel() Level {
+	max := LevelNone
+	for _, r := range p.Rules {
+		if r.Level.atLeast(max) {
+			max = r.Level
+		}
+	}
+	return max
+}
+
+// LevelFor returns the level of a request.
+func (p Policy) LevelFor(user string, groups []string, verb string, ref *ObjectRef, path string) Level {
+	for _, r := range p.Rules {
+		if r.matches(user, groups, verb, ref, path) {
+			return r.Level
+		}
+	}
+	return LevelNone
+}
+
+func (r PolicyRule) matches(user string, groups []string, verb string, ref *ObjectRef, path string) bool {
+	if len(r.Users) > 0 && !contains(r.Users, user) {
+		return false
+	}
+	if len(r.UserGroups) > 0 && !containsAny(r.UserGroups, groups) {
+		return false
+	}
+	if len(r.Verbs) > 0 && !contains(r.Verbs, verb) && !contains(r.Verbs, "*") {
+		return false
+	}
+	if len(r.NonResourceURLs) > 0 {
+		return matchesURL(r.NonResourceURLs, path)
+	}
+	if len(r.Resources) > 0 || len(r.Namespaces) > 0 {
+		if ref == nil {
+			return false
+		}
+		if len(r.Namespaces) > 0 && !contains(r.Namespaces, ref.Namespace) {
+			return false
+		}
+		if len(r.Resources) > 0 && !matchesResource(r.Resources, ref) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesResource(groups []GroupResources, ref *ObjectRef) bool {
+	resource := ref.Resource
+	if ref.Subresource != "" {
+		resource += "/" + ref.Subresource
+	}
+	for _, g := range groups {
+		if g.Group != "" {
+			continue
+		}
+		if len(g.Resources) > 0 && !contains(g.Resources, resource) && !contains(g.Resources, "*") &&
+			!(ref.Subresource != "" && contains(g.Resources, ref.Resource+"/*")) {
+			continue
+		}
+		if len(g.ResourceNames) > 0 && !contains(g.ResourceNames, ref.Name) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// matchesURL matches a path against URLs that are exact or end in "*".
+func matchesURL(urls []string, path string) bool {
+	for _, u := range urls {
+		if u == path || (strings.HasSuffix(u, "*") && strings.HasPrefix(path, strings.TrimSuffix(u, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list, values []string) bool {
+	for _, v := range values {
+		if contains(list, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bytes"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	webhookBufferSize    = 10000 // events waiting to be sent before new ones are dropped
+	webhookMaxBatchSize  = 400
+	webhookMaxBatchWait  = time.Second
+	webhookTimeout       = 10 * time.Second
+	webhookRetryInterval = time.Second
+)
+
+// EventList is the payload a WebhookBackend posts, shaped like a
+// Kubernetes audit.k8s.io/v1 EventList.
+type EventList struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Items      []*Event `json:"items"`
+}
+
+// WebhookBackend posts events in batches to an HTTP endpoint. Events are
+// buffered so a slow endpoint never holds up a request; when the buffer is
+// full new events are dropped and counted as errors.
+type WebhookBackend struct {
+	url    string
+	client *http.Client
+	events chan *Event
+	done   chan struct{}
+
+	mu     sync.RWMutex // Protects closed, so no event is sent on a closed channel
+	closed bool
+}
+
+// NewWebhookBackend starts posting events to rawURL. caFile is the CA bundle
+// to verify an https endpoint with, "" for the system roots.
+func NewWebhookBackend(rawURL, caFile string) (*WebhookBackend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("audit webhook URL must be an http or https URL")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("audit webhook CA: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("audit webhook CA: no PEM certificates found in %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	b := &WebhookBackend{
+		url:    rawURL,
+		client: &http.Client{Transport: transport, Timeout: webhookTimeout},
+		events: make(chan *Event, webhookBufferSize),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+// ProcessEvent implements Backend.
+func (b *WebhookBackend) ProcessEvent(ev *Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.events <- ev:
+	default:
+		metrics.AuditErrors.WithLabelValues(b.Name()).Inc()
+	}
+}
+
+// run collects events into batches, sending one when it is full or its
+// oldest event has waited long enough, until the channel is closed.
+func (b *WebhookBackend) run() {
+	defer close(b.done)
+	var batch []*Event
+	timer := time.NewTimer(webhookMaxBatchWait)
+	timer.Stop()
+	for {
+		select {
+		case ev, ok := <-b.events:
+			if !ok {
+				b.send(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(webhookMaxBatchWait)
+			}
+			batch = append(batch, ev)
+			if len(batch) < webhookMaxBatchSize {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+		b.send(batch)
+		batch = nil
+	}
+}
+
+// send posts a batch, retrying once before giving up on it.
+func (b *WebhookBackend) send(batch []*Event) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(EventList{APIVersion: "audit.k8s.io/v1", Kind: "EventList", Items: batch})
+	if err != nil {
+		metrics.AuditErrors.WithLabelValues(b.Name()).Add(float64(len(batch)))
+		logger.Error("Error encoding audit events", logging.Err(err))
+		return
+	}
+	if err = b.post(body); err != nil {
+		time.Sleep(webhookRetryInterval)
+		err = b.post(body)
+	}
+	if err != nil {
+		metrics.AuditErrors.WithLabelValues(b.Name()).Add(float64(len(batch)))
+		logger.Error("Error sending audit events", "events", len(batch), "url", b.url, logging.Err(err))
+		return
+	}
+	for _, ev := range batch {
+		metrics.AuditEvents.WithLabelValues(b.Name(), string(ev.Level)).Inc()
+	}
+}
+
+func (b *WebhookBackend) post(body []byte) error {
+	resp, err := b.client.Post(b.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements Backend. It sends the events still buffered.
+func (b *WebhookBackend) Shutdown() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.events)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+// Name implements Backend.
+func (b *WebhookBackend) Name() string { return "webhook" }
@@ -0,0 +1,213 @@
+// All the gin handlers are here for auth package
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to show who the request was authenticated as
+func WhoAmIHandler(c *gin.Context) {
+	user := UserFrom(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{"authenticated": false, "message": "Authentication is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authenticated": true, "user": user})
+}
+
+// CanIHandler returns the API Handler that checks whether the caller may
+// perform an action, as `auth can-i` asks.
+func CanIHandler(authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var attrs Attributes
+		if err := c.ShouldBindJSON(&attrs); err != nil || attrs.Verb == "" || attrs.Resource == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: verb and resource are required"})
+			return
+		}
+		attrs = withNamespace(attrs)
+		user := UserFrom(c)
+		if user == nil {
+			c.JSON(http.StatusOK, gin.H{"allowed": true, "reason": "authentication is disabled", "attributes": attrs})
+			return
+		}
+		allowed, reason := authz.Authorize(user, attrs)
+		c.JSON(http.StatusOK, gin.H{"allowed": allowed, "reason": reason, "attributes": attrs})
+	}
+}
+
+// API Handler to show every role and binding
+func (a *RBAC) PolicyHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, a.Policy())
+}
+
+// respondGrantError answers a request to save a role or binding that
+// failed, with 403 when the user may not grant it.
+func respondGrantError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrEscalation) {
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// AddRoleHandler returns the API Handler that creates or replaces a Role.
+// Users may only grant what authz already allows them in its namespace.
+func (a *RBAC) AddRoleHandler(authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role Role
+		if err := c.ShouldBindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		err := role.Validate()
+		if user := UserFrom(c); err == nil && user != nil {
+			err = CheckRole(authz, user, "roles", role.Namespace, role.Name, role.Rules)
+		}
+		if err == nil {
+			err = a.SetRole(role)
+		}
+		if err != nil {
+			respondGrantError(c, err)
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "Role saved", "namespace", role.Namespace, "name", role.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "Role saved", "name": role.Name})
+	}
+}
+
+// API Handler to list Roles, of one namespace with ?namespace=
+func (a *RBAC) ListRolesHandler(c *gin.Context) {
+	roles := a.Policy().Roles
+	if namespace := c.Query("namespace"); namespace != "" {
+		inNamespace := []Role{}
+		for _, r := range roles {
+			if r.Namespace == namespace {
+				inNamespace = append(inNamespace, r)
+			}
+		}
+		roles = inNamespace
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// AddClusterRoleHandler returns the API Handler that creates or replaces a
+// ClusterRole. Users may only grant what authz already allows them
+// cluster-wide.
+func (a *RBAC) AddClusterRoleHandler(authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role ClusterRole
+		if err := c.ShouldBindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		err := role.Validate()
+		if user := UserFrom(c); err == nil && user != nil {
+			err = CheckRole(authz, user, "clusterroles", "", role.Name, role.Rules)
+		}
+		if err == nil {
+			err = a.SetClusterRole(role)
+		}
+		if err != nil {
+			respondGrantError(c, err)
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "ClusterRole saved", "name", role.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "ClusterRole saved", "name": role.Name})
+	}
+}
+
+// API Handler to list ClusterRoles
+func (a *RBAC) ListClusterRolesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, a.Policy().ClusterRoles)
+}
+
+// AddRoleBindingHandler returns the API Handler that creates or replaces a
+// RoleBinding. Users may only bind roles whose rules authz already allows
+// them in the binding's namespace, unless they may bind the role.
+func (a *RBAC) AddRoleBindingHandler(authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var binding RoleBinding
+		if err := c.ShouldBindJSON(&binding); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		err := binding.Validate()
+		if user := UserFrom(c); err == nil && user != nil {
+			err = a.CheckBinding(authz, user, binding.Namespace, binding.RoleRef)
+		}
+		if err == nil {
+			err = a.SetRoleBinding(binding)
+		}
+		if err != nil {
+			respondGrantError(c, err)
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "RoleBinding saved", "namespace", binding.Namespace, "name", binding.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "RoleBinding saved", "name": binding.Name})
+	}
+}
+
+// API Handler to list RoleBindings, of one namespace with ?namespace=
+func (a *RBAC) ListRoleBindingsHandler(c *gin.Context) {
+	bindings := a.Policy().RoleBindings
+	if namespace := c.Query("namespace"); namespace != "" {
+		inNamespace := []RoleBinding{}
+		for _, b := range bindings {
+			if b.Namespace == namespace {
+				inNamespace = append(inNamespace, b)
+			}
+		}
+		bindings = inNamespace
+	}
+	c.JSON(http.StatusOK, bindings)
+}
+
+// AddClusterRoleBindingHandler returns the API Handler that creates or
+// replaces a ClusterRoleBinding. Users may only bind cluster roles whose
+// rules authz already allows them cluster-wide, unless they may bind the
+// role.
+func (a *RBAC) AddClusterRoleBindingHandler(authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var binding ClusterRoleBinding
+		if err := c.ShouldBindJSON(&binding); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		err := binding.Validate()
+		if user := UserFrom(c); err == nil && user != nil {
+			err = a.CheckBinding(authz, user, "", binding.RoleRef)
+		}
+		if err == nil {
+			err = a.SetClusterRoleBinding(binding)
+		}
+		if err != nil {
+			respondGrantError(c, err)
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "ClusterRoleBinding saved", "name", binding.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "ClusterRoleBinding saved", "name": binding.Name})
+	}
+}
+
+// API Handler to list ClusterRoleBindings
+func (a *RBAC) ListClusterRoleBindingsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, a.Policy().ClusterRoleBindings)
+}
+
+// DeleteHandler returns the API Handler that deletes a role or binding of
+// one kind, e.g. "rolebindings"; namespaced kinds take ?namespace=.
+func (a *RBAC) DeleteHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		err := a.Delete(kind, c.Query("namespace"), name)
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": kind + " " + name + " not found"})
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "Deleted", "kind", kind, "name", name)
+		c.JSON(http.StatusOK, gin.H{"message": "Deleted", "name": name})
+	}
+}
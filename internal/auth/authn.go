@@ -0,0 +1,207 @@
+// Package auth authenticates API requests and authorizes them with RBAC.
+// Authenticators turn a request into a User; the RBAC authorizer decides
+// whether that user may perform the request's verb on its resource.
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrInvalidCredentials is returned when a request carries credentials an
+// authenticator recognises as its kind but cannot accept.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// User is the identity a request was authenticated as.
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Authenticator identifies the user behind a request. It returns false if
+// the request carries none of the credentials it handles, and an error if
+// they are invalid.
+type Authenticator interface {
+	AuthenticateRequest(r *http.Request) (*User, bool, error)
+}
+
+// Union tries each authenticator in turn and accepts the first identity.
+type Union []Authenticator
+
+// AuthenticateRequest implements Authenticator.
+func (u Union) AuthenticateRequest(r *http.Request) (*User, bool, error) {
+	var errs []error
+	for _, a := range u {
+		user, ok, err := a.AuthenticateRequest(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			return user, true, nil
+		}
+	}
+	return nil, false, errors.Join(errs...)
+}
+
+// credential is one line of a token or password file.
+type credential struct {
+	secret string
+	user   User
+}
+
+// readCredentials parses a file of "secret,user,uid,groups" lines, as
+// Kubernetes static token and password files are laid out. The uid is
+// ignored and the groups, a quoted comma-separated list, are optional.
+// Lines starting with # are comments.
+func readCredentials(path string) ([]credential, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	var creds []credential
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("%s:%d: expected secret,user[,uid[,groups]]", path, line)
+		}
+		c := credential{secret: record[0], user: User{Name: record[1]}}
+		if len(record) > 3 && record[3] != "" {
+			for _, g := range strings.Split(record[3], ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					c.user.Groups = append(c.user.Groups, g)
+				}
+			}
+		}
+		creds = append(creds, c)
+	}
+	return creds, nil
+}
+
+// TokenFile authenticates "Authorization: Bearer <token>" headers against
+// a static token file.
+type TokenFile struct {
+	tokens map[string]User
+}
+
+// NewTokenFile loads a token file of "token,user,uid,groups" lines.
+func NewTokenFile(path string) (*TokenFile, error) {
+	creds, err := readCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &TokenFile{tokens: make(map[string]User, len(creds))}
+	for _, c := range creds {
+		t.tokens[c.secret] = c.user
+	}
+	return t, nil
+}
+
+// AuthenticateRequest implements Authenticator.
+func (t *TokenFile) AuthenticateRequest(r *http.Request) (*User, bool, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, false, nil
+	}
+	user, exists := t.tokens[strings.TrimSpace(token)]
+	if !exists {
+		return nil, false, fmt.Errorf("%w: unknown bearer token", ErrInvalidCredentials)
+	}
+	return &user, true, nil
+}
+
+// BasicAuthFile authenticates HTTP basic auth against a password file.
+type BasicAuthFile struct {
+	users map[string]credential
+}
+
+// NewBasicAuthFile loads a password file of "password,user,uid,groups" lines.
+func NewBasicAuthFile(path string) (*BasicAuthFile, error) {
+	creds, err := readCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &BasicAuthFile{users: make(map[string]credential, len(creds))}
+	for _, c := range creds {
+		b.users[c.user.Name] = c
+	}
+	return b, nil
+}
+
+// AuthenticateRequest implements Authenticator.
+func (b *BasicAuthFile) AuthenticateRequest(r *http.Request) (*User, bool, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, false, nil
+	}
+	c, exists := b.users[name]
+	if !exists || subtle.ConstantTimeCompare([]byte(c.secret), []byte(password)) != 1 {
+		return nil, false, fmt.Errorf("%w: wrong username or password", ErrInvalidCredentials)
+	}
+	return &c.user, true, nil
+}
+
+// ClientCert authenticates requests made over TLS with a client certificate
+// signed by one of its CAs. The certificate's common name is the user and
+// its organizations are the groups.
+type ClientCert struct {
+	roots *x509.CertPool
+}
+
+// NewClientCert trusts the PEM-encoded CA certificates in caFile.
+func NewClientCert(caFile string) (*ClientCert, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", caFile)
+	}
+	return &ClientCert{roots: roots}, nil
+}
+
+// AuthenticateRequest implements Authenticator.
+func (a *ClientCert) AuthenticateRequest(r *http.Request) (*User, bool, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, false, nil
+	}
+	cert := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         a.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: client certificate: %v", ErrInvalidCredentials, err)
+	}
+	if cert.Subject.CommonName == "" {
+		return nil, false, fmt.Errorf("%w: client certificate has no common name", ErrInvalidCredentials)
+	}
+	return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization}, true, nil
+}
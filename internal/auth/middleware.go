@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"bytes"
+	"cluster-sim/internal/logging"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userKey is where the middleware stores the authenticated user in the
+// gin context.
+const userKey = "auth.user"
+
+// attributesKey is where the middleware stores the attributes it
+// authorized the request with, so later readers see the same ones.
+const attributesKey = "auth.attributes"
+
+var logger = logging.For("auth")
+
+// Attributes describe what a request does, in the terms RBAC rules use.
+type Attributes struct {
+	Verb        string `json:"verb"` // get, list, watch, create, update, patch or delete
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Namespace   string `json:"namespace,omitempty"` // "" for cluster-scoped resources
+}
+
+// clusterScoped lists the resources that do not live in a namespace; Roles
+// cannot grant access to them.
+var clusterScoped = map[string]bool{
+	"nodes":               true,
+	"nodegroups":          true,
+	"nodetemplates":       true,
+	"autoscaler":          true,
+	"pvs":                 true,
+	"storageclasses":      true,
+	"network":             true,
+	"ipam":                true,
+	"rbac":                true,
+	"admission":           true,
+	"namespaces":          true,
+	"resourcequotas":      true,
+	"dns":                 true,
+	"metrics":             true,
+	"clusterroles":        true,
+	"clusterrolebindings": true,
+	"loglevels":           true,
+}
+
+// ownNamespaces lists the namespaced resources whose objects each name
+// their namespace. The objects of the other namespaced resources all live
+// in DefaultNamespace.
+var ownNamespaces = map[string]bool{
+	"pods":         true,
+	"roles":        true,
+	"rolebindings": true,
+}
+
+// namespaceLookups find the namespace of a stored object by its name, by
+// resource; see SetNamespaceLookup.
+var namespaceLookups = struct {
+	sync.RWMutex
+	byResource map[string]func(name string) (string, bool)
+}{byResource: make(map[string]func(string) (string, bool))}
+
+// SetNamespaceLookup registers how to find the namespace of a stored object
+// of resource, so that requests naming the object are authorized in its
+// namespace rather than in the one they claim.
+func SetNamespaceLookup(resource string, lookup func(name string) (namespace string, exists bool)) {
+	namespaceLookups.Lock()
+	defer namespaceLookups.Unlock()
+	namespaceLookups.byResource[resource] = lookup
+}
+
+// legacyRoutes maps the action-style routes that predate the resource
+// paths to what they do.
+var legacyRoutes = map[string]Attributes{
+	"/add_node":     {Verb: "create", Resource: "nodes"},
+	"/add_pod":      {Verb: "create", Resource: "pods"},
+	"/restart_node": {Verb: "update", Resource: "nodes", Subresource: "restart"},
+	"/delete_node":  {Verb: "delete", Resource: "nodes"},
+}
+
+// selfRoutes tell users about their own identity and access, so every
+// authenticated user may call them.
+var selfRoutes = map[string]bool{
+	"/auth/whoami": true,
+	"/auth/can-i":  true,
+}
+
+// RequestAttributes derives the attributes of a request from its route:
+// the first path segment is the resource, a parameter after it names the
+// object and a literal segment is a subresource or action, as in
+// /nodes/:id/drain. The verb follows from the method and the namespace is
+// found by requestNamespace. Once the middleware has authorized a request
+// the attributes it used are returned.
+func RequestAttributes(c *gin.Context) Attributes {
+	if v, exists := c.Get(attributesKey); exists {
+		return v.(Attributes)
+	}
+	route := c.FullPath()
+	if attrs, exists := legacyRoutes[route]; exists {
+		attrs.Namespace = requestNamespace(c, attrs)
+		return attrs
+	}
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	attrs := Attributes{Resource: segments[0]}
+	for _, s := range segments[1:] {
+		switch {
+		case strings.HasPrefix(s, ":") && attrs.Name == "":
+			attrs.Name = c.Param(s[1:])
+		case !strings.HasPrefix(s, ":") && attrs.Subresource == "":
+			attrs.Subresource = s
+		}
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case c.Query("watch") == "true":
+			attrs.Verb = "watch"
+		case attrs.Name == "" && attrs.Subresource == "":
+			attrs.Verb = "list"
+		default:
+			attrs.Verb = "get"
+		}
+	case http.MethodPost:
+		attrs.Verb = "create"
+	case http.MethodPut:
+		attrs.Verb = "update"
+	case http.MethodPatch:
+		attrs.Verb = "patch"
+	case http.MethodDelete:
+		attrs.Verb = "delete"
+	default:
+		attrs.Verb = strings.ToLower(c.Request.Method)
+	}
+	attrs.Namespace = requestNamespace(c, attrs)
+	return attrs
+}
+
+// requestNamespace finds the namespace a request acts in. An object that
+// is already stored is in its own namespace, whatever the request says.
+// Otherwise the namespace is the one in ?namespace= or in the body, as for
+// a new pod. A list or watch that gives none covers every namespace and is
+// only authorized cluster-wide, with "". Anything else is in
+// DefaultNamespace.
+func requestNamespace(c *gin.Context, attrs Attributes) string {
+	if clusterScoped[attrs.Resource] {
+		return ""
+	}
+	if !ownNamespaces[attrs.Resource] {
+		return DefaultNamespace
+	}
+	if attrs.Name != "" {
+		namespaceLookups.RLock()
+		lookup := namespaceLookups.byResource[attrs.Resource]
+		namespaceLookups.RUnlock()
+		if lookup != nil {
+			if namespace, exists := lookup(attrs.Name); exists {
+				return namespace
+			}
+		}
+	}
+	if namespace := c.Query("namespace"); namespace != "" {
+		return namespace
+	}
+	if namespace := bodyNamespace(c); namespace != "" {
+		return namespace
+	}
+	if attrs.Verb == "list" || attrs.Verb == "watch" {
+		return ""
+	}
+	return DefaultNamespace
+}
+
+// bodyNamespace returns the namespace field of a JSON request body, and
+// leaves the body for the handler to read.
+func bodyNamespace(c *gin.Context) string {
+	if c.Request.Body == nil || c.Request.Method == http.MethodGet {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var object struct {
+		Namespace string `json:"namespace"`
+	}
+	if json.Unmarshal(body, &object) != nil {
+		return ""
+	}
+	return object.Namespace
+}
+
+// withNamespace puts attributes that name no namespace in DefaultNamespace,
+// and takes cluster-scoped ones out of any.
+func withNamespace(attrs Attributes) Attributes {
+	switch {
+	case clusterScoped[attrs.Resource]:
+		attrs.Namespace = ""
+	case attrs.Namespace == "":
+		attrs.Namespace = DefaultNamespace
+	}
+	return attrs
+}
+
+// Middleware authenticates every request and authorizes it against its
+// route. Requests without valid credentials get 401, those the user may
+// not make 403.
+func Middleware(authn Authenticator, authz Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok, err := authn.AuthenticateRequest(c.Request)
+		if err != nil || !ok {
+			message := "Unauthorized"
+			if err != nil {
+				message += ": " + err.Error()
+			}
+			c.Header("WWW-Authenticate", `Basic realm="cluster-sim"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+			return
+		}
+		c.Set(userKey, user)
+
+		// Unknown routes fall through to a 404.
+		if c.FullPath() == "" || selfRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		attrs := RequestAttributes(c)
+		c.Set(attributesKey, attrs)
+		if allowed, _ := authz.Authorize(user, attrs); !allowed {
+			logger.WarnContext(c.Request.Context(), "Forbidden", "user", user.Name, "action", describe(attrs))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("Forbidden: user %q cannot %s", user.Name, describe(attrs)),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// describe renders attributes for messages, e.g. `delete resource "nodes"
+// named "node_1"`.
+func describe(attrs Attributes) string {
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	s := fmt.Sprintf("%s resource %q", attrs.Verb, resource)
+	if attrs.Name != "" {
+		s += fmt.Sprintf(" named %q", attrs.Name)
+	}
+	if attrs.Namespace != "" {
+		s += fmt.Sprintf(" in namespace %q", attrs.Namespace)
+	}
+	return s
+}
+
+// UserFrom returns the user the middleware authenticated the request as,
+// or nil when authentication is disabled.
+func UserFrom(c *gin.Context) *User {
+	if v, exists := c.Get(userKey); exists {
+		return v.(*User)
+	}
+	return nil
+}
+
+// Anonymous is who requests are made by when authentication is disabled.
+var Anonymous = User{Name: "system:anonymous", Groups: []string{"system:unauthenticated"}}
+
+// RequestUser is UserFrom, but returns Anonymous when authentication is
+// disabled, for code that must name who made a request.
+func RequestUser(c *gin.Context) *User {
+	if user := UserFrom(c); user != nil {
+		return user
+	}
+	anonymous := Anonymous
+	return &anonymous
+}
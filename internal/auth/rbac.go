@@ -0,0 +1,505 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultNamespace is the namespace every namespaced object of the
+// simulator lives in, so it is the one Roles and RoleBindings are useful in.
+const DefaultNamespace = "default"
+
+// MastersGroup is allowed everything, whatever the RBAC policy says, so a
+// bad policy cannot lock every administrator out.
+const MastersGroup = "system:masters"
+
+// Subject kinds of a binding.
+const (
+	KindUser  = "User"
+	KindGroup = "Group"
+)
+
+// Role reference kinds of a binding.
+const (
+	KindRole        = "Role"
+	KindClusterRole = "ClusterRole"
+)
+
+// ErrNotFound is returned when a role or binding does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrEscalation is returned when a user tries to grant access they do not
+// have themselves.
+var ErrEscalation = errors.New("privilege escalation")
+
+// PolicyRule allows its verbs on its resources. "*" matches any verb or
+// resource, a resource may name a subresource as "nodes/drain" or all of
+// them as "nodes/*", and an empty ResourceNames matches every object.
+type PolicyRule struct {
+	Verbs         []string `json:"verbs" yaml:"verbs"`
+	Resources     []string `json:"resources" yaml:"resources"`
+	ResourceNames []string `json:"resource_names,omitempty" yaml:"resource_names,omitempty"`
+}
+
+// Role grants its rules on the namespaced resources of one namespace.
+type Role struct {
+	Name      string       `json:"name" yaml:"name"`
+	Namespace string       `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Rules     []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// ClusterRole is a set of rules usable across the cluster, or in one
+// namespace when a RoleBinding refers to it.
+type ClusterRole struct {
+	Name  string       `json:"name" yaml:"name"`
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// Subject is a user or group a binding grants a role to.
+type Subject struct {
+	Kind string `json:"kind" yaml:"kind"` // User or Group
+	Name string `json:"name" yaml:"name"`
+}
+
+// RoleRef names the role a binding grants.
+type RoleRef struct {
+	Kind string `json:"kind" yaml:"kind"` // Role or ClusterRole
+	Name string `json:"name" yaml:"name"`
+}
+
+// RoleBinding grants a Role, or a ClusterRole, within its namespace.
+type RoleBinding struct {
+	Name      string    `json:"name" yaml:"name"`
+	Namespace string    `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	RoleRef   RoleRef   `json:"role_ref" yaml:"role_ref"`
+	Subjects  []Subject `json:"subjects" yaml:"subjects"`
+}
+
+// ClusterRoleBinding grants a ClusterRole across the cluster.
+type ClusterRoleBinding struct {
+	Name     string    `json:"name" yaml:"name"`
+	RoleRef  RoleRef   `json:"role_ref" yaml:"role_ref"`
+	Subjects []Subject `json:"subjects" yaml:"subjects"`
+}
+
+// Policy is every role and binding, as read from a policy file.
+type Policy struct {
+	Roles               []Role               `json:"roles" yaml:"roles"`
+	ClusterRoles        []ClusterRole        `json:"cluster_roles" yaml:"cluster_roles"`
+	RoleBindings        []RoleBinding        `json:"role_bindings" yaml:"role_bindings"`
+	ClusterRoleBindings []ClusterRoleBinding `json:"cluster_role_bindings" yaml:"cluster_role_bindings"`
+}
+
+func validateRules(rules []PolicyRule) error {
+	for i, r := range rules {
+		if len(r.Verbs) == 0 || len(r.Resources) == 0 {
+			return fmt.Errorf("rule %d needs verbs and resources", i)
+		}
+	}
+	return nil
+}
+
+func validateBinding(ref RoleRef, subjects []Subject) error {
+	if ref.Name == "" || (ref.Kind != KindRole && ref.Kind != KindClusterRole) {
+		return fmt.Errorf("role_ref needs a name and a kind of Role or ClusterRole")
+	}
+	if len(subjects) == 0 {
+		return fmt.Errorf("at least one subject is required")
+	}
+	for _, s := range subjects {
+		if s.Name == "" || (s.Kind != KindUser && s.Kind != KindGroup) {
+			return fmt.Errorf("subjects need a name and a kind of User or Group")
+		}
+	}
+	return nil
+}
+
+// Validate fills in defaults and checks the role is well formed.
+func (r *Role) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Namespace == "" {
+		r.Namespace = DefaultNamespace
+	}
+	return validateRules(r.Rules)
+}
+
+// Validate checks the cluster role is well formed.
+func (r *ClusterRole) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return validateRules(r.Rules)
+}
+
+// Validate fills in defaults and checks the binding is well formed.
+func (b *RoleBinding) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if b.Namespace == "" {
+		b.Namespace = DefaultNamespace
+	}
+	return validateBinding(b.RoleRef, b.Subjects)
+}
+
+// Validate checks the binding is well formed.
+func (b *ClusterRoleBinding) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if b.RoleRef.Kind != KindClusterRole {
+		return fmt.Errorf("a ClusterRoleBinding can only refer to a ClusterRole")
+	}
+	return validateBinding(b.RoleRef, b.Subjects)
+}
+
+// LoadPolicy reads a YAML or JSON policy file.
+func LoadPolicy(path string) (Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Authorizer decides whether a user may make a request.
+type Authorizer interface {
+	// Authorize returns whether the request is allowed and, if so, why.
+	Authorize(user *User, attrs Attributes) (bool, string)
+}
+
+// AlwaysAllow lets every authenticated user do everything.
+type AlwaysAllow struct{}
+
+// Authorize implements Authorizer.
+func (AlwaysAllow) Authorize(*User, Attributes) (bool, string) {
+	return true, "authorization is disabled"
+}
+
+// namespaced is the key of a namespaced role or binding.
+type namespaced struct {
+	namespace string
+	name      string
+}
+
+// RBAC authorizes requests with roles and bindings.
+type RBAC struct {
+	mu                  sync.RWMutex // Protects the maps below
+	roles               map[namespaced]Role
+	clusterRoles        map[string]ClusterRole
+	roleBindings        map[namespaced]RoleBinding
+	clusterRoleBindings map[string]ClusterRoleBinding
+}
+
+// NewRBAC returns an authorizer holding the roles and bindings of policy.
+func NewRBAC(policy Policy) (*RBAC, error) {
+	a := &RBAC{
+		roles:               make(map[namespaced]Role),
+		clusterRoles:        make(map[string]ClusterRole),
+		roleBindings:        make(map[namespaced]RoleBinding),
+		clusterRoleBindings: make(map[string]ClusterRoleBinding),
+	}
+	for _, r := range policy.Roles {
+		if err := a.SetRole(r); err != nil {
+			return nil, fmt.Errorf("role %s: %v", r.Name, err)
+		}
+	}
+	for _, r := range policy.ClusterRoles {
+		if err := a.SetClusterRole(r); err != nil {
+			return nil, fmt.Errorf("cluster role %s: %v", r.Name, err)
+		}
+	}
+	for _, b := range policy.RoleBindings {
+		if err := a.SetRoleBinding(b); err != nil {
+			return nil, fmt.Errorf("role binding %s: %v", b.Name, err)
+		}
+	}
+	for _, b := range policy.ClusterRoleBindings {
+		if err := a.SetClusterRoleBinding(b); err != nil {
+			return nil, fmt.Errorf("cluster role binding %s: %v", b.Name, err)
+		}
+	}
+	return a, nil
+}
+
+// SetRole creates or replaces a role.
+func (a *RBAC) SetRole(r Role) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.roles[namespaced{r.Namespace, r.Name}] = r
+	return nil
+}
+
+// SetClusterRole creates or replaces a cluster role.
+func (a *RBAC) SetClusterRole(r ClusterRole) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clusterRoles[r.Name] = r
+	return nil
+}
+
+// SetRoleBinding creates or replaces a role binding.
+func (a *RBAC) SetRoleBinding(b RoleBinding) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.roleBindings[namespaced{b.Namespace, b.Name}] = b
+	return nil
+}
+
+// SetClusterRoleBinding creates or replaces a cluster role binding.
+func (a *RBAC) SetClusterRoleBinding(b ClusterRoleBinding) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clusterRoleBindings[b.Name] = b
+	return nil
+}
+
+// Delete removes a role or binding of the given kind ("roles",
+// "clusterroles", "rolebindings" or "clusterrolebindings").
+func (a *RBAC) Delete(kind, namespace, name string) error {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	key := namespaced{namespace, name}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var exists bool
+	switch kind {
+	case "roles":
+		_, exists = a.roles[key]
+		delete(a.roles, key)
+	case "clusterroles":
+		_, exists = a.clusterRoles[name]
+		delete(a.clusterRoles, name)
+	case "rolebindings":
+		_, exists = a.roleBindings[key]
+		delete(a.roleBindings, key)
+	case "clusterrolebindings":
+		_, exists = a.clusterRoleBindings[name]
+		delete(a.clusterRoleBindings, name)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Policy returns every role and binding, by namespace and name.
+func (a *RBAC) Policy() Policy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	p := Policy{
+		Roles:               []Role{},
+		ClusterRoles:        []ClusterRole{},
+		RoleBindings:        []RoleBinding{},
+		ClusterRoleBindings: []ClusterRoleBinding{},
+	}
+	for _, r := range a.roles {
+		p.Roles = append(p.Roles, r)
+	}
+	for _, r := range a.clusterRoles {
+		p.ClusterRoles = append(p.ClusterRoles, r)
+	}
+	for _, b := range a.roleBindings {
+		p.RoleBindings = append(p.RoleBindings, b)
+	}
+	for _, b := range a.clusterRoleBindings {
+		p.ClusterRoleBindings = append(p.ClusterRoleBindings, b)
+	}
+	sort.Slice(p.Roles, func(i, j int) bool {
+		return p.Roles[i].Namespace+"/"+p.Roles[i].Name < p.Roles[j].Namespace+"/"+p.Roles[j].Name
+	})
+	sort.Slice(p.ClusterRoles, func(i, j int) bool { return p.ClusterRoles[i].Name < p.ClusterRoles[j].Name })
+	sort.Slice(p.RoleBindings, func(i, j int) bool {
+		return p.RoleBindings[i].Namespace+"/"+p.RoleBindings[i].Name < p.RoleBindings[j].Namespace+"/"+p.RoleBindings[j].Name
+	})
+	sort.Slice(p.ClusterRoleBindings, func(i, j int) bool {
+		return p.ClusterRoleBindings[i].Name < p.ClusterRoleBindings[j].Name
+	})
+	return p
+}
+
+// Authorize implements Authorizer: the request is allowed if a binding
+// that applies to it grants the user, or one of their groups, a role with
+// a matching rule.
+func (a *RBAC) Authorize(user *User, attrs Attributes) (bool, string) {
+	for _, g := range user.Groups {
+		if g == MastersGroup {
+			return true, "member of " + MastersGroup
+		}
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, b := range a.clusterRoleBindings {
+		if !bindsUser(b.Subjects, user) {
+			continue
+		}
+		if r, exists := a.clusterRoles[b.RoleRef.Name]; exists && rulesAllow(r.Rules, attrs) {
+			return true, fmt.Sprintf("allowed by ClusterRoleBinding %q of ClusterRole %q", b.Name, r.Name)
+		}
+	}
+	if attrs.Namespace == "" {
+		// Roles only reach into their namespace.
+		return false, ""
+	}
+	for key, b := range a.roleBindings {
+		if key.namespace != attrs.Namespace || !bindsUser(b.Subjects, user) {
+			continue
+		}
+		var rules []PolicyRule
+		if b.RoleRef.Kind == KindClusterRole {
+			rules = a.clusterRoles[b.RoleRef.Name].Rules
+		} else {
+			rules = a.roles[namespaced{key.namespace, b.RoleRef.Name}].Rules
+		}
+		if rulesAllow(rules, attrs) {
+			return true, fmt.Sprintf("allowed by RoleBinding %q of %s %q in namespace %q", b.Name, b.RoleRef.Kind, b.RoleRef.Name, key.namespace)
+		}
+	}
+	return false, ""
+}
+
+// CheckGrant returns ErrEscalation unless authz already allows user
+// everything rules allow, in namespace or cluster-wide for "". A role
+// cannot be used to hand out more than its author has.
+func CheckGrant(authz Authorizer, user *User, namespace string, rules []PolicyRule) error {
+	for _, r := range rules {
+		names := r.ResourceNames
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, verb := range r.Verbs {
+			for _, resource := range r.Resources {
+				attrs := Attributes{Verb: verb, Namespace: namespace}
+				attrs.Resource, attrs.Subresource, _ = strings.Cut(resource, "/")
+				if clusterScoped[attrs.Resource] {
+					attrs.Namespace = ""
+				}
+				for _, name := range names {
+					attrs.Name = name
+					if allowed, _ := authz.Authorize(user, attrs); !allowed {
+						return fmt.Errorf("%w: user %q cannot %s", ErrEscalation, user.Name, describe(attrs))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRole returns ErrEscalation unless user may save a role named name
+// with rules: kind is "roles" for a Role in namespace or "clusterroles".
+// The user must hold every rule already, or have the escalate verb on the
+// role.
+func CheckRole(authz Authorizer, user *User, kind, namespace, name string, rules []PolicyRule) error {
+	err := CheckGrant(authz, user, namespace, rules)
+	if err == nil {
+		return nil
+	}
+	escalate := Attributes{Verb: "escalate", Resource: kind, Name: name, Namespace: namespace}
+	if allowed, _ := authz.Authorize(user, escalate); allowed {
+		return nil
+	}
+	return err
+}
+
+// CheckBinding returns ErrEscalation unless user may bind ref in namespace,
+// or cluster-wide for "". The user must hold every rule of the role
+// already, or have the bind verb on it. Binding a role that does not exist
+// yet always takes bind, since nobody knows what it will grant.
+func (a *RBAC) CheckBinding(authz Authorizer, user *User, namespace string, ref RoleRef) error {
+	bind := Attributes{Verb: "bind", Resource: "clusterroles", Name: ref.Name, Namespace: namespace}
+	if ref.Kind == KindRole {
+		bind.Resource = "roles"
+	}
+	if allowed, _ := authz.Authorize(user, bind); allowed {
+		return nil
+	}
+	a.mu.RLock()
+	var rules []PolicyRule
+	var exists bool
+	if ref.Kind == KindRole {
+		var r Role
+		r, exists = a.roles[namespaced{namespace, ref.Name}]
+		rules = r.Rules
+	} else {
+		var r ClusterRole
+		r, exists = a.clusterRoles[ref.Name]
+		rules = r.Rules
+	}
+	a.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %s %q does not exist and user %q cannot %s", ErrEscalation, ref.Kind, ref.Name, user.Name, describe(bind))
+	}
+	return CheckGrant(authz, user, namespace, rules)
+}
+
+func bindsUser(subjects []Subject, user *User) bool {
+	for _, s := range subjects {
+		switch s.Kind {
+		case KindUser:
+			if s.Name == user.Name {
+				return true
+			}
+		case KindGroup:
+			for _, g := range user.Groups {
+				if s.Name == g {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func rulesAllow(rules []PolicyRule, attrs Attributes) bool {
+	for _, r := range rules {
+		if ruleAllows(r, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleAllows(r PolicyRule, attrs Attributes) bool {
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	return matches(r.Verbs, attrs.Verb) &&
+		(matches(r.Resources, resource) || (attrs.Subresource != "" && matches(r.Resources, attrs.Resource+"/*"))) &&
+		(len(r.ResourceNames) == 0 || (attrs.Name != "" && matches(r.ResourceNames, attrs.Name)))
+}
+
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
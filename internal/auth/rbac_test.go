@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+// testPolicy lets viewers read everything, pod-admins manage pods in
+// team-a, and drainers drain one node.
+var testPolicy = Policy{
+	ClusterRoles: []ClusterRole{
+		{Name: "view", Rules: []PolicyRule{{Verbs: []string{"get", "list", "watch"}, Resources: []string{"*"}}}},
+		{Name: "pod-admin", Rules: []PolicyRule{{Verbs: []string{"*"}, Resources: []string{"pods", "pods/*"}}}},
+		{Name: "drainer", Rules: []PolicyRule{{Verbs: []string{"create"}, Resources: []string{"nodes/drain"}, ResourceNames: []string{"node-1"}}}},
+	},
+	Roles: []Role{
+		{Name: "deployer", Namespace: "team-a", Rules: []PolicyRule{{Verbs: []string{"create", "delete"}, Resources: []string{"podgroups"}}}},
+		{Name: "binder", Namespace: "team-a", Rules: []PolicyRule{
+			{Verbs: []string{"create"}, Resources: []string{"rolebindings"}},
+			{Verbs: []string{"bind"}, Resources: []string{"clusterroles"}, ResourceNames: []string{"view"}},
+		}},
+		{Name: "escalator", Namespace: "team-a", Rules: []PolicyRule{{Verbs: []string{"escalate"}, Resources: []string{"roles"}}}},
+	},
+	ClusterRoleBindings: []ClusterRoleBinding{
+		{Name: "viewers", RoleRef: RoleRef{Kind: KindClusterRole, Name: "view"}, Subjects: []Subject{{Kind: KindGroup, Name: "viewers"}}},
+		{Name: "drain", RoleRef: RoleRef{Kind: KindClusterRole, Name: "drainer"}, Subjects: []Subject{{Kind: KindUser, Name: "ops"}}},
+	},
+	RoleBindings: []RoleBinding{
+		{Name: "alice-pods", Namespace: "team-a", RoleRef: RoleRef{Kind: KindClusterRole, Name: "pod-admin"}, Subjects: []Subject{{Kind: KindUser, Name: "alice"}}},
+		{Name: "alice-deploy", Namespace: "team-a", RoleRef: RoleRef{Kind: KindRole, Name: "deployer"}, Subjects: []Subject{{Kind: KindUser, Name: "alice"}}},
+		{Name: "bob-bind", Namespace: "team-a", RoleRef: RoleRef{Kind: KindRole, Name: "binder"}, Subjects: []Subject{{Kind: KindUser, Name: "bob"}}},
+		{Name: "carol-escalate", Namespace: "team-a", RoleRef: RoleRef{Kind: KindRole, Name: "escalator"}, Subjects: []Subject{{Kind: KindUser, Name: "carol"}}},
+	},
+}
+
+func newTestRBAC(t *testing.T) *RBAC {
+	t.Helper()
+	a, err := NewRBAC(testPolicy)
+	if err != nil {
+		t.Fatalf("NewRBAC: %v", err)
+	}
+	return a
+}
+
+func TestRBACAuthorize(t *testing.T) {
+	a := newTestRBAC(t)
+	alice := &User{Name: "alice"}
+	tests := []struct {
+		name  string
+		user  *User
+		attrs Attributes
+		want  bool
+	}{
+		{"masters allowed anything", &User{Name: "root", Groups: []string{MastersGroup}}, Attributes{Verb: "delete", Resource: "nodes"}, true},
+		{"no bindings", &User{Name: "nobody"}, Attributes{Verb: "get", Resource: "pods", Namespace: "default"}, false},
+		{"group through cluster binding", &User{Name: "v", Groups: []string{"viewers"}}, Attributes{Verb: "list", Resource: "nodes"}, true},
+		{"cluster binding in any namespace", &User{Name: "v", Groups: []string{"viewers"}}, Attributes{Verb: "get", Resource: "pods", Namespace: "team-b"}, true},
+		{"verb not granted", &User{Name: "v", Groups: []string{"viewers"}}, Attributes{Verb: "delete", Resource: "pods", Namespace: "default"}, false},
+		{"cluster role bound in namespace", alice, Attributes{Verb: "delete", Resource: "pods", Name: "p1", Namespace: "team-a"}, true},
+		{"subresource by wildcard", alice, Attributes{Verb: "create", Resource: "pods", Subresource: "eviction", Namespace: "team-a"}, true},
+		{"role binding stays in its namespace", alice, Attributes{Verb: "delete", Resource: "pods", Namespace: "team-b"}, false},
+		{"role binding not cluster-wide", alice, Attributes{Verb: "list", Resource: "pods"}, false},
+		{"role in its namespace", alice, Attributes{Verb: "create", Resource: "podgroups", Namespace: "team-a"}, true},
+		{"resource not granted", alice, Attributes{Verb: "create", Resource: "jobs", Namespace: "team-a"}, false},
+		{"resource name matches", &User{Name: "ops"}, Attributes{Verb: "create", Resource: "nodes", Subresource: "drain", Name: "node-1"}, true},
+		{"resource name differs", &User{Name: "ops"}, Attributes{Verb: "create", Resource: "nodes", Subresource: "drain", Name: "node-2"}, false},
+		{"resource name needs a name", &User{Name: "ops"}, Attributes{Verb: "create", Resource: "nodes", Subresource: "drain"}, false},
+		{"subresource is not the resource", &User{Name: "ops"}, Attributes{Verb: "create", Resource: "nodes", Name: "node-1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := a.Authorize(tt.user, tt.attrs)
+			if got != tt.want {
+				t.Errorf("Authorize(%s, %+v) = %v, want %v", tt.user.Name, tt.attrs, got, tt.want)
+			}
+			if got && reason == "" {
+				t.Errorf("Authorize(%s, %+v) gave no reason", tt.user.Name, tt.attrs)
+			}
+		})
+	}
+}
+
+func TestRBACDelete(t *testing.T) {
+	a := newTestRBAC(t)
+	alice := &User{Name: "alice"}
+	attrs := Attributes{Verb: "get", Resource: "pods", Namespace: "team-a"}
+	if allowed, _ := a.Authorize(alice, attrs); !allowed {
+		t.Fatalf("alice cannot get pods before the binding is deleted")
+	}
+	if err := a.Delete("rolebindings", "team-a", "alice-pods"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if allowed, _ := a.Authorize(alice, attrs); allowed {
+		t.Errorf("alice can still get pods after the binding is deleted")
+	}
+	if err := a.Delete("rolebindings", "team-a", "alice-pods"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of a deleted binding = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewRBACInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+	}{
+		{"role without name", Policy{Roles: []Role{{Rules: []PolicyRule{{Verbs: []string{"get"}, Resources: []string{"pods"}}}}}}},
+		{"rule without verbs", Policy{ClusterRoles: []ClusterRole{{Name: "r", Rules: []PolicyRule{{Resources: []string{"pods"}}}}}}},
+		{"binding without subjects", Policy{RoleBindings: []RoleBinding{{Name: "b", RoleRef: RoleRef{Kind: KindRole, Name: "r"}}}}},
+		{"binding to unknown kind", Policy{RoleBindings: []RoleBinding{{Name: "b", RoleRef: RoleRef{Kind: "Thing", Name: "r"}, Subjects: []Subject{{Kind: KindUser, Name: "u"}}}}}},
+		{"subject of unknown kind", Policy{RoleBindings: []RoleBinding{{Name: "b", RoleRef: RoleRef{Kind: KindRole, Name: "r"}, Subjects: []Subject{{Kind: "Robot", Name: "u"}}}}}},
+		{"cluster binding to a role", Policy{ClusterRoleBindings: []ClusterRoleBinding{{Name: "b", RoleRef: RoleRef{Kind: KindRole, Name: "r"}, Subjects: []Subject{{Kind: KindUser, Name: "u"}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRBAC(tt.policy); err == nil {
+				t.Errorf("NewRBAC succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCheckRole(t *testing.T) {
+	a := newTestRBAC(t)
+	podRules := []PolicyRule{{Verbs: []string{"get", "delete"}, Resources: []string{"pods"}}}
+	jobRules := []PolicyRule{{Verbs: []string{"create"}, Resources: []string{"jobs"}}}
+	nodeRules := []PolicyRule{{Verbs: []string{"delete"}, Resources: []string{"nodes"}}}
+	tests := []struct {
+		name      string
+		user      *User
+		kind      string
+		namespace string
+		rules     []PolicyRule
+		wantErr   bool
+	}{
+		{"rules the user holds", &User{Name: "alice"}, "roles", "team-a", podRules, false},
+		{"rules held in another namespace only", &User{Name: "alice"}, "roles", "team-b", podRules, true},
+		{"rules the user lacks", &User{Name: "alice"}, "roles", "team-a", jobRules, true},
+		{"cluster-scoped rules need cluster-wide access", &User{Name: "alice"}, "roles", "team-a", nodeRules, true},
+		{"escalate verb", &User{Name: "carol"}, "roles", "team-a", jobRules, false},
+		{"escalate verb in another namespace", &User{Name: "carol"}, "roles", "team-b", jobRules, true},
+		{"masters", &User{Name: "root", Groups: []string{MastersGroup}}, "clusterroles", "", nodeRules, false},
+		{"cluster role needs cluster-wide access", &User{Name: "alice"}, "clusterroles", "", podRules, true},
+		{"resource names are checked", &User{Name: "ops"}, "clusterroles", "",
+			[]PolicyRule{{Verbs: []string{"create"}, Resources: []string{"nodes/drain"}, ResourceNames: []string{"node-1"}}}, false},
+		{"other resource names are not held", &User{Name: "ops"}, "clusterroles", "",
+			[]PolicyRule{{Verbs: []string{"create"}, Resources: []string{"nodes/drain"}, ResourceNames: []string{"node-1", "node-2"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRole(a, tt.user, tt.kind, tt.namespace, "new", tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckRole() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrEscalation) {
+				t.Errorf("CheckRole() = %v, want ErrEscalation", err)
+			}
+		})
+	}
+}
+
+func TestCheckBinding(t *testing.T) {
+	a := newTestRBAC(t)
+	tests := []struct {
+		name      string
+		user      *User
+		namespace string
+		ref       RoleRef
+		wantErr   bool
+	}{
+		{"role whose rules the user holds", &User{Name: "alice"}, "team-a", RoleRef{Kind: KindClusterRole, Name: "pod-admin"}, false},
+		{"same role in another namespace", &User{Name: "alice"}, "team-b", RoleRef{Kind: KindClusterRole, Name: "pod-admin"}, true},
+		{"role with more than the user holds", &User{Name: "alice"}, "team-a", RoleRef{Kind: KindClusterRole, Name: "view"}, true},
+		{"bind verb on the role", &User{Name: "bob"}, "team-a", RoleRef{Kind: KindClusterRole, Name: "view"}, false},
+		{"bind verb names another role", &User{Name: "bob"}, "team-a", RoleRef{Kind: KindClusterRole, Name: "pod-admin"}, true},
+		{"namespaced role", &User{Name: "alice"}, "team-a", RoleRef{Kind: KindRole, Name: "deployer"}, false},
+		{"missing role", &User{Name: "alice"}, "team-a", RoleRef{Kind: KindRole, Name: "missing"}, true},
+		{"cluster-wide binding", &User{Name: "alice"}, "", RoleRef{Kind: KindClusterRole, Name: "pod-admin"}, true},
+		{"masters bind anything", &User{Name: "root", Groups: []string{MastersGroup}}, "", RoleRef{Kind: KindClusterRole, Name: "missing"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.CheckBinding(a, tt.user, tt.namespace, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckBinding() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrEscalation) {
+				t.Errorf("CheckBinding() = %v, want ErrEscalation", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,493 @@
+// Package autoscaler implements a cluster autoscaler that grows node groups
+// when pods cannot be scheduled and shrinks them when nodes sit idle.
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+)
+
+// NodeGroupLabel is set on every node the autoscaler creates and names the
+// group the node belongs to.
+const NodeGroupLabel = "autoscaler.cluster-sim/node-group"
+
+// drainTimeout bounds how long a scale-down waits for a node to drain.
+const drainTimeout = 2 * time.Minute
+
+// ErrNodeGroupNotFound is returned when an operation names an unknown node group.
+var ErrNodeGroupNotFound = errors.New("node group not found")
+
+var logger = logging.For("autoscaler")
+
+// NodeGroup is a set of identically shaped nodes the autoscaler may resize
+// between MinSize and MaxSize. CPUs, Labels and Taints are the template for
+// new nodes.
+type NodeGroup struct {
+	Name    string            `json:"name"`
+	MinSize int               `json:"min_size"`
+	MaxSize int               `json:"max_size"`
+	CPUs    int               `json:"cpus"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Taints  []node.Taint      `json:"taints,omitempty"`
+}
+
+// Validate checks the node group is well formed.
+func (g NodeGroup) Validate() error {
+	if g.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if g.CPUs <= 0 {
+		return fmt.Errorf("cpus must be positive")
+	}
+	if g.MinSize < 0 || g.MaxSize < g.MinSize || g.MaxSize == 0 {
+		return fmt.Errorf("sizes must satisfy 0 <= min_size <= max_size and max_size > 0")
+	}
+	for _, t := range g.Taints {
+		if t.Key == "" {
+			return fmt.Errorf("taint key is required")
+		}
+		switch t.Effect {
+		case "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return fmt.Errorf("invalid taint effect %q", t.Effect)
+		}
+	}
+	return nil
+}
+
+// template returns the node a scale-up of this group would create.
+func (g NodeGroup) template(id string) node.Node {
+	return node.Node{
+		ID:     id,
+		CPUs:   g.CPUs,
+		Status: "Running",
+		Labels: g.nodeLabels(),
+		Taints: g.Taints,
+	}
+}
+
+func (g NodeGroup) nodeLabels() map[string]string {
+	nodeLabels := map[string]string{NodeGroupLabel: g.Name}
+	for k, v := range g.Labels {
+		nodeLabels[k] = v
+	}
+	return nodeLabels
+}
+
+// NodeGroupStatus is a node group together with its current members.
+type NodeGroupStatus struct {
+	NodeGroup
+	CurrentSize int      `json:"current_size"`
+	Nodes       []string `json:"nodes"`
+	Unneeded    []string `json:"unneeded_nodes"` // candidates for scale-down
+}
+
+// Options tunes the autoscaler.
+type Options struct {
+	// ScanInterval is how often the autoscaler looks at the cluster.
+	ScanInterval time.Duration
+	// ScaleDownUnneededTime is how long a node must stay underutilized
+	// before it is removed.
+	ScaleDownUnneededTime time.Duration
+	// ScaleDownUtilizationThreshold is the fraction of a node's CPUs below
+	// which it is considered underutilized.
+	ScaleDownUtilizationThreshold float64
+	// ScaleDownEnabled turns scale-down on or off.
+	ScaleDownEnabled bool
+}
+
+// DefaultOptions returns the settings used when none are configured.
+func DefaultOptions() Options {
+	return Options{
+		ScanInterval:                  10 * time.Second,
+		ScaleDownUnneededTime:         10 * time.Minute,
+		ScaleDownUtilizationThreshold: 0.5,
+		ScaleDownEnabled:              true,
+	}
+}
+
+// Autoscaler resizes node groups to fit the pods in the cluster.
+type Autoscaler struct {
+	nm *node.NodeManager
+
+	mu            sync.Mutex // Protects the fields below
+	opts          Options
+	groups        map[string]NodeGroup
+	unneededSince map[string]time.Time // node ID -> when it became a scale-down candidate
+}
+
+// New creates an autoscaler for the cluster managed by nm.
+func New(nm *node.NodeManager, opts Options) *Autoscaler {
+	return &Autoscaler{
+		nm:            nm,
+		opts:          opts,
+		groups:        make(map[string]NodeGroup),
+		unneededSince: make(map[string]time.Time),
+	}
+}
+
+// Options returns the current settings.
+func (a *Autoscaler) Options() Options {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.opts
+}
+
+// SetOptions replaces the settings; they take effect on the next scan.
+func (a *Autoscaler) SetOptions(opts Options) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.opts = opts
+}
+
+// SetNodeGroup creates or replaces a node group.
+func (a *Autoscaler) SetNodeGroup(g NodeGroup) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.groups[g.Name] = g
+	a.mu.Unlock()
+	logger.Info("Node group set", "node_group", g.Name, "min", g.MinSize, "max", g.MaxSize, "cpus", g.CPUs)
+	return nil
+}
+
+// DeleteNodeGroup stops managing a node group. Its nodes are left running.
+func (a *Autoscaler) DeleteNodeGroup(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, exists := a.groups[name]; !exists {
+		return ErrNodeGroupNotFound
+	}
+	delete(a.groups, name)
+	return nil
+}
+
+// ListNodeGroups returns every node group with its current members, by name.
+func (a *Autoscaler) ListNodeGroups() []NodeGroupStatus {
+	members := a.groupMembers()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]NodeGroupStatus, 0, len(a.groups))
+	for name, g := range a.groups {
+		st := NodeGroupStatus{NodeGroup: g, Nodes: []string{}, Unneeded: []string{}}
+		for _, n := range members[name] {
+			st.Nodes = append(st.Nodes, n.ID)
+			if _, unneeded := a.unneededSince[n.ID]; unneeded {
+				st.Unneeded = append(st.Unneeded, n.ID)
+			}
+		}
+		st.CurrentSize = len(st.Nodes)
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// groupMembers returns a snapshot of the nodes in each group, oldest first.
+func (a *Autoscaler) groupMembers() map[string][]node.Node {
+	a.nm.Mu.Lock()
+	defer a.nm.Mu.Unlock()
+	members := make(map[string][]node.Node)
+	for _, n := range a.nm.Nodes {
+		if name, ok := n.Labels[NodeGroupLabel]; ok {
+			members[name] = append(members[name], n)
+		}
+	}
+	for _, nodes := range members {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].CreatedAt.Before(nodes[j].CreatedAt) })
+	}
+	return members
+}
+
+// Start runs the autoscaler loop in a goroutine.
+func (a *Autoscaler) Start() {
+	go func() {
+		for {
+			a.RunOnce()
+			time.Sleep(a.Options().ScanInterval)
+		}
+	}()
+}
+
+// RunOnce performs a single autoscaling pass: it keeps groups at their
+// minimum size, scales up for unschedulable pods and otherwise removes at
+// most one unneeded node.
+func (a *Autoscaler) RunOnce() {
+	a.mu.Lock()
+	opts := a.opts
+	groups := make([]NodeGroup, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, g)
+	}
+	a.mu.Unlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	scaledUp := a.enforceMinSize(groups)
+
+	// Capacity may have been freed since the pods were rejected.
+	a.nm.SchedulePendingPods()
+	pending := a.nm.PendingPods()
+	metrics.UnschedulablePods.Set(float64(len(pending)))
+	if len(pending) > 0 && a.scaleUp(groups, pending) {
+		scaledUp = true
+	}
+
+	members := a.groupMembers()
+	for _, g := range groups {
+		metrics.NodeGroupSize.WithLabelValues(g.Name).Set(float64(len(members[g.Name])))
+	}
+
+	// New nodes are empty and would look unneeded right away; wait for the
+	// next scan before considering scale-down.
+	if !scaledUp && opts.ScaleDownEnabled {
+		a.scaleDown(groups, members, opts)
+	}
+}
+
+// enforceMinSize adds nodes to groups that are below their minimum size.
+func (a *Autoscaler) enforceMinSize(groups []NodeGroup) bool {
+	members := a.groupMembers()
+	added := false
+	for _, g := range groups {
+		missing := g.MinSize - len(members[g.Name])
+		if missing <= 0 {
+			continue
+		}
+		created := a.addNodes(g, missing)
+		if created > 0 {
+			added = true
+			a.nm.Events.Eventf(events.KindNodeGroup, g.Name, events.TypeNormal, "ScaledUp", "Added %d node(s) to reach minimum size %d", created, g.MinSize)
+		}
+	}
+	return added
+}
+
+// scaleUp picks the node group whose template nodes would take the most
+// pending pods and grows it by as many nodes as those pods need.
+func (a *Autoscaler) scaleUp(groups []NodeGroup, pending []pod.Pod) bool {
+	members := a.groupMembers()
+
+	var best NodeGroup
+	var bestPods []pod.Pod
+	bestNodes := 0
+	helpedByAny := make(map[string]bool)
+	for _, g := range groups {
+		headroom := g.MaxSize - len(members[g.Name])
+		if headroom <= 0 {
+			continue
+		}
+		helped, nodes := simulateScaleUp(g, headroom, pending, a.fits)
+		if len(helped) == 0 {
+			continue
+		}
+		for _, p := range helped {
+			helpedByAny[p.ID] = true
+		}
+		if len(helped) > len(bestPods) || (len(helped) == len(bestPods) && nodes < bestNodes) {
+			best, bestPods, bestNodes = g, helped, nodes
+		}
+	}
+
+	for _, p := range pending {
+		if !helpedByAny[p.ID] {
+			a.nm.Events.Eventf(events.KindPod, p.ID, events.TypeWarning, "NotTriggerScaleUp", "Pod didn't trigger scale-up: no node group has room for a node that fits it")
+		}
+	}
+	if len(bestPods) == 0 {
+		return false
+	}
+
+	logger.Info("Scaling up node group", "node_group", best.Name, "nodes", bestNodes, "pending_pods", len(bestPods))
+	for _, p := range bestPods {
+		a.nm.Events.Eventf(events.KindPod, p.ID, events.TypeNormal, "TriggeredScaleUp", "Pod triggered scale-up of node group %s", best.Name)
+	}
+	created := a.addNodes(best, bestNodes)
+	if created == 0 {
+		return false
+	}
+	a.nm.Events.Eventf(events.KindNodeGroup, best.Name, events.TypeNormal, "ScaledUp", "Added %d node(s) for %d pending pod(s)", created, len(bestPods))
+	return true
+}
+
+// fits returns why pod p could not run on node n, counting the pod's volume
+// claims, or "" if it could.
+func (a *Autoscaler) fits(p pod.Pod, n node.Node) string {
+	if reason := node.PodFitsNode(p, n); reason != "" {
+		return reason
+	}
+	a.nm.Mu.Lock()
+	defer a.nm.Mu.Unlock()
+	return a.nm.VolumesFitNodeLocked(p, n)
+}
+
+// simulateScaleUp packs the pending pods first-fit onto at most maxNodes new
+// nodes built from the group's template. It returns the pods that would be
+// scheduled and the number of nodes they need.
+func simulateScaleUp(g NodeGroup, maxNodes int, pending []pod.Pod, fits func(pod.Pod, node.Node) string) ([]pod.Pod, int) {
+	var simulated []node.Node
+	var helped []pod.Pod
+	for _, p := range pending {
+		placed := false
+		for i := range simulated {
+			if fits(p, simulated[i]) == "" {
+				simulated[i].UsedCPUs += p.CPUs
+				placed = true
+				break
+			}
+		}
+		if !placed && len(simulated) < maxNodes {
+			candidate := g.template(fmt.Sprintf("template-%s-%d", g.Name, len(simulated)))
+			if fits(p, candidate) == "" {
+				candidate.UsedCPUs += p.CPUs
+				simulated = append(simulated, candidate)
+				placed = true
+			}
+		}
+		if placed {
+			helped = append(helped, p)
+		}
+	}
+	return helped, len(simulated)
+}
+
+// addNodes creates count nodes from the group's template and returns how
+// many were created.
+func (a *Autoscaler) addNodes(g NodeGroup, count int) int {
+	created := 0
+	for i := 0; i < count; i++ {
+		n, err := a.nm.CreateNode(context.Background(), node.NodeSpec{CPUs: g.CPUs, Labels: g.nodeLabels(), Taints: g.Taints})
+		if err != nil {
+			logger.Error("Failed to add node to group", "node_group", g.Name, logging.Err(err))
+			a.nm.Events.Eventf(events.KindNodeGroup, g.Name, events.TypeWarning, "FailedScaleUp", "Failed to add node: %v", err)
+			break
+		}
+		created++
+		metrics.AutoscalerScaleUps.WithLabelValues(g.Name).Inc()
+		logger.Info("Added node to group", logging.NodeID(n.ID), "node_group", g.Name)
+	}
+	return created
+}
+
+// scaleDown tracks underutilized nodes whose pods fit elsewhere and removes
+// the one that has been unneeded the longest once it passes
+// ScaleDownUnneededTime.
+func (a *Autoscaler) scaleDown(groups []NodeGroup, members map[string][]node.Node, opts Options) {
+	now := time.Now()
+	unneeded := make(map[string]bool)
+	groupOf := make(map[string]NodeGroup)
+	for _, g := range groups {
+		if len(members[g.Name]) <= g.MinSize {
+			continue
+		}
+		for _, n := range members[g.Name] {
+			if a.isUnneeded(n, opts.ScaleDownUtilizationThreshold) {
+				unneeded[n.ID] = true
+				groupOf[n.ID] = g
+			}
+		}
+	}
+
+	a.mu.Lock()
+	for id := range a.unneededSince {
+		if !unneeded[id] {
+			delete(a.unneededSince, id)
+		}
+	}
+	candidate := ""
+	var since time.Time
+	for id := range unneeded {
+		if _, tracked := a.unneededSince[id]; !tracked {
+			a.unneededSince[id] = now
+		}
+		first := a.unneededSince[id]
+		if now.Sub(first) >= opts.ScaleDownUnneededTime && (candidate == "" || first.Before(since)) {
+			candidate, since = id, first
+		}
+	}
+	a.mu.Unlock()
+
+	if candidate != "" {
+		a.removeNode(groupOf[candidate], candidate, now.Sub(since))
+	}
+}
+
+// isUnneeded reports whether a node is below the utilization threshold and
+// every pod on it would fit on some other node.
+func (a *Autoscaler) isUnneeded(n node.Node, threshold float64) bool {
+	if n.Status != "Running" || n.CPUs == 0 {
+		return false
+	}
+	if float64(n.UsedCPUs)/float64(n.CPUs) >= threshold {
+		return false
+	}
+
+	a.nm.Mu.Lock()
+	defer a.nm.Mu.Unlock()
+	others := make([]node.Node, 0, len(a.nm.Nodes))
+	for id, other := range a.nm.Nodes {
+		if id != n.ID {
+			others = append(others, other)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].CreatedAt.Before(others[j].CreatedAt) })
+	for _, podID := range n.Pods {
+		p, exists := a.nm.Pods[podID]
+		// DaemonSet pods are removed with the node rather than moved.
+		if !exists || node.IsDaemonSetPod(p) {
+			continue
+		}
+		placed := false
+		for i := range others {
+			if node.PodFitsNode(p, others[i]) == "" && a.nm.VolumesFitNodeLocked(p, others[i]) == "" {
+				others[i].UsedCPUs += p.CPUs
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			return false
+		}
+	}
+	return true
+}
+
+// removeNode drains a node and deletes it. A node that cannot be drained is
+// made schedulable again and kept.
+func (a *Autoscaler) removeNode(g NodeGroup, nodeID string, unneededFor time.Duration) {
+	logger.Info("Removing unneeded node from group", logging.NodeID(nodeID), "node_group", g.Name, "unneeded_for", unneededFor.Round(time.Second))
+	a.nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "ScaleDown", "Node unneeded for %s, removing it", unneededFor.Round(time.Second))
+
+	a.mu.Lock()
+	delete(a.unneededSince, nodeID)
+	a.mu.Unlock()
+
+	if _, err := a.nm.Drain(nodeID, node.DrainOptions{Timeout: drainTimeout, GracePeriodSeconds: -1}); err != nil {
+		logger.Warn("Failed to drain node", logging.NodeID(nodeID), logging.Err(err))
+		metrics.AutoscalerFailedScaleDowns.WithLabelValues(g.Name).Inc()
+		a.nm.Events.Eventf(events.KindNodeGroup, g.Name, events.TypeWarning, "FailedScaleDown", "Failed to drain node %s: %v", nodeID, err)
+		if err := a.nm.Uncordon(nodeID); err != nil {
+			logger.Error("Failed to uncordon node", logging.NodeID(nodeID), logging.Err(err))
+		}
+		return
+	}
+	if err := a.nm.RemoveNode(context.Background(), nodeID); err != nil {
+		logger.Error("Failed to remove node", logging.NodeID(nodeID), logging.Err(err))
+		metrics.AutoscalerFailedScaleDowns.WithLabelValues(g.Name).Inc()
+		a.nm.Events.Eventf(events.KindNodeGroup, g.Name, events.TypeWarning, "FailedScaleDown", "Failed to remove node %s: %v", nodeID, err)
+		return
+	}
+	metrics.AutoscalerScaleDowns.WithLabelValues(g.Name).Inc()
+	a.nm.Events.Eventf(events.KindNodeGroup, g.Name, events.TypeNormal, "ScaledDown", "Removed node %s", nodeID)
+}
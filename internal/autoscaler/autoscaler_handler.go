@@ -0,0 +1,106 @@
+// All the gin handlers are here for autoscaler package
+package autoscaler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// optionsResponse is Options as seen by API clients.
+type optionsResponse struct {
+	ScanIntervalSeconds           float64 `json:"scan_interval_seconds"`
+	ScaleDownUnneededSeconds      float64 `json:"scale_down_unneeded_seconds"`
+	ScaleDownUtilizationThreshold float64 `json:"scale_down_utilization_threshold"`
+	ScaleDownEnabled              bool    `json:"scale_down_enabled"`
+}
+
+func toResponse(opts Options) optionsResponse {
+	return optionsResponse{
+		ScanIntervalSeconds:           opts.ScanInterval.Seconds(),
+		ScaleDownUnneededSeconds:      opts.ScaleDownUnneededTime.Seconds(),
+		ScaleDownUtilizationThreshold: opts.ScaleDownUtilizationThreshold,
+		ScaleDownEnabled:              opts.ScaleDownEnabled,
+	}
+}
+
+// API Handler to show the autoscaler settings and node groups
+func (a *Autoscaler) StatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"options": toResponse(a.Options()), "node_groups": a.ListNodeGroups()})
+}
+
+// API Handler to change autoscaler settings; omitted fields are left as they are
+func (a *Autoscaler) UpdateOptionsHandler(c *gin.Context) {
+	var request struct {
+		ScanIntervalSeconds           *float64 `json:"scan_interval_seconds"`
+		ScaleDownUnneededSeconds      *float64 `json:"scale_down_unneeded_seconds"`
+		ScaleDownUtilizationThreshold *float64 `json:"scale_down_utilization_threshold"`
+		ScaleDownEnabled              *bool    `json:"scale_down_enabled"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	opts := a.Options()
+	if request.ScanIntervalSeconds != nil {
+		if *request.ScanIntervalSeconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scan_interval_seconds must be positive"})
+			return
+		}
+		opts.ScanInterval = time.Duration(*request.ScanIntervalSeconds * float64(time.Second))
+	}
+	if request.ScaleDownUnneededSeconds != nil {
+		if *request.ScaleDownUnneededSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scale_down_unneeded_seconds must not be negative"})
+			return
+		}
+		opts.ScaleDownUnneededTime = time.Duration(*request.ScaleDownUnneededSeconds * float64(time.Second))
+	}
+	if request.ScaleDownUtilizationThreshold != nil {
+		if t := *request.ScaleDownUtilizationThreshold; t < 0 || t > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scale_down_utilization_threshold must be between 0 and 1"})
+			return
+		}
+		opts.ScaleDownUtilizationThreshold = *request.ScaleDownUtilizationThreshold
+	}
+	if request.ScaleDownEnabled != nil {
+		opts.ScaleDownEnabled = *request.ScaleDownEnabled
+	}
+	a.SetOptions(opts)
+	c.JSON(http.StatusOK, gin.H{"message": "Autoscaler options updated", "options": toResponse(opts)})
+}
+
+// API Handler to create or replace a node group
+func (a *Autoscaler) AddNodeGroupHandler(c *gin.Context) {
+	var group NodeGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := a.SetNodeGroup(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node group saved", "name": group.Name})
+}
+
+// API Handler to list node groups with their current size
+func (a *Autoscaler) ListNodeGroupsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, a.ListNodeGroups())
+}
+
+// API Handler to stop autoscaling a node group
+func (a *Autoscaler) DeleteNodeGroupHandler(c *gin.Context) {
+	if err := a.DeleteNodeGroup(c.Param("name")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNodeGroupNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node group deleted", "name": c.Param("name")})
+}
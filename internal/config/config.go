@@ -0,0 +1,451 @@
+// Package config holds the settings of the API server. They come from a
+// YAML file, are overridden by CLUSTER_SIM_* environment variables and then
+// by command-line flags, and are checked as a whole before the server
+// starts. The sections that can change while the server runs are applied
+// again when the server is sent SIGHUP; see Reloadable.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cluster-sim/internal/dns"
+	"cluster-sim/internal/health"
+	"cluster-sim/internal/ipam"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/tracing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the server can be configured with.
+type Config struct {
+	Server  Server  `yaml:"server"`
+	Runtime Runtime `yaml:"runtime"`
+	Node    Node    `yaml:"node"`
+	// NodeTemplates are registered at startup, as if through the API.
+	NodeTemplates []NodeTemplate `yaml:"node_templates"`
+	Health        Health         `yaml:"health"`
+	Scheduler     Scheduler      `yaml:"scheduler"`
+	Network       Network        `yaml:"network"`
+	DNS           DNS            `yaml:"dns"`
+	Admission     Admission      `yaml:"admission"`
+	Auth          Auth           `yaml:"auth"`
+	TLS           TLS            `yaml:"tls"`
+	Audit         Audit          `yaml:"audit"`
+	Logging       Logging        `yaml:"logging"`
+	Tracing       Tracing        `yaml:"tracing"`
+	Persistence   Persistence    `yaml:"persistence"`
+}
+
+// Server is how the API is served.
+type Server struct {
+	Port            string        `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // for requests in flight on shutdown
+}
+
+// Runtime is what nodes and their network are simulated with.
+type Runtime struct {
+	Backend string `yaml:"backend"` // docker, the only one
+	Network string `yaml:"network"` // docker enforces links on container traffic, fake only in the simulation
+}
+
+// Node is what node containers run.
+type Node struct {
+	Image       string        `yaml:"image"`
+	Command     []string      `yaml:"command"`
+	RestartWait time.Duration `yaml:"restart_wait"` // before a restarted node is checked again
+}
+
+// NodeTemplate is a named machine type nodes can be created from.
+type NodeTemplate struct {
+	Name         string             `yaml:"name"`
+	Image        string             `yaml:"image"`   // node.image when empty
+	Command      []string           `yaml:"command"` // node.command when empty
+	CPUs         int                `yaml:"cpus"`
+	MemoryMiB    int                `yaml:"memory_mib"`
+	Labels       map[string]string  `yaml:"labels"`
+	Taints       []node.Taint       `yaml:"taints"`
+	Resources    ContainerResources `yaml:"resources"`
+	StartupDelay time.Duration      `yaml:"startup_delay"` // before a new node registers
+}
+
+// ContainerResources are the limits Docker enforces on a node container.
+type ContainerResources struct {
+	CPULimit       float64 `yaml:"cpu_limit"`
+	MemoryLimitMiB int     `yaml:"memory_limit_mib"`
+}
+
+// Health tunes the health monitor and the heartbeats.
+type Health struct {
+	Interval             time.Duration `yaml:"interval"`               // between container checks
+	UnhealthyThreshold   int           `yaml:"unhealthy_threshold"`    // failed checks in a row before a restart
+	HeartbeatInterval    time.Duration `yaml:"heartbeat_interval"`     // between node heartbeats
+	HeartbeatGracePeriod time.Duration `yaml:"heartbeat_grace_period"` // without heartbeats before a node is Unreachable
+	PodEvictionTimeout   time.Duration `yaml:"pod_eviction_timeout"`   // Unreachable before its pods move
+}
+
+// Scheduler names the ways pods can be placed.
+type Scheduler struct {
+	// DefaultProfile places pods that ask for no profile or algorithm. It
+	// names a profile or one of the algorithms.
+	DefaultProfile string    `yaml:"default_profile"`
+	Profiles       []Profile `yaml:"profiles"`
+}
+
+// Profile lets pods ask for an algorithm by a name of the cluster's choosing.
+type Profile struct {
+	Name      string `yaml:"name"`
+	Algorithm string `yaml:"algorithm"`
+}
+
+// Network is how pod addresses are handed out.
+type Network struct {
+	PodCIDR          string `yaml:"pod_cidr"`
+	NodeCIDRMaskSize int    `yaml:"node_cidr_mask_size"`
+}
+
+// DNS is where the cluster DNS server listens.
+type DNS struct {
+	Addr string `yaml:"addr"`
+}
+
+// Admission points at the admission plugins, namespaces, quotas and webhooks.
+type Admission struct {
+	ConfigFile string `yaml:"config_file"`
+}
+
+// Auth is how clients are authenticated and authorized.
+type Auth struct {
+	TokenFile         string `yaml:"token_file"`
+	BasicAuthFile     string `yaml:"basic_auth_file"`
+	AuthorizationMode string `yaml:"authorization_mode"` // RBAC or AlwaysAllow
+	RBACPolicyFile    string `yaml:"rbac_policy_file"`
+}
+
+// TLS is how the API is served over TLS.
+type TLS struct {
+	CertFile     string   `yaml:"cert_file"`
+	KeyFile      string   `yaml:"key_file"`
+	PKIDir       string   `yaml:"pki_dir"` // run a built-in CA here
+	Hosts        []string `yaml:"hosts"`   // extra names for the built-in server certificate
+	ClientCAFile string   `yaml:"client_ca_file"`
+	ClientAuth   string   `yaml:"client_auth"` // request or require
+}
+
+// Audit is which requests are recorded and where.
+type Audit struct {
+	PolicyFile    string `yaml:"policy_file"`
+	LogPath       string `yaml:"log_path"`
+	LogMaxSize    int    `yaml:"log_max_size"` // megabytes
+	LogMaxBackups int    `yaml:"log_max_backups"`
+	WebhookURL    string `yaml:"webhook_url"`
+	WebhookCAFile string `yaml:"webhook_ca_file"`
+}
+
+// Logging is how the server logs.
+type Logging struct {
+	Format string            `yaml:"format"` // text or json
+	Level  string            `yaml:"level"`
+	Levels map[string]string `yaml:"levels"` // by component
+}
+
+// Tracing is where spans go.
+type Tracing struct {
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	File         string  `yaml:"file"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// Persistence is where the cluster's objects are saved across restarts.
+type Persistence struct {
+	StateFile    string        `yaml:"state_file"`    // none keeps the cluster in memory only
+	SaveInterval time.Duration `yaml:"save_interval"` // between saves; the state is also saved on shutdown
+}
+
+// Default returns the settings of a server started without any.
+func Default() Config {
+	runtime := node.DefaultRuntimeOptions()
+	monitor := health.DefaultOptions()
+	return Config{
+		Server:  Server{Port: "8080", ShutdownTimeout: 10 * time.Second},
+		Runtime: Runtime{Backend: "docker", Network: "docker"},
+		Node: Node{
+			Image:       runtime.Image,
+			Command:     runtime.Command,
+			RestartWait: runtime.RestartWait,
+		},
+		Health: Health{
+			Interval:             monitor.Interval,
+			UnhealthyThreshold:   monitor.UnhealthyThreshold,
+			HeartbeatInterval:    monitor.HeartbeatInterval,
+			HeartbeatGracePeriod: monitor.HeartbeatGracePeriod,
+			PodEvictionTimeout:   monitor.PodEvictionTimeout,
+		},
+		Scheduler: Scheduler{DefaultProfile: node.Algorithms[0]},
+		Network:   Network{PodCIDR: ipam.DefaultClusterCIDR, NodeCIDRMaskSize: ipam.DefaultNodeMaskSize},
+		DNS:       DNS{Addr: dns.DefaultAddr},
+		Auth:      Auth{AuthorizationMode: "RBAC"},
+		TLS:       TLS{ClientAuth: "request"},
+		Audit:     Audit{LogMaxSize: 100, LogMaxBackups: 10},
+		Logging:   Logging{Format: logging.FormatText, Level: "info"},
+		Tracing:   Tracing{SampleRatio: 1},
+
+		Persistence: Persistence{SaveInterval: 30 * time.Second},
+	}
+}
+
+// Load builds the configuration: the defaults, then the file at
+// flags.Path if there is one, then the environment and then the flags.
+// The result is validated, and every problem found is reported at once.
+func Load(flags *Flags) (Config, error) {
+	cfg := Default()
+	if flags.Path != "" {
+		if err := cfg.readFile(flags.Path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	flags.apply(&cfg)
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readFile reads a YAML file over cfg. Keys the file may not contain are
+// errors, so a misspelt setting is not silently ignored.
+func (cfg *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and returns all the problems it finds.
+func (cfg Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Server.Port != "", "server.port must be set")
+	check(cfg.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(cfg.Runtime.Backend == "docker", "runtime.backend %q is not supported: use docker", cfg.Runtime.Backend)
+	check(cfg.Runtime.Network == "docker" || cfg.Runtime.Network == "fake", "runtime.network %q is not supported: use docker or fake", cfg.Runtime.Network)
+
+	check(cfg.Node.Image != "", "node.image must be set")
+	check(len(cfg.Node.Command) > 0, "node.command must be set")
+	check(cfg.Node.RestartWait >= 0, "node.restart_wait must not be negative")
+
+	check(cfg.Health.Interval > 0, "health.interval must be positive")
+	check(cfg.Health.UnhealthyThreshold >= 1, "health.unhealthy_threshold must be at least 1")
+	check(cfg.Health.HeartbeatInterval > 0, "health.heartbeat_interval must be positive")
+	check(cfg.Health.HeartbeatGracePeriod > cfg.Health.HeartbeatInterval,
+		"health.heartbeat_grace_period must be longer than health.heartbeat_interval")
+	check(cfg.Health.PodEvictionTimeout >= 0, "health.pod_eviction_timeout must not be negative")
+
+	templates := make(map[string]bool)
+	for i, t := range cfg.NodeTemplates {
+		if err := t.NodeTemplate().Validate(); err != nil {
+			check(false, "node_templates[%d]: %v", i, err)
+		}
+		check(!templates[t.Name], "node_templates[%d]: %q is defined twice", i, t.Name)
+		templates[t.Name] = true
+	}
+
+	profiles := make(map[string]bool)
+	for i, p := range cfg.Scheduler.Profiles {
+		switch {
+		case p.Name == "":
+			check(false, "scheduler.profiles[%d]: name must be set", i)
+		case isAlgorithm(p.Name):
+			check(false, "scheduler.profiles[%d]: %q is the name of an algorithm", i, p.Name)
+		case profiles[p.Name]:
+			check(false, "scheduler.profiles[%d]: %q is defined twice", i, p.Name)
+		}
+		check(isAlgorithm(p.Algorithm), "scheduler.profiles[%d]: unknown algorithm %q: use %s", i, p.Algorithm, strings.Join(node.Algorithms, ", "))
+		profiles[p.Name] = true
+	}
+	check(isAlgorithm(cfg.Scheduler.DefaultProfile) || profiles[cfg.Scheduler.DefaultProfile],
+		"scheduler.default_profile %q is neither a profile nor an algorithm", cfg.Scheduler.DefaultProfile)
+
+	if _, err := ipam.New(cfg.Network.PodCIDR, cfg.Network.NodeCIDRMaskSize); err != nil {
+		check(false, "network: %v", err)
+	}
+	if _, _, err := net.SplitHostPort(cfg.DNS.Addr); err != nil {
+		check(false, "dns.addr %q is not host:port", cfg.DNS.Addr)
+	}
+
+	check(cfg.Auth.AuthorizationMode == "RBAC" || cfg.Auth.AuthorizationMode == "AlwaysAllow",
+		"auth.authorization_mode %q is not supported: use RBAC or AlwaysAllow", cfg.Auth.AuthorizationMode)
+	check((cfg.TLS.CertFile == "") == (cfg.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(cfg.TLS.ClientAuth == "request" || cfg.TLS.ClientAuth == "require",
+		"tls.client_auth %q is not supported: use request or require", cfg.TLS.ClientAuth)
+
+	check(cfg.Audit.LogMaxSize >= 0, "audit.log_max_size must not be negative")
+	check(cfg.Audit.LogMaxBackups >= 0, "audit.log_max_backups must not be negative")
+
+	if _, err := cfg.Logging.Config(); err != nil {
+		check(false, "logging: %v", err)
+	}
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be from 0 to 1")
+	check(cfg.Persistence.SaveInterval > 0, "persistence.save_interval must be positive")
+
+	return errors.Join(errs...)
+}
+
+func isAlgorithm(name string) bool {
+	for _, a := range node.Algorithms {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Config returns the logging package's configuration. The component names
+// are checked when logging is set up, once every component has registered.
+func (l Logging) Config() (logging.Config, error) {
+	cfg := logging.Config{Format: l.Format}
+	if l.Format != logging.FormatText && l.Format != logging.FormatJSON {
+		return cfg, fmt.Errorf("unknown format %q: use text or json", l.Format)
+	}
+	level, err := logging.ParseLevel(l.Level)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Level = level
+	if len(l.Levels) > 0 {
+		cfg.Levels = make(map[string]slog.Level, len(l.Levels))
+		for name, text := range l.Levels {
+			level, err := logging.ParseLevel(text)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %v", name, err)
+			}
+			cfg.Levels[name] = level
+		}
+	}
+	return cfg, nil
+}
+
+// RuntimeOptions returns the node package's settings.
+func (n Node) RuntimeOptions() node.RuntimeOptions {
+	return node.RuntimeOptions{Image: n.Image, Command: n.Command, RestartWait: n.RestartWait}
+}
+
+// NodeTemplate returns the template as the node package takes it.
+func (t NodeTemplate) NodeTemplate() node.NodeTemplate {
+	return node.NodeTemplate{
+		Name: t.Name,
+		ContainerSpec: node.ContainerSpec{
+			Image:   t.Image,
+			Command: t.Command,
+			Resources: node.ContainerResources{
+				CPULimit:       t.Resources.CPULimit,
+				MemoryLimitMiB: t.Resources.MemoryLimitMiB,
+			},
+		},
+		CPUs:               t.CPUs,
+		MemoryMiB:          t.MemoryMiB,
+		Labels:             t.Labels,
+		Taints:             t.Taints,
+		StartupDelayMillis: int(t.StartupDelay / time.Millisecond),
+	}
+}
+
+// Options returns the health monitor's settings.
+func (h Health) Options() health.Options {
+	return health.Options{
+		Interval:             h.Interval,
+		UnhealthyThreshold:   h.UnhealthyThreshold,
+		HeartbeatInterval:    h.HeartbeatInterval,
+		HeartbeatGracePeriod: h.HeartbeatGracePeriod,
+		PodEvictionTimeout:   h.PodEvictionTimeout,
+	}
+}
+
+// SchedulerProfiles returns the profiles as the scheduler takes them.
+func (s Scheduler) SchedulerProfiles() []node.SchedulerProfile {
+	profiles := make([]node.SchedulerProfile, 0, len(s.Profiles))
+	for _, p := range s.Profiles {
+		profiles = append(profiles, node.SchedulerProfile{Name: p.Name, Algorithm: p.Algorithm})
+	}
+	return profiles
+}
+
+// Config returns the tracing package's configuration.
+func (t Tracing) Config() tracing.Config {
+	return tracing.Config{Endpoint: t.OTLPEndpoint, File: t.File, SampleRatio: t.SampleRatio}
+}
+
+// Reloadable reports whether a section can change while the server runs.
+// The others are read once at startup. Node templates in the file are
+// saved again on reload; those taken out of it are left as they are.
+var Reloadable = map[string]bool{
+	"node":           true,
+	"node_templates": true,
+	"health":         true,
+	"scheduler":      true,
+	"logging":        true,
+}
+
+// Changed returns the sections that differ between cfg and other, by name.
+func (cfg Config) Changed(other Config) []string {
+	a, b := cfg.sections(), other.sections()
+	var changed []string
+	for name, section := range a {
+		if !equal(section, b[name]) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (cfg Config) sections() map[string]interface{} {
+	return map[string]interface{}{
+		"server":         cfg.Server,
+		"runtime":        cfg.Runtime,
+		"node":           cfg.Node,
+		"node_templates": cfg.NodeTemplates,
+		"health":         cfg.Health,
+		"scheduler":      cfg.Scheduler,
+		"network":        cfg.Network,
+		"dns":            cfg.DNS,
+		"admission":      cfg.Admission,
+		"auth":           cfg.Auth,
+		"tls":            cfg.TLS,
+		"audit":          cfg.Audit,
+		"logging":        cfg.Logging,
+		"tracing":        cfg.Tracing,
+		"persistence":    cfg.Persistence,
+	}
+}
+
+// equal compares two sections by their YAML, which treats nil and empty
+// lists and maps alike.
+func equal(a, b interface{}) bool {
+	x, err1 := yaml.Marshal(a)
+	y, err2 := yaml.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
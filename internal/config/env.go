@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides cfg with the CLUSTER_SIM_* variables that are set.
+func (cfg *Config) applyEnv() error {
+	e := envReader{}
+
+	e.duration("CLUSTER_SIM_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	e.string("CLUSTER_SIM_RUNTIME", &cfg.Runtime.Backend)
+	e.string("CLUSTER_SIM_NETWORK_RUNTIME", &cfg.Runtime.Network)
+
+	e.string("CLUSTER_SIM_NODE_IMAGE", &cfg.Node.Image)
+	e.command("CLUSTER_SIM_NODE_COMMAND", &cfg.Node.Command)
+	e.duration("CLUSTER_SIM_NODE_RESTART_WAIT", &cfg.Node.RestartWait)
+
+	e.duration("CLUSTER_SIM_HEALTH_INTERVAL", &cfg.Health.Interval)
+	e.int("CLUSTER_SIM_HEALTH_UNHEALTHY_THRESHOLD", &cfg.Health.UnhealthyThreshold)
+	e.duration("CLUSTER_SIM_HEARTBEAT_INTERVAL", &cfg.Health.HeartbeatInterval)
+	e.duration("CLUSTER_SIM_HEARTBEAT_GRACE_PERIOD", &cfg.Health.HeartbeatGracePeriod)
+	e.duration("CLUSTER_SIM_POD_EVICTION_TIMEOUT", &cfg.Health.PodEvictionTimeout)
+
+	e.string("CLUSTER_SIM_SCHEDULER_PROFILE", &cfg.Scheduler.DefaultProfile)
+
+	e.string("CLUSTER_SIM_POD_CIDR", &cfg.Network.PodCIDR)
+	e.int("CLUSTER_SIM_NODE_CIDR_MASK_SIZE", &cfg.Network.NodeCIDRMaskSize)
+	e.string("CLUSTER_SIM_DNS_ADDR", &cfg.DNS.Addr)
+	e.string("CLUSTER_SIM_ADMISSION_CONFIG", &cfg.Admission.ConfigFile)
+
+	e.string("CLUSTER_SIM_TOKEN_AUTH_FILE", &cfg.Auth.TokenFile)
+	e.string("CLUSTER_SIM_BASIC_AUTH_FILE", &cfg.Auth.BasicAuthFile)
+	e.string("CLUSTER_SIM_AUTHORIZATION_MODE", &cfg.Auth.AuthorizationMode)
+	e.string("CLUSTER_SIM_RBAC_POLICY_FILE", &cfg.Auth.RBACPolicyFile)
+
+	e.string("CLUSTER_SIM_TLS_CERT_FILE", &cfg.TLS.CertFile)
+	e.string("CLUSTER_SIM_TLS_KEY_FILE", &cfg.TLS.KeyFile)
+	e.string("CLUSTER_SIM_PKI_DIR", &cfg.TLS.PKIDir)
+	e.list("CLUSTER_SIM_TLS_HOSTS", &cfg.TLS.Hosts)
+	e.string("CLUSTER_SIM_CLIENT_CA_FILE", &cfg.TLS.ClientCAFile)
+	e.string("CLUSTER_SIM_TLS_CLIENT_AUTH", &cfg.TLS.ClientAuth)
+
+	e.string("CLUSTER_SIM_AUDIT_POLICY_FILE", &cfg.Audit.PolicyFile)
+	e.string("CLUSTER_SIM_AUDIT_LOG_PATH", &cfg.Audit.LogPath)
+	e.int("CLUSTER_SIM_AUDIT_LOG_MAXSIZE", &cfg.Audit.LogMaxSize)
+	e.int("CLUSTER_SIM_AUDIT_LOG_MAXBACKUP", &cfg.Audit.LogMaxBackups)
+	e.string("CLUSTER_SIM_AUDIT_WEBHOOK_URL", &cfg.Audit.WebhookURL)
+	e.string("CLUSTER_SIM_AUDIT_WEBHOOK_CA_FILE", &cfg.Audit.WebhookCAFile)
+
+	e.string("CLUSTER_SIM_LOG_FORMAT", &cfg.Logging.Format)
+	e.string("CLUSTER_SIM_LOG_LEVEL", &cfg.Logging.Level)
+	e.levels("CLUSTER_SIM_LOG_LEVELS", &cfg.Logging.Levels)
+
+	e.string("CLUSTER_SIM_OTLP_ENDPOINT", &cfg.Tracing.OTLPEndpoint)
+	e.string("CLUSTER_SIM_TRACE_FILE", &cfg.Tracing.File)
+	e.float("CLUSTER_SIM_TRACE_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	e.string("CLUSTER_SIM_STATE_FILE", &cfg.Persistence.StateFile)
+	e.duration("CLUSTER_SIM_STATE_SAVE_INTERVAL", &cfg.Persistence.SaveInterval)
+
+	return e.err
+}
+
+// envReader sets settings from the variables that are set, keeping the
+// first value that does not parse.
+type envReader struct {
+	err error
+}
+
+func (e *envReader) lookup(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	return value, ok && value != ""
+}
+
+func (e *envReader) fail(name, value string, err error) {
+	if e.err == nil {
+		e.err = fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+}
+
+func (e *envReader) string(name string, to *string) {
+	if value, ok := e.lookup(name); ok {
+		*to = value
+	}
+}
+
+func (e *envReader) int(name string, to *int) {
+	if value, ok := e.lookup(name); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		*to = n
+	}
+}
+
+func (e *envReader) float(name string, to *float64) {
+	if value, ok := e.lookup(name); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		*to = f
+	}
+}
+
+func (e *envReader) duration(name string, to *time.Duration) {
+	if value, ok := e.lookup(name); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		*to = d
+	}
+}
+
+// list reads a comma-separated list.
+func (e *envReader) list(name string, to *[]string) {
+	if value, ok := e.lookup(name); ok {
+		*to = splitList(value)
+	}
+}
+
+// command reads a command as a JSON array, e.g. ["sh", "-c", "sleep 30"],
+// or as words split the way a shell would: sh -c 'sleep 30'.
+func (e *envReader) command(name string, to *[]string) {
+	if value, ok := e.lookup(name); ok {
+		words, err := splitCommand(value)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		*to = words
+	}
+}
+
+// levels reads a list such as "scheduler=debug,health=warn".
+func (e *envReader) levels(name string, to *map[string]string) {
+	if value, ok := e.lookup(name); ok {
+		levels, err := parseLevels(value)
+		if err != nil {
+			e.fail(name, value, err)
+			return
+		}
+		*to = levels
+	}
+}
+
+// splitCommand splits a command into its words. A value starting with [ is
+// a JSON array. Otherwise words are separated by spaces, and single quotes,
+// double quotes and backslashes work as in a POSIX shell, without expansion.
+func splitCommand(value string) ([]string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var words []string
+		if err := json.Unmarshal([]byte(value), &words); err != nil {
+			return nil, fmt.Errorf("not a JSON array of strings: %v", err)
+		}
+		return words, nil
+	}
+
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune // ' or " while inside quotes
+		escaped bool
+	)
+	for _, r := range value {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseLevels(value string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, item := range splitList(value) {
+		name, level, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not component=level", item)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"words", "sleep 30", []string{"sleep", "30"}, false},
+		{"extra spaces", "  sleep \t 30\n", []string{"sleep", "30"}, false},
+		{"single quotes", `sh -c 'while true; do sleep 30; done'`, []string{"sh", "-c", "while true; do sleep 30; done"}, false},
+		{"double quotes", `sh -c "echo \"hi\" \\ there"`, []string{"sh", "-c", `echo "hi" \ there`}, false},
+		{"backslash in single quotes", `echo 'a\b'`, []string{"echo", `a\b`}, false},
+		{"escaped space", `echo a\ b`, []string{"echo", "a b"}, false},
+		{"quotes join a word", `a'b c'd`, []string{"ab cd"}, false},
+		{"empty quotes are a word", `echo ''`, []string{"echo", ""}, false},
+		{"no expansion", `echo $HOME`, []string{"echo", "$HOME"}, false},
+		{"JSON array", `["sh", "-c", "sleep 30"]`, []string{"sh", "-c", "sleep 30"}, false},
+		{"JSON array after spaces", ` ["sleep","1"]`, []string{"sleep", "1"}, false},
+		{"empty", "", nil, false},
+		{"unterminated single quote", `sh -c 'sleep`, nil, true},
+		{"unterminated double quote", `sh -c "sleep`, nil, true},
+		{"trailing backslash", `sleep\`, nil, true},
+		{"bad JSON", `["sleep", 30]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCommand(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitCommand(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"scheduler=debug", map[string]string{"scheduler": "debug"}, false},
+		{"scheduler=debug, health=warn,", map[string]string{"scheduler": "debug", "health": "warn"}, false},
+		{"", map[string]string{}, false},
+		{"scheduler", nil, true},
+		{"=debug", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseLevels(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLevels(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLevels(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// clearEnv unsets every CLUSTER_SIM_ variable for the test, so the
+// environment of whoever runs it does not leak in.
+func clearEnv(t *testing.T) {
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "CLUSTER_SIM_") {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(Config) bool
+		wantErr bool
+	}{
+		{"string", map[string]string{"CLUSTER_SIM_NODE_IMAGE": "alpine:3"},
+			func(c Config) bool { return c.Node.Image == "alpine:3" }, false},
+		{"command as words", map[string]string{"CLUSTER_SIM_NODE_COMMAND": `sh -c 'sleep 30'`},
+			func(c Config) bool { return reflect.DeepEqual(c.Node.Command, []string{"sh", "-c", "sleep 30"}) }, false},
+		{"command as JSON", map[string]string{"CLUSTER_SIM_NODE_COMMAND": `["sleep", "infinity"]`},
+			func(c Config) bool { return reflect.DeepEqual(c.Node.Command, []string{"sleep", "infinity"}) }, false},
+		{"duration", map[string]string{"CLUSTER_SIM_HEALTH_INTERVAL": "250ms"},
+			func(c Config) bool { return c.Health.Interval == 250*time.Millisecond }, false},
+		{"int", map[string]string{"CLUSTER_SIM_HEALTH_UNHEALTHY_THRESHOLD": "5"},
+			func(c Config) bool { return c.Health.UnhealthyThreshold == 5 }, false},
+		{"float", map[string]string{"CLUSTER_SIM_TRACE_SAMPLE_RATIO": "0.25"},
+			func(c Config) bool { return c.Tracing.SampleRatio == 0.25 }, false},
+		{"list", map[string]string{"CLUSTER_SIM_TLS_HOSTS": "a.example.com, 10.0.0.1,"},
+			func(c Config) bool { return reflect.DeepEqual(c.TLS.Hosts, []string{"a.example.com", "10.0.0.1"}) }, false},
+		{"levels", map[string]string{"CLUSTER_SIM_LOG_LEVELS": "scheduler=debug"},
+			func(c Config) bool {
+				return reflect.DeepEqual(c.Logging.Levels, map[string]string{"scheduler": "debug"})
+			}, false},
+		{"state file", map[string]string{"CLUSTER_SIM_STATE_FILE": "/tmp/state.json", "CLUSTER_SIM_STATE_SAVE_INTERVAL": "1m"},
+			func(c Config) bool {
+				return c.Persistence.StateFile == "/tmp/state.json" && c.Persistence.SaveInterval == time.Minute
+			}, false},
+		{"empty is unset", map[string]string{"CLUSTER_SIM_NODE_IMAGE": ""},
+			func(c Config) bool { return c.Node.Image == Default().Node.Image }, false},
+		{"bad command", map[string]string{"CLUSTER_SIM_NODE_COMMAND": `sh -c 'sleep`}, nil, true},
+		{"bad duration", map[string]string{"CLUSTER_SIM_HEALTH_INTERVAL": "10"}, nil, true},
+		{"bad int", map[string]string{"CLUSTER_SIM_HEALTH_UNHEALTHY_THRESHOLD": "three"}, nil, true},
+		{"bad float", map[string]string{"CLUSTER_SIM_TRACE_SAMPLE_RATIO": "half"}, nil, true},
+		{"bad levels", map[string]string{"CLUSTER_SIM_LOG_LEVELS": "debug"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg := Default()
+			err := cfg.applyEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				for name := range tt.env {
+					if !strings.Contains(err.Error(), name) {
+						t.Errorf("applyEnv() error %q does not name %s", err, name)
+					}
+				}
+				return
+			}
+			if !tt.check(cfg) {
+				t.Errorf("applyEnv() with %v did not take effect", tt.env)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("valid.yaml", "server:\n  port: \"9000\"\nnode:\n  image: from-file\nhealth:\n  interval: 3s\n")
+	unknown := write("unknown.yaml", "node:\n  imagee: typo\n")
+	invalid := write("invalid.yaml", "health:\n  interval: 0s\n  unhealthy_threshold: 0\npersistence:\n  save_interval: -1s\n")
+
+	tests := []struct {
+		name     string
+		path     string
+		env      map[string]string
+		args     []string
+		check    func(Config) bool
+		wantErrs []string
+	}{
+		{"defaults", "", nil, nil, func(c Config) bool { return reflect.DeepEqual(c, Default()) }, nil},
+		{"file", valid, nil, nil, func(c Config) bool {
+			return c.Server.Port == "9000" && c.Node.Image == "from-file" && c.Health.Interval == 3*time.Second
+		}, nil},
+		{"environment over file", valid, map[string]string{"CLUSTER_SIM_NODE_IMAGE": "from-env"}, nil,
+			func(c Config) bool { return c.Node.Image == "from-env" && c.Server.Port == "9000" }, nil},
+		{"flags over environment", valid, map[string]string{"CLUSTER_SIM_NODE_IMAGE": "from-env"},
+			[]string{"-node-image", "from-flag", "-health-interval", "7s", "-state-file", "s.json"},
+			func(c Config) bool {
+				return c.Node.Image == "from-flag" && c.Health.Interval == 7*time.Second && c.Persistence.StateFile == "s.json"
+			}, nil},
+		{"missing file", filepath.Join(dir, "missing.yaml"), nil, nil, nil, []string{"missing.yaml"}},
+		{"unknown key", unknown, nil, nil, nil, []string{"imagee"}},
+		{"every mistake reported", invalid, nil, nil, nil, []string{
+			"health.interval must be positive",
+			"health.unhealthy_threshold must be at least 1",
+			"persistence.save_interval must be positive",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			var flags Flags
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(new(strings.Builder))
+			flags.Register(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("parsing %v: %v", tt.args, err)
+			}
+			flags.Path = tt.path
+
+			cfg, err := Load(&flags)
+			if tt.wantErrs != nil {
+				if err == nil {
+					t.Fatalf("Load() succeeded, want errors %q", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error %q does not contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load(): %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("Load() = %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	tests := [][]string{
+		{"-health-interval", "10"},
+		{"-unhealthy-threshold", "three"},
+		{"-log-levels", "debug"},
+	}
+	for _, args := range tests {
+		t.Run(args[0], func(t *testing.T) {
+			var flags Flags
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(new(strings.Builder))
+			flags.Register(fs)
+			if err := fs.Parse(args); err == nil {
+				t.Errorf("parsing %v succeeded, want an error", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flags are the command-line options of the server. The settings they name
+// win over the file and the environment, and are kept across reloads.
+type Flags struct {
+	// Path is the config file, from -config or CLUSTER_SIM_SERVER_CONFIG.
+	Path string
+
+	set []func(*Config) // one for each flag given, in order
+}
+
+// Register defines the flags on fs.
+func (f *Flags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Path, "config", os.Getenv("CLUSTER_SIM_SERVER_CONFIG"), "YAML config `file`")
+	f.string(fs, "port", "port the API listens on", func(c *Config, v string) { c.Server.Port = v })
+	f.string(fs, "node-image", "image of new node containers", func(c *Config, v string) { c.Node.Image = v })
+	f.string(fs, "network-runtime", "docker or fake", func(c *Config, v string) { c.Runtime.Network = v })
+	f.string(fs, "scheduler-profile", "profile or algorithm of pods that name none", func(c *Config, v string) { c.Scheduler.DefaultProfile = v })
+	f.string(fs, "dns-addr", "address the cluster DNS server listens on", func(c *Config, v string) { c.DNS.Addr = v })
+	f.string(fs, "log-format", "text or json", func(c *Config, v string) { c.Logging.Format = v })
+	f.string(fs, "log-level", "debug, info, warn or error", func(c *Config, v string) { c.Logging.Level = v })
+	f.string(fs, "state-file", "file the cluster's objects are saved to and restored from", func(c *Config, v string) { c.Persistence.StateFile = v })
+	fs.Func("health-interval", "time between node health checks, e.g. 10s", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.set = append(f.set, func(c *Config) { c.Health.Interval = d })
+		return nil
+	})
+	fs.Func("unhealthy-threshold", "failed health checks in a row before a node is restarted", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.set = append(f.set, func(c *Config) { c.Health.UnhealthyThreshold = n })
+		return nil
+	})
+	fs.Func("log-levels", "levels by component, e.g. scheduler=debug,health=warn", func(s string) error {
+		levels, err := parseLevels(s)
+		if err != nil {
+			return err
+		}
+		f.set = append(f.set, func(c *Config) { c.Logging.Levels = levels })
+		return nil
+	})
+}
+
+// SetPort overrides the port, for the port given as the only argument.
+func (f *Flags) SetPort(port string) {
+	f.set = append(f.set, func(c *Config) { c.Server.Port = port })
+}
+
+func (f *Flags) string(fs *flag.FlagSet, name, usage string, apply func(*Config, string)) {
+	fs.Func(name, usage, func(s string) error {
+		s = strings.TrimSpace(s)
+		f.set = append(f.set, func(c *Config) { apply(c, s) })
+		return nil
+	})
+}
+
+func (f *Flags) apply(cfg *Config) {
+	for _, set := range f.set {
+		set(cfg)
+	}
+}
@@ -0,0 +1,301 @@
+// All the gin handlers are here for controller package
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to create or replace a pod group
+func (c *PodGroupController) AddPodGroupHandler(ctx *gin.Context) {
+	var group PodGroup
+	if err := ctx.ShouldBindJSON(&group); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Set(group); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Pod group saved", "name": group.Name})
+}
+
+// API Handler to list pod groups with the state of their pods
+func (c *PodGroupController) ListPodGroupsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one pod group
+func (c *PodGroupController) GetPodGroupHandler(ctx *gin.Context) {
+	st, exists := c.Status(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Pod group not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, st)
+}
+
+// API Handler to delete a pod group and its pods
+func (c *PodGroupController) DeletePodGroupHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		podGroupError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Pod group deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to change a pod group's replica count
+func (c *PodGroupController) ScalePodGroupHandler(ctx *gin.Context) {
+	var request struct {
+		Replicas *int `json:"replicas"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Replicas == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Scale(ctx.Param("name"), *request.Replicas); err != nil {
+		podGroupError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Pod group scaled", "name": ctx.Param("name"), "replicas": *request.Replicas})
+}
+
+// API Handler to set the simulated load of a pod group
+func (c *PodGroupController) SetLoadHandler(ctx *gin.Context) {
+	var load LoadModel
+	if err := ctx.ShouldBindJSON(&load); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.SetLoad(ctx.Param("name"), load); err != nil {
+		podGroupError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Pod group load updated", "name": ctx.Param("name")})
+}
+
+func podGroupError(ctx *gin.Context, err error) {
+	if errors.Is(err, ErrPodGroupNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Pod group not found"})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// API Handler to create or replace a horizontal pod autoscaler
+func (c *HPAController) AddHPAHandler(ctx *gin.Context) {
+	var hpa HorizontalPodAutoscaler
+	if err := ctx.ShouldBindJSON(&hpa); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Set(hpa); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Horizontal pod autoscaler saved", "name": hpa.Name})
+}
+
+// API Handler to list horizontal pod autoscalers with their status
+func (c *HPAController) ListHPAsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to delete a horizontal pod autoscaler
+func (c *HPAController) DeleteHPAHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Horizontal pod autoscaler not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Horizontal pod autoscaler deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to start a job
+func (c *JobController) AddJobHandler(ctx *gin.Context) {
+	var job Job
+	if err := ctx.ShouldBindJSON(&job); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	// Only CronJobs create owned jobs
+	job.Owner = ""
+	if err := c.Create(job); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Job created", "name": job.Name})
+}
+
+// API Handler to list jobs with their status
+func (c *JobController) ListJobsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one job
+func (c *JobController) GetJobHandler(ctx *gin.Context) {
+	job, exists := c.Get(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// API Handler to delete a job and its pods
+func (c *JobController) DeleteJobHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Job deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to create or replace a CronJob
+func (c *CronJobController) AddCronJobHandler(ctx *gin.Context) {
+	var cj CronJob
+	if err := ctx.ShouldBindJSON(&cj); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Set(cj); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "CronJob saved", "name": cj.Name})
+}
+
+// API Handler to list CronJobs with their status
+func (c *CronJobController) ListCronJobsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one CronJob
+func (c *CronJobController) GetCronJobHandler(ctx *gin.Context) {
+	cj, exists := c.Get(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "CronJob not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, cj)
+}
+
+// API Handler to delete a CronJob and its jobs
+func (c *CronJobController) DeleteCronJobHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "CronJob not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "CronJob deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to run a CronJob now, outside its schedule
+func (c *CronJobController) TriggerCronJobHandler(ctx *gin.Context) {
+	job, err := c.Trigger(ctx.Param("name"))
+	if errors.Is(err, ErrCronJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "CronJob not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Job created", "name": job})
+}
+
+// API Handler to create or replace a DaemonSet
+func (c *DaemonSetController) AddDaemonSetHandler(ctx *gin.Context) {
+	var ds DaemonSet
+	if err := ctx.ShouldBindJSON(&ds); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Set(ds); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "DaemonSet saved", "name": ds.Name})
+}
+
+// API Handler to list DaemonSets with the state of their pods
+func (c *DaemonSetController) ListDaemonSetsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one DaemonSet
+func (c *DaemonSetController) GetDaemonSetHandler(ctx *gin.Context) {
+	st, exists := c.Status(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "DaemonSet not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, st)
+}
+
+// API Handler to delete a DaemonSet and its pods
+func (c *DaemonSetController) DeleteDaemonSetHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "DaemonSet not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "DaemonSet deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to create or replace a StatefulSet
+func (c *StatefulSetController) AddStatefulSetHandler(ctx *gin.Context) {
+	var s StatefulSet
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Set(s); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "StatefulSet saved", "name": s.Name})
+}
+
+// API Handler to list StatefulSets with the state of their pods
+func (c *StatefulSetController) ListStatefulSetsHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one StatefulSet
+func (c *StatefulSetController) GetStatefulSetHandler(ctx *gin.Context) {
+	st, exists := c.Status(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "StatefulSet not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, st)
+}
+
+// API Handler to change the number of pods in a StatefulSet
+func (c *StatefulSetController) ScaleStatefulSetHandler(ctx *gin.Context) {
+	var request struct {
+		Replicas *int `json:"replicas"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Replicas == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.Scale(ctx.Param("name"), *request.Replicas); err != nil {
+		if errors.Is(err, ErrStatefulSetNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "StatefulSet not found"})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "StatefulSet scaled", "name": ctx.Param("name"), "replicas": *request.Replicas})
+}
+
+// API Handler to delete a StatefulSet and its pods, keeping their claims
+func (c *StatefulSetController) DeleteStatefulSetHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "StatefulSet not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "StatefulSet deleted", "name": ctx.Param("name")})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"unknown name", "0 0 * * funday"},
+		{"reversed range", "0 5-2 * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"bad step", "*/x * * * *"},
+		{"unknown macro", "@fortnightly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("ParseCron(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// A Monday
+	from := time.Date(2024, time.January, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2024, time.January, 1, 10, 8, 0, 0, time.UTC)},
+		{"step", "*/15 * * * *", time.Date(2024, time.January, 1, 10, 15, 0, 0, time.UTC)},
+		{"list", "5,50 * * * *", time.Date(2024, time.January, 1, 10, 50, 0, 0, time.UTC)},
+		{"range with step", "10-40/10 * * * *", time.Date(2024, time.January, 1, 10, 10, 0, 0, time.UTC)},
+		{"next hour", "0 * * * *", time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC)},
+		{"next day", "30 9 * * *", time.Date(2024, time.January, 2, 9, 30, 0, 0, time.UTC)},
+		{"day of week name", "0 0 * * fri", time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		{"seven is sunday", "0 0 * * 7", time.Date(2024, time.January, 7, 0, 0, 0, 0, time.UTC)},
+		{"weekday range", "0 9 * * mon-fri", time.Date(2024, time.January, 2, 9, 0, 0, 0, time.UTC)},
+		{"month name", "0 0 1 mar *", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"either day field", "0 0 15 * sun", time.Date(2024, time.January, 7, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"macro", "@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"macro in capitals", "@DAILY", time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", from, got, tt.want)
+			}
+			if !tt.want.IsZero() && !s.Matches(tt.want) {
+				t.Errorf("Matches(%v) = false for the time Next returned", tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"minute matches", "7 10 * * *", time.Date(2024, time.January, 1, 10, 7, 59, 0, time.UTC), true},
+		{"minute differs", "8 10 * * *", time.Date(2024, time.January, 1, 10, 7, 0, 0, time.UTC), false},
+		{"day of month only", "0 0 1 * *", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), true},
+		{"restricted days both miss", "0 0 15 * sun", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), false},
+		{"unrestricted day of month needs day of week", "0 0 * * sun", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			if got := s.Matches(tt.at); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+)
+
+// cronJobSyncInterval is how often CronJobs check whether a run is due.
+// Schedules have minute resolution, so this only needs to be well under a minute.
+const cronJobSyncInterval = 10 * time.Second
+
+// maxMissedSchedules caps how many missed runs a sync counts before giving
+// up on a CronJob, as Kubernetes does after a long outage.
+const maxMissedSchedules = 100
+
+// ErrCronJobNotFound is returned when an operation names an unknown CronJob.
+var ErrCronJobNotFound = errors.New("cronjob not found")
+
+// Concurrency policies
+const (
+	ConcurrencyAllow   = "Allow"
+	ConcurrencyForbid  = "Forbid"
+	ConcurrencyReplace = "Replace"
+)
+
+// CronJob creates a Job from JobTemplate each time Schedule fires.
+type CronJob struct {
+	Name                       string  `json:"name"`
+	Schedule                   string  `json:"schedule"`
+	ConcurrencyPolicy          string  `json:"concurrency_policy,omitempty"` // Allow (default), Forbid or Replace
+	Suspend                    bool    `json:"suspend,omitempty"`
+	StartingDeadlineSeconds    *int    `json:"starting_deadline_seconds,omitempty"`
+	SuccessfulJobsHistoryLimit *int    `json:"successful_jobs_history_limit,omitempty"`
+	FailedJobsHistoryLimit     *int    `json:"failed_jobs_history_limit,omitempty"`
+	JobTemplate                JobSpec `json:"job_template"`
+}
+
+// Validate fills in defaults and checks the CronJob is well formed.
+func (cj *CronJob) Validate() error {
+	if cj.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := ParseCron(cj.Schedule); err != nil {
+		return err
+	}
+	switch cj.ConcurrencyPolicy {
+	case "":
+		cj.ConcurrencyPolicy = ConcurrencyAllow
+	case ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+	default:
+		return fmt.Errorf("concurrency_policy must be Allow, Forbid or Replace")
+	}
+	if cj.SuccessfulJobsHistoryLimit == nil {
+		cj.SuccessfulJobsHistoryLimit = intPtr(3)
+	}
+	if cj.FailedJobsHistoryLimit == nil {
+		cj.FailedJobsHistoryLimit = intPtr(1)
+	}
+	if *cj.SuccessfulJobsHistoryLimit < 0 || *cj.FailedJobsHistoryLimit < 0 {
+		return fmt.Errorf("history limits must not be negative")
+	}
+	if cj.StartingDeadlineSeconds != nil && *cj.StartingDeadlineSeconds <= 0 {
+		return fmt.Errorf("starting_deadline_seconds must be positive")
+	}
+	return cj.JobTemplate.Validate()
+}
+
+// CronJobStatus reports a CronJob's runs.
+type CronJobStatus struct {
+	Active             []string   `json:"active"`
+	LastScheduleTime   *time.Time `json:"last_schedule_time,omitempty"`
+	LastSuccessfulTime *time.Time `json:"last_successful_time,omitempty"`
+	NextScheduleTime   *time.Time `json:"next_schedule_time,omitempty"`
+}
+
+// CronJobWithStatus is a CronJob together with its status.
+type CronJobWithStatus struct {
+	CronJob
+	Status CronJobStatus `json:"status"`
+}
+
+type cronJobState struct {
+	cronJob  CronJob
+	schedule CronSchedule
+	// since is the time scheduled runs are counted from: the last scheduled
+	// run, or when the CronJob was created.
+	since              time.Time
+	lastScheduleTime   *time.Time
+	lastSuccessfulTime *time.Time
+}
+
+// CronJobController starts Jobs on a cron schedule.
+type CronJobController struct {
+	jobs *JobController
+
+	mu       sync.Mutex // Protects cronJobs
+	cronJobs map[string]*cronJobState
+}
+
+// NewCronJobController creates a controller that runs its jobs through jobs.
+func NewCronJobController(jobs *JobController) *CronJobController {
+	return &CronJobController{jobs: jobs, cronJobs: make(map[string]*cronJobState)}
+}
+
+// Start runs the schedule loop in a goroutine.
+func (c *CronJobController) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.sync(name, time.Now())
+			}
+			time.Sleep(cronJobSyncInterval)
+		}
+	}()
+}
+
+func (c *CronJobController) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.cronJobs))
+	for name := range c.cronJobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set creates or replaces a CronJob. Replacing one keeps its run history.
+func (c *CronJobController) Set(cj CronJob) error {
+	if err := cj.Validate(); err != nil {
+		return err
+	}
+	schedule, _ := ParseCron(cj.Schedule)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, exists := c.cronJobs[cj.Name]; exists {
+		state.cronJob, state.schedule = cj, schedule
+		return nil
+	}
+	c.cronJobs[cj.Name] = &cronJobState{cronJob: cj, schedule: schedule, since: time.Now()}
+	logger.Info("CronJob created", "cronjob", cj.Name, "schedule", cj.Schedule)
+	return nil
+}
+
+// Restore puts back a CronJob saved by an earlier server with its run
+// history. Runs missed while the server was down are counted from its last
+// scheduled run, as after any other pause.
+func (c *CronJobController) Restore(cj CronJobWithStatus) error {
+	if err := c.Set(cj.CronJob); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.cronJobs[cj.Name]
+	state.lastScheduleTime, state.lastSuccessfulTime = cj.Status.LastScheduleTime, cj.Status.LastSuccessfulTime
+	if state.lastScheduleTime != nil {
+		state.since = *state.lastScheduleTime
+	}
+	return nil
+}
+
+// Get returns a CronJob with its status.
+func (c *CronJobController) Get(name string) (CronJobWithStatus, bool) {
+	c.mu.Lock()
+	state, exists := c.cronJobs[name]
+	if !exists {
+		c.mu.Unlock()
+		return CronJobWithStatus{}, false
+	}
+	cj := CronJobWithStatus{CronJob: state.cronJob, Status: CronJobStatus{
+		LastScheduleTime:   state.lastScheduleTime,
+		LastSuccessfulTime: state.lastSuccessfulTime,
+	}}
+	if !cj.Suspend {
+		if next := state.schedule.Next(time.Now()); !next.IsZero() {
+			cj.Status.NextScheduleTime = &next
+		}
+	}
+	c.mu.Unlock()
+
+	cj.Status.Active = []string{}
+	for _, job := range c.ownedJobs(name) {
+		if !job.Status.Finished() {
+			cj.Status.Active = append(cj.Status.Active, job.Name)
+		}
+	}
+	return cj, true
+}
+
+// List returns every CronJob with its status, sorted by name.
+func (c *CronJobController) List() []CronJobWithStatus {
+	var list []CronJobWithStatus
+	for _, name := range c.names() {
+		if cj, exists := c.Get(name); exists {
+			list = append(list, cj)
+		}
+	}
+	return list
+}
+
+// Delete removes a CronJob and the jobs it created.
+func (c *CronJobController) Delete(name string) error {
+	c.mu.Lock()
+	_, exists := c.cronJobs[name]
+	delete(c.cronJobs, name)
+	c.mu.Unlock()
+	if !exists {
+		return ErrCronJobNotFound
+	}
+	for _, job := range c.ownedJobs(name) {
+		c.jobs.Delete(job.Name)
+	}
+	return nil
+}
+
+// Trigger starts a run of a CronJob now, outside its schedule. The
+// concurrency policy still applies.
+func (c *CronJobController) Trigger(name string) (string, error) {
+	c.mu.Lock()
+	state, exists := c.cronJobs[name]
+	if !exists {
+		c.mu.Unlock()
+		return "", ErrCronJobNotFound
+	}
+	cj := state.cronJob
+	c.mu.Unlock()
+
+	now := time.Now()
+	jobName := fmt.Sprintf("%s-manual-%d", name, now.Unix())
+	if err := c.run(cj, jobName); err != nil {
+		return "", err
+	}
+	return jobName, nil
+}
+
+// ownedJobs returns the jobs a CronJob created, oldest first.
+func (c *CronJobController) ownedJobs(name string) []JobWithStatus {
+	owner := ownerRef(events.KindCronJob, name)
+	var owned []JobWithStatus
+	for _, job := range c.jobs.List() {
+		if job.Owner == owner {
+			owned = append(owned, job)
+		}
+	}
+	return owned
+}
+
+// sync starts the most recent run that became due since the last one, if
+// any, and trims the CronJob's finished jobs to its history limits.
+func (c *CronJobController) sync(name string, now time.Time) {
+	c.mu.Lock()
+	state, exists := c.cronJobs[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	cj := state.cronJob
+
+	// Only the latest missed run is started; earlier ones are skipped.
+	var scheduled time.Time
+	missed := 0
+	for t := state.schedule.Next(state.since); !t.IsZero() && !t.After(now); t = state.schedule.Next(t) {
+		scheduled = t
+		if missed++; missed > maxMissedSchedules {
+			break
+		}
+	}
+	if !scheduled.IsZero() {
+		state.since = scheduled
+	}
+	c.mu.Unlock()
+
+	c.recordSuccess(name)
+	defer c.cleanupHistory(name)
+
+	switch {
+	case scheduled.IsZero() || cj.Suspend:
+		return
+	case missed > maxMissedSchedules:
+		logger.Warn("Too many missed start times, skipping ahead", "cronjob", name, "next", scheduled.Format(time.RFC3339))
+		c.jobs.nm.Events.Eventf(events.KindCronJob, name, events.TypeWarning, "TooManyMissedTimes",
+			"Too many missed start times (> %d), set or decrease starting_deadline_seconds", maxMissedSchedules)
+	}
+	if cj.StartingDeadlineSeconds != nil && now.Sub(scheduled) > time.Duration(*cj.StartingDeadlineSeconds)*time.Second {
+		metrics.CronJobMissedRuns.WithLabelValues(name, "StartingDeadline").Inc()
+		c.jobs.nm.Events.Eventf(events.KindCronJob, name, events.TypeWarning, "MissSchedule",
+			"Missed scheduled time to start a job: %s", scheduled.Format(time.RFC3339))
+		return
+	}
+
+	jobName := fmt.Sprintf("%s-%d", name, scheduled.Unix()/60)
+	if err := c.run(cj, jobName); err != nil {
+		logger.Error("Failed to start job", "cronjob", name, logging.Err(err))
+		return
+	}
+	c.mu.Lock()
+	if state, exists := c.cronJobs[name]; exists {
+		state.lastScheduleTime = &scheduled
+	}
+	c.mu.Unlock()
+}
+
+// run creates one job for a CronJob, applying its concurrency policy to the
+// jobs still running from earlier runs.
+func (c *CronJobController) run(cj CronJob, jobName string) error {
+	var active []string
+	for _, job := range c.ownedJobs(cj.Name) {
+		if !job.Status.Finished() {
+			active = append(active, job.Name)
+		}
+	}
+	if len(active) > 0 {
+		switch cj.ConcurrencyPolicy {
+		case ConcurrencyForbid:
+			metrics.CronJobMissedRuns.WithLabelValues(cj.Name, "ConcurrencyForbid").Inc()
+			c.jobs.nm.Events.Eventf(events.KindCronJob, cj.Name, events.TypeNormal, "JobAlreadyActive",
+				"Not starting job because prior execution is running and concurrency policy is Forbid")
+			return fmt.Errorf("not starting job %s: %s is still running", jobName, active[0])
+		case ConcurrencyReplace:
+			for _, name := range active {
+				c.jobs.Delete(name)
+				c.jobs.nm.Events.Eventf(events.KindCronJob, cj.Name, events.TypeNormal, "SuccessfulDelete", "Deleted job %s", name)
+			}
+		}
+	}
+
+	job := Job{Name: jobName, Owner: ownerRef(events.KindCronJob, cj.Name), JobSpec: cj.JobTemplate}
+	// Each job gets its own copy of the counts so defaulting cannot leak back.
+	job.Completions, job.Parallelism, job.BackoffLimit = copyInt(job.Completions), copyInt(job.Parallelism), copyInt(job.BackoffLimit)
+	job.ActiveDeadlineSeconds = copyInt(job.ActiveDeadlineSeconds)
+	job.SuccessfulPodsHistoryLimit, job.FailedPodsHistoryLimit = copyInt(job.SuccessfulPodsHistoryLimit), copyInt(job.FailedPodsHistoryLimit)
+	if err := c.jobs.Create(job); err != nil {
+		c.jobs.nm.Events.Eventf(events.KindCronJob, cj.Name, events.TypeWarning, "FailedCreate", "Error creating job: %v", err)
+		return err
+	}
+	metrics.CronJobRuns.WithLabelValues(cj.Name).Inc()
+	c.jobs.nm.Events.Eventf(events.KindCronJob, cj.Name, events.TypeNormal, "SuccessfulCreate", "Created job %s", jobName)
+	return nil
+}
+
+// recordSuccess updates a CronJob's last successful time from its jobs.
+func (c *CronJobController) recordSuccess(name string) {
+	var last *time.Time
+	for _, job := range c.ownedJobs(name) {
+		if job.Status.Condition == JobComplete && (last == nil || job.Status.CompletionTime.After(*last)) {
+			last = job.Status.CompletionTime
+		}
+	}
+	if last == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, exists := c.cronJobs[name]; exists {
+		if state.lastSuccessfulTime == nil || last.After(*state.lastSuccessfulTime) {
+			state.lastSuccessfulTime = last
+		}
+	}
+}
+
+// cleanupHistory deletes the oldest finished jobs beyond the CronJob's
+// history limits.
+func (c *CronJobController) cleanupHistory(name string) {
+	c.mu.Lock()
+	state, exists := c.cronJobs[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	successLimit, failedLimit := *state.cronJob.SuccessfulJobsHistoryLimit, *state.cronJob.FailedJobsHistoryLimit
+	c.mu.Unlock()
+
+	var succeeded, failed []JobWithStatus
+	for _, job := range c.ownedJobs(name) {
+		switch job.Status.Condition {
+		case JobComplete:
+			succeeded = append(succeeded, job)
+		case JobFailed:
+			failed = append(failed, job)
+		}
+	}
+	for _, history := range []struct {
+		jobs  []JobWithStatus
+		limit int
+	}{{succeeded, successLimit}, {failed, failedLimit}} {
+		sort.Slice(history.jobs, func(i, j int) bool {
+			return history.jobs[i].Status.CompletionTime.Before(*history.jobs[j].Status.CompletionTime)
+		})
+		for i := 0; i < len(history.jobs)-history.limit; i++ {
+			c.jobs.Delete(history.jobs[i].Name)
+			c.jobs.nm.Events.Eventf(events.KindCronJob, name, events.TypeNormal, "SawCompletedJob",
+				"Deleted job %s beyond the history limit", history.jobs[i].Name)
+		}
+	}
+}
+
+func copyInt(p *int) *int {
+	if p == nil {
+		return nil
+	}
+	return intPtr(*p)
+}
@@ -0,0 +1,337 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/labels"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+)
+
+// daemonSetSyncInterval is how often DaemonSets are reconciled against the
+// nodes, so a joining node gets its pods within a couple of seconds.
+const daemonSetSyncInterval = 2 * time.Second
+
+// RevisionLabel is set on DaemonSet pods to the hash of the template they
+// were created from, so a changed template can be rolled out.
+const RevisionLabel = "controller-revision-hash"
+
+// ErrDaemonSetNotFound is returned when an operation names an unknown DaemonSet.
+var ErrDaemonSetNotFound = errors.New("daemonset not found")
+
+// DaemonSet update strategies
+const (
+	UpdateRollingUpdate = "RollingUpdate"
+	UpdateOnDelete      = "OnDelete"
+)
+
+// DaemonSetUpdateStrategy controls how pods are replaced when the template
+// changes. RollingUpdate (the default) replaces up to MaxUnavailable pods at
+// a time; OnDelete only uses the new template for pods that were deleted.
+type DaemonSetUpdateStrategy struct {
+	Type           string `json:"type,omitempty"`
+	MaxUnavailable int    `json:"max_unavailable,omitempty"`
+}
+
+// DaemonSet runs one pod from Template on every node matching NodeSelector
+// whose taints the template tolerates.
+type DaemonSet struct {
+	Name           string                  `json:"name"`
+	NodeSelector   string                  `json:"node_selector,omitempty"` // label selector; empty matches every node
+	Template       PodTemplate             `json:"template"`
+	UpdateStrategy DaemonSetUpdateStrategy `json:"update_strategy"`
+}
+
+// Validate fills in defaults and checks the DaemonSet is well formed.
+func (ds *DaemonSet) Validate() error {
+	if ds.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := labels.Parse(ds.NodeSelector); err != nil {
+		return err
+	}
+	switch ds.UpdateStrategy.Type {
+	case "":
+		ds.UpdateStrategy.Type = UpdateRollingUpdate
+	case UpdateRollingUpdate, UpdateOnDelete:
+	default:
+		return fmt.Errorf("update_strategy type must be RollingUpdate or OnDelete")
+	}
+	if ds.UpdateStrategy.MaxUnavailable == 0 {
+		ds.UpdateStrategy.MaxUnavailable = 1
+	}
+	if ds.UpdateStrategy.MaxUnavailable < 0 {
+		return fmt.Errorf("max_unavailable must be positive")
+	}
+	return ds.Template.Validate()
+}
+
+// revision hashes the template, so pods made from an older one can be told apart.
+func (t PodTemplate) revision() string {
+	data, _ := json.Marshal(t)
+	h := fnv.New32a()
+	h.Write(data)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// DaemonSetStatus is a DaemonSet together with the state of its pods.
+type DaemonSetStatus struct {
+	DaemonSet
+	DesiredNumberScheduled int      `json:"desired_number_scheduled"` // eligible nodes
+	CurrentNumberScheduled int      `json:"current_number_scheduled"` // eligible nodes with a pod
+	NumberReady            int      `json:"number_ready"`             // eligible nodes with a Running pod
+	UpdatedNumberScheduled int      `json:"updated_number_scheduled"` // eligible nodes with a pod from the current template
+	NumberMisscheduled     int      `json:"number_misscheduled"`      // pods on nodes that are no longer eligible
+	Pods                   []string `json:"pods"`
+}
+
+// DaemonSetController keeps exactly one pod of each DaemonSet on every
+// eligible node.
+type DaemonSetController struct {
+	nm *node.NodeManager
+
+	mu         sync.Mutex // Protects daemonSets
+	daemonSets map[string]DaemonSet
+}
+
+// NewDaemonSetController creates a controller for the cluster managed by nm.
+func NewDaemonSetController(nm *node.NodeManager) *DaemonSetController {
+	return &DaemonSetController{nm: nm, daemonSets: make(map[string]DaemonSet)}
+}
+
+// Start runs the reconcile loop in a goroutine.
+func (c *DaemonSetController) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.sync(name)
+			}
+			time.Sleep(daemonSetSyncInterval)
+		}
+	}()
+}
+
+func (c *DaemonSetController) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.daemonSets))
+	for name := range c.daemonSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set creates or replaces a DaemonSet and reconciles it right away. A new
+// template is rolled out according to the update strategy.
+func (c *DaemonSetController) Set(ds DaemonSet) error {
+	if err := ds.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.daemonSets[ds.Name] = ds
+	c.mu.Unlock()
+	logger.Info("DaemonSet set", "daemonset", ds.Name, "selector", ds.NodeSelector, "cpus", ds.Template.CPUs, "revision", ds.Template.revision())
+	c.sync(ds.Name)
+	return nil
+}
+
+// Get returns a DaemonSet by name.
+func (c *DaemonSetController) Get(name string) (DaemonSet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ds, exists := c.daemonSets[name]
+	return ds, exists
+}
+
+// Delete removes a DaemonSet and all of its pods.
+func (c *DaemonSetController) Delete(name string) error {
+	c.mu.Lock()
+	_, exists := c.daemonSets[name]
+	delete(c.daemonSets, name)
+	c.mu.Unlock()
+	if !exists {
+		return ErrDaemonSetNotFound
+	}
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindDaemonSet, name)) {
+		if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod of DaemonSet", "daemonset", name, logging.PodID(p.ID), logging.Err(err))
+		}
+	}
+	return nil
+}
+
+// List returns every DaemonSet with its status, by name.
+func (c *DaemonSetController) List() []DaemonSetStatus {
+	list := []DaemonSetStatus{}
+	for _, name := range c.names() {
+		if st, ok := c.Status(name); ok {
+			list = append(list, st)
+		}
+	}
+	return list
+}
+
+// Status returns a DaemonSet with the state of its pods.
+func (c *DaemonSetController) Status(name string) (DaemonSetStatus, bool) {
+	ds, exists := c.Get(name)
+	if !exists {
+		return DaemonSetStatus{}, false
+	}
+	eligible := c.eligibleNodes(ds)
+	revision := ds.Template.revision()
+	st := DaemonSetStatus{DaemonSet: ds, DesiredNumberScheduled: len(eligible), Pods: []string{}}
+	for nodeID, pods := range c.podsByNode(name) {
+		for _, p := range pods {
+			st.Pods = append(st.Pods, p.ID)
+		}
+		if !eligible[nodeID] {
+			st.NumberMisscheduled += len(pods)
+			continue
+		}
+		st.CurrentNumberScheduled++
+		if pods[0].Status == "Running" {
+			st.NumberReady++
+		}
+		if pods[0].Labels[RevisionLabel] == revision {
+			st.UpdatedNumberScheduled++
+		}
+	}
+	sort.Strings(st.Pods)
+	return st, true
+}
+
+// eligibleNodes returns the IDs of the nodes that should run a pod of ds.
+func (c *DaemonSetController) eligibleNodes(ds DaemonSet) map[string]bool {
+	sel, err := labels.Parse(ds.NodeSelector)
+	if err != nil {
+		return nil
+	}
+	probe := pod.Pod{Tolerations: ds.Template.Tolerations}
+	c.nm.Mu.Lock()
+	defer c.nm.Mu.Unlock()
+	eligible := make(map[string]bool)
+	for id, n := range c.nm.Nodes {
+		if sel.Matches(n.Labels) && node.NodeTaintsTolerated(probe, n) == "" {
+			eligible[id] = true
+		}
+	}
+	return eligible
+}
+
+// podsByNode groups a DaemonSet's pods by the node they are bound to, oldest
+// first.
+func (c *DaemonSetController) podsByNode(name string) map[string][]pod.Pod {
+	byNode := make(map[string][]pod.Pod)
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindDaemonSet, name)) {
+		byNode[p.NodeName] = append(byNode[p.NodeName], p)
+	}
+	return byNode
+}
+
+// sync deletes pods that exited, sit on nodes that are no longer eligible
+// or duplicate another on the same node, replaces outdated pods within the
+// rolling update budget, and creates a pod on every eligible node without one.
+func (c *DaemonSetController) sync(name string) {
+	ds, exists := c.Get(name)
+	if !exists {
+		return
+	}
+	owner := ownerRef(events.KindDaemonSet, name)
+	eligible := c.eligibleNodes(ds)
+	revision := ds.Template.revision()
+
+	// current holds the one pod kept on each eligible node.
+	current := make(map[string]pod.Pod)
+	for nodeID, pods := range c.podsByNode(name) {
+		for _, p := range pods {
+			switch {
+			case p.Finished():
+				c.deletePod(name, p, "exited")
+			case !eligible[nodeID]:
+				c.deletePod(name, p, "node is no longer eligible")
+			case current[nodeID].ID != "":
+				c.deletePod(name, p, "duplicate pod on node")
+			default:
+				current[nodeID] = p
+			}
+		}
+	}
+
+	if ds.UpdateStrategy.Type == UpdateRollingUpdate {
+		c.rollingUpdate(ds, revision, eligible, current)
+	}
+
+	nodeIDs := make([]string, 0, len(eligible))
+	for nodeID := range eligible {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+	for _, nodeID := range nodeIDs {
+		if _, exists := current[nodeID]; exists {
+			continue
+		}
+		spec := ds.Template.spec(owner)
+		spec.NodeName = nodeID
+		spec.Labels[RevisionLabel] = revision
+		p, err := c.nm.CreatePod(spec)
+		if errors.Is(err, node.ErrPodRejected) {
+			c.nm.Events.Eventf(events.KindDaemonSet, name, events.TypeWarning, "FailedCreate", "Error creating pod on node %s: %v", nodeID, err)
+			continue
+		}
+		if err != nil {
+			logger.Debug("Pod is pending", "daemonset", name, logging.PodID(p.ID), logging.NodeID(nodeID), "reason", err)
+		}
+		c.nm.Events.Eventf(events.KindDaemonSet, name, events.TypeNormal, "SuccessfulCreate", "Created pod %s on node %s", p.ID, nodeID)
+	}
+}
+
+// rollingUpdate deletes outdated pods, removing them from current so they
+// are recreated from the new template. Outdated pods that are not running
+// go first and freely; running ones only while fewer than MaxUnavailable
+// eligible nodes lack a running pod.
+func (c *DaemonSetController) rollingUpdate(ds DaemonSet, revision string, eligible map[string]bool, current map[string]pod.Pod) {
+	unavailable := 0
+	for nodeID := range eligible {
+		if p, exists := current[nodeID]; !exists || p.Status != "Running" {
+			unavailable++
+		}
+	}
+
+	var outdated []pod.Pod
+	for _, p := range current {
+		if p.Labels[RevisionLabel] != revision {
+			outdated = append(outdated, p)
+		}
+	}
+	sort.Slice(outdated, func(i, j int) bool { return outdated[i].NodeName < outdated[j].NodeName })
+
+	for _, p := range outdated {
+		if p.Status == "Running" {
+			if unavailable >= ds.UpdateStrategy.MaxUnavailable {
+				continue
+			}
+			unavailable++
+		}
+		c.deletePod(ds.Name, p, "outdated template")
+		delete(current, p.NodeName)
+	}
+}
+
+func (c *DaemonSetController) deletePod(name string, p pod.Pod, reason string) {
+	if err := c.nm.DeletePod(p.ID); err != nil {
+		if !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod", "daemonset", name, logging.PodID(p.ID), logging.Err(err))
+		}
+		return
+	}
+	c.nm.Events.Eventf(events.KindDaemonSet, name, events.TypeNormal, "SuccessfulDelete", "Deleted pod %s: %s", p.ID, reason)
+}
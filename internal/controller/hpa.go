@@ -0,0 +1,511 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+)
+
+// hpaSyncInterval is how often every HPA compares utilization to its target.
+const hpaSyncInterval = 15 * time.Second
+
+// hpaTolerance is how far the utilization ratio may drift from 1.0 before the
+// HPA acts, so small fluctuations do not cause scaling.
+const hpaTolerance = 0.1
+
+// ErrHPANotFound is returned when an operation names an unknown HPA.
+var ErrHPANotFound = errors.New("horizontal pod autoscaler not found")
+
+// Scaling policy types and selection.
+const (
+	PolicyPods    = "Pods"
+	PolicyPercent = "Percent"
+
+	SelectMax      = "Max"
+	SelectMin      = "Min"
+	SelectDisabled = "Disabled"
+)
+
+// ScalingPolicy limits how much the replica count may change within PeriodSeconds.
+type ScalingPolicy struct {
+	Type          string `json:"type"`
+	Value         int    `json:"value"`
+	PeriodSeconds int    `json:"period_seconds"`
+}
+
+// ScalingRules configure one scaling direction.
+type ScalingRules struct {
+	// StabilizationWindowSeconds is how far back recommendations are
+	// considered, so the HPA does not flap.
+	StabilizationWindowSeconds *int `json:"stabilization_window_seconds,omitempty"`
+	// SelectPolicy picks the policy allowing the most (Max) or least (Min)
+	// change, or turns this direction off (Disabled).
+	SelectPolicy string          `json:"select_policy,omitempty"`
+	Policies     []ScalingPolicy `json:"policies,omitempty"`
+}
+
+// Behavior configures scale-up and scale-down separately.
+type Behavior struct {
+	ScaleUp   *ScalingRules `json:"scale_up,omitempty"`
+	ScaleDown *ScalingRules `json:"scale_down,omitempty"`
+}
+
+// HorizontalPodAutoscaler scales a pod group so its pods' average CPU
+// utilization stays near TargetUtilization percent.
+type HorizontalPodAutoscaler struct {
+	Name              string   `json:"name"`
+	Target            string   `json:"target"` // pod group name
+	MinReplicas       int      `json:"min_replicas"`
+	MaxReplicas       int      `json:"max_replicas"`
+	TargetUtilization int      `json:"target_utilization"`
+	Behavior          Behavior `json:"behavior"`
+}
+
+func intPtr(i int) *int { return &i }
+
+// defaultScaleUp and defaultScaleDown match Kubernetes' autoscaling/v2 defaults.
+func defaultScaleUp() ScalingRules {
+	return ScalingRules{
+		StabilizationWindowSeconds: intPtr(0),
+		SelectPolicy:               SelectMax,
+		Policies: []ScalingPolicy{
+			{Type: PolicyPercent, Value: 100, PeriodSeconds: 15},
+			{Type: PolicyPods, Value: 4, PeriodSeconds: 15},
+		},
+	}
+}
+
+func defaultScaleDown() ScalingRules {
+	return ScalingRules{
+		StabilizationWindowSeconds: intPtr(300),
+		SelectPolicy:               SelectMax,
+		Policies: []ScalingPolicy{
+			{Type: PolicyPercent, Value: 100, PeriodSeconds: 15},
+		},
+	}
+}
+
+// withDefaults fills in any rules left out.
+func (r *ScalingRules) withDefaults(defaults ScalingRules) ScalingRules {
+	if r == nil {
+		return defaults
+	}
+	rules := *r
+	if rules.StabilizationWindowSeconds == nil {
+		rules.StabilizationWindowSeconds = defaults.StabilizationWindowSeconds
+	}
+	if rules.SelectPolicy == "" {
+		rules.SelectPolicy = SelectMax
+	}
+	if len(rules.Policies) == 0 {
+		rules.Policies = defaults.Policies
+	}
+	return rules
+}
+
+// Validate fills in defaults and checks the HPA is well formed.
+func (h *HorizontalPodAutoscaler) Validate() error {
+	if h.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if h.Target == "" {
+		return fmt.Errorf("target pod group is required")
+	}
+	if h.MinReplicas == 0 {
+		h.MinReplicas = 1
+	}
+	if h.MinReplicas < 0 || h.MaxReplicas < h.MinReplicas {
+		return fmt.Errorf("replicas must satisfy 1 <= min_replicas <= max_replicas")
+	}
+	if h.TargetUtilization <= 0 {
+		return fmt.Errorf("target_utilization must be a positive percentage")
+	}
+	up := h.Behavior.ScaleUp.withDefaults(defaultScaleUp())
+	down := h.Behavior.ScaleDown.withDefaults(defaultScaleDown())
+	for _, rules := range []ScalingRules{up, down} {
+		if *rules.StabilizationWindowSeconds < 0 || *rules.StabilizationWindowSeconds > 3600 {
+			return fmt.Errorf("stabilization_window_seconds must be between 0 and 3600")
+		}
+		switch rules.SelectPolicy {
+		case SelectMax, SelectMin, SelectDisabled:
+		default:
+			return fmt.Errorf("invalid select_policy %q", rules.SelectPolicy)
+		}
+		for _, p := range rules.Policies {
+			if p.Type != PolicyPods && p.Type != PolicyPercent {
+				return fmt.Errorf("invalid policy type %q, expected Pods or Percent", p.Type)
+			}
+			if p.Value <= 0 || p.PeriodSeconds <= 0 {
+				return fmt.Errorf("policy value and period_seconds must be positive")
+			}
+		}
+	}
+	h.Behavior = Behavior{ScaleUp: &up, ScaleDown: &down}
+	return nil
+}
+
+// HPAStatus is the HPA's view of its target.
+type HPAStatus struct {
+	CurrentReplicas    int        `json:"current_replicas"`
+	DesiredReplicas    int        `json:"desired_replicas"`
+	CurrentUtilization *int       `json:"current_utilization,omitempty"` // percent, unset without metrics
+	LastScaleTime      *time.Time `json:"last_scale_time,omitempty"`
+	Message            string     `json:"message,omitempty"`
+}
+
+// HPAWithStatus is an HPA together with its status.
+type HPAWithStatus struct {
+	HorizontalPodAutoscaler
+	Status HPAStatus `json:"status"`
+}
+
+// timestampedReplicas is a recommendation or a scale event.
+type timestampedReplicas struct {
+	at       time.Time
+	replicas int
+}
+
+type hpaState struct {
+	spec            HorizontalPodAutoscaler
+	status          HPAStatus
+	recommendations []timestampedReplicas
+	scaleUps        []timestampedReplicas // replicas added
+	scaleDowns      []timestampedReplicas // replicas removed
+}
+
+// HPAController runs every HorizontalPodAutoscaler against the pod groups.
+type HPAController struct {
+	nm     *node.NodeManager
+	groups *PodGroupController
+
+	mu   sync.Mutex // Protects hpas
+	hpas map[string]*hpaState
+}
+
+// NewHPAController creates a controller scaling the groups managed by groups.
+func NewHPAController(nm *node.NodeManager, groups *PodGroupController) *HPAController {
+	return &HPAController{nm: nm, groups: groups, hpas: make(map[string]*hpaState)}
+}
+
+// Start runs the HPA loop in a goroutine.
+func (c *HPAController) Start() {
+	go func() {
+		for {
+			time.Sleep(hpaSyncInterval)
+			c.mu.Lock()
+			names := make([]string, 0, len(c.hpas))
+			for name := range c.hpas {
+				names = append(names, name)
+			}
+			c.mu.Unlock()
+			sort.Strings(names)
+			for _, name := range names {
+				c.reconcile(name, time.Now())
+			}
+		}
+	}()
+}
+
+// Set creates or replaces an HPA. Replacing one keeps its history.
+func (c *HPAController) Set(h HorizontalPodAutoscaler) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	logger.Info("HPA set", "hpa", h.Name, "target", h.Target, "min_replicas", h.MinReplicas, "max_replicas", h.MaxReplicas, "utilization", h.TargetUtilization)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st, exists := c.hpas[h.Name]; exists {
+		st.spec = h
+		return nil
+	}
+	c.hpas[h.Name] = &hpaState{spec: h}
+	return nil
+}
+
+// Delete removes an HPA; the pod group keeps its current size.
+func (c *HPAController) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.hpas[name]; !exists {
+		return ErrHPANotFound
+	}
+	delete(c.hpas, name)
+	metrics.HPACurrentReplicas.DeleteLabelValues(name)
+	metrics.HPADesiredReplicas.DeleteLabelValues(name)
+	metrics.HPAUtilization.DeleteLabelValues(name)
+	return nil
+}
+
+// List returns every HPA with its status, by name.
+func (c *HPAController) List() []HPAWithStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]HPAWithStatus, 0, len(c.hpas))
+	for _, st := range c.hpas {
+		list = append(list, HPAWithStatus{HorizontalPodAutoscaler: st.spec, Status: st.status})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// reconcile computes the desired replica count for one HPA and applies it.
+func (c *HPAController) reconcile(name string, now time.Time) {
+	c.mu.Lock()
+	st, exists := c.hpas[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	spec := st.spec
+	c.mu.Unlock()
+
+	group, exists := c.groups.Get(spec.Target)
+	if !exists {
+		c.setStatus(name, func(s *HPAStatus) { s.Message = fmt.Sprintf("pod group %q not found", spec.Target) })
+		c.nm.Events.Eventf(events.KindHPA, name, events.TypeWarning, "FailedGetScale", "Pod group %q not found", spec.Target)
+		return
+	}
+	current := group.Replicas
+	if current == 0 {
+		// Like Kubernetes, a target scaled to zero by hand disables the HPA.
+		c.setStatus(name, func(s *HPAStatus) {
+			s.CurrentReplicas, s.DesiredReplicas, s.Message = 0, 0, "scaling is disabled since the replica count of the target is zero"
+		})
+		return
+	}
+
+	recommended, utilization, ok := c.recommend(spec, current)
+	message := ""
+	if !ok {
+		recommended = current
+		message = "no running pods to read utilization from"
+	}
+
+	c.mu.Lock()
+	st, exists = c.hpas[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	desired := st.stabilize(now, current, recommended)
+	desired = st.applyPolicies(now, current, desired)
+	if desired < spec.MinReplicas {
+		desired = spec.MinReplicas
+	}
+	if desired > spec.MaxReplicas {
+		desired = spec.MaxReplicas
+	}
+	st.status.CurrentReplicas = current
+	st.status.DesiredReplicas = desired
+	st.status.Message = message
+	if ok {
+		st.status.CurrentUtilization = intPtr(utilization)
+	} else {
+		st.status.CurrentUtilization = nil
+	}
+	c.mu.Unlock()
+
+	metrics.HPACurrentReplicas.WithLabelValues(name).Set(float64(current))
+	metrics.HPADesiredReplicas.WithLabelValues(name).Set(float64(desired))
+	if ok {
+		metrics.HPAUtilization.WithLabelValues(name).Set(float64(utilization))
+	}
+	if desired == current {
+		return
+	}
+
+	if err := c.groups.Scale(spec.Target, desired); err != nil {
+		c.nm.Events.Eventf(events.KindHPA, name, events.TypeWarning, "FailedRescale", "New size: %d; error: %v", desired, err)
+		return
+	}
+	direction, reason := "up", "above"
+	if desired < current {
+		direction, reason = "down", "below"
+	}
+	if !ok {
+		reason = "outside"
+	}
+	metrics.HPAScalings.WithLabelValues(name, direction).Inc()
+	logger.Info("Scaled pod group", "hpa", name, "pod_group", spec.Target, "from", current, "to", desired)
+	c.nm.Events.Eventf(events.KindHPA, name, events.TypeNormal, "SuccessfulRescale", "New size: %d; reason: cpu utilization %s target (or replica limits)", desired, reason)
+
+	c.mu.Lock()
+	if st, exists := c.hpas[name]; exists {
+		if desired > current {
+			st.scaleUps = append(pruneScaleEvents(st.scaleUps, now), timestampedReplicas{now, desired - current})
+		} else {
+			st.scaleDowns = append(pruneScaleEvents(st.scaleDowns, now), timestampedReplicas{now, current - desired})
+		}
+		st.status.LastScaleTime = &now
+	}
+	c.mu.Unlock()
+}
+
+func (c *HPAController) setStatus(name string, update func(*HPAStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st, exists := c.hpas[name]; exists {
+		update(&st.status)
+	}
+}
+
+// recommend returns the replica count the current utilization calls for and
+// the average utilization in percent. Pending pods count as idle when
+// scaling up, so pods stuck without a node do not trigger runaway growth.
+func (c *HPAController) recommend(spec HorizontalPodAutoscaler, current int) (int, int, bool) {
+	pods := c.nm.PodsOwnedBy(ownerRef(events.KindPodGroup, spec.Target))
+	var total float64
+	running, pending := 0, 0
+	for _, p := range pods {
+		switch p.Status {
+		case "Running":
+			running++
+			total += p.Utilization
+		case "Pending":
+			pending++
+		}
+	}
+	if running == 0 {
+		return current, 0, false
+	}
+
+	target := float64(spec.TargetUtilization) / 100
+	utilization := int(math.Round(total / float64(running) * 100))
+	ratio := total / float64(running) / target
+	if math.Abs(ratio-1) <= hpaTolerance {
+		return current, utilization, true
+	}
+	if ratio > 1 && pending > 0 {
+		ratio = total / float64(running+pending) / target
+		if ratio <= 1+hpaTolerance {
+			return current, utilization, true
+		}
+		return int(math.Ceil(ratio * float64(running+pending))), utilization, true
+	}
+	return int(math.Ceil(ratio * float64(running))), utilization, true
+}
+
+// stabilize records the recommendation and returns the replica count allowed
+// by the stabilization windows: scale-ups go no higher than the lowest
+// recommendation in the up window, scale-downs no lower than the highest in
+// the down window. c.mu must be held.
+func (st *hpaState) stabilize(now time.Time, current, recommended int) int {
+	upWindow := time.Duration(*st.spec.Behavior.ScaleUp.StabilizationWindowSeconds) * time.Second
+	downWindow := time.Duration(*st.spec.Behavior.ScaleDown.StabilizationWindowSeconds) * time.Second
+	longest := upWindow
+	if downWindow > longest {
+		longest = downWindow
+	}
+
+	st.recommendations = append(st.recommendations, timestampedReplicas{now, recommended})
+	kept := st.recommendations[:0]
+	for _, r := range st.recommendations {
+		if now.Sub(r.at) <= longest {
+			kept = append(kept, r)
+		}
+	}
+	st.recommendations = kept
+
+	upRecommendation, downRecommendation := recommended, recommended
+	for _, r := range st.recommendations {
+		if now.Sub(r.at) <= upWindow && r.replicas < upRecommendation {
+			upRecommendation = r.replicas
+		}
+		if now.Sub(r.at) <= downWindow && r.replicas > downRecommendation {
+			downRecommendation = r.replicas
+		}
+	}
+
+	stabilized := current
+	if stabilized < upRecommendation {
+		stabilized = upRecommendation
+	}
+	if stabilized > downRecommendation {
+		stabilized = downRecommendation
+	}
+	return stabilized
+}
+
+// applyPolicies limits the change from current to desired by the scaling
+// policies of that direction. c.mu must be held.
+func (st *hpaState) applyPolicies(now time.Time, current, desired int) int {
+	switch {
+	case desired > current:
+		rules := st.spec.Behavior.ScaleUp
+		if rules.SelectPolicy == SelectDisabled {
+			return current
+		}
+		limit := -1
+		for _, p := range rules.Policies {
+			start := current - replicasChangedSince(st.scaleUps, now, p.PeriodSeconds)
+			var policyLimit int
+			if p.Type == PolicyPods {
+				policyLimit = start + p.Value
+			} else {
+				policyLimit = int(math.Ceil(float64(start) * (1 + float64(p.Value)/100)))
+			}
+			if limit < 0 || (rules.SelectPolicy == SelectMax && policyLimit > limit) || (rules.SelectPolicy == SelectMin && policyLimit < limit) {
+				limit = policyLimit
+			}
+		}
+		if limit >= 0 && desired > limit {
+			return limit
+		}
+	case desired < current:
+		rules := st.spec.Behavior.ScaleDown
+		if rules.SelectPolicy == SelectDisabled {
+			return current
+		}
+		limit := -1
+		for _, p := range rules.Policies {
+			start := current + replicasChangedSince(st.scaleDowns, now, p.PeriodSeconds)
+			var policyLimit int
+			if p.Type == PolicyPods {
+				policyLimit = start - p.Value
+			} else {
+				policyLimit = int(float64(start) * (1 - float64(p.Value)/100))
+			}
+			// For scale-down the policy allowing the most change has the lowest limit.
+			if limit < 0 || (rules.SelectPolicy == SelectMax && policyLimit < limit) || (rules.SelectPolicy == SelectMin && policyLimit > limit) {
+				limit = policyLimit
+			}
+		}
+		if limit >= 0 && desired < limit {
+			return limit
+		}
+	}
+	return desired
+}
+
+// scaleEventRetention is how long scale events are kept for the policies;
+// longer policy periods only see the events within it.
+const scaleEventRetention = time.Hour
+
+// pruneScaleEvents drops scale events older than scaleEventRetention.
+func pruneScaleEvents(changes []timestampedReplicas, now time.Time) []timestampedReplicas {
+	kept := changes[:0]
+	for _, ch := range changes {
+		if now.Sub(ch.at) < scaleEventRetention {
+			kept = append(kept, ch)
+		}
+	}
+	return kept
+}
+
+// replicasChangedSince sums the scale events within the last periodSeconds.
+func replicasChangedSince(changes []timestampedReplicas, now time.Time, periodSeconds int) int {
+	total := 0
+	for _, ch := range changes {
+		if now.Sub(ch.at) < time.Duration(periodSeconds)*time.Second {
+			total += ch.replicas
+		}
+	}
+	return total
+}
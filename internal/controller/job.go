@@ -0,0 +1,384 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+)
+
+// jobSyncInterval is how often jobs are reconciled against their pods.
+const jobSyncInterval = time.Second
+
+// ErrJobNotFound is returned when an operation names an unknown job.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job conditions
+const (
+	JobComplete = "Complete"
+	JobFailed   = "Failed"
+)
+
+// JobSpec describes the work a job does. Unset counts take Kubernetes'
+// defaults: one completion, one pod at a time and six retries. Finished
+// pods are kept as CronJobs keep jobs: the latest three that succeeded and
+// the latest that failed.
+type JobSpec struct {
+	Completions                *int        `json:"completions,omitempty"`
+	Parallelism                *int        `json:"parallelism,omitempty"`
+	BackoffLimit               *int        `json:"backoff_limit,omitempty"`
+	ActiveDeadlineSeconds      *int        `json:"active_deadline_seconds,omitempty"`
+	SuccessfulPodsHistoryLimit *int        `json:"successful_pods_history_limit,omitempty"`
+	FailedPodsHistoryLimit     *int        `json:"failed_pods_history_limit,omitempty"`
+	Template                   PodTemplate `json:"template"`
+}
+
+// Validate fills in defaults and checks the spec is well formed.
+func (s *JobSpec) Validate() error {
+	if s.Completions == nil {
+		s.Completions = intPtr(1)
+	}
+	if s.Parallelism == nil {
+		s.Parallelism = intPtr(1)
+	}
+	if s.BackoffLimit == nil {
+		s.BackoffLimit = intPtr(6)
+	}
+	if s.SuccessfulPodsHistoryLimit == nil {
+		s.SuccessfulPodsHistoryLimit = intPtr(3)
+	}
+	if s.FailedPodsHistoryLimit == nil {
+		s.FailedPodsHistoryLimit = intPtr(1)
+	}
+	if *s.Completions <= 0 || *s.Parallelism <= 0 || *s.BackoffLimit < 0 {
+		return fmt.Errorf("completions and parallelism must be positive and backoff_limit must not be negative")
+	}
+	if *s.SuccessfulPodsHistoryLimit < 0 || *s.FailedPodsHistoryLimit < 0 {
+		return fmt.Errorf("history limits must not be negative")
+	}
+	if s.ActiveDeadlineSeconds != nil && *s.ActiveDeadlineSeconds <= 0 {
+		return fmt.Errorf("active_deadline_seconds must be positive")
+	}
+	if err := s.Template.Validate(); err != nil {
+		return err
+	}
+	if !s.Template.runsToCompletion() {
+		return fmt.Errorf("job pods need a run_seconds or a command")
+	}
+	return nil
+}
+
+func (t PodTemplate) runsToCompletion() bool {
+	return t.RunSeconds > 0 || len(t.Command) > 0
+}
+
+// Job runs pods until Completions of them have succeeded.
+type Job struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty"` // set for jobs created by a CronJob
+	JobSpec
+}
+
+// JobStatus tracks a job's pods. Succeeded and Failed count every pod that
+// finished, including those since removed beyond the history limits.
+type JobStatus struct {
+	Active         int        `json:"active"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	StartTime      time.Time  `json:"start_time"`
+	CompletionTime *time.Time `json:"completion_time,omitempty"`
+	Condition      string     `json:"condition,omitempty"` // Complete or Failed once finished
+	Reason         string     `json:"reason,omitempty"`
+}
+
+// Finished reports whether the job has completed or failed.
+func (s JobStatus) Finished() bool {
+	return s.Condition != ""
+}
+
+// JobWithStatus is a job together with its status.
+type JobWithStatus struct {
+	Job
+	Status JobStatus `json:"status"`
+
+	// counted holds the finished pods already in Status. Only sync uses
+	// it, holding the job's sync lock.
+	counted map[string]bool
+}
+
+// JobController creates pods for jobs and tracks how they finish.
+type JobController struct {
+	nm *node.NodeManager
+
+	mu      sync.Mutex // Protects jobs and syncing
+	jobs    map[string]*JobWithStatus
+	syncing map[string]*sync.Mutex // by job, so syncs of a job never overlap
+}
+
+// NewJobController creates a controller for the cluster managed by nm.
+func NewJobController(nm *node.NodeManager) *JobController {
+	return &JobController{nm: nm, jobs: make(map[string]*JobWithStatus), syncing: make(map[string]*sync.Mutex)}
+}
+
+// lockJob takes the sync lock of a job and returns the function that
+// releases it. Two syncs at once would both see too few active pods and
+// both start more.
+func (c *JobController) lockJob(name string) func() {
+	c.mu.Lock()
+	m, exists := c.syncing[name]
+	if !exists {
+		m = &sync.Mutex{}
+		c.syncing[name] = m
+	}
+	c.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+// Start runs the reconcile loop in a goroutine.
+func (c *JobController) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.sync(name, time.Now())
+			}
+			time.Sleep(jobSyncInterval)
+		}
+	}()
+}
+
+func (c *JobController) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.jobs))
+	for name := range c.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create starts a new job. Jobs cannot be replaced; delete and recreate them.
+func (c *JobController) Create(job Job) error {
+	if job.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := job.JobSpec.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if _, exists := c.jobs[job.Name]; exists {
+		c.mu.Unlock()
+		return fmt.Errorf("job %q already exists", job.Name)
+	}
+	c.jobs[job.Name] = &JobWithStatus{Job: job, Status: JobStatus{StartTime: time.Now()}, counted: make(map[string]bool)}
+	c.mu.Unlock()
+	logger.Info("Job created", "job", job.Name, "completions", *job.Completions, "parallelism", *job.Parallelism)
+	c.sync(job.Name, time.Now())
+	return nil
+}
+
+// Restore puts back a job saved by an earlier server with its status. A
+// job that had not finished starts pods again, as the ones it had are gone
+// with their nodes.
+func (c *JobController) Restore(job JobWithStatus) error {
+	if job.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := job.JobSpec.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if _, exists := c.jobs[job.Name]; exists {
+		c.mu.Unlock()
+		return fmt.Errorf("job %q already exists", job.Name)
+	}
+	job.Status.Active = 0
+	job.counted = make(map[string]bool)
+	c.jobs[job.Name] = &job
+	c.mu.Unlock()
+	c.sync(job.Name, time.Now())
+	return nil
+}
+
+// Get returns a job with its status.
+func (c *JobController) Get(name string) (JobWithStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job, exists := c.jobs[name]
+	if !exists {
+		return JobWithStatus{}, false
+	}
+	return *job, true
+}
+
+// List returns every job with its status, oldest first.
+func (c *JobController) List() []JobWithStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]JobWithStatus, 0, len(c.jobs))
+	for _, job := range c.jobs {
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Status.StartTime.Equal(list[j].Status.StartTime) {
+			return list[i].Status.StartTime.Before(list[j].Status.StartTime)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Delete removes a job and its pods.
+func (c *JobController) Delete(name string) error {
+	c.mu.Lock()
+	_, exists := c.jobs[name]
+	delete(c.jobs, name)
+	delete(c.syncing, name)
+	c.mu.Unlock()
+	if !exists {
+		return ErrJobNotFound
+	}
+	c.deletePods(name, false)
+	return nil
+}
+
+// prunePods deletes the oldest of a job's finished pods beyond limit.
+func (c *JobController) prunePods(name string, job JobWithStatus, finished []pod.Pod, limit int) {
+	if len(finished) <= limit {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finishedAt(finished[i]).Before(finishedAt(finished[j]))
+	})
+	for _, p := range finished[:len(finished)-limit] {
+		if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod", "job", name, logging.PodID(p.ID), logging.Err(err))
+			continue
+		}
+		delete(job.counted, p.ID)
+	}
+}
+
+func finishedAt(p pod.Pod) time.Time {
+	if p.FinishedAt == nil {
+		return time.Time{}
+	}
+	return *p.FinishedAt
+}
+
+// deletePods deletes a job's pods, or only the unfinished ones when activeOnly is set.
+func (c *JobController) deletePods(name string, activeOnly bool) {
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindJob, name)) {
+		if activeOnly && p.Finished() {
+			continue
+		}
+		if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod", "job", name, logging.PodID(p.ID), logging.Err(err))
+		}
+	}
+}
+
+// sync counts a job's pods, finishes the job when it has enough successes,
+// too many failures or runs past its deadline, and otherwise creates pods up
+// to its parallelism. Finished pods beyond the history limits are deleted.
+func (c *JobController) sync(name string, now time.Time) {
+	defer c.lockJob(name)()
+	job, exists := c.Get(name)
+	if !exists || job.Status.Finished() {
+		return
+	}
+	owner := ownerRef(events.KindJob, name)
+
+	st := job.Status
+	st.Active = 0
+	var succeeded, failed []pod.Pod
+	for _, p := range c.nm.PodsOwnedBy(owner) {
+		switch p.Status {
+		case "Succeeded":
+			if !job.counted[p.ID] {
+				st.Succeeded++
+			}
+			succeeded = append(succeeded, p)
+		case "Failed":
+			if !job.counted[p.ID] {
+				st.Failed++
+			}
+			failed = append(failed, p)
+		default:
+			st.Active++
+			continue
+		}
+		job.counted[p.ID] = true
+	}
+	c.prunePods(name, job, succeeded, *job.SuccessfulPodsHistoryLimit)
+	c.prunePods(name, job, failed, *job.FailedPodsHistoryLimit)
+	completions, parallelism, backoffLimit := *job.Completions, *job.Parallelism, *job.BackoffLimit
+
+	switch {
+	case st.Succeeded >= completions:
+		st.Condition, st.Reason = JobComplete, ""
+	case st.Failed > backoffLimit:
+		st.Condition, st.Reason = JobFailed, "BackoffLimitExceeded"
+	case job.ActiveDeadlineSeconds != nil && now.Sub(job.Status.StartTime) >= time.Duration(*job.ActiveDeadlineSeconds)*time.Second:
+		st.Condition, st.Reason = JobFailed, "DeadlineExceeded"
+	}
+
+	if st.Condition == "" {
+		want := parallelism
+		if remaining := completions - st.Succeeded; remaining < want {
+			want = remaining
+		}
+		for i := st.Active; i < want; i++ {
+			p, err := c.nm.CreatePod(job.Template.spec(owner))
+			if errors.Is(err, node.ErrPodRejected) {
+				c.nm.Events.Eventf(events.KindJob, name, events.TypeWarning, "FailedCreate", "Error creating pod: %v", err)
+				break
+			}
+			if err != nil {
+				logger.Debug("Pod is pending", "job", name, logging.PodID(p.ID), "reason", err)
+			}
+			st.Active++
+			c.nm.Events.Eventf(events.KindJob, name, events.TypeNormal, "SuccessfulCreate", "Created pod %s", p.ID)
+		}
+	}
+
+	c.mu.Lock()
+	current, exists := c.jobs[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	if st.Condition != "" {
+		st.CompletionTime = &now
+	}
+	current.Status = st
+	c.mu.Unlock()
+
+	if st.Condition == "" {
+		return
+	}
+	duration := now.Sub(st.StartTime)
+	metrics.JobsFinished.WithLabelValues(st.Condition).Inc()
+	metrics.JobDuration.WithLabelValues(st.Condition).Observe(duration.Seconds())
+	if st.Condition == JobComplete {
+		logger.Info("Job completed", "job", name, "duration", duration.Round(time.Second))
+		c.nm.Events.Eventf(events.KindJob, name, events.TypeNormal, "Completed", "Job completed in %s", duration.Round(time.Second))
+		return
+	}
+	// A failed job stops its remaining pods.
+	c.deletePods(name, true)
+	logger.Warn("Job failed", "job", name, "reason", st.Reason)
+	if st.Reason == "BackoffLimitExceeded" {
+		c.nm.Events.Eventf(events.KindJob, name, events.TypeWarning, st.Reason, "Job has reached the specified backoff limit of %d", backoffLimit)
+	} else {
+		c.nm.Events.Eventf(events.KindJob, name, events.TypeWarning, st.Reason, "Job was active longer than specified deadline of %ds", *job.ActiveDeadlineSeconds)
+	}
+}
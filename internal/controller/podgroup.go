@@ -0,0 +1,380 @@
+// Package controller holds the workload controllers, which create and remove
+// pods through the NodeManager so they are placed by the normal scheduler.
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+)
+
+// podGroupSyncInterval is how often pod groups are reconciled and their
+// load is spread over their pods.
+const podGroupSyncInterval = 5 * time.Second
+
+// ErrPodGroupNotFound is returned when an operation names an unknown pod group.
+var ErrPodGroupNotFound = errors.New("pod group not found")
+
+var logger = logging.For("controller")
+
+// ownerRef is the value stored in pod.Owner for pods a controller manages.
+func ownerRef(kind, name string) string {
+	return kind + "/" + name
+}
+
+// PodTemplate describes the pods a controller creates.
+type PodTemplate struct {
+	CPUs               int               `json:"cpus"`
+	Algorithm          string            `json:"algorithm,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Tolerations        []pod.Toleration  `json:"tolerations,omitempty"`
+	GracePeriodSeconds int               `json:"termination_grace_period_seconds"`
+	// RunSeconds or Command make the pods run to completion; FailureRate is
+	// the chance a simulated run fails.
+	RunSeconds  int      `json:"run_seconds,omitempty"`
+	Command     []string `json:"command,omitempty"`
+	FailureRate float64  `json:"failure_rate,omitempty"`
+}
+
+// Validate checks the template is well formed.
+func (t PodTemplate) Validate() error {
+	if t.CPUs <= 0 {
+		return fmt.Errorf("template cpus must be positive")
+	}
+	if t.RunSeconds < 0 {
+		return fmt.Errorf("template run_seconds must not be negative")
+	}
+	if t.FailureRate < 0 || t.FailureRate > 1 {
+		return fmt.Errorf("template failure_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// spec returns the NodeManager pod spec for a pod owned by owner.
+func (t PodTemplate) spec(owner string) node.PodSpec {
+	podLabels := make(map[string]string, len(t.Labels))
+	for k, v := range t.Labels {
+		podLabels[k] = v
+	}
+	return node.PodSpec{
+		CPUs:               t.CPUs,
+		Algorithm:          t.Algorithm,
+		Labels:             podLabels,
+		GracePeriodSeconds: t.GracePeriodSeconds,
+		Tolerations:        t.Tolerations,
+		RunSeconds:         t.RunSeconds,
+		Command:            t.Command,
+		FailureRate:        t.FailureRate,
+		Owner:              owner,
+	}
+}
+
+// LoadModel is the simulated work a pod group has to handle, in CPUs. It is
+// spread evenly over the group's running pods to give each its utilization.
+type LoadModel struct {
+	// Type is "constant" (the default) or "sine".
+	Type string `json:"type,omitempty"`
+	// Load is the constant load, or the mean of a sine wave.
+	Load float64 `json:"load"`
+	// Amplitude and PeriodSeconds shape a sine wave.
+	Amplitude     float64 `json:"amplitude,omitempty"`
+	PeriodSeconds int     `json:"period_seconds,omitempty"`
+}
+
+// Validate checks the model is well formed.
+func (m LoadModel) Validate() error {
+	switch m.Type {
+	case "", "constant":
+	case "sine":
+		if m.PeriodSeconds <= 0 {
+			return fmt.Errorf("sine load needs a positive period_seconds")
+		}
+	default:
+		return fmt.Errorf("unknown load model %q, expected constant or sine", m.Type)
+	}
+	if m.Load < 0 {
+		return fmt.Errorf("load must not be negative")
+	}
+	return nil
+}
+
+// At returns the load at time t, never below zero.
+func (m LoadModel) At(t time.Time) float64 {
+	load := m.Load
+	if m.Type == "sine" {
+		phase := 2 * math.Pi * float64(t.UnixNano()) / float64(time.Duration(m.PeriodSeconds)*time.Second)
+		load += m.Amplitude * math.Sin(phase)
+	}
+	return math.Max(load, 0)
+}
+
+// PodGroup keeps Replicas copies of a pod template running.
+type PodGroup struct {
+	Name     string      `json:"name"`
+	Replicas int         `json:"replicas"`
+	Template PodTemplate `json:"template"`
+	Load     LoadModel   `json:"load"`
+}
+
+// Validate checks the pod group is well formed.
+func (g PodGroup) Validate() error {
+	if g.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if g.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	if err := g.Template.Validate(); err != nil {
+		return err
+	}
+	return g.Load.Validate()
+}
+
+// PodGroupStatus is a pod group together with the state of its pods.
+type PodGroupStatus struct {
+	PodGroup
+	CurrentReplicas    int      `json:"current_replicas"`
+	ReadyReplicas      int      `json:"ready_replicas"`
+	CurrentLoad        float64  `json:"current_load"`
+	AverageUtilization float64  `json:"average_utilization"` // percent of requested CPUs
+	Pods               []string `json:"pods"`
+}
+
+// PodGroupController creates and deletes pods so each group has as many as
+// it asks for, and feeds each group's load model into its pods.
+type PodGroupController struct {
+	nm *node.NodeManager
+
+	mu     sync.Mutex // Protects groups
+	groups map[string]PodGroup
+}
+
+// NewPodGroupController creates a controller for the cluster managed by nm.
+func NewPodGroupController(nm *node.NodeManager) *PodGroupController {
+	return &PodGroupController{nm: nm, groups: make(map[string]PodGroup)}
+}
+
+// Start runs the reconcile loop in a goroutine.
+func (c *PodGroupController) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.sync(name)
+			}
+			time.Sleep(podGroupSyncInterval)
+		}
+	}()
+}
+
+func (c *PodGroupController) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.groups))
+	for name := range c.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set creates or replaces a pod group and reconciles it right away.
+func (c *PodGroupController) Set(g PodGroup) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.groups[g.Name] = g
+	c.mu.Unlock()
+	logger.Info("Pod group set", "pod_group", g.Name, "replicas", g.Replicas, "cpus", g.Template.CPUs)
+	c.sync(g.Name)
+	return nil
+}
+
+// Get returns a pod group by name.
+func (c *PodGroupController) Get(name string) (PodGroup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g, exists := c.groups[name]
+	return g, exists
+}
+
+// Scale changes a pod group's replica count.
+func (c *PodGroupController) Scale(name string, replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	c.mu.Lock()
+	g, exists := c.groups[name]
+	if !exists {
+		c.mu.Unlock()
+		return ErrPodGroupNotFound
+	}
+	g.Replicas = replicas
+	c.groups[name] = g
+	c.mu.Unlock()
+	c.sync(name)
+	return nil
+}
+
+// SetLoad replaces a pod group's load model.
+func (c *PodGroupController) SetLoad(name string, load LoadModel) error {
+	if err := load.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	g, exists := c.groups[name]
+	if !exists {
+		c.mu.Unlock()
+		return ErrPodGroupNotFound
+	}
+	g.Load = load
+	c.groups[name] = g
+	c.mu.Unlock()
+	c.sync(name)
+	return nil
+}
+
+// Delete removes a pod group and all of its pods.
+func (c *PodGroupController) Delete(name string) error {
+	c.mu.Lock()
+	_, exists := c.groups[name]
+	delete(c.groups, name)
+	c.mu.Unlock()
+	if !exists {
+		return ErrPodGroupNotFound
+	}
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindPodGroup, name)) {
+		if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod of pod group", "pod_group", name, logging.PodID(p.ID), logging.Err(err))
+		}
+	}
+	metrics.PodGroupLoad.DeleteLabelValues(name)
+	return nil
+}
+
+// List returns every pod group with its status, by name.
+func (c *PodGroupController) List() []PodGroupStatus {
+	var list []PodGroupStatus
+	for _, name := range c.names() {
+		if st, ok := c.Status(name); ok {
+			list = append(list, st)
+		}
+	}
+	if list == nil {
+		list = []PodGroupStatus{}
+	}
+	return list
+}
+
+// Status returns a pod group with the state of its pods.
+func (c *PodGroupController) Status(name string) (PodGroupStatus, bool) {
+	g, exists := c.Get(name)
+	if !exists {
+		return PodGroupStatus{}, false
+	}
+	st := PodGroupStatus{PodGroup: g, CurrentLoad: g.Load.At(time.Now()), Pods: []string{}}
+	var total float64
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindPodGroup, name)) {
+		st.CurrentReplicas++
+		st.Pods = append(st.Pods, p.ID)
+		if p.Status == "Running" {
+			st.ReadyReplicas++
+			total += p.Utilization
+		}
+	}
+	if st.ReadyReplicas > 0 {
+		st.AverageUtilization = math.Round(total/float64(st.ReadyReplicas)*1000) / 10
+	}
+	return st, true
+}
+
+// sync creates or deletes pods until the group has Replicas of them and
+// spreads the current load over the running ones.
+func (c *PodGroupController) sync(name string) {
+	g, exists := c.Get(name)
+	if !exists {
+		return
+	}
+	owner := ownerRef(events.KindPodGroup, name)
+	var owned []pod.Pod
+	for _, p := range c.nm.PodsOwnedBy(owner) {
+		// Pods that exited are replaced, like a restart policy of Always.
+		if p.Finished() {
+			if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+				logger.Error("Failed to delete exited pod", "pod_group", name, logging.PodID(p.ID), logging.Err(err))
+			}
+			continue
+		}
+		owned = append(owned, p)
+	}
+
+	for i := len(owned); i < g.Replicas; i++ {
+		p, err := c.nm.CreatePod(g.Template.spec(owner))
+		if errors.Is(err, node.ErrPodRejected) {
+			c.nm.Events.Eventf(events.KindPodGroup, name, events.TypeWarning, "FailedCreate", "Error creating pod: %v", err)
+			break
+		}
+		if err != nil {
+			logger.Debug("Pod is pending", "pod_group", name, logging.PodID(p.ID), "reason", err)
+		}
+		c.nm.Events.Eventf(events.KindPodGroup, name, events.TypeNormal, "SuccessfulCreate", "Created pod %s", p.ID)
+	}
+	if excess := len(owned) - g.Replicas; excess > 0 {
+		for _, p := range scaleDownOrder(owned)[:excess] {
+			if err := c.nm.DeletePod(p.ID); err != nil {
+				logger.Error("Failed to delete pod", "pod_group", name, logging.PodID(p.ID), logging.Err(err))
+				continue
+			}
+			c.nm.Events.Eventf(events.KindPodGroup, name, events.TypeNormal, "SuccessfulDelete", "Deleted pod %s", p.ID)
+		}
+	}
+
+	c.distributeLoad(g, owner)
+}
+
+// scaleDownOrder sorts pods so the cheapest to lose come first: pending
+// pods, then the most recently created.
+func scaleDownOrder(pods []pod.Pod) []pod.Pod {
+	ordered := append([]pod.Pod(nil), pods...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iRunning, jRunning := ordered[i].Status == "Running", ordered[j].Status == "Running"
+		if iRunning != jRunning {
+			return !iRunning
+		}
+		return ordered[i].CreatedAt.After(ordered[j].CreatedAt)
+	})
+	return ordered
+}
+
+// distributeLoad sets every running pod's utilization to its share of the
+// group's current load. Pods that are not running carry no load.
+func (c *PodGroupController) distributeLoad(g PodGroup, owner string) {
+	load := g.Load.At(time.Now())
+	metrics.PodGroupLoad.WithLabelValues(g.Name).Set(load)
+
+	pods := c.nm.PodsOwnedBy(owner)
+	running := 0
+	for _, p := range pods {
+		if p.Status == "Running" {
+			running++
+		}
+	}
+	for _, p := range pods {
+		utilization := 0.0
+		if p.Status == "Running" && p.CPUs > 0 {
+			utilization = load / float64(running*p.CPUs)
+		}
+		if err := c.nm.SetPodUtilization(p.ID, utilization); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Warn("Failed to set pod utilization", "pod_group", g.Name, logging.PodID(p.ID), logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/pod"
+	"cluster-sim/internal/storage"
+)
+
+// statefulSetSyncInterval is how often StatefulSets are reconciled.
+const statefulSetSyncInterval = 2 * time.Second
+
+// ErrStatefulSetNotFound is returned when an operation names an unknown StatefulSet.
+var ErrStatefulSetNotFound = errors.New("statefulset not found")
+
+// Pod management policies
+const (
+	PodManagementOrderedReady = "OrderedReady"
+	PodManagementParallel     = "Parallel"
+)
+
+// StatefulSetUpdateStrategy controls how pods are replaced when the template
+// changes. RollingUpdate (the default) replaces pods one at a time from the
+// highest ordinal down, leaving pods below Partition on the old template;
+// OnDelete only uses the new template for pods that were deleted.
+type StatefulSetUpdateStrategy struct {
+	Type      string `json:"type,omitempty"`
+	Partition int    `json:"partition,omitempty"`
+}
+
+// VolumeClaimTemplate describes a claim made for every pod of a StatefulSet.
+// The claim for pod db-1 from template "data" is named data-db-1.
+type VolumeClaimTemplate struct {
+	Name         string   `json:"name"`
+	StorageClass string   `json:"storage_class,omitempty"`
+	AccessModes  []string `json:"access_modes,omitempty"`
+	StorageGiB   int      `json:"storage_gib"`
+}
+
+// StatefulSet runs Replicas pods with stable names <name>-0 ... <name>-N-1,
+// each keeping its own claims across restarts and rescheduling.
+type StatefulSet struct {
+	Name                 string                    `json:"name"`
+	Replicas             int                       `json:"replicas"`
+	Template             PodTemplate               `json:"template"`
+	PodManagementPolicy  string                    `json:"pod_management_policy,omitempty"` // OrderedReady (default) or Parallel
+	UpdateStrategy       StatefulSetUpdateStrategy `json:"update_strategy"`
+	VolumeClaimTemplates []VolumeClaimTemplate     `json:"volume_claim_templates,omitempty"`
+}
+
+// Validate fills in defaults and checks the StatefulSet is well formed.
+func (s *StatefulSet) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	switch s.PodManagementPolicy {
+	case "":
+		s.PodManagementPolicy = PodManagementOrderedReady
+	case PodManagementOrderedReady, PodManagementParallel:
+	default:
+		return fmt.Errorf("pod_management_policy must be OrderedReady or Parallel")
+	}
+	switch s.UpdateStrategy.Type {
+	case "":
+		s.UpdateStrategy.Type = UpdateRollingUpdate
+	case UpdateRollingUpdate, UpdateOnDelete:
+	default:
+		return fmt.Errorf("update_strategy type must be RollingUpdate or OnDelete")
+	}
+	if s.UpdateStrategy.Partition < 0 {
+		return fmt.Errorf("partition must not be negative")
+	}
+	for _, t := range s.VolumeClaimTemplates {
+		claim := t.claim(s.Name, 0)
+		if err := claim.Validate(); err != nil {
+			return fmt.Errorf("volume claim template %q: %v", t.Name, err)
+		}
+	}
+	return s.Template.Validate()
+}
+
+// podName returns the stable name of the pod with the given ordinal.
+func (s StatefulSet) podName(ordinal int) string {
+	return fmt.Sprintf("%s-%d", s.Name, ordinal)
+}
+
+// claim returns the claim template t makes for the pod with the given ordinal.
+func (t VolumeClaimTemplate) claim(set string, ordinal int) storage.PersistentVolumeClaim {
+	return storage.PersistentVolumeClaim{
+		Name:         fmt.Sprintf("%s-%s-%d", t.Name, set, ordinal),
+		StorageClass: t.StorageClass,
+		AccessModes:  t.AccessModes,
+		StorageGiB:   t.StorageGiB,
+		Owner:        ownerRef(events.KindStatefulSet, set),
+	}
+}
+
+// StatefulSetStatus is a StatefulSet together with the state of its pods.
+type StatefulSetStatus struct {
+	StatefulSet
+	CurrentReplicas int      `json:"current_replicas"`
+	ReadyReplicas   int      `json:"ready_replicas"`
+	UpdatedReplicas int      `json:"updated_replicas"`
+	CurrentRevision string   `json:"current_revision"`
+	UpdateRevision  string   `json:"update_revision"`
+	Pods            []string `json:"pods"` // by ordinal
+}
+
+type statefulSetState struct {
+	set StatefulSet
+	// current is the template of the last completed rollout. Pods below the
+	// partition are recreated from it.
+	current PodTemplate
+}
+
+// StatefulSetController keeps each StatefulSet's ordinal pods running, in
+// order when asked to, and rolls out template changes.
+type StatefulSetController struct {
+	nm *node.NodeManager
+
+	mu   sync.Mutex // Protects sets
+	sets map[string]*statefulSetState
+}
+
+// NewStatefulSetController creates a controller for the cluster managed by nm.
+func NewStatefulSetController(nm *node.NodeManager) *StatefulSetController {
+	return &StatefulSetController{nm: nm, sets: make(map[string]*statefulSetState)}
+}
+
+// Start runs the reconcile loop in a goroutine.
+func (c *StatefulSetController) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.sync(name)
+			}
+			time.Sleep(statefulSetSyncInterval)
+		}
+	}()
+}
+
+func (c *StatefulSetController) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.sets))
+	for name := range c.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set creates or replaces a StatefulSet and reconciles it right away. A new
+// template is rolled out according to the update strategy.
+func (c *StatefulSetController) Set(s StatefulSet) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if state, exists := c.sets[s.Name]; exists {
+		state.set = s
+	} else {
+		c.sets[s.Name] = &statefulSetState{set: s, current: s.Template}
+	}
+	c.mu.Unlock()
+	logger.Info("StatefulSet set", "statefulset", s.Name, "replicas", s.Replicas, "cpus", s.Template.CPUs, "revision", s.Template.revision())
+	c.sync(s.Name)
+	return nil
+}
+
+func (c *StatefulSetController) get(name string) (StatefulSet, PodTemplate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, exists := c.sets[name]
+	if !exists {
+		return StatefulSet{}, PodTemplate{}, false
+	}
+	return state.set, state.current, true
+}
+
+// Scale changes a StatefulSet's replica count.
+func (c *StatefulSetController) Scale(name string, replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	c.mu.Lock()
+	state, exists := c.sets[name]
+	if !exists {
+		c.mu.Unlock()
+		return ErrStatefulSetNotFound
+	}
+	state.set.Replicas = replicas
+	c.mu.Unlock()
+	c.sync(name)
+	return nil
+}
+
+// Delete removes a StatefulSet and its pods. Its claims are kept, as in
+// Kubernetes, and can be deleted separately.
+func (c *StatefulSetController) Delete(name string) error {
+	c.mu.Lock()
+	_, exists := c.sets[name]
+	delete(c.sets, name)
+	c.mu.Unlock()
+	if !exists {
+		return ErrStatefulSetNotFound
+	}
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindStatefulSet, name)) {
+		if err := c.nm.DeletePod(p.ID); err != nil && !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod of StatefulSet", "statefulset", name, logging.PodID(p.ID), logging.Err(err))
+		}
+	}
+	return nil
+}
+
+// List returns every StatefulSet with its status, by name.
+func (c *StatefulSetController) List() []StatefulSetStatus {
+	list := []StatefulSetStatus{}
+	for _, name := range c.names() {
+		if st, ok := c.Status(name); ok {
+			list = append(list, st)
+		}
+	}
+	return list
+}
+
+// Status returns a StatefulSet with the state of its pods.
+func (c *StatefulSetController) Status(name string) (StatefulSetStatus, bool) {
+	s, current, exists := c.get(name)
+	if !exists {
+		return StatefulSetStatus{}, false
+	}
+	st := StatefulSetStatus{
+		StatefulSet:     s,
+		CurrentRevision: current.revision(),
+		UpdateRevision:  s.Template.revision(),
+		Pods:            []string{},
+	}
+	pods := c.podsByOrdinal(s)
+	for _, ordinal := range sortedOrdinals(pods) {
+		p := pods[ordinal]
+		st.Pods = append(st.Pods, p.ID)
+		st.CurrentReplicas++
+		if p.Status == "Running" {
+			st.ReadyReplicas++
+		}
+		if p.Labels[RevisionLabel] == st.UpdateRevision {
+			st.UpdatedReplicas++
+		}
+	}
+	return st, true
+}
+
+// podsByOrdinal returns a StatefulSet's pods keyed by ordinal.
+func (c *StatefulSetController) podsByOrdinal(s StatefulSet) map[int]pod.Pod {
+	pods := make(map[int]pod.Pod)
+	for _, p := range c.nm.PodsOwnedBy(ownerRef(events.KindStatefulSet, s.Name)) {
+		ordinal, err := strconv.Atoi(strings.TrimPrefix(p.ID, s.Name+"-"))
+		if err != nil {
+			continue
+		}
+		pods[ordinal] = p
+	}
+	return pods
+}
+
+func sortedOrdinals(pods map[int]pod.Pod) []int {
+	ordinals := make([]int, 0, len(pods))
+	for ordinal := range pods {
+		ordinals = append(ordinals, ordinal)
+	}
+	sort.Ints(ordinals)
+	return ordinals
+}
+
+// sync replaces exited pods, creates missing ordinals from the lowest up,
+// removes surplus ordinals from the highest down and rolls out a changed
+// template from the highest ordinal down to the partition. Under
+// OrderedReady every step waits until the pods before it are Running.
+func (c *StatefulSetController) sync(name string) {
+	s, current, exists := c.get(name)
+	if !exists {
+		return
+	}
+	ordered := s.PodManagementPolicy == PodManagementOrderedReady
+	updateRevision := s.Template.revision()
+
+	pods := c.podsByOrdinal(s)
+	for ordinal, p := range pods {
+		// Pods that exited are restarted under the same name.
+		if p.Finished() {
+			c.deletePod(s.Name, p, "exited")
+			delete(pods, ordinal)
+		}
+	}
+
+	// Create missing pods, lowest ordinal first.
+	for ordinal := 0; ordinal < s.Replicas; ordinal++ {
+		if p, exists := pods[ordinal]; exists {
+			if ordered && p.Status != "Running" {
+				return
+			}
+			continue
+		}
+		template := s.Template
+		if s.UpdateStrategy.Type == UpdateRollingUpdate && ordinal < s.UpdateStrategy.Partition {
+			template = current
+		}
+		p, ok := c.createPod(s, ordinal, template)
+		if !ok {
+			return
+		}
+		pods[ordinal] = p
+		if ordered && p.Status != "Running" {
+			return
+		}
+	}
+
+	// Remove surplus pods, highest ordinal first.
+	ordinals := sortedOrdinals(pods)
+	for i := len(ordinals) - 1; i >= 0 && ordinals[i] >= s.Replicas; i-- {
+		c.deletePod(s.Name, pods[ordinals[i]], "scaled down")
+		delete(pods, ordinals[i])
+		if ordered {
+			return
+		}
+	}
+
+	// Every wanted pod now exists; replace the highest outdated one at or
+	// above the partition once all of them are Running.
+	if s.UpdateStrategy.Type == UpdateRollingUpdate {
+		for _, p := range pods {
+			if p.Status != "Running" {
+				return
+			}
+		}
+		for ordinal := s.Replicas - 1; ordinal >= s.UpdateStrategy.Partition; ordinal-- {
+			if pods[ordinal].Labels[RevisionLabel] == updateRevision {
+				continue
+			}
+			c.deletePod(s.Name, pods[ordinal], "outdated template")
+			if p, ok := c.createPod(s, ordinal, s.Template); ok {
+				pods[ordinal] = p
+			}
+			return
+		}
+	}
+
+	// Once every pod runs the new template the rollout is complete.
+	for _, p := range pods {
+		if p.Labels[RevisionLabel] != updateRevision {
+			return
+		}
+	}
+	c.mu.Lock()
+	if state, exists := c.sets[name]; exists && state.current.revision() != updateRevision && state.set.Template.revision() == updateRevision {
+		state.current = state.set.Template
+		logger.Info("Rollout complete", "statefulset", name, "revision", updateRevision)
+		c.nm.Events.Eventf(events.KindStatefulSet, name, events.TypeNormal, "RolloutComplete", "All pods run revision %s", updateRevision)
+	}
+	c.mu.Unlock()
+}
+
+// createPod makes the claims for an ordinal and creates its pod from template.
+func (c *StatefulSetController) createPod(s StatefulSet, ordinal int, template PodTemplate) (pod.Pod, bool) {
+	spec := template.spec(ownerRef(events.KindStatefulSet, s.Name))
+	spec.Name = s.podName(ordinal)
+	spec.Labels[RevisionLabel] = template.revision()
+	for _, t := range s.VolumeClaimTemplates {
+		claim, err := c.nm.EnsureClaim(t.claim(s.Name, ordinal))
+		if err != nil {
+			logger.Error("Failed to create volume claim", "statefulset", s.Name, "pod", spec.Name, logging.Err(err))
+			c.nm.Events.Eventf(events.KindStatefulSet, s.Name, events.TypeWarning, "FailedCreate", "Failed to create claim for pod %s: %v", spec.Name, err)
+			return pod.Pod{}, false
+		}
+		spec.Claims = append(spec.Claims, claim.Name)
+	}
+
+	p, err := c.nm.CreatePod(spec)
+	if errors.Is(err, node.ErrPodExists) {
+		// The previous pod of this name is still being torn down.
+		return pod.Pod{}, false
+	}
+	if errors.Is(err, node.ErrPodRejected) {
+		c.nm.Events.Eventf(events.KindStatefulSet, s.Name, events.TypeWarning, "FailedCreate", "Error creating pod %s: %v", spec.Name, err)
+		return pod.Pod{}, false
+	}
+	if err != nil {
+		logger.Debug("Pod is pending", "statefulset", s.Name, logging.PodID(p.ID), "reason", err)
+	}
+	c.nm.Events.Eventf(events.KindStatefulSet, s.Name, events.TypeNormal, "SuccessfulCreate", "Created pod %s", p.ID)
+	return p, true
+}
+
+func (c *StatefulSetController) deletePod(name string, p pod.Pod, reason string) {
+	if err := c.nm.DeletePod(p.ID); err != nil {
+		if !errors.Is(err, node.ErrPodNotFound) {
+			logger.Error("Failed to delete pod", "statefulset", name, logging.PodID(p.ID), logging.Err(err))
+		}
+		return
+	}
+	c.nm.Events.Eventf(events.KindStatefulSet, name, events.TypeNormal, "SuccessfulDelete", "Deleted pod %s: %s", p.ID, reason)
+}
@@ -0,0 +1,437 @@
+// Package dns serves the cluster's service discovery records. It answers A
+// and SRV queries for services and pods under the cluster domain from a
+// zone rebuilt whenever pods change, and refuses everything else, so it
+// works without any upstream resolver.
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+	"cluster-sim/internal/service"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultDomain is the cluster domain names are served under.
+const DefaultDomain = "cluster.local"
+
+// DefaultAddr is where the server listens unless told otherwise. Containers
+// can only be pointed at a nameserver on port 53.
+const DefaultAddr = ":53"
+
+// ttl is the time to live of every record, in seconds. It is short because
+// records follow pods as they come and go.
+const ttl = 5
+
+// dnsSyncInterval is how often the zone is rebuilt when no pod or node
+// events arrive, to pick up endpoint and service changes.
+const dnsSyncInterval = time.Second
+
+var logger = logging.For("dns")
+
+// Server is an authoritative DNS server for the cluster domain.
+type Server struct {
+	nm       *node.NodeManager
+	services *service.Controller
+	domain   string
+	addr     string
+
+	mu     sync.RWMutex // Protects zone and serial
+	zone   zone
+	serial uint32
+}
+
+// NewServer creates a DNS server for the pods of nm and the services of
+// services.
+func NewServer(nm *node.NodeManager, services *service.Controller) *Server {
+	return &Server{
+		nm:       nm,
+		services: services,
+		domain:   DefaultDomain,
+		zone:     make(zone),
+	}
+}
+
+// Start listens for UDP and TCP queries on addr, keeps the zone in sync and
+// points node containers created from now on at the server when they can
+// reach it.
+func (s *Server) Start(addr string) error {
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	s.addr = addr
+	s.sync()
+	go s.serveUDP(udp)
+	go s.serveTCP(tcp)
+	go s.syncLoop()
+	logger.Info("Cluster DNS serving", "domain", s.domain, "addr", addr)
+
+	nameserver, err := s.containerNameserver()
+	if err != nil {
+		logger.Warn("Node containers keep Docker's resolver", logging.Err(err))
+		return nil
+	}
+	node.SetClusterDNS([]string{nameserver}, s.searchDomains())
+	logger.Info("Node containers will resolve through cluster DNS", "nameserver", nameserver)
+	return nil
+}
+
+// containerNameserver returns the address node containers reach the server
+// at: the host's address on their network unless the server listens on a
+// specific one.
+func (s *Server) containerNameserver() (string, error) {
+	host, port, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return "", err
+	}
+	if port != "53" {
+		return "", fmt.Errorf("listening on port %s, but containers can only use a nameserver on port 53", port)
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return host, nil
+	}
+	return node.NetworkGateway()
+}
+
+// searchDomains lets containers resolve services by short name, as pods in
+// the default namespace do.
+func (s *Server) searchDomains() []string {
+	return []string{
+		service.DefaultNamespace + ".svc." + s.domain,
+		"svc." + s.domain,
+		s.domain,
+	}
+}
+
+// Records returns every record being served, by name.
+func (s *Server) Records() []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zone.records()
+}
+
+// syncLoop rebuilds the zone after every pod or node event, and at least
+// every dnsSyncInterval.
+func (s *Server) syncLoop() {
+	watch, _ := s.nm.Events.Watch()
+	ticker := time.NewTicker(dnsSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-watch:
+			if ev.InvolvedObject.Kind != events.KindPod && ev.InvolvedObject.Kind != events.KindNode {
+				continue
+			}
+		case <-ticker.C:
+		}
+		s.sync()
+	}
+}
+
+// sync rebuilds the zone from the Running pods, by their pod IP, and the
+// services.
+func (s *Server) sync() {
+	var pods []runningPod
+	s.nm.Mu.Lock()
+	for id, p := range s.nm.Pods {
+		if p.Status == "Running" && p.IP != "" {
+			pods = append(pods, runningPod{id: id, ip: p.IP})
+		}
+	}
+	s.nm.Mu.Unlock()
+
+	z := buildZone(s.domain, s.services.List(), pods)
+	metrics.DNSRecords.Set(float64(len(z.records())))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if z.equal(s.zone) {
+		return
+	}
+	s.zone = z
+	s.serial++
+	logger.Debug("Zone updated", "names", len(z), "serial", s.serial)
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			logger.Error("UDP read failed", logging.Err(err))
+			return
+		}
+		resp, err := s.answer(buf[:n], true)
+		if err != nil {
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			logger.Warn("UDP reply failed", "client", addr.String(), logging.Err(err))
+		}
+	}
+}
+
+func (s *Server) serveTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			logger.Error("TCP accept failed", logging.Err(err))
+			return
+		}
+		go s.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn answers length-prefixed queries until the client hangs up
+// or goes quiet.
+func (s *Server) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		req := make([]byte, length)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		resp, err := s.answer(req, false)
+		if err != nil {
+			return
+		}
+		out := make([]byte, 2, 2+len(resp))
+		binary.BigEndian.PutUint16(out, uint16(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// errMalformed is returned for queries that cannot even be answered with
+// an error, which are dropped.
+var errMalformed = errors.New("malformed DNS message")
+
+// answer builds the response to a query. UDP responses that do not fit the
+// client's buffer are truncated so it retries over TCP.
+func (s *Server) answer(req []byte, udp bool) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(req)
+	if err != nil || h.Response {
+		return nil, errMalformed
+	}
+	resp := dnsmessage.Header{ID: h.ID, Response: true, OpCode: h.OpCode, RecursionDesired: h.RecursionDesired}
+	q, err := p.Question()
+	if err != nil {
+		resp.RCode = dnsmessage.RCodeFormatError
+		return s.build(resp, nil, nil, nil, nil, 0)
+	}
+	maxSize, edns := 512, false
+	if udp {
+		if size, ok := ednsSize(&p); ok {
+			maxSize, edns = size, true
+		}
+	} else {
+		maxSize = 65535
+	}
+
+	var answers, authority, additional []Record
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case h.OpCode != 0:
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	case name != s.domain+"." && !strings.HasSuffix(name, "."+s.domain+"."):
+		// Nothing outside the cluster domain is resolved, so the simulator
+		// never depends on the network.
+		resp.RCode = dnsmessage.RCodeRefused
+	default:
+		resp.Authoritative = true
+		answers, additional, resp.RCode = s.lookup(name, q.Type)
+		if len(answers) == 0 {
+			authority = []Record{{Name: s.domain + ".", Type: "SOA"}}
+		}
+	}
+	metrics.DNSQueries.WithLabelValues(strings.TrimPrefix(q.Type.String(), "Type"), strings.TrimPrefix(resp.RCode.String(), "RCode")).Inc()
+
+	msg, err := s.build(resp, &q, answers, authority, additional, ednsPayload(edns))
+	if err != nil || len(msg) <= maxSize {
+		return msg, err
+	}
+	resp.Truncated = true
+	return s.build(resp, &q, nil, nil, nil, ednsPayload(edns))
+}
+
+// lookup returns the records answering a query, with the A records of SRV
+// targets as additional records.
+func (s *Server) lookup(name string, qtype dnsmessage.Type) ([]Record, []Record, dnsmessage.RCode) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records, exists := s.zone[name]
+	if !exists && name != s.domain+"." {
+		return nil, nil, dnsmessage.RCodeNameError
+	}
+	var answers, additional []Record
+	for _, r := range records {
+		if qtype == dnsmessage.TypeALL || recordType(r.Type) == qtype {
+			answers = append(answers, r)
+		}
+	}
+	for _, r := range answers {
+		if r.Type == "SRV" {
+			for _, target := range s.zone[r.Target] {
+				if target.Type == "A" {
+					additional = append(additional, target)
+				}
+			}
+		}
+	}
+	return answers, additional, dnsmessage.RCodeSuccess
+}
+
+func recordType(t string) dnsmessage.Type {
+	switch t {
+	case "A":
+		return dnsmessage.TypeA
+	case "SRV":
+		return dnsmessage.TypeSRV
+	case "SOA":
+		return dnsmessage.TypeSOA
+	}
+	return 0
+}
+
+// ednsSize returns the UDP payload size a query advertises in an OPT record.
+func ednsSize(p *dnsmessage.Parser) (int, bool) {
+	if err := p.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return 0, false
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return 0, false
+	}
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return 0, false
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			return max(512, min(int(h.Class), 4096)), true
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return 0, false
+		}
+	}
+}
+
+// ednsPayload is the payload size advertised back to EDNS clients, or zero
+// to leave the OPT record out.
+func ednsPayload(edns bool) int {
+	if edns {
+		return 4096
+	}
+	return 0
+}
+
+func (s *Server) build(h dnsmessage.Header, q *dnsmessage.Question, answers, authority, additional []Record, payload int) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), h)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if q != nil {
+		if err := b.Question(*q); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	for _, r := range answers {
+		if err := s.resource(&b, r); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAuthorities(); err != nil {
+		return nil, err
+	}
+	for _, r := range authority {
+		if err := s.resource(&b, r); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	for _, r := range additional {
+		if err := s.resource(&b, r); err != nil {
+			return nil, err
+		}
+	}
+	if payload > 0 {
+		var opt dnsmessage.ResourceHeader
+		if err := opt.SetEDNS0(payload, dnsmessage.RCodeSuccess, false); err != nil {
+			return nil, err
+		}
+		if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// resource adds one record to a message being built.
+func (s *Server) resource(b *dnsmessage.Builder, r Record) error {
+	name, err := dnsmessage.NewName(r.Name)
+	if err != nil {
+		return err
+	}
+	h := dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: ttl}
+	switch r.Type {
+	case "A":
+		ip := net.ParseIP(r.IP).To4()
+		if ip == nil {
+			return fmt.Errorf("record %s has no IPv4 address", r.Name)
+		}
+		var a dnsmessage.AResource
+		copy(a.A[:], ip)
+		return b.AResource(h, a)
+	case "SRV":
+		target, err := dnsmessage.NewName(r.Target)
+		if err != nil {
+			return err
+		}
+		return b.SRVResource(h, dnsmessage.SRVResource{Priority: 0, Weight: 100, Port: uint16(r.Port), Target: target})
+	case "SOA":
+		ns, err := dnsmessage.NewName("ns.dns." + s.domain + ".")
+		if err != nil {
+			return err
+		}
+		mbox, err := dnsmessage.NewName("hostmaster." + s.domain + ".")
+		if err != nil {
+			return err
+		}
+		s.mu.RLock()
+		serial := s.serial
+		s.mu.RUnlock()
+		return b.SOAResource(h, dnsmessage.SOAResource{NS: ns, MBox: mbox, Serial: serial, Refresh: 7200, Retry: 1800, Expire: 86400, MinTTL: ttl})
+	}
+	return fmt.Errorf("unknown record type %s", r.Type)
+}
@@ -0,0 +1,21 @@
+// All the gin handlers are here for dns package
+package dns
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to show the records the cluster DNS serves
+func (s *Server) RecordsHandler(c *gin.Context) {
+	s.mu.RLock()
+	serial := s.serial
+	s.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{
+		"domain":  s.domain,
+		"address": s.addr,
+		"serial":  serial,
+		"records": s.Records(),
+	})
+}
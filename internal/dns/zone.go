@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"sort"
+	"strings"
+
+	"cluster-sim/internal/service"
+)
+
+// Record is a resource record served by the cluster DNS.
+type Record struct {
+	Name   string `json:"name"` // fully qualified, with a trailing dot
+	Type   string `json:"type"` // A or SRV
+	IP     string `json:"ip,omitempty"`
+	Port   int    `json:"port,omitempty"`   // SRV only
+	Target string `json:"target,omitempty"` // SRV only
+}
+
+// zone maps lowercase fully qualified names to their records.
+type zone map[string][]Record
+
+func (z zone) add(r Record) {
+	r.Name = strings.ToLower(r.Name)
+	r.Target = strings.ToLower(r.Target)
+	z[r.Name] = append(z[r.Name], r)
+}
+
+// records returns every record of the zone, by name and type.
+func (z zone) records() []Record {
+	list := []Record{}
+	for _, records := range z {
+		list = append(list, records...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].IP+list[i].Target < list[j].IP+list[j].Target
+	})
+	return list
+}
+
+// equal reports whether two zones hold the same records.
+func (z zone) equal(other zone) bool {
+	a, b := z.records(), other.records()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runningPod is what the zone needs to know about a Running pod.
+type runningPod struct {
+	id string
+	ip string
+}
+
+// buildZone returns the records for the services and Running pods:
+//
+//	<service>.<namespace>.svc.<domain>                    A    cluster IP
+//	<service>.<namespace>.svc.<domain>                    SRV  every port
+//	_<port>._<protocol>.<service>.<namespace>.svc.<domain> SRV  a named port
+//	<pod>.<service>.<namespace>.svc.<domain>              A    each ready endpoint
+//	<pod>.<namespace>.pod.<domain>                        A    each Running pod
+func buildZone(domain string, services []service.ServiceStatus, pods []runningPod) zone {
+	z := make(zone)
+	podIPs := make(map[string]string, len(pods))
+	for _, p := range pods {
+		podIPs[p.id] = p.ip
+		z.add(Record{Name: p.id + "." + service.DefaultNamespace + ".pod." + domain + ".", Type: "A", IP: p.ip})
+	}
+	for _, svc := range services {
+		name := svc.Name + "." + svc.Namespace + ".svc." + domain + "."
+		z.add(Record{Name: name, Type: "A", IP: svc.ClusterIP})
+		for _, port := range svc.Ports {
+			z.add(Record{Name: name, Type: "SRV", Port: port.Port, Target: name})
+			if port.Name != "" {
+				srv := "_" + port.Name + "._" + strings.ToLower(port.Protocol) + "." + name
+				z.add(Record{Name: srv, Type: "SRV", Port: port.Port, Target: name})
+			}
+		}
+		for _, ep := range svc.Endpoints {
+			if ip, running := podIPs[ep.PodID]; running && ep.Ready {
+				z.add(Record{Name: ep.PodID + "." + name, Type: "A", IP: ip})
+			}
+		}
+	}
+	return z
+}
@@ -0,0 +1,199 @@
+// Package events records significant cluster occurrences as Event objects.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypeNormal  = "Normal"
+	TypeWarning = "Warning"
+)
+
+// Kinds of objects an event can refer to
+const (
+	KindNode                  = "Node"
+	KindPod                   = "Pod"
+	KindNodeGroup             = "NodeGroup"
+	KindPodGroup              = "PodGroup"
+	KindHPA                   = "HorizontalPodAutoscaler"
+	KindJob                   = "Job"
+	KindCronJob               = "CronJob"
+	KindDaemonSet             = "DaemonSet"
+	KindStatefulSet           = "StatefulSet"
+	KindPersistentVolumeClaim = "PersistentVolumeClaim"
+	KindPersistentVolume      = "PersistentVolume"
+	KindService               = "Service"
+)
+
+// DefaultTTL is how long an event is kept after it was last seen.
+const DefaultTTL = time.Hour
+
+// ObjectReference identifies the object an event is about.
+type ObjectReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Event describes something that happened to a node or pod.
+type Event struct {
+	ID             string          `json:"id"`
+	InvolvedObject ObjectReference `json:"involved_object"`
+	Type           string          `json:"type"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Count          int             `json:"count"`
+	FirstTimestamp time.Time       `json:"first_timestamp"`
+	LastTimestamp  time.Time       `json:"last_timestamp"`
+}
+
+// Filter selects events for List and Watch. Empty fields match anything.
+type Filter struct {
+	Kind           string
+	InvolvedObject string
+	Reason         string
+}
+
+func (f Filter) matches(e Event) bool {
+	if f.Kind != "" && f.Kind != e.InvolvedObject.Kind {
+		return false
+	}
+	if f.InvolvedObject != "" && f.InvolvedObject != e.InvolvedObject.Name {
+		return false
+	}
+	if f.Reason != "" && f.Reason != e.Reason {
+		return false
+	}
+	return true
+}
+
+// Recorder stores deduplicated events and fans new ones out to watchers.
+type Recorder struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	events   map[string]*Event // keyed by dedupKey
+	watchers map[chan Event]struct{}
+}
+
+// NewRecorder creates a Recorder that forgets events ttl after they were last seen.
+func NewRecorder(ttl time.Duration) *Recorder {
+	return &Recorder{
+		ttl:      ttl,
+		events:   make(map[string]*Event),
+		watchers: make(map[chan Event]struct{}),
+	}
+}
+
+func dedupKey(obj ObjectReference, eventType, reason, message string) string {
+	return obj.Kind + "/" + obj.Name + "/" + eventType + "/" + reason + "/" + message
+}
+
+// Eventf records an event about the object kind/name. Repeated identical
+// events bump the count and last timestamp of the existing one.
+func (r *Recorder) Eventf(kind, name, eventType, reason, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	obj := ObjectReference{Kind: kind, Name: name}
+	message := fmt.Sprintf(format, args...)
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked(now)
+
+	key := dedupKey(obj, eventType, reason, message)
+	ev, exists := r.events[key]
+	if exists {
+		ev.Count++
+		ev.LastTimestamp = now
+	} else {
+		ev = &Event{
+			ID:             uuid.New().String(),
+			InvolvedObject: obj,
+			Type:           eventType,
+			Reason:         reason,
+			Message:        message,
+			Count:          1,
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+		}
+		r.events[key] = ev
+	}
+
+	for ch := range r.watchers {
+		select {
+		case ch <- *ev:
+		default:
+			// Slow watcher; drop rather than block the caller.
+		}
+	}
+}
+
+// pruneLocked drops events older than the TTL. r.mu must be held.
+func (r *Recorder) pruneLocked(now time.Time) {
+	for key, ev := range r.events {
+		if now.Sub(ev.LastTimestamp) > r.ttl {
+			delete(r.events, key)
+		}
+	}
+}
+
+// List returns the events matching f, oldest first.
+func (r *Recorder) List(f Filter) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listLocked(f)
+}
+
+// listLocked is List. r.mu must be held.
+func (r *Recorder) listLocked(f Filter) []Event {
+	r.pruneLocked(time.Now())
+
+	result := make([]Event, 0, len(r.events))
+	for _, ev := range r.events {
+		if f.matches(*ev) {
+			result = append(result, *ev)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastTimestamp.Before(result[j].LastTimestamp)
+	})
+	return result
+}
+
+// Watch returns a channel receiving every event recorded or updated from now
+// on, and a function that stops the watch.
+func (r *Recorder) Watch() (<-chan Event, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watchLocked()
+}
+
+// ListAndWatch returns the events matching f, like List, together with a
+// watch that starts right after them, like Watch. Nothing recorded between
+// the two is either lost or sent on the channel as well as listed.
+func (r *Recorder) ListAndWatch(f Filter) ([]Event, <-chan Event, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, stop := r.watchLocked()
+	return r.listLocked(f), ch, stop
+}
+
+// watchLocked is Watch. r.mu must be held.
+func (r *Recorder) watchLocked() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	r.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.watchers, ch)
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,50 @@
+// All the gin handlers are here for events package
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to list events, optionally streaming new ones with ?watch=true
+func (r *Recorder) ListEventsHandler(c *gin.Context) {
+	filter := Filter{
+		Kind:           c.Query("kind"),
+		InvolvedObject: c.Query("involvedObject"),
+		Reason:         c.Query("reason"),
+	}
+
+	if c.Query("watch") != "true" {
+		c.JSON(http.StatusOK, r.List(filter))
+		return
+	}
+
+	// List and subscribe at once so nothing recorded in between is lost or sent twice.
+	list, ch, stop := r.ListAndWatch(filter)
+	defer stop()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, ev := range list {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev := <-ch:
+			if !filter.matches(ev) {
+				return true
+			}
+			return enc.Encode(ev) == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -2,22 +2,78 @@ package health
 
 import (
 	"context"
-	"log"
+	"sync"
 	"time"
 
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
 	"cluster-sim/internal/node"
+	"cluster-sim/internal/tracing"
 	// "github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("cluster-sim/internal/health")
+
+var logger = logging.For("health")
+
+// Options tunes the health checks.
+type Options struct {
+	// Interval is how often each node's container is inspected.
+	Interval time.Duration
+	// UnhealthyThreshold is how many failed inspections in a row it takes
+	// before a node is restarted.
+	UnhealthyThreshold int
+	// HeartbeatInterval is how often each node posts a heartbeat to the
+	// control plane.
+	HeartbeatInterval time.Duration
+	// HeartbeatGracePeriod is how long a node may go without a heartbeat
+	// reaching the control plane before it is marked Unreachable.
+	HeartbeatGracePeriod time.Duration
+	// PodEvictionTimeout is how long an Unreachable node keeps its pods
+	// before they are rescheduled elsewhere.
+	PodEvictionTimeout time.Duration
+}
+
+// DefaultOptions returns the settings used when none are configured.
+func DefaultOptions() Options {
+	return Options{
+		Interval:             10 * time.Second,
+		UnhealthyThreshold:   1,
+		HeartbeatInterval:    2 * time.Second,
+		HeartbeatGracePeriod: 10 * time.Second,
+		PodEvictionTimeout:   20 * time.Second,
+	}
+}
+
 // HealthManager periodically checks the health of nodes.
 type HealthManager struct {
 	NodeManager *node.NodeManager
+
+	mu       sync.Mutex // Protects the fields below
+	opts     Options
+	failures map[string]int // node ID -> failed inspections in a row
 }
 
 // NewHealthManager creates a new HealthManager.
-func NewHealthManager(nm *node.NodeManager) *HealthManager {
-	return &HealthManager{NodeManager: nm}
+func NewHealthManager(nm *node.NodeManager, opts Options) *HealthManager {
+	return &HealthManager{NodeManager: nm, opts: opts, failures: make(map[string]int)}
+}
+
+// Options returns the current settings.
+func (hm *HealthManager) Options() Options {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	return hm.opts
+}
+
+// SetOptions replaces the settings; they take effect on the next check.
+func (hm *HealthManager) SetOptions(opts Options) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.opts = opts
 }
 
 // StartMonitoring begins a goroutine that periodically inspects each node's container.
@@ -25,42 +81,99 @@ func (hm *HealthManager) StartMonitoring() {
 	go func() {
 		for {
 			hm.checkNodesHealth()
-			time.Sleep(10 * time.Second)
+			time.Sleep(hm.Options().Interval)
 		}
 	}()
 }
 
+// recordFailure counts a failed inspection of a node and reports whether
+// it has now failed often enough in a row to be restarted.
+func (hm *HealthManager) recordFailure(id string) bool {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.failures[id]++
+	if hm.failures[id] < hm.opts.UnhealthyThreshold {
+		return false
+	}
+	delete(hm.failures, id)
+	return true
+}
+
+func (hm *HealthManager) resetFailures(id string) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	delete(hm.failures, id)
+}
+
 // checkNodesHealth inspects the container for each node and updates its status.
 func (hm *HealthManager) checkNodesHealth() {
-	// Lock NodeManager to safely update the nodes map.
+	start := time.Now()
+	defer func() { metrics.HealthCheckDuration.Observe(time.Since(start).Seconds()) }()
+
+	ctx, span := tracer.Start(context.Background(), "CheckNodesHealth")
+	defer span.End()
+
+	// Restarts wait for the node to come back and may reschedule its pods,
+	// which takes the lock, so they happen once the lock is released.
+	for _, id := range hm.updateNodeStatuses(ctx) {
+		if err := hm.NodeManager.RestartNode(ctx, id); err != nil {
+			logger.ErrorContext(ctx, "Auto-restart failed", logging.NodeID(id), logging.Err(err))
+		}
+	}
+}
 
+// updateNodeStatuses inspects the container for each node and updates its
+// status. It returns the nodes that have failed often enough to be restarted.
+func (hm *HealthManager) updateNodeStatuses(ctx context.Context) []string {
+	// Lock NodeManager to safely update the nodes map.
 	hm.NodeManager.Mu.Lock()
 	defer hm.NodeManager.Mu.Unlock()
 
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Println("Error creating Docker client:", err)
-		return
-	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("nodes", len(hm.NodeManager.Nodes)))
+
+	var restart []string
 
-	for id, node := range hm.NodeManager.Nodes {
-		inspect, err := cli.ContainerInspect(context.Background(), id)
+	for id, n := range hm.NodeManager.Nodes {
+		previous := n.Status
+		running, err := node.CheckNodeHealth(ctx, id)
 		if err != nil {
-			log.Printf("Error inspecting container %s: %v", id, err)
-			node.Status = "Unhealthy"
+			logger.WarnContext(ctx, "Error inspecting node container", logging.NodeID(id), logging.Err(err))
+			n.Status = "Unhealthy"
 
-			if err := hm.NodeManager.RestartNode(id); err != nil {
-				log.Printf("Auto-restart failed for node %s: %v", id, err)
+			if hm.recordFailure(id) {
+				restart = append(restart, id)
 			}
 
 		} else {
-			if inspect.State.Running {
-				node.Status = "Running"
+			hm.resetFailures(id)
+			if running {
+				// A live container says nothing about whether its
+				// heartbeats get through; that is the heartbeat loop's call.
+				if n.Status != "Unreachable" {
+					n.Status = "Running"
+				}
 			} else {
-				node.Status = "Stopped"
+				n.Status = "Stopped"
 			}
 		}
-		hm.NodeManager.Nodes[id] = node
-		log.Printf("Health Monitor: Node %s running", id)
+		if n.Status == "Running" && previous != "Running" {
+			// Give a node that just came back a full grace period.
+			n.LastHeartbeat = time.Now()
+		}
+		hm.NodeManager.Nodes[id] = n
+		// Only changes are worth a record above debug; a steady cluster
+		// would otherwise log every node on every pass.
+		if n.Status != previous {
+			if n.Status == "Running" {
+				logger.InfoContext(ctx, "Node is ready", logging.NodeID(id), "previous_status", previous)
+				hm.NodeManager.Events.Eventf(events.KindNode, id, events.TypeNormal, "NodeReady", "Node status is now Running")
+			} else {
+				logger.WarnContext(ctx, "Node is not ready", logging.NodeID(id), "status", n.Status, "previous_status", previous)
+				hm.NodeManager.Events.Eventf(events.KindNode, id, events.TypeWarning, "NodeNotReady", "Node status is now %s", n.Status)
+			}
+		} else {
+			logger.DebugContext(ctx, "Node checked", logging.NodeID(id), "status", n.Status)
+		}
 	}
+	return restart
 }
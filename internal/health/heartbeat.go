@@ -0,0 +1,70 @@
+package health
+
+import (
+	"time"
+
+	"cluster-sim/internal/network"
+)
+
+// StartHeartbeats begins a goroutine that plays the part of each node's
+// kubelet posting heartbeats, sent over the network model so partitions,
+// latency and loss decide which arrive, and of the node lifecycle
+// controller acting on the ones that stop arriving.
+func (hm *HealthManager) StartHeartbeats() {
+	go func() {
+		for {
+			hm.sendHeartbeats()
+			hm.checkHeartbeats()
+			time.Sleep(hm.Options().HeartbeatInterval)
+		}
+	}()
+}
+
+// sendHeartbeats sends one heartbeat from every node whose container runs.
+func (hm *HealthManager) sendHeartbeats() {
+	nm := hm.NodeManager
+	nm.Mu.Lock()
+	var up []string
+	for id, n := range nm.Nodes {
+		if n.Status == "Running" || n.Status == "Unreachable" {
+			up = append(up, id)
+		}
+	}
+	nm.Mu.Unlock()
+
+	for _, id := range up {
+		delay, delivered := nm.Network.Deliver(id, network.ControlPlane)
+		if !delivered {
+			continue
+		}
+		id, sent := id, time.Now()
+		time.AfterFunc(delay, func() { nm.RecordHeartbeat(id, sent) })
+	}
+}
+
+// checkHeartbeats marks nodes whose heartbeats stopped arriving
+// Unreachable, and evicts the pods of those that stayed so too long.
+func (hm *HealthManager) checkHeartbeats() {
+	nm := hm.NodeManager
+	opts := hm.Options()
+	now := time.Now()
+	nm.Mu.Lock()
+	var silent, expired []string
+	for id, n := range nm.Nodes {
+		since := now.Sub(n.LastHeartbeat)
+		switch {
+		case n.Status == "Running" && since > opts.HeartbeatGracePeriod:
+			silent = append(silent, id)
+		case n.Status == "Unreachable" && since > opts.HeartbeatGracePeriod+opts.PodEvictionTimeout:
+			expired = append(expired, id)
+		}
+	}
+	nm.Mu.Unlock()
+
+	for _, id := range silent {
+		nm.MarkUnreachable(id)
+	}
+	for _, id := range expired {
+		nm.EvictUnreachableNode(id)
+	}
+}
@@ -0,0 +1,243 @@
+// Package ipam hands out pod addresses. The cluster CIDR is split into one
+// pod CIDR per node, and each pod gets an address from its node's CIDR for
+// as long as it is bound there.
+package ipam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+const (
+	// DefaultClusterCIDR is the range pod CIDRs are carved from.
+	DefaultClusterCIDR = "10.244.0.0/16"
+	// DefaultNodeMaskSize gives each node a /24, 253 pod addresses.
+	DefaultNodeMaskSize = 24
+	// maxNodeMaskSize leaves a node at least one pod address.
+	maxNodeMaskSize = 30
+)
+
+// ErrCIDRsExhausted is returned when every pod CIDR is assigned to a node.
+var ErrCIDRsExhausted = errors.New("no pod CIDR left in the cluster CIDR")
+
+// ErrAddressesExhausted is returned when a node's pod CIDR has no free address.
+var ErrAddressesExhausted = errors.New("no free pod address on the node")
+
+// ErrNoCIDR is returned when a pod is given an address on a node without a pod CIDR.
+var ErrNoCIDR = errors.New("node has no pod CIDR")
+
+// nodeBlock is the pod CIDR of one node and the addresses taken from it.
+type nodeBlock struct {
+	index int    // position of the CIDR in the cluster CIDR
+	base  uint32 // network address
+	size  uint32 // addresses in the CIDR, including the reserved ones
+	used  map[uint32]string
+	next  uint32 // where the search for a free address starts
+}
+
+// podAddress is where a pod's address was taken from.
+type podAddress struct {
+	nodeID string
+	ip     uint32
+}
+
+// Allocator assigns pod CIDRs to nodes and pod addresses to pods.
+type Allocator struct {
+	mu       sync.Mutex // Protects nodes, pods and taken
+	cluster  *net.IPNet
+	maskSize int
+	nodes    map[string]*nodeBlock
+	pods     map[string]podAddress
+	taken    map[int]bool // CIDR indexes assigned to a node
+}
+
+// New returns an allocator that splits clusterCIDR, an IPv4 CIDR, into pod
+// CIDRs with the given prefix length.
+func New(clusterCIDR string, nodeMaskSize int) (*Allocator, error) {
+	_, cluster, err := net.ParseCIDR(clusterCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster CIDR: %v", err)
+	}
+	if cluster.IP.To4() == nil {
+		return nil, fmt.Errorf("cluster CIDR %s is not IPv4", clusterCIDR)
+	}
+	clusterSize, _ := cluster.Mask.Size()
+	if nodeMaskSize < clusterSize || nodeMaskSize > maxNodeMaskSize {
+		return nil, fmt.Errorf("node mask size must be between %d and %d", clusterSize, maxNodeMaskSize)
+	}
+	return &Allocator{
+		cluster:  cluster,
+		maskSize: nodeMaskSize,
+		nodes:    make(map[string]*nodeBlock),
+		pods:     make(map[string]podAddress),
+		taken:    make(map[int]bool),
+	}, nil
+}
+
+// ClusterCIDR returns the range pod CIDRs are carved from.
+func (a *Allocator) ClusterCIDR() string {
+	return a.cluster.String()
+}
+
+// Capacity returns how many pods a node's CIDR has addresses for: all but
+// the network address, the gateway (.1) and the broadcast address.
+func (a *Allocator) Capacity() int {
+	return 1<<(32-a.maskSize) - 3
+}
+
+func toUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func toIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func (b *nodeBlock) cidr(maskSize int) string {
+	return fmt.Sprintf("%s/%d", toIP(b.base), maskSize)
+}
+
+// AssignNode gives a node the first free pod CIDR and returns it. A node
+// that already has one keeps it.
+func (a *Allocator) AssignNode(nodeID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b, exists := a.nodes[nodeID]; exists {
+		return b.cidr(a.maskSize), nil
+	}
+	clusterSize, _ := a.cluster.Mask.Size()
+	blocks := 1 << (a.maskSize - clusterSize)
+	size := uint32(1) << (32 - a.maskSize)
+	for i := 0; i < blocks; i++ {
+		if a.taken[i] {
+			continue
+		}
+		a.taken[i] = true
+		b := &nodeBlock{
+			index: i,
+			base:  toUint32(a.cluster.IP) + uint32(i)*size,
+			size:  size,
+			used:  make(map[uint32]string),
+			next:  2,
+		}
+		a.nodes[nodeID] = b
+		return b.cidr(a.maskSize), nil
+	}
+	return "", ErrCIDRsExhausted
+}
+
+// ReleaseNode frees a node's pod CIDR along with every address taken from
+// it, and returns the pods that held one.
+func (a *Allocator) ReleaseNode(nodeID string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b, exists := a.nodes[nodeID]
+	if !exists {
+		return nil
+	}
+	var pods []string
+	for _, podID := range b.used {
+		delete(a.pods, podID)
+		pods = append(pods, podID)
+	}
+	sort.Strings(pods)
+	delete(a.taken, b.index)
+	delete(a.nodes, nodeID)
+	return pods
+}
+
+// NodeCIDR returns a node's pod CIDR, or "" if it has none.
+func (a *Allocator) NodeCIDR(nodeID string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b, exists := a.nodes[nodeID]; exists {
+		return b.cidr(a.maskSize)
+	}
+	return ""
+}
+
+// Allocate gives a pod an address from its node's CIDR and returns it. A pod
+// keeps its address while it stays on the node; one moved from another node
+// gives its old address back. Addresses are handed out in turn rather than
+// lowest first, so a freed one is not reused straight away.
+func (a *Allocator) Allocate(nodeID, podID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if addr, exists := a.pods[podID]; exists {
+		if addr.nodeID == nodeID {
+			return toIP(addr.ip).String(), nil
+		}
+		a.releaseLocked(podID)
+	}
+	b, exists := a.nodes[nodeID]
+	if !exists {
+		return "", ErrNoCIDR
+	}
+	// Offsets 0, 1 and size-1 are the network, gateway and broadcast addresses.
+	hosts := b.size - 3
+	for i := uint32(0); i < hosts; i++ {
+		offset := 2 + (b.next-2+i)%hosts
+		ip := b.base + offset
+		if _, used := b.used[ip]; used {
+			continue
+		}
+		b.used[ip] = podID
+		b.next = offset + 1
+		a.pods[podID] = podAddress{nodeID: nodeID, ip: ip}
+		return toIP(ip).String(), nil
+	}
+	return "", ErrAddressesExhausted
+}
+
+// Release frees a pod's address and reports whether it had one.
+func (a *Allocator) Release(podID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.releaseLocked(podID)
+}
+
+func (a *Allocator) releaseLocked(podID string) bool {
+	addr, exists := a.pods[podID]
+	if !exists {
+		return false
+	}
+	delete(a.pods, podID)
+	if b, exists := a.nodes[addr.nodeID]; exists {
+		delete(b.used, addr.ip)
+	}
+	return true
+}
+
+// NodeUsage is how much of a node's pod CIDR is in use.
+type NodeUsage struct {
+	NodeID    string `json:"node_id"`
+	CIDR      string `json:"cidr"`
+	Capacity  int    `json:"capacity"`
+	Allocated int    `json:"allocated"`
+}
+
+// Usage returns the pod CIDR of every node and how many of its addresses
+// are taken, by CIDR.
+func (a *Allocator) Usage() []NodeUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	usage := make([]NodeUsage, 0, len(a.nodes))
+	for id, b := range a.nodes {
+		usage = append(usage, NodeUsage{
+			NodeID:    id,
+			CIDR:      b.cidr(a.maskSize),
+			Capacity:  int(b.size - 3),
+			Allocated: len(b.used),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return a.nodes[usage[i].NodeID].index < a.nodes[usage[j].NodeID].index
+	})
+	return usage
+}
@@ -0,0 +1,226 @@
+package ipam
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name         string
+		cidr         string
+		maskSize     int
+		wantErr      bool
+		wantCapacity int
+	}{
+		{"defaults", DefaultClusterCIDR, DefaultNodeMaskSize, false, 253},
+		{"smallest node CIDR", "10.0.0.0/24", 30, false, 1},
+		{"one node CIDR", "10.0.0.0/24", 24, false, 253},
+		{"host bits are dropped", "10.0.3.7/16", 24, false, 253},
+		{"invalid CIDR", "10.0.0.0/33", 24, true, 0},
+		{"not a CIDR", "10.0.0.0", 24, true, 0},
+		{"IPv6", "fd00::/64", 120, true, 0},
+		{"mask shorter than cluster", "10.0.0.0/16", 15, true, 0},
+		{"mask too long", "10.0.0.0/16", 31, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cidr, tt.maskSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%q, %d) error = %v, want error %v", tt.cidr, tt.maskSize, err, tt.wantErr)
+			}
+			if err == nil && a.Capacity() != tt.wantCapacity {
+				t.Errorf("Capacity() = %d, want %d", a.Capacity(), tt.wantCapacity)
+			}
+		})
+	}
+}
+
+// ipamStep is one call on an allocator and what it should return.
+type ipamStep struct {
+	op      string // assign, release-node, allocate or release
+	node    string
+	pod     string
+	want    string // CIDR or address; for release, "true" or "false"
+	wantErr error
+}
+
+func TestAllocator(t *testing.T) {
+	tests := []struct {
+		name     string
+		cidr     string
+		maskSize int
+		steps    []ipamStep
+	}{
+		{
+			name: "node CIDRs in order and kept",
+			cidr: "10.244.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.244.0.0/24"},
+				{op: "assign", node: "b", want: "10.244.1.0/24"},
+				{op: "assign", node: "a", want: "10.244.0.0/24"},
+			},
+		},
+		{
+			name: "freed node CIDR is reused",
+			cidr: "10.244.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.244.0.0/24"},
+				{op: "assign", node: "b", want: "10.244.1.0/24"},
+				{op: "release-node", node: "a"},
+				{op: "assign", node: "c", want: "10.244.0.0/24"},
+			},
+		},
+		{
+			name: "node CIDRs run out",
+			cidr: "10.0.0.0/23", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/24"},
+				{op: "assign", node: "b", want: "10.0.1.0/24"},
+				{op: "assign", node: "c", wantErr: ErrCIDRsExhausted},
+			},
+		},
+		{
+			name: "addresses skip network and gateway",
+			cidr: "10.0.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/24"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+				{op: "allocate", node: "a", pod: "p2", want: "10.0.0.3"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+			},
+		},
+		{
+			name: "freed address is not reused straight away",
+			cidr: "10.0.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/24"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+				{op: "release", pod: "p1", want: "true"},
+				{op: "release", pod: "p1", want: "false"},
+				{op: "allocate", node: "a", pod: "p2", want: "10.0.0.3"},
+			},
+		},
+		{
+			name: "addresses run out and wrap around",
+			cidr: "10.0.0.0/24", maskSize: 29,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/29"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+				{op: "allocate", node: "a", pod: "p2", want: "10.0.0.3"},
+				{op: "allocate", node: "a", pod: "p3", want: "10.0.0.4"},
+				{op: "allocate", node: "a", pod: "p4", want: "10.0.0.5"},
+				{op: "allocate", node: "a", pod: "p5", want: "10.0.0.6"},
+				{op: "allocate", node: "a", pod: "p6", wantErr: ErrAddressesExhausted},
+				{op: "release", pod: "p2", want: "true"},
+				{op: "allocate", node: "a", pod: "p6", want: "10.0.0.3"},
+			},
+		},
+		{
+			name: "pod moved to another node",
+			cidr: "10.0.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/24"},
+				{op: "assign", node: "b", want: "10.0.1.0/24"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+				{op: "allocate", node: "b", pod: "p1", want: "10.0.1.2"},
+				{op: "allocate", node: "a", pod: "p2", want: "10.0.0.3"},
+				{op: "allocate", node: "a", pod: "p3", want: "10.0.0.4"},
+			},
+		},
+		{
+			name: "node without CIDR",
+			cidr: "10.0.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "allocate", node: "a", pod: "p1", wantErr: ErrNoCIDR},
+			},
+		},
+		{
+			name: "releasing a node frees its pods",
+			cidr: "10.0.0.0/16", maskSize: 24,
+			steps: []ipamStep{
+				{op: "assign", node: "a", want: "10.0.0.0/24"},
+				{op: "allocate", node: "a", pod: "p1", want: "10.0.0.2"},
+				{op: "release-node", node: "a"},
+				{op: "release", pod: "p1", want: "false"},
+				{op: "allocate", node: "a", pod: "p1", wantErr: ErrNoCIDR},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cidr, tt.maskSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.steps {
+				var got string
+				var err error
+				switch s.op {
+				case "assign":
+					got, err = a.AssignNode(s.node)
+				case "release-node":
+					a.ReleaseNode(s.node)
+					continue
+				case "allocate":
+					got, err = a.Allocate(s.node, s.pod)
+				case "release":
+					if a.Release(s.pod) {
+						got = "true"
+					} else {
+						got = "false"
+					}
+				default:
+					t.Fatalf("step %d: unknown op %q", i, s.op)
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d %s(%s, %s): error = %v, want %v", i, s.op, s.node, s.pod, err, s.wantErr)
+				}
+				if got != s.want {
+					t.Fatalf("step %d %s(%s, %s) = %q, want %q", i, s.op, s.node, s.pod, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseNodeAndUsage(t *testing.T) {
+	a, err := New("10.0.0.0/16", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []string{"a", "b"} {
+		if _, err := a.AssignNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pod := range []string{"p2", "p1"} {
+		if _, err := a.Allocate("a", pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.Allocate("b", "p3"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []NodeUsage{
+		{NodeID: "a", CIDR: "10.0.0.0/24", Capacity: 253, Allocated: 2},
+		{NodeID: "b", CIDR: "10.0.1.0/24", Capacity: 253, Allocated: 1},
+	}
+	if got := a.Usage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+	if got, want := a.ReleaseNode("a"), []string{"p1", "p2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReleaseNode(a) = %v, want %v", got, want)
+	}
+	if got := a.ReleaseNode("a"); got != nil {
+		t.Errorf("ReleaseNode(a) again = %v, want nil", got)
+	}
+	if got := a.NodeCIDR("a"); got != "" {
+		t.Errorf("NodeCIDR(a) = %q after release, want none", got)
+	}
+	if got := a.Usage(); len(got) != 1 || got[0].NodeID != "b" {
+		t.Errorf("Usage() = %+v, want only b", got)
+	}
+}
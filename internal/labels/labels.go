@@ -0,0 +1,224 @@
+// Package labels implements Kubernetes-style label selectors.
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Operators supported in a selector requirement
+const (
+	OpEquals       = "="
+	OpNotEquals    = "!="
+	OpIn           = "in"
+	OpNotIn        = "notin"
+	OpExists       = "exists"
+	OpDoesNotExist = "!"
+)
+
+// Requirement is a single clause of a selector, e.g. "tier in (web,api)".
+type Requirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// Matches reports whether the label set satisfies the requirement.
+func (r Requirement) Matches(set map[string]string) bool {
+	value, exists := set[r.Key]
+	switch r.Operator {
+	case OpEquals:
+		return exists && value == r.Values[0]
+	case OpNotEquals:
+		return !exists || value != r.Values[0]
+	case OpIn:
+		return exists && contains(r.Values, value)
+	case OpNotIn:
+		return !exists || !contains(r.Values, value)
+	case OpExists:
+		return exists
+	case OpDoesNotExist:
+		return !exists
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector is a conjunction of requirements. The empty selector matches everything.
+type Selector []Requirement
+
+// Matches reports whether the label set satisfies every requirement.
+func (s Selector) Matches(set map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty reports whether the selector has no requirements.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// String renders the selector back into its textual form.
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		switch r.Operator {
+		case OpEquals, OpNotEquals:
+			parts = append(parts, r.Key+r.Operator+r.Values[0])
+		case OpIn, OpNotIn:
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ",")))
+		case OpExists:
+			parts = append(parts, r.Key)
+		case OpDoesNotExist:
+			parts = append(parts, "!"+r.Key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// SelectorFromSet returns a selector requiring every key=value in set.
+func SelectorFromSet(set map[string]string) Selector {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sel := make(Selector, 0, len(keys))
+	for _, k := range keys {
+		sel = append(sel, Requirement{Key: k, Operator: OpEquals, Values: []string{set[k]}})
+	}
+	return sel
+}
+
+// Parse parses a selector such as "app=web,tier!=db,env in (prod,staging),!legacy".
+func Parse(selector string) (Selector, error) {
+	var sel Selector
+	for _, clause := range splitClauses(selector) {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		r, err := parseRequirement(clause)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// splitClauses splits on commas that are not inside parentheses.
+func splitClauses(selector string) []string {
+	var clauses []string
+	depth, start := 0, 0
+	for i, ch := range selector {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				clauses = append(clauses, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(clauses, selector[start:])
+}
+
+func parseRequirement(clause string) (Requirement, error) {
+	if strings.HasPrefix(clause, "!") {
+		key := strings.TrimSpace(clause[1:])
+		if err := validateKey(key); err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: OpDoesNotExist}, nil
+	}
+
+	for _, op := range []string{OpNotIn, OpIn} {
+		marker := " " + op + " "
+		if i := strings.Index(clause, marker); i >= 0 {
+			key := strings.TrimSpace(clause[:i])
+			list := strings.TrimSpace(clause[i+len(marker):])
+			if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+				return Requirement{}, fmt.Errorf("invalid selector %q: values must be in parentheses", clause)
+			}
+			var values []string
+			for _, v := range strings.Split(list[1:len(list)-1], ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				return Requirement{}, fmt.Errorf("invalid selector %q: empty value list", clause)
+			}
+			if err := validateKey(key); err != nil {
+				return Requirement{}, err
+			}
+			return Requirement{Key: key, Operator: op, Values: values}, nil
+		}
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if i := strings.Index(clause, op); i >= 0 {
+			key := strings.TrimSpace(clause[:i])
+			value := strings.TrimSpace(clause[i+len(op):])
+			if err := validateKey(key); err != nil {
+				return Requirement{}, err
+			}
+			operator := OpEquals
+			if op == "!=" {
+				operator = OpNotEquals
+			}
+			return Requirement{Key: key, Operator: operator, Values: []string{value}}, nil
+		}
+	}
+
+	if err := validateKey(clause); err != nil {
+		return Requirement{}, err
+	}
+	return Requirement{Key: clause, Operator: OpExists}, nil
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.ContainsAny(key, " =!(),") {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	return nil
+}
+
+// ParseSet parses "k=v" pairs, as given to --label flags, into a label set.
+func ParseSet(pairs []string) (map[string]string, error) {
+	set := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		for _, kv := range strings.Split(pair, ",") {
+			if kv == "" {
+				continue
+			}
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid label %q, expected key=value", kv)
+			}
+			key := strings.TrimSpace(parts[0])
+			if err := validateKey(key); err != nil {
+				return nil, err
+			}
+			set[key] = strings.TrimSpace(parts[1])
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,156 @@
+package labels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     Selector
+	}{
+		{"empty", "", nil},
+		{"equals", "app=web", Selector{{Key: "app", Operator: OpEquals, Values: []string{"web"}}}},
+		{"double equals", "app==web", Selector{{Key: "app", Operator: OpEquals, Values: []string{"web"}}}},
+		{"not equals", "tier != db", Selector{{Key: "tier", Operator: OpNotEquals, Values: []string{"db"}}}},
+		{"in", "env in (prod, staging)", Selector{{Key: "env", Operator: OpIn, Values: []string{"prod", "staging"}}}},
+		{"notin", "env notin (dev)", Selector{{Key: "env", Operator: OpNotIn, Values: []string{"dev"}}}},
+		{"exists", "gpu", Selector{{Key: "gpu", Operator: OpExists}}},
+		{"does not exist", "!legacy", Selector{{Key: "legacy", Operator: OpDoesNotExist}}},
+		{"conjunction", "app=web,env in (prod,staging),!legacy", Selector{
+			{Key: "app", Operator: OpEquals, Values: []string{"web"}},
+			{Key: "env", Operator: OpIn, Values: []string{"prod", "staging"}},
+			{Key: "legacy", Operator: OpDoesNotExist},
+		}},
+		{"empty clauses skipped", "app=web,,", Selector{{Key: "app", Operator: OpEquals, Values: []string{"web"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.selector, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+	}{
+		{"missing key", "=web"},
+		{"values without parentheses", "env in prod"},
+		{"empty value list", "env in ()"},
+		{"key with space", "my app=web"},
+		{"bare not", "!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.selector); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.selector)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	set := map[string]string{"app": "web", "env": "prod"}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"app=web", true},
+		{"app=api", false},
+		{"app!=api", true},
+		{"missing!=x", true},
+		{"env in (prod,staging)", true},
+		{"env in (dev)", false},
+		{"env notin (dev)", true},
+		{"missing notin (dev)", true},
+		{"missing in (dev)", false},
+		{"app", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!app", false},
+		{"app=web,env=dev", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.selector, err)
+			}
+			if got := sel.Matches(set); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.selector, set, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorStringRoundTrip(t *testing.T) {
+	for _, selector := range []string{
+		"app=web",
+		"tier!=db",
+		"env in (prod,staging)",
+		"env notin (dev)",
+		"gpu",
+		"!legacy",
+		"app=web,env in (prod,staging),!legacy",
+	} {
+		t.Run(selector, func(t *testing.T) {
+			sel, err := Parse(selector)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", selector, err)
+			}
+			if got := sel.String(); got != selector {
+				t.Errorf("String() = %q, want %q", got, selector)
+			}
+		})
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, map[string]string{}, false},
+		{"one per flag", []string{"app=web", "env=prod"}, map[string]string{"app": "web", "env": "prod"}, false},
+		{"comma-separated", []string{"app=web,env=prod"}, map[string]string{"app": "web", "env": "prod"}, false},
+		{"empty value", []string{"canary="}, map[string]string{"canary": ""}, false},
+		{"value with equals", []string{"expr=a=b"}, map[string]string{"expr": "a=b"}, false},
+		{"later wins", []string{"app=web", "app=api"}, map[string]string{"app": "api"}, false},
+		{"missing value", []string{"app"}, nil, true},
+		{"bad key", []string{"!app=web"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSet(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSet(%q) error = %v, want error %v", tt.pairs, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSet(%q) = %v, want %v", tt.pairs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorFromSet(t *testing.T) {
+	sel := SelectorFromSet(map[string]string{"env": "prod", "app": "web"})
+	if got, want := sel.String(), "app=web,env=prod"; got != want {
+		t.Errorf("SelectorFromSet().String() = %q, want %q", got, want)
+	}
+	if !SelectorFromSet(nil).Empty() {
+		t.Errorf("SelectorFromSet(nil) is not empty")
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var apiLog = For("api")
+
+// Middleware logs every API request once it is served, at the warn level
+// for client errors and the error level for server errors. It gives each
+// request an ID, taken from the X-Request-Id header or generated, that is
+// returned in the same header and carried by the records of its handlers.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader("X-Request-Id")
+		if id == "" {
+			id = uuid.New().String()
+		}
+		c.Header("X-Request-Id", id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String(KeyError, c.Errors.String()))
+		}
+		// c.Request carries the trace the handlers ran in by now.
+		apiLog.LogAttrs(c.Request.Context(), level, "Request served", attrs...)
+	}
+}
+
+// Recovery turns a panic in a handler into a 500 response and logs it with
+// its stack, in place of gin's recovery, which writes plain text.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		apiLog.ErrorContext(c.Request.Context(), "Handler panicked",
+			"panic", recovered, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+// RouteGin sends gin's own debug output, such as the routes it registers,
+// to the api component at the debug level.
+func RouteGin() {
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		apiLog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		apiLog.Debug("Route registered", "method", method, "path", path, "handler", handler)
+	}
+}
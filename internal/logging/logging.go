@@ -0,0 +1,292 @@
+// Package logging gives every component of the server its own structured
+// logger. Records are written by log/slog as JSON or text, name the
+// component they come from and, when logged with a request's context, carry
+// the request and trace IDs too. Each component's level can be changed while
+// the server runs.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Keys every component uses for the same things, so all records about a
+// node, pod or request can be found together.
+const (
+	KeyComponent = "component"
+	KeyNodeID    = "node_id"
+	KeyPodID     = "pod_id"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyError     = "error"
+)
+
+// DefaultComponent names the level of components that have none of their own.
+const DefaultComponent = "default"
+
+// ErrUnknownComponent is returned when a level is set for a component that
+// does not log.
+var ErrUnknownComponent = errors.New("unknown log component")
+
+// Config sets up logging.
+type Config struct {
+	Format string                // text (the default) or json
+	Level  slog.Level            // of components without a level of their own
+	Levels map[string]slog.Level // by component
+	Output io.Writer             // standard error when nil
+}
+
+// ParseLevel parses debug, info, warn or error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid level %q: use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// component is a part of the server with a logger and a level.
+type component struct {
+	name  string
+	level slog.LevelVar
+	own   atomic.Bool // false while the component follows the default level
+}
+
+// enabled reports whether the component logs records at level.
+func (c *component) enabled(level slog.Level) bool {
+	if c.own.Load() {
+		return level >= c.level.Level()
+	}
+	return level >= defaultLevel.Level()
+}
+
+var (
+	mu           sync.Mutex // Protects components
+	components   = make(map[string]*component)
+	defaultLevel slog.LevelVar
+	// base writes the records of every component; Setup replaces it.
+	base atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	base.Store(&h)
+}
+
+// lookup returns the named component, registering it if need be.
+func lookup(name string) *component {
+	mu.Lock()
+	defer mu.Unlock()
+	c, exists := components[name]
+	if !exists {
+		c = &component{name: name}
+		components[name] = c
+	}
+	return c
+}
+
+// For returns the logger of a component. Loggers may be created before
+// Setup runs; their records go wherever Setup sends them.
+func For(name string) *slog.Logger {
+	return slog.New(&handler{component: lookup(name)})
+}
+
+// Setup sends the records of every component to cfg.Output in cfg.Format,
+// sets the levels and makes the standard log package write through the
+// "server" component, so nothing escapes the format. Components register
+// their loggers as their packages initialize, so by the time Setup runs a
+// level for a name that is not among them is a mistake. Levels set through
+// the API are replaced.
+func Setup(cfg Config) error {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	// Components filter records themselves, so the handler passes them all.
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var h slog.Handler
+	switch cfg.Format {
+	case "", FormatText:
+		h = slog.NewTextHandler(out, options)
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, options)
+	default:
+		return fmt.Errorf("unknown log format %q: use text or json", cfg.Format)
+	}
+	base.Store(&h)
+
+	slog.SetDefault(For("server"))
+	defaultLevel.Set(cfg.Level)
+	// Setup runs again on reload, when levels left out must go back to
+	// following the default.
+	mu.Lock()
+	for _, c := range components {
+		c.own.Store(false)
+	}
+	mu.Unlock()
+	for name, level := range cfg.Levels {
+		if err := SetLevel(name, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComponentLevel is the level a component logs at.
+type ComponentLevel struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+	Default   bool   `json:"default"` // the component follows the default level
+}
+
+// Levels returns the default level followed by the level of every
+// component, by name.
+func Levels() []ComponentLevel {
+	mu.Lock()
+	defer mu.Unlock()
+	levels := []ComponentLevel{{Component: DefaultComponent, Level: levelName(defaultLevel.Level())}}
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := components[name]
+		if c.own.Load() {
+			levels = append(levels, ComponentLevel{Component: name, Level: levelName(c.level.Level())})
+		} else {
+			levels = append(levels, ComponentLevel{Component: name, Level: levelName(defaultLevel.Level()), Default: true})
+		}
+	}
+	return levels
+}
+
+// SetLevel sets the level of a component, or the default level when name
+// is DefaultComponent.
+func SetLevel(name string, level slog.Level) error {
+	if name == DefaultComponent {
+		defaultLevel.Set(level)
+		return nil
+	}
+	mu.Lock()
+	c, exists := components[name]
+	mu.Unlock()
+	if !exists {
+		return fmt.Errorf("%w %q", ErrUnknownComponent, name)
+	}
+	c.level.Set(level)
+	c.own.Store(true)
+	return nil
+}
+
+// ResetLevel makes a component follow the default level again.
+func ResetLevel(name string) error {
+	mu.Lock()
+	c, exists := components[name]
+	mu.Unlock()
+	if !exists {
+		return fmt.Errorf("%w %q", ErrUnknownComponent, name)
+	}
+	c.own.Store(false)
+	return nil
+}
+
+// levelName is the lower-case name of a level, as ParseLevel accepts it.
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// Fatal logs msg at the error level and exits, for errors the server cannot
+// start or go on with.
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// NodeID is the attribute naming the node a record is about.
+func NodeID(id string) slog.Attr { return slog.String(KeyNodeID, id) }
+
+// PodID is the attribute naming the pod a record is about.
+func PodID(id string) slog.Attr { return slog.String(KeyPodID, id) }
+
+// Err is the attribute of the error a record reports.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.String(KeyError, err.Error())
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose records carry the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// handler filters records by the level of its component and passes them to
+// the current base handler with the component, request and trace added.
+type handler struct {
+	component *component
+	// derive replays the WithAttrs and WithGroup calls made on the logger
+	// onto the base handler, which may have changed since.
+	derive []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.component.enabled(level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String(KeyRequestID, id))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && sc.IsSampled() {
+			r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()), slog.String(KeySpanID, sc.SpanID().String()))
+		}
+	}
+	out := (*base.Load()).WithAttrs([]slog.Attr{slog.String(KeyComponent, h.component.name)})
+	for _, derive := range h.derive {
+		out = derive(out)
+	}
+	return out.Handle(ctx, r)
+}
+
+func (h *handler) with(derive func(slog.Handler) slog.Handler) *handler {
+	return &handler{component: h.component, derive: append(h.derive[:len(h.derive):len(h.derive)], derive)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
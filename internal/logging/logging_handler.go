@@ -0,0 +1,59 @@
+// All the gin handlers are here for logging package
+package logging
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to list the level of every component
+func ListLevelsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, Levels())
+}
+
+// API Handler to change the level of a component, or the default level
+func SetLevelHandler(c *gin.Context) {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Level == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: level is required"})
+		return
+	}
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("component")
+	if err := SetLevel(name, level); err != nil {
+		c.JSON(levelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	apiLog.InfoContext(c.Request.Context(), "Log level changed", "target", name, "level", levelName(level))
+	c.JSON(http.StatusOK, gin.H{"message": "Log level set", "component": name, "level": levelName(level)})
+}
+
+// API Handler to make a component follow the default level again
+func ResetLevelHandler(c *gin.Context) {
+	name := c.Param("component")
+	if name == DefaultComponent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default level cannot be reset; set it instead"})
+		return
+	}
+	if err := ResetLevel(name); err != nil {
+		c.JSON(levelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	apiLog.InfoContext(c.Request.Context(), "Log level reset to the default", "target", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Log level reset", "component": name})
+}
+
+func levelErrorStatus(err error) int {
+	if errors.Is(err, ErrUnknownComponent) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
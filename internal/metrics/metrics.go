@@ -0,0 +1,255 @@
+// Package metrics holds the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cluster_sim"
+
+var (
+	// SchedulingAttempts counts scheduling attempts by algorithm.
+	SchedulingAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "attempts_total",
+		Help:      "Number of pod scheduling attempts.",
+	}, []string{"algorithm"})
+
+	// SchedulingFailures counts scheduling attempts that found no node.
+	SchedulingFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "failures_total",
+		Help:      "Number of pod scheduling attempts that found no suitable node.",
+	}, []string{"algorithm"})
+
+	// SchedulingLatency observes how long a single scheduling decision takes.
+	SchedulingLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "latency_seconds",
+		Help:      "Time spent choosing a node for a pod.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}, []string{"algorithm"})
+
+	// PodsRescheduled counts pods moved off a failed or deleted node.
+	PodsRescheduled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "rescheduled_pods_total",
+		Help:      "Number of pods rescheduled after their node went away, by result.",
+	}, []string{"result"})
+
+	// HealthCheckDuration observes the duration of a full health check pass.
+	HealthCheckDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "health",
+		Name:      "check_duration_seconds",
+		Help:      "Time spent inspecting every node during one health check pass.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// DockerCallDuration observes Docker API call latency by operation.
+	DockerCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "docker",
+		Name:      "call_duration_seconds",
+		Help:      "Latency of Docker API calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// DockerCallErrors counts failed Docker API calls by operation.
+	DockerCallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "docker",
+		Name:      "call_errors_total",
+		Help:      "Number of Docker API calls that returned an error.",
+	}, []string{"operation"})
+
+	// AutoscalerScaleUps counts nodes added by the cluster autoscaler.
+	AutoscalerScaleUps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "autoscaler",
+		Name:      "scaled_up_nodes_total",
+		Help:      "Number of nodes added by the cluster autoscaler, by node group.",
+	}, []string{"node_group"})
+
+	// AutoscalerScaleDowns counts nodes removed by the cluster autoscaler.
+	AutoscalerScaleDowns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "autoscaler",
+		Name:      "scaled_down_nodes_total",
+		Help:      "Number of nodes removed by the cluster autoscaler, by node group.",
+	}, []string{"node_group"})
+
+	// AutoscalerFailedScaleDowns counts drains that kept a node from being removed.
+	AutoscalerFailedScaleDowns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "autoscaler",
+		Name:      "failed_scale_downs_total",
+		Help:      "Number of scale-downs abandoned because the node could not be drained or removed.",
+	}, []string{"node_group"})
+
+	// NodeGroupSize reports the current number of nodes in each node group.
+	NodeGroupSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "autoscaler",
+		Name:      "node_group_size",
+		Help:      "Current number of nodes in each node group.",
+	}, []string{"node_group"})
+
+	// UnschedulablePods reports the pending pods seen by the last autoscaler scan.
+	UnschedulablePods = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "autoscaler",
+		Name:      "unschedulable_pods",
+		Help:      "Number of pending pods no existing node can take, as of the last scan.",
+	})
+
+	// PodGroupLoad reports the simulated load, in CPUs, of each pod group.
+	PodGroupLoad = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "pod_group",
+		Name:      "load_cpus",
+		Help:      "Simulated load of each pod group, in CPUs.",
+	}, []string{"pod_group"})
+
+	// HPACurrentReplicas reports the replica count each HPA last observed.
+	HPACurrentReplicas = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "hpa",
+		Name:      "current_replicas",
+		Help:      "Replica count of the HPA's target as of its last sync.",
+	}, []string{"hpa"})
+
+	// HPADesiredReplicas reports the replica count each HPA asked for.
+	HPADesiredReplicas = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "hpa",
+		Name:      "desired_replicas",
+		Help:      "Replica count the HPA computed at its last sync.",
+	}, []string{"hpa"})
+
+	// HPAUtilization reports the average pod utilization each HPA observed.
+	HPAUtilization = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "hpa",
+		Name:      "current_utilization_percent",
+		Help:      "Average CPU utilization of the HPA's running pods, in percent of requests.",
+	}, []string{"hpa"})
+
+	// HPAScalings counts replica changes made by HPAs.
+	HPAScalings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "hpa",
+		Name:      "scalings_total",
+		Help:      "Number of times an HPA changed its target's replica count, by direction.",
+	}, []string{"hpa", "direction"})
+
+	// JobsFinished counts jobs that completed or failed.
+	JobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "finished_total",
+		Help:      "Number of jobs that finished, by condition (Complete or Failed).",
+	}, []string{"condition"})
+
+	// JobDuration tracks how long jobs ran before finishing.
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "Time from a job's creation until it completed or failed.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"condition"})
+
+	// CronJobRuns counts jobs started by CronJobs.
+	CronJobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cronjob",
+		Name:      "runs_total",
+		Help:      "Number of jobs a CronJob started.",
+	}, []string{"cronjob"})
+
+	// CronJobMissedRuns counts scheduled runs a CronJob skipped.
+	CronJobMissedRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cronjob",
+		Name:      "missed_runs_total",
+		Help:      "Number of scheduled runs a CronJob skipped, by reason.",
+	}, []string{"cronjob", "reason"})
+
+	// ServiceRequests counts synthetic requests sent to services, by result.
+	ServiceRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "requests_total",
+		Help:      "Number of simulated requests a service's load balancer handled, by result (Success or a failure reason).",
+	}, []string{"service", "result"})
+
+	// ServiceEndpoints reports how many ready endpoints each service has.
+	ServiceEndpoints = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "ready_endpoints",
+		Help:      "Number of ready pods behind a service as of its last endpoints sync.",
+	}, []string{"service"})
+
+	// DNSQueries counts queries answered by the cluster DNS server.
+	DNSQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dns",
+		Name:      "queries_total",
+		Help:      "Number of DNS queries answered, by query type and response code.",
+	}, []string{"type", "rcode"})
+
+	// DNSRecords reports the size of the cluster DNS zone.
+	DNSRecords = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "dns",
+		Name:      "records",
+		Help:      "Number of records served by the cluster DNS as of its last sync.",
+	})
+
+	// AuditEvents counts audit events written, by backend and level.
+	AuditEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "events_total",
+		Help:      "Number of audit events sent to a backend, by backend and level.",
+	}, []string{"backend", "level"})
+
+	// AuditErrors counts audit events a backend failed to write or dropped.
+	AuditErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "errors_total",
+		Help:      "Number of audit events a backend failed to write or dropped, by backend.",
+	}, []string{"backend"})
+)
+
+// ObserveDockerCall records the latency and outcome of a Docker API call
+// started at start.
+func ObserveDockerCall(operation string, start time.Time, err error) {
+	DockerCallDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DockerCallErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// Register adds extra collectors, such as the cluster state collector, to the
+// registry served by Handler.
+func Register(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(collectors...)
+}
+
+// Handler serves all registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
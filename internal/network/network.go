@@ -0,0 +1,202 @@
+// Package network models the links between the control plane and the nodes,
+// and between nodes. A link can be partitioned, delayed or lossy; the
+// simulator's own traffic (heartbeats and service requests) consults the
+// model, and a Runtime makes real traffic between node containers follow it.
+package network
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ControlPlane is the link endpoint of the API server and its controllers.
+const ControlPlane = "control-plane"
+
+// Link is the state of the connection between two endpoints, each a node ID
+// or ControlPlane. It applies in both directions: latency is added to each
+// direction and every packet or message is lost with LossRate.
+type Link struct {
+	A             string  `json:"a"`
+	B             string  `json:"b"`
+	Partitioned   bool    `json:"partitioned"`
+	LatencyMillis int     `json:"latency_ms,omitempty"`
+	LossRate      float64 `json:"loss_rate,omitempty"`
+}
+
+// Validate checks the link is well formed.
+func (l Link) Validate() error {
+	if l.A == "" || l.B == "" {
+		return fmt.Errorf("a link needs two endpoints")
+	}
+	if l.A == l.B {
+		return fmt.Errorf("a link needs two different endpoints")
+	}
+	if l.LatencyMillis < 0 {
+		return fmt.Errorf("latency_ms must not be negative")
+	}
+	if l.LossRate < 0 || l.LossRate > 1 {
+		return fmt.Errorf("loss_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// Healthy reports whether the link is neither partitioned, delayed nor lossy.
+func (l Link) Healthy() bool {
+	return !l.Partitioned && l.LatencyMillis == 0 && l.LossRate == 0
+}
+
+// Latency is the delay the link adds in each direction.
+func (l Link) Latency() time.Duration {
+	return time.Duration(l.LatencyMillis) * time.Millisecond
+}
+
+// Peer returns the endpoint at the other end of the link from end.
+func (l Link) Peer(end string) string {
+	if l.A == end {
+		return l.B
+	}
+	return l.A
+}
+
+// pair identifies a link regardless of the order of its endpoints.
+type pair [2]string
+
+func pairOf(a, b string) pair {
+	if a > b {
+		a, b = b, a
+	}
+	return pair{a, b}
+}
+
+// Model holds every link that is not healthy; all others are.
+type Model struct {
+	mu      sync.Mutex // Protects links and version
+	links   map[pair]Link
+	version uint64
+}
+
+// NewModel returns a model in which every link is healthy.
+func NewModel() *Model {
+	return &Model{links: make(map[pair]Link)}
+}
+
+// SetLink changes the state of a link. Setting it healthy removes it.
+func (m *Model) SetLink(l Link) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	key := pairOf(l.A, l.B)
+	l.A, l.B = key[0], key[1]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l.Healthy() {
+		delete(m.links, key)
+	} else {
+		m.links[key] = l
+	}
+	m.version++
+	return nil
+}
+
+// Heal makes a link healthy again and reports whether it was not.
+func (m *Model) Heal(a, b string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := pairOf(a, b)
+	if _, exists := m.links[key]; !exists {
+		return false
+	}
+	delete(m.links, key)
+	m.version++
+	return true
+}
+
+// HealAll makes every link healthy and returns how many were not.
+func (m *Model) HealAll() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	healed := len(m.links)
+	m.links = make(map[pair]Link)
+	m.version++
+	return healed
+}
+
+// Forget drops every link of an endpoint, such as a deleted node.
+func (m *Model) Forget(end string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.links {
+		if key[0] == end || key[1] == end {
+			delete(m.links, key)
+			m.version++
+		}
+	}
+}
+
+// Link returns the state of the link between a and b.
+func (m *Model) Link(a, b string) Link {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := pairOf(a, b)
+	if l, exists := m.links[key]; exists {
+		return l
+	}
+	return Link{A: key[0], B: key[1]}
+}
+
+// Links returns every link that is not healthy, by endpoints.
+func (m *Model) Links() []Link {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	links := make([]Link, 0, len(m.links))
+	for _, l := range m.links {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].A != links[j].A {
+			return links[i].A < links[j].A
+		}
+		return links[i].B < links[j].B
+	})
+	return links
+}
+
+// LinksOf returns the links of one endpoint that are not healthy.
+func (m *Model) LinksOf(end string) []Link {
+	var links []Link
+	for _, l := range m.Links() {
+		if l.A == end || l.B == end {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// Version changes whenever a link does, so appliers can tell they are stale.
+func (m *Model) Version() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version
+}
+
+// Reachable reports whether a and b are not partitioned from each other.
+// An endpoint can always reach itself.
+func (m *Model) Reachable(a, b string) bool {
+	return a == b || !m.Link(a, b).Partitioned
+}
+
+// Deliver decides the fate of one message from one endpoint to another: it
+// returns how long the message takes and false if it is lost.
+func (m *Model) Deliver(from, to string) (time.Duration, bool) {
+	if from == to {
+		return 0, true
+	}
+	l := m.Link(from, to)
+	if l.Partitioned || (l.LossRate > 0 && rand.Float64() < l.LossRate) {
+		return 0, false
+	}
+	return l.Latency(), true
+}
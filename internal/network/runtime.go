@@ -0,0 +1,64 @@
+package network
+
+import (
+	"sync"
+)
+
+// Rule is a degraded link as seen from one node: traffic to and from Peer
+// is dropped, or delayed and lost, as Link says.
+type Rule struct {
+	Peer string `json:"peer"` // node ID or ControlPlane
+	Link Link   `json:"link"`
+}
+
+// RulesFor returns the rules of a node for the current state of the model.
+func (m *Model) RulesFor(nodeID string) []Rule {
+	var rules []Rule
+	for _, l := range m.LinksOf(nodeID) {
+		rules = append(rules, Rule{Peer: l.Peer(nodeID), Link: l})
+	}
+	return rules
+}
+
+// Runtime makes the traffic of a node follow the model. Apply replaces all
+// rules previously applied to the node.
+type Runtime interface {
+	Name() string
+	Apply(nodeID string, rules []Rule) error
+}
+
+// FakeRuntime keeps the rules in memory without touching any real traffic,
+// so only the simulator's own heartbeats and requests are affected.
+type FakeRuntime struct {
+	mu      sync.Mutex // Protects applied
+	applied map[string][]Rule
+}
+
+// NewFakeRuntime returns a runtime that only records the rules.
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{applied: make(map[string][]Rule)}
+}
+
+// Name returns "fake".
+func (r *FakeRuntime) Name() string {
+	return "fake"
+}
+
+// Apply records the rules of a node.
+func (r *FakeRuntime) Apply(nodeID string, rules []Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(rules) == 0 {
+		delete(r.applied, nodeID)
+	} else {
+		r.applied[nodeID] = rules
+	}
+	return nil
+}
+
+// Applied returns the rules last applied to a node.
+func (r *FakeRuntime) Applied(nodeID string) []Rule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied[nodeID]
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"sort"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/pod"
+)
+
+// Condition is a computed True/False/Unknown fact about a node or pod.
+type Condition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// NodeDescription is the detailed view of a node returned by GET /nodes/:id.
+type NodeDescription struct {
+	Node              Node           `json:"node"`
+	Conditions        []Condition    `json:"conditions"`
+	CapacityCPUs      int            `json:"capacity_cpus"`
+	CapacityMemoryMiB int            `json:"capacity_memory_mib,omitempty"`
+	AllocatedCPUs     int            `json:"allocated_cpus"`
+	Pods              []pod.Pod      `json:"pods"`
+	Events            []events.Event `json:"events"`
+}
+
+// NodeFit is the scheduler's verdict for one node when placing a pod.
+type NodeFit struct {
+	NodeID string `json:"node_id"`
+	Fits   bool   `json:"fits"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PodDescription is the detailed view of a pod returned by GET /pods/:id.
+type PodDescription struct {
+	Pod        pod.Pod        `json:"pod"`
+	Conditions []Condition    `json:"conditions"`
+	Events     []events.Event `json:"events"`
+	// Scheduling explains every node's verdict; only set for pending pods.
+	Scheduling []NodeFit `json:"scheduling,omitempty"`
+}
+
+func nodeConditions(n Node) []Condition {
+	ready := Condition{Type: "Ready", Status: "True", Reason: "NodeRunning"}
+	if n.Status == "Unreachable" {
+		ready = Condition{Type: "Ready", Status: "Unknown", Reason: "NodeUnreachable",
+			Message: "node stopped posting heartbeats; last one at " + n.LastHeartbeat.Format(time.RFC3339)}
+	} else if n.Status != "Running" {
+		ready = Condition{Type: "Ready", Status: "False", Reason: "Node" + n.Status,
+			Message: "node container is not running"}
+	}
+	return []Condition{ready}
+}
+
+// podConditions derives pod conditions; unschedulable explains a pending pod.
+func podConditions(p pod.Pod, unschedulable string) []Condition {
+	if p.NodeID == "" {
+		return []Condition{
+			{Type: "PodScheduled", Status: "False", Reason: "Unschedulable", Message: unschedulable},
+			{Type: "Ready", Status: "False", Reason: "PodPending"},
+		}
+	}
+	ready := Condition{Type: "Ready", Status: "True"}
+	if p.Status != "Running" {
+		ready = Condition{Type: "Ready", Status: "False", Reason: "Pod" + p.Status}
+	}
+	return []Condition{{Type: "PodScheduled", Status: "True"}, ready}
+}
+
+// DescribeNode returns the detailed view of a node and whether it exists.
+func (nm *NodeManager) DescribeNode(nodeID string) (NodeDescription, bool) {
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists {
+		nm.Mu.Unlock()
+		return NodeDescription{}, false
+	}
+	pods := make([]pod.Pod, 0, len(n.Pods))
+	for _, podID := range n.Pods {
+		if p, ok := nm.Pods[podID]; ok {
+			pods = append(pods, p)
+		}
+	}
+	nm.Mu.Unlock()
+
+	return NodeDescription{
+		Node:              n,
+		Conditions:        nodeConditions(n),
+		CapacityCPUs:      n.CPUs,
+		CapacityMemoryMiB: n.MemoryMiB,
+		AllocatedCPUs:     n.UsedCPUs,
+		Pods:              pods,
+		Events:            nm.Events.List(events.Filter{Kind: events.KindNode, InvolvedObject: nodeID}),
+	}, true
+}
+
+// DescribePod returns the detailed view of a pod and whether it exists.
+// Pending pods include the scheduler's verdict for every node.
+func (nm *NodeManager) DescribePod(podID string) (PodDescription, bool) {
+	nm.Mu.Lock()
+	p, exists := nm.Pods[podID]
+	if !exists {
+		nm.Mu.Unlock()
+		return PodDescription{}, false
+	}
+	var reasons map[string]string
+	var summary string
+	if p.NodeID == "" {
+		reasons = explainPodFor(p, nm.Nodes, nm.podFitsNodeLocked)
+		summary = unschedulableErrorFor(p, nm.Nodes, nm.podFitsNodeLocked).Error()
+		for _, reason := range reasons {
+			if reason == "" {
+				summary = "a node now fits the pod; waiting for the next scheduling attempt"
+				break
+			}
+		}
+	}
+	nm.Mu.Unlock()
+
+	desc := PodDescription{
+		Pod:        p,
+		Conditions: podConditions(p, summary),
+		Events:     nm.Events.List(events.Filter{Kind: events.KindPod, InvolvedObject: podID}),
+	}
+	for nodeID, reason := range reasons {
+		desc.Scheduling = append(desc.Scheduling, NodeFit{NodeID: nodeID, Fits: reason == "", Reason: reason})
+	}
+	sort.Slice(desc.Scheduling, func(i, j int) bool {
+		return desc.Scheduling[i].NodeID < desc.Scheduling[j].NodeID
+	})
+	return desc, true
+}
@@ -0,0 +1,291 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/pod"
+)
+
+// evictionRetryInterval is how long a drain waits before retrying an eviction
+// refused by a disruption budget.
+const evictionRetryInterval = 2 * time.Second
+
+// DrainOptions controls how Drain evicts pods from a node.
+type DrainOptions struct {
+	// Timeout bounds the whole drain; zero means no limit.
+	Timeout time.Duration
+	// Force evicts pods even when no other node can take them, leaving them Pending.
+	Force bool
+	// DryRun only reports what would be evicted.
+	DryRun bool
+	// GracePeriodSeconds overrides each pod's own grace period when >= 0.
+	GracePeriodSeconds int
+}
+
+// DrainResult reports what a drain did, or would do for a dry run.
+type DrainResult struct {
+	NodeID      string            `json:"node_id"`
+	DryRun      bool              `json:"dry_run"`
+	Evicted     []string          `json:"evicted"`
+	Rescheduled map[string]string `json:"rescheduled"` // pod ID -> new node ID
+	Pending     []string          `json:"pending"`     // evicted but no node could take them
+	Remaining   []string          `json:"remaining"`   // still on the node when the drain stopped
+	Blocked     []string          `json:"blocked"`     // dry run: evictions a disruption budget would refuse right now
+}
+
+// Cordon marks a node unschedulable so no new pods are placed on it.
+func (nm *NodeManager) Cordon(nodeID string) error {
+	return nm.setUnschedulable(nodeID, true)
+}
+
+// Uncordon makes a node schedulable again.
+func (nm *NodeManager) Uncordon(nodeID string) error {
+	return nm.setUnschedulable(nodeID, false)
+}
+
+func (nm *NodeManager) setUnschedulable(nodeID string, unschedulable bool) error {
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists {
+		nm.Mu.Unlock()
+		return ErrNodeNotFound
+	}
+	changed := n.Unschedulable != unschedulable
+	n.Unschedulable = unschedulable
+	nm.Nodes[nodeID] = n
+	nm.Mu.Unlock()
+
+	if changed {
+		if unschedulable {
+			schedulerLog.Info("Node cordoned", logging.NodeID(nodeID))
+			nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "NodeNotSchedulable", "Node marked unschedulable")
+		} else {
+			schedulerLog.Info("Node uncordoned", logging.NodeID(nodeID))
+			nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "NodeSchedulable", "Node marked schedulable")
+		}
+	}
+	return nil
+}
+
+// bindPodLocked marks a pod placed by the scheduler Running on its node,
+// with an address from the node's pod CIDR. nm.Mu must be held.
+func (nm *NodeManager) bindPodLocked(p *pod.Pod, nodeID string) {
+	p.NodeID = nodeID
+	p.Status = "Running"
+	ip, err := nm.IPAM.Allocate(nodeID, p.ID)
+	if err != nil {
+		// The scheduler only picks nodes with a free address, so this is a
+		// node that has no pod CIDR.
+		schedulerLog.Error("No pod address on node", logging.PodID(p.ID), logging.NodeID(nodeID), logging.Err(err))
+	}
+	p.IP = ip
+}
+
+// unbindPodLocked removes a pod from its node's pod list and frees its CPUs
+// and address. nm.Mu must be held.
+func (nm *NodeManager) unbindPodLocked(p pod.Pod) {
+	// Whatever the pod was running on this node is over.
+	delete(nm.started, p.ID)
+	nm.IPAM.Release(p.ID)
+	n, exists := nm.Nodes[p.NodeID]
+	if !exists {
+		return
+	}
+	for i, id := range n.Pods {
+		if id == p.ID {
+			n.Pods = append(n.Pods[:i:i], n.Pods[i+1:]...)
+			n.UsedCPUs -= p.CPUs
+			break
+		}
+	}
+	nm.Nodes[p.NodeID] = n
+}
+
+// canRescheduleLocked reports whether some node other than the pod's own
+// would accept it. nm.Mu must be held.
+func (nm *NodeManager) canRescheduleLocked(p pod.Pod) bool {
+	for id, n := range nm.Nodes {
+		if id != p.NodeID && nm.podFitsNodeLocked(p, n) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// evictPod gracefully terminates a pod and reschedules it elsewhere. It
+// returns the new node ID, or "" if the pod was left Pending.
+func (nm *NodeManager) evictPod(ctx context.Context, podID string, gracePeriodSeconds int) (string, error) {
+	nm.Mu.Lock()
+	p, exists := nm.Pods[podID]
+	if !exists {
+		nm.Mu.Unlock()
+		return "", ErrPodNotFound
+	}
+	// Checking the budgets and marking the pod Terminating under one lock
+	// keeps concurrent evictions from both squeezing through the same budget.
+	if err := nm.checkDisruptionBudgetsLocked(podID); err != nil {
+		nm.Mu.Unlock()
+		nm.recordBlockedEviction(podID, err)
+		return "", err
+	}
+	oldNodeID := p.NodeID
+	p.Status = "Terminating"
+	nm.Pods[podID] = p
+	nm.Mu.Unlock()
+
+	if gracePeriodSeconds < 0 {
+		gracePeriodSeconds = p.GracePeriodSeconds
+	}
+	nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Killing", "Stopping pod on node %s with a %ds grace period", oldNodeID, gracePeriodSeconds)
+
+	// Simulate the pod shutting down; a cancelled drain cuts the grace period short.
+	select {
+	case <-time.After(time.Duration(gracePeriodSeconds) * time.Second):
+	case <-ctx.Done():
+	}
+
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	p, exists = nm.Pods[podID]
+	if !exists {
+		return "", nil
+	}
+	nm.unbindPodLocked(p)
+	p.NodeID = ""
+	p.IP = ""
+	p.Status = "Pending"
+	nm.Pods[podID] = p
+	nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Evicted", "Pod evicted from node %s", oldNodeID)
+
+	// The old node is left out, or the scheduler would often put the pod
+	// straight back and the eviction would only use up disruption budget.
+	candidates := make(map[string]Node, len(nm.Nodes))
+	for id, n := range nm.Nodes {
+		if id != oldNodeID {
+			candidates[id] = n
+		}
+	}
+	newNodeID, err := nm.schedulePodAmongLocked(ctx, p, candidates, p.Algorithm)
+	if err != nil {
+		nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "FailedScheduling", "%v", err)
+		return "", nil
+	}
+	nm.bindPodLocked(&p, newNodeID)
+	nm.Pods[podID] = p
+	nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Scheduled", "Successfully assigned pod to node %s", newNodeID)
+	return newNodeID, nil
+}
+
+// Drain cordons a node and evicts its pods one by one through the eviction
+// API, rescheduling each on another node. DaemonSet pods are left in place.
+// Evictions refused by a disruption budget are retried until the timeout.
+// Without Force it stops at the first pod no other node can take.
+func (nm *NodeManager) Drain(nodeID string, opts DrainOptions) (DrainResult, error) {
+	result := DrainResult{NodeID: nodeID, DryRun: opts.DryRun, Rescheduled: map[string]string{}}
+
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists {
+		nm.Mu.Unlock()
+		return result, ErrNodeNotFound
+	}
+	// DaemonSet pods stay: they are bound to this node and their DaemonSet
+	// would only recreate them here.
+	var podIDs []string
+	for _, podID := range n.Pods {
+		if p, ok := nm.Pods[podID]; ok && IsDaemonSetPod(p) {
+			continue
+		}
+		podIDs = append(podIDs, podID)
+	}
+	if opts.DryRun {
+		// Nothing is changed; report which pods would be evicted and which
+		// of those would be stranded.
+		for _, podID := range podIDs {
+			p, ok := nm.Pods[podID]
+			if !ok {
+				continue
+			}
+			result.Evicted = append(result.Evicted, podID)
+			if !nm.canRescheduleLocked(p) {
+				result.Pending = append(result.Pending, podID)
+			}
+			if nm.checkDisruptionBudgetsLocked(podID) != nil {
+				result.Blocked = append(result.Blocked, podID)
+			}
+		}
+		nm.Mu.Unlock()
+		if len(result.Pending) > 0 && !opts.Force {
+			return result, fmt.Errorf("pods %v cannot be rescheduled to another node (use force to evict anyway)", result.Pending)
+		}
+		return result, nil
+	}
+	nm.Mu.Unlock()
+
+	if err := nm.Cordon(nodeID); err != nil {
+		return result, err
+	}
+	schedulerLog.Info("Draining node", logging.NodeID(nodeID), "pods", len(podIDs))
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	for i, podID := range podIDs {
+		if ctx.Err() != nil {
+			result.Remaining = podIDs[i:]
+			return result, fmt.Errorf("drain timed out after %s with %d pods remaining", opts.Timeout, len(result.Remaining))
+		}
+
+		nm.Mu.Lock()
+		p, ok := nm.Pods[podID]
+		if ok && p.NodeID != nodeID {
+			ok = false // already moved by someone else
+		}
+		stranded := ok && !nm.canRescheduleLocked(p)
+		nm.Mu.Unlock()
+		if !ok {
+			continue
+		}
+		if stranded && !opts.Force {
+			result.Remaining = podIDs[i:]
+			return result, fmt.Errorf("pod %s cannot be rescheduled to another node (use force to evict anyway)", podID)
+		}
+
+		newNodeID, err := nm.evictPod(ctx, podID, opts.GracePeriodSeconds)
+		// A budget may allow the eviction later, once earlier evicted pods
+		// are running again elsewhere; keep retrying until the timeout.
+		var budgetErr *DisruptionBudgetError
+		for errors.As(err, &budgetErr) {
+			select {
+			case <-time.After(evictionRetryInterval):
+			case <-ctx.Done():
+				result.Remaining = podIDs[i:]
+				return result, fmt.Errorf("drain timed out after %s: %v", opts.Timeout, err)
+			}
+			newNodeID, err = nm.evictPod(ctx, podID, opts.GracePeriodSeconds)
+		}
+		if err != nil {
+			result.Remaining = podIDs[i:]
+			return result, err
+		}
+		result.Evicted = append(result.Evicted, podID)
+		if newNodeID == "" {
+			result.Pending = append(result.Pending, podID)
+		} else {
+			result.Rescheduled[podID] = newNodeID
+		}
+	}
+
+	schedulerLog.Info("Node drained", logging.NodeID(nodeID), "evicted", len(result.Evicted))
+	nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "NodeDrained", "Evicted %d pods", len(result.Evicted))
+	return result, nil
+}
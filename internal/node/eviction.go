@@ -0,0 +1,106 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/policy"
+)
+
+// DisruptionBudgetError is returned when evicting a pod would violate a
+// PodDisruptionBudget. The eviction API maps it to 429 Too Many Requests.
+type DisruptionBudgetError struct {
+	Budget string
+	Status policy.Status
+}
+
+func (e *DisruptionBudgetError) Error() string {
+	return fmt.Sprintf("cannot evict pod as it would violate the pod's disruption budget %q (%d/%d healthy, %d required)",
+		e.Budget, e.Status.CurrentHealthy, e.Status.ExpectedPods, e.Status.DesiredHealthy)
+}
+
+// PDBWithStatus is a budget together with its computed status.
+type PDBWithStatus struct {
+	policy.PodDisruptionBudget
+	Status policy.Status `json:"status"`
+}
+
+// podInfosLocked summarises every pod for budget computation. nm.Mu must be held.
+func (nm *NodeManager) podInfosLocked() []policy.PodInfo {
+	infos := make([]policy.PodInfo, 0, len(nm.Pods))
+	for _, p := range nm.Pods {
+		infos = append(infos, policy.PodInfo{Labels: p.Labels, Healthy: p.Status == "Running"})
+	}
+	return infos
+}
+
+// checkDisruptionBudgetsLocked returns a *DisruptionBudgetError if evicting
+// the pod would take any budget covering it below its desired healthy count.
+// Pods that are already unhealthy can always be evicted. nm.Mu must be held.
+func (nm *NodeManager) checkDisruptionBudgetsLocked(podID string) error {
+	p := nm.Pods[podID]
+	if p.Status != "Running" {
+		return nil
+	}
+	var infos []policy.PodInfo
+	for name, budget := range nm.PDBs {
+		if !budget.Matches(p.Labels) {
+			continue
+		}
+		if infos == nil {
+			infos = nm.podInfosLocked()
+		}
+		if st := budget.Compute(infos); st.DisruptionsAllowed <= 0 {
+			return &DisruptionBudgetError{Budget: name, Status: st}
+		}
+	}
+	return nil
+}
+
+// EvictPod evicts a pod through the eviction API: it is refused with a
+// *DisruptionBudgetError if a budget would be violated, otherwise the pod is
+// given its grace period and rescheduled elsewhere. It returns the new node ID,
+// or "" if no node could take the pod.
+func (nm *NodeManager) EvictPod(podID string, gracePeriodSeconds int) (string, error) {
+	return nm.evictPod(context.Background(), podID, gracePeriodSeconds)
+}
+
+// SetPDB creates or replaces a PodDisruptionBudget.
+func (nm *NodeManager) SetPDB(budget policy.PodDisruptionBudget) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+	nm.Mu.Lock()
+	nm.PDBs[budget.Name] = budget
+	nm.Mu.Unlock()
+	return nil
+}
+
+// DeletePDB removes a budget and reports whether it existed.
+func (nm *NodeManager) DeletePDB(name string) bool {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	_, exists := nm.PDBs[name]
+	delete(nm.PDBs, name)
+	return exists
+}
+
+// ListPDBs returns every budget with its current status, sorted by name.
+func (nm *NodeManager) ListPDBs() []PDBWithStatus {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	infos := nm.podInfosLocked()
+	result := make([]PDBWithStatus, 0, len(nm.PDBs))
+	for _, budget := range nm.PDBs {
+		result = append(result, PDBWithStatus{PodDisruptionBudget: budget, Status: budget.Compute(infos)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// recordBlockedEviction notes on the pod that a budget refused its eviction.
+func (nm *NodeManager) recordBlockedEviction(podID string, err error) {
+	nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "EvictionBlocked", "%v", err)
+}
@@ -0,0 +1,196 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/network"
+)
+
+// networkEnforceInterval is how often the enforcer brings node containers
+// in line with the network model.
+const networkEnforceInterval = 2 * time.Second
+
+// networkApplyTimeout bounds one exec of the rules script in a node.
+const networkApplyTimeout = 10 * time.Second
+
+// networkChain is the iptables chain the Docker runtime owns in each node.
+const networkChain = "CLUSTER-SIM"
+
+// DockerNetworkRuntime enforces the model inside node containers: iptables
+// drops traffic to and from partitioned peers, and tc netem delays and
+// drops the packets of degraded ones. Node images need iptables and
+// iproute2, and the containers NET_ADMIN, which nodeHostConfig grants.
+type DockerNetworkRuntime struct{}
+
+// Name returns "docker".
+func (DockerNetworkRuntime) Name() string {
+	return "docker"
+}
+
+// Apply replaces the rules in a node container.
+func (DockerNetworkRuntime) Apply(nodeID string, rules []network.Rule) error {
+	ips := make([]string, len(rules))
+	for i, r := range rules {
+		var ip string
+		var err error
+		if r.Peer == network.ControlPlane {
+			ip, err = NetworkGateway()
+		} else {
+			ip, err = ContainerIP(r.Peer)
+		}
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", r.Peer, err)
+		}
+		ips[i] = ip
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkApplyTimeout)
+	defer cancel()
+	code, err := ExecInNode(ctx, nodeID, []string{"sh", "-c", networkScript(rules, ips)})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("network rules script exited with code %d; the node image needs iptables and iproute2", code)
+	}
+	return nil
+}
+
+// networkScript returns the shell script that replaces the rules of a node,
+// given the address of each rule's peer.
+func networkScript(rules []network.Rule, ips []string) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "iptables -N %s 2>/dev/null || iptables -F %s\n", networkChain, networkChain)
+	for _, hook := range []string{"INPUT", "OUTPUT"} {
+		fmt.Fprintf(&b, "iptables -C %s -j %s 2>/dev/null || iptables -I %s -j %s\n", hook, networkChain, hook, networkChain)
+	}
+	b.WriteString("tc qdisc del dev eth0 root 2>/dev/null || true\n")
+
+	shaped := false
+	for i, r := range rules {
+		ip := ips[i]
+		if r.Link.Partitioned {
+			fmt.Fprintf(&b, "iptables -A %s -s %s -j DROP\n", networkChain, ip)
+			fmt.Fprintf(&b, "iptables -A %s -d %s -j DROP\n", networkChain, ip)
+			continue
+		}
+		if !shaped {
+			b.WriteString("tc qdisc add dev eth0 root handle 1: htb default 1\n")
+			b.WriteString("tc class add dev eth0 parent 1: classid 1:1 htb rate 10gbit\n")
+			shaped = true
+		}
+		class := 10 + i
+		fmt.Fprintf(&b, "tc class add dev eth0 parent 1: classid 1:%d htb rate 10gbit\n", class)
+		fmt.Fprintf(&b, "tc qdisc add dev eth0 parent 1:%d netem delay %dms loss %g%%\n", class, r.Link.LatencyMillis, r.Link.LossRate*100)
+		fmt.Fprintf(&b, "tc filter add dev eth0 protocol ip parent 1: prio 1 u32 match ip dst %s/32 flowid 1:%d\n", ip, class)
+	}
+	return b.String()
+}
+
+// appliedNetwork is what the enforcer last applied to a node.
+type appliedNetwork struct {
+	Rules     []network.Rule `json:"rules"`
+	AppliedAt time.Time      `json:"applied_at"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// networkEnforcer remembers the rules applied to each node so they are only
+// reapplied when they change.
+type networkEnforcer struct {
+	runtime network.Runtime
+	mu      sync.Mutex // Protects applied
+	applied map[string]appliedNetwork
+}
+
+// StartNetworkEnforcer begins a goroutine that applies the network model to
+// every Running or Unreachable node through the runtime.
+func (nm *NodeManager) StartNetworkEnforcer(rt network.Runtime) {
+	nm.enforcer = &networkEnforcer{runtime: rt, applied: make(map[string]appliedNetwork)}
+	go func() {
+		for {
+			nm.enforceNetwork()
+			time.Sleep(networkEnforceInterval)
+		}
+	}()
+}
+
+// enforceNetwork applies the rules of each node whose rules changed or
+// failed to apply, and forgets the nodes that are gone or stopped.
+func (nm *NodeManager) enforceNetwork() {
+	e := nm.enforcer
+	nm.Mu.Lock()
+	var up []string
+	for id, n := range nm.Nodes {
+		if n.Status == "Running" || n.Status == "Unreachable" {
+			up = append(up, id)
+		}
+	}
+	nm.Mu.Unlock()
+
+	live := make(map[string]bool, len(up))
+	for _, id := range up {
+		live[id] = true
+		rules := nm.Network.RulesFor(id)
+		e.mu.Lock()
+		last, seen := e.applied[id]
+		e.mu.Unlock()
+		if seen && last.Error == "" && reflect.DeepEqual(last.Rules, rules) {
+			continue
+		}
+		// A node never shaped needs nothing undone.
+		if !seen && len(rules) == 0 {
+			continue
+		}
+
+		state := appliedNetwork{Rules: rules, AppliedAt: time.Now()}
+		if err := e.runtime.Apply(id, rules); err != nil {
+			state.Error = err.Error()
+			if !seen || last.Error != state.Error {
+				networkLog.Error("Applying network rules failed", logging.NodeID(id), logging.Err(err))
+				nm.Events.Eventf(events.KindNode, id, events.TypeWarning, "NetworkRulesFailed", "Applying network rules failed: %v", err)
+			}
+		} else {
+			networkLog.Info("Applied network rules", logging.NodeID(id), "rules", len(rules), "runtime", e.runtime.Name())
+		}
+		e.mu.Lock()
+		e.applied[id] = state
+		e.mu.Unlock()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id := range e.applied {
+		if !live[id] {
+			delete(e.applied, id)
+		}
+	}
+}
+
+// NetworkStatus is the network model together with what was applied.
+type NetworkStatus struct {
+	Runtime string                    `json:"runtime"`
+	Links   []network.Link            `json:"links"`
+	Applied map[string]appliedNetwork `json:"applied"`
+}
+
+// NetworkStatus returns the degraded links and the rules applied per node.
+func (nm *NodeManager) NetworkStatus() NetworkStatus {
+	status := NetworkStatus{Links: nm.Network.Links(), Applied: map[string]appliedNetwork{}}
+	if e := nm.enforcer; e != nil {
+		status.Runtime = e.runtime.Name()
+		e.mu.Lock()
+		for id, state := range e.applied {
+			status.Applied[id] = state
+		}
+		e.mu.Unlock()
+	}
+	return status
+}
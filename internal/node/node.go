@@ -3,13 +3,45 @@ package node
 import (
     "fmt"
     "context"
+    "io"
+    "sync"
     "time"
-    "log"
+    "cluster-sim/internal/logging"
+    "cluster-sim/internal/metrics"
+    "cluster-sim/internal/tracing"
     "github.com/google/uuid"
     "github.com/docker/docker/api/types/container"
+    "github.com/docker/docker/api/types/network"
     "github.com/docker/docker/client"
+    "github.com/docker/docker/pkg/stdcopy"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
 )
 
+// tracer records the spans of node operations, scheduling cycles and the
+// Docker calls they make.
+var tracer = tracing.Tracer("cluster-sim/internal/node")
+
+// The package logs as several components, so each can be turned up on its own.
+var (
+    runtimeLog   = logging.For("runtime")   // containers and node lifecycle
+    schedulerLog = logging.For("scheduler") // placing, evicting and rescheduling pods
+    healthLog    = logging.For("health")    // heartbeats and reachability
+    networkLog   = logging.For("network")   // links and partitions
+    storageLog   = logging.For("storage")   // volumes and claims
+)
+
+// startDockerCall starts the span of a Docker API call. The returned function
+// ends it with the call's error and records the call's latency and outcome.
+func startDockerCall(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+    start := time.Now()
+    ctx, span := tracer.Start(ctx, "docker "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+    return ctx, func(err error) {
+        metrics.ObserveDockerCall(operation, start, err)
+        tracing.End(span, err)
+    }
+}
+
 // Node structure to store node information
 type Node struct {
     ID     string `json:"id"`
@@ -18,52 +50,273 @@ type Node struct {
     Status string `json:"status"`
     Pods   []string `json:"pods"` 
     CreatedAt time.Time `json:"created_at"`// List of Pod IDs running on the node
+    Labels map[string]string `json:"labels,omitempty"`
+    Unschedulable bool `json:"unschedulable"` // Set by cordon; the scheduler skips the node
+    Taints []Taint `json:"taints,omitempty"`
+    LastHeartbeat time.Time `json:"last_heartbeat"` // When the node's last heartbeat reached the control plane
+    StalePods []string `json:"stale_pods,omitempty"` // Pods moved away while the node was unreachable, which it may still run
+    PodCIDR string `json:"pod_cidr,omitempty"` // Range the node's pod addresses come from
+    MaxPods int `json:"max_pods,omitempty"` // Pod addresses in PodCIDR; 0 means unlimited
+    MemoryMiB int `json:"memory_mib,omitempty"` // Memory capacity, from the node's template
+    Template string `json:"template,omitempty"` // Node template the node was created from
+    Container ContainerSpec `json:"container"` // What the node's container runs, also after a restart
+}
+
+// Taint repels pods that do not tolerate it. Effects are "NoSchedule" and
+// "NoExecute", which both keep new pods off the node, and "PreferNoSchedule",
+// which the scheduler ignores.
+type Taint struct {
+    Key    string `json:"key"`
+    Value  string `json:"value,omitempty"`
+    Effect string `json:"effect"`
+}
+
+// clusterDNS is the resolver configuration given to new node containers.
+// It stays empty, leaving Docker's default, until SetClusterDNS is called.
+var clusterDNS struct {
+    sync.Mutex
+    servers []string
+    search  []string
+}
+
+// SetClusterDNS makes node containers created from now on resolve names
+// through the given nameservers, trying the search domains for short names.
+func SetClusterDNS(servers, search []string) {
+    clusterDNS.Lock()
+    defer clusterDNS.Unlock()
+    clusterDNS.servers = servers
+    clusterDNS.search = search
+}
+
+// RuntimeOptions are what node containers are created from.
+type RuntimeOptions struct {
+    Image       string
+    Command     []string
+    RestartWait time.Duration // How long RestartNode waits before checking the node again
+}
+
+// DefaultRuntimeOptions returns the settings used when none are configured.
+func DefaultRuntimeOptions() RuntimeOptions {
+    return RuntimeOptions{
+        Image:       "python:3.8-slim", // Use a lightweight image
+        Command:     []string{"sh", "-c", "while true; do sleep 30; done"},
+        RestartWait: 5 * time.Second,
+    }
+}
+
+// runtimeOptions are the settings new node containers get.
+var runtimeOptions = struct {
+    sync.Mutex
+    opts RuntimeOptions
+}{opts: DefaultRuntimeOptions()}
+
+// SetRuntimeOptions replaces the settings; containers created from now on
+// run the new image and command.
+func SetRuntimeOptions(opts RuntimeOptions) {
+    runtimeOptions.Lock()
+    defer runtimeOptions.Unlock()
+    runtimeOptions.opts = opts
+}
+
+// currentRuntimeOptions returns the settings in effect.
+func currentRuntimeOptions() RuntimeOptions {
+    runtimeOptions.Lock()
+    defer runtimeOptions.Unlock()
+    return runtimeOptions.opts
+}
+
+// nodeContainerConfig returns the config of a new node container. The
+// runtime options fill in what spec leaves out.
+func nodeContainerConfig(spec ContainerSpec) *container.Config {
+    opts := currentRuntimeOptions()
+    config := &container.Config{Image: opts.Image, Cmd: opts.Command}
+    if spec.Image != "" {
+        config.Image = spec.Image
+    }
+    if len(spec.Command) > 0 {
+        config.Cmd = spec.Command
+    }
+    return config
+}
+
+// ClusterNetwork is the Docker network node containers are attached to, so
+// traffic between them and with the host can be partitioned and shaped.
+const ClusterNetwork = "cluster-sim"
+
+// clusterNetworkReady is set once EnsureClusterNetwork succeeded; until
+// then node containers use Docker's default bridge.
+var clusterNetworkReady bool
+
+// EnsureClusterNetwork creates the cluster network unless it exists.
+func EnsureClusterNetwork() error {
+    cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+    if err != nil {
+        return err
+    }
+    ctx := context.Background()
+
+    callCtx, done := startDockerCall(ctx, "network_inspect", attribute.String("network.name", ClusterNetwork))
+    _, err = cli.NetworkInspect(callCtx, ClusterNetwork, network.InspectOptions{})
+    done(err)
+    if err != nil {
+        if !client.IsErrNotFound(err) {
+            return err
+        }
+        callCtx, done = startDockerCall(ctx, "network_create", attribute.String("network.name", ClusterNetwork))
+        _, err = cli.NetworkCreate(callCtx, ClusterNetwork, network.CreateOptions{
+            Driver: "bridge",
+            Labels: map[string]string{"cluster-sim": "true"},
+        })
+        done(err)
+        if err != nil {
+            return err
+        }
+        runtimeLog.InfoContext(ctx, "Docker network created", "network", ClusterNetwork)
+    }
+    clusterDNS.Lock()
+    clusterNetworkReady = true
+    clusterDNS.Unlock()
+    return nil
+}
+
+// nodeHostConfig returns the host config for a node container, limited to
+// resources. NET_ADMIN lets the network runtime install iptables and tc
+// rules inside it.
+func nodeHostConfig(resources ContainerResources) *container.HostConfig {
+    clusterDNS.Lock()
+    defer clusterDNS.Unlock()
+    hostConfig := &container.HostConfig{CapAdd: []string{"NET_ADMIN"}}
+    hostConfig.Resources.NanoCPUs = int64(resources.CPULimit * 1e9)
+    hostConfig.Resources.Memory = int64(resources.MemoryLimitMiB) << 20
+    if clusterNetworkReady {
+        hostConfig.NetworkMode = container.NetworkMode(ClusterNetwork)
+    }
+    if len(clusterDNS.servers) > 0 {
+        hostConfig.DNS = clusterDNS.servers
+        hostConfig.DNSSearch = clusterDNS.search
+        hostConfig.DNSOptions = []string{"ndots:5"}
+    }
+    return hostConfig
+}
+
+// nodeNetworkingConfig attaches a node container to the cluster network
+// under its node ID, so nodes reach each other by name. It is nil, leaving
+// Docker's default bridge, until the network exists.
+func nodeNetworkingConfig(nodeID string) *network.NetworkingConfig {
+    clusterDNS.Lock()
+    defer clusterDNS.Unlock()
+    if !clusterNetworkReady {
+        return nil
+    }
+    return &network.NetworkingConfig{
+        EndpointsConfig: map[string]*network.EndpointSettings{
+            ClusterNetwork: {Aliases: []string{nodeID}},
+        },
+    }
+}
+
+// ContainerIP returns the address of a node container on its Docker network.
+func ContainerIP(nodeID string) (string, error) {
+    cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+    if err != nil {
+        return "", err
+    }
+
+    ctx, done := startDockerCall(context.Background(), "container_inspect", attribute.String("container.name", nodeID))
+    inspect, err := cli.ContainerInspect(ctx, nodeID)
+    done(err)
+    if err != nil {
+        return "", err
+    }
+    if inspect.NetworkSettings != nil {
+        for _, endpoint := range inspect.NetworkSettings.Networks {
+            if endpoint != nil && endpoint.IPAddress != "" {
+                return endpoint.IPAddress, nil
+            }
+        }
+    }
+    return "", fmt.Errorf("container %s has no IP address", nodeID)
+}
+
+// NetworkGateway returns the host's address on the Docker network of the
+// node containers, where they reach services running on the host.
+func NetworkGateway() (string, error) {
+    cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+    if err != nil {
+        return "", err
+    }
+
+    name := "bridge"
+    clusterDNS.Lock()
+    if clusterNetworkReady {
+        name = ClusterNetwork
+    }
+    clusterDNS.Unlock()
+
+    ctx, done := startDockerCall(context.Background(), "network_inspect", attribute.String("network.name", name))
+    bridge, err := cli.NetworkInspect(ctx, name, network.InspectOptions{})
+    done(err)
+    if err != nil {
+        return "", err
+    }
+    for _, cfg := range bridge.IPAM.Config {
+        if cfg.Gateway != "" {
+            return cfg.Gateway, nil
+        }
+    }
+    return "", fmt.Errorf("network %s has no gateway", name)
 }
 
 // Function to create a new node container
 //Name of the container is the node id
-func CreateNodeContainer(cpus int) (string, error) {
+func CreateNodeContainer(ctx context.Context, spec ContainerSpec) (string, error) {
     cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
     if err != nil {
         return "", err
     }
     containerName := fmt.Sprintf("node_container_%s", uuid.New().String())
 
+    callCtx, done := startDockerCall(ctx, "container_create", attribute.String("container.name", containerName))
     resp, err := cli.ContainerCreate(
-        context.Background(),
-        &container.Config{
-            Image: "python:3.8-slim", // Use a lightweight image
-            Cmd:   []string{"sh", "-c", "while true; do sleep 30; done"},
-        },
-        nil, nil, nil, containerName)
+        callCtx,
+        nodeContainerConfig(spec),
+        nodeHostConfig(spec.Resources), nodeNetworkingConfig(containerName), nil, containerName)
+    done(err)
     if err != nil {
         return "", err
     }
 
-    err = cli.ContainerStart(context.Background(), resp.ID, container.StartOptions{})
+    callCtx, done = startDockerCall(ctx, "container_start", attribute.String("container.name", containerName))
+    err = cli.ContainerStart(callCtx, resp.ID, container.StartOptions{})
+    done(err)
     if err != nil {
         return "", err
     }
 
     return containerName, nil
 }
-func DeleteNodeContainer(nodeID string) (error){
+func DeleteNodeContainer(ctx context.Context, nodeID string) (error){
 
     cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
     if err != nil {
         return err
     }
-    ctx := context.Background()
 
     // Attempt to stop the container (if not already stopped).  Force stop if needed.
-    if err := cli.ContainerStop(ctx, nodeID, container.StopOptions{}); err != nil {
-        log.Printf("Error stopping container %s: %v", nodeID, err)
+    callCtx, done := startDockerCall(ctx, "container_stop", attribute.String("container.name", nodeID))
+    err = cli.ContainerStop(callCtx, nodeID, container.StopOptions{})
+    done(err)
+    if err != nil {
+        runtimeLog.WarnContext(ctx, "Error stopping container", logging.NodeID(nodeID), logging.Err(err))
         // Continue even if stopping fails.
     }
     // Remove the container.
-    if err := cli.ContainerRemove(ctx,nodeID, 
+    callCtx, done = startDockerCall(ctx, "container_remove", attribute.String("container.name", nodeID))
+    err = cli.ContainerRemove(callCtx,nodeID, 
         // Force remove the container so it gets cleaned up.
-        container.RemoveOptions{Force: true}); err != nil {
+        container.RemoveOptions{Force: true})
+    done(err)
+    if err != nil {
         return err
     }
     return nil
@@ -75,20 +328,62 @@ func StopNodeContainer(nodeID string) (error){
     if err != nil {
         return err
     }
-    ctx := context.Background()
+    ctx, done := startDockerCall(context.Background(), "container_stop", attribute.String("container.name", nodeID))
 
     // Attempt to stop the container (if not already stopped).  Force stop if needed.
-    if err := cli.ContainerStop(ctx, nodeID, container.StopOptions{}); err != nil {
-        log.Printf("Error stopping container %s: %v", nodeID, err)
+    err = cli.ContainerStop(ctx, nodeID, container.StopOptions{})
+    done(err)
+    if err != nil {
+        runtimeLog.WarnContext(ctx, "Error stopping container", logging.NodeID(nodeID), logging.Err(err))
         // Continue even if stopping fails.
     }
     // Remove the container.
     return nil
 }
 
+// Function to run a command inside a node container and wait for it to exit
+// Returns the command's exit code
+func ExecInNode(ctx context.Context, nodeID string, cmd []string) (int, error) {
+    cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+    if err != nil {
+        return -1, err
+    }
+
+    callCtx, done := startDockerCall(ctx, "exec_create", attribute.String("container.name", nodeID))
+    exec, err := cli.ContainerExecCreate(callCtx, nodeID, container.ExecOptions{
+        Cmd:          cmd,
+        AttachStdout: true,
+        AttachStderr: true,
+    })
+    done(err)
+    if err != nil {
+        return -1, err
+    }
+
+    callCtx, done = startDockerCall(ctx, "exec_attach", attribute.String("container.name", nodeID))
+    attach, err := cli.ContainerExecAttach(callCtx, exec.ID, container.ExecAttachOptions{})
+    done(err)
+    if err != nil {
+        return -1, err
+    }
+    defer attach.Close()
+    // The exec finishes when its output stream ends.
+    if _, err := stdcopy.StdCopy(io.Discard, io.Discard, attach.Reader); err != nil {
+        return -1, err
+    }
+
+    callCtx, done = startDockerCall(ctx, "exec_inspect", attribute.String("container.name", nodeID))
+    inspect, err := cli.ContainerExecInspect(callCtx, exec.ID)
+    done(err)
+    if err != nil {
+        return -1, err
+    }
+    return inspect.ExitCode, nil
+}
+
 // Function to create a new node container with the same id as the failed node
 // Function to restart a node container while preserving its ID and data
-func RestartNodeContainer(nodeID string) error {
+func RestartNodeContainer(ctx context.Context, nodeID string, spec ContainerSpec) error {
     cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
     if err != nil {
         return fmt.Errorf("failed to create Docker client: %v", err)
@@ -98,18 +393,19 @@ func RestartNodeContainer(nodeID string) error {
 
     // First stop the container
 
+    callCtx, done := startDockerCall(ctx, "container_create", attribute.String("container.name", nodeID))
     resp, err := cli.ContainerCreate(
-        context.Background(),
-        &container.Config{
-            Image: "python:3.8-slim", // Use a lightweight image
-            Cmd:   []string{"sh", "-c", "while true; do sleep 30; done"},
-        },
-        nil, nil, nil, nodeID)
+        callCtx,
+        nodeContainerConfig(spec),
+        nodeHostConfig(spec.Resources), nodeNetworkingConfig(nodeID), nil, nodeID)
+    done(err)
     if err != nil {
         return err
     }
 
-    err = cli.ContainerStart(context.Background(), resp.ID, container.StartOptions{})
+    callCtx, done = startDockerCall(ctx, "container_start", attribute.String("container.name", nodeID))
+    err = cli.ContainerStart(callCtx, resp.ID, container.StartOptions{})
+    done(err)
     if err != nil {
         return err
     }
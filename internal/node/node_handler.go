@@ -2,10 +2,20 @@
 package node
 
 import (
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/labels"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/network"
 	"cluster-sim/internal/pod"
+	"cluster-sim/internal/policy"
+	"cluster-sim/internal/storage"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
   "os"
   "os/signal"
@@ -16,57 +26,119 @@ import (
 // API Handler to add a new node
 func (nm *NodeManager) AddNodeHandler(c *gin.Context) {
 	var request struct {
-		CPUs int `json:"cpus"`
+		NodeSpec
+		Count *int `json:"count"` // nodes to create alike; 1 when left out
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	id, err := CreateNodeContainer(request.CPUs)
+	if request.CPUs < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cpus must not be negative"})
+		return
+	}
+	if request.Template != "" {
+		if _, err := nm.GetNodeTemplate(request.Template); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Node template not found", "template": request.Template})
+			return
+		}
+	} else if request.CPUs <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cpus must be positive unless a template is given"})
+		return
+	}
+	count := 1
+	if request.Count != nil {
+		count = *request.Count
+	}
+	if count < 1 || count > MaxNodeCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be from 1 to %d", MaxNodeCount)})
+		return
+	}
+	if count > 1 {
+		nm.addNodes(c, request.NodeSpec, count)
+		return
+	}
+	newNode, err := nm.CreateNode(c.Request.Context(), request.NodeSpec)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	newNode := Node{
-		ID:        id,
-		CPUs:      request.CPUs,
-		UsedCPUs:  0,
-		Status:    "Running",
-		Pods:      []string{},
-		CreatedAt: time.Now(),
-	}
-	nm.AddNode(newNode)
-	log.Printf("Node created: id=%s, cpus=%d", id, request.CPUs)
 	//Simulate Heartbeat Initialization
-	println("Simulating heartbeat initialization for node:", id)
+	runtimeLog.DebugContext(c.Request.Context(), "Simulating heartbeat initialization", logging.NodeID(newNode.ID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Node added", "node_id": newNode.ID})
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Node added", "node_id": id})
+// addNodes creates count nodes in parallel and reports the ones that
+// failed next to the ones that were created. Only when every one failed is
+// the request an error.
+func (nm *NodeManager) addNodes(c *gin.Context, spec NodeSpec, count int) {
+	created, failed := nm.CreateNodes(c.Request.Context(), spec, count)
+	ids := make([]string, 0, len(created))
+	for _, n := range created {
+		ids = append(ids, n.ID)
+	}
+	sort.Strings(ids)
+	errs := make([]string, 0, len(failed))
+	for _, err := range failed {
+		errs = append(errs, err.Error())
+	}
+	if len(created) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("none of %d nodes could be added: %s", count, errs[0]), "failed": errs})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   fmt.Sprintf("%d of %d nodes added", len(created), count),
+		"requested": count,
+		"node_ids":  ids,
+		"failed":    errs,
+	})
 }
 
 // API Handler to list all nodes with health status
 func (nm *NodeManager) ListNodesHandler(c *gin.Context) {
-	responseNodes := make([]gin.H, 0, len(nm.Nodes))
+	selector, err := labels.Parse(c.Query("labelSelector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	nodes := nm.GetNodes()
+	responseNodes := make([]gin.H, 0, len(nodes))
 	for _, node := range nodes {
+		if !selector.Matches(node.Labels) {
+			continue
+		}
 		// Check node health
-		healthy, err := checkNodeHealth(node.ID)
+		healthy, err := CheckNodeHealth(c.Request.Context(), node.ID)
 		if err != nil {
 			// Log the error but continue
-			println("Error checking node health:", err.Error())
+			healthLog.WarnContext(c.Request.Context(), "Error checking node health", logging.NodeID(node.ID), logging.Err(err))
 			node.Status = "Unhealthy" // Or "Error"
 		} else if healthy {
-			node.Status = "Running"
+			if node.Status != "Unreachable" {
+				node.Status = "Running"
+			}
 		} else {
 			node.Status = "Stopped"
 		}
 		//log each node details
-		log.Printf("Listing node: id=%s, cpus=%d, used_cpus=%d, status=%s", node.ID, node.CPUs, node.UsedCPUs, node.Status)
+		runtimeLog.DebugContext(c.Request.Context(), "Listing node", logging.NodeID(node.ID), "cpus", node.CPUs, "used_cpus", node.UsedCPUs, "status", node.Status)
 		responseNodes = append(responseNodes, gin.H{
-			"id":        node.ID,
-			"cpus":      node.CPUs,
-			"used_cpus": node.UsedCPUs,
-			"status":    node.Status,
-			"pods":      node.Pods,
+			"id":            node.ID,
+			"cpus":          node.CPUs,
+			"used_cpus":     node.UsedCPUs,
+			"status":        node.Status,
+			"pods":          node.Pods,
+			"labels":        node.Labels,
+			"created_at":    node.CreatedAt,
+			"unschedulable": node.Unschedulable,
+			"taints":        node.Taints,
+			"last_heartbeat": node.LastHeartbeat,
+			"stale_pods":    node.StalePods,
+			"pod_cidr":      node.PodCIDR,
+			"max_pods":      node.MaxPods,
+			"memory_mib":    node.MemoryMiB,
+			"template":      node.Template,
 		})
 	}
 
@@ -75,35 +147,325 @@ func (nm *NodeManager) ListNodesHandler(c *gin.Context) {
 
 // API Handler to add a new pod
 func (nm *NodeManager) AddPodHandler(c *gin.Context) {
-	var request struct {
-		CPUs      int    `json:"cpus"`
-		Algorithm string `json:"algorithm"`
-	}
+	var request PodSpec
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
+	newPod, err := nm.CreatePodFor(c.Request.Context(), request, auth.RequestUser(c))
+	if errors.Is(err, ErrPodExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "pod_id": request.Name})
+		return
+	}
+	if errors.Is(err, ErrPodRejected) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "pod_id": request.Name})
+		return
+	}
+	if errors.Is(err, ErrUnknownAlgorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "pod_id": request.Name})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "pod_id": newPod.ID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pod scheduled", "node_id": newPod.NodeID, "pod_id": newPod.ID})
+}
+
+// API Handler to delete a pod
+func (nm *NodeManager) DeletePodHandler(c *gin.Context) {
+	if err := nm.DeletePod(c.Param("id")); err != nil {
+		if errors.Is(err, ErrPodNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pod not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pod deleted", "pod_id": c.Param("id")})
+}
+
+// API Handler to list all pods, or those of one namespace with ?namespace=
+func (nm *NodeManager) ListPodsHandler(c *gin.Context) {
+	selector, err := labels.Parse(c.Query("labelSelector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	namespace := c.Query("namespace")
+
 	nm.Mu.Lock()
-	defer nm.Mu.Unlock()
+	pods := make([]pod.Pod, 0, len(nm.Pods))
+	for _, p := range nm.Pods {
+		if (namespace == "" || namespaceOf(p) == namespace) && selector.Matches(p.Labels) {
+			pods = append(pods, p)
+		}
+	}
+	nm.Mu.Unlock()
 
-	// Create a pod
-	newPod := pod.CreatePod(request.CPUs)
-	log.Printf("Pod created (pending): id=%s, cpus=%d", newPod.ID, request.CPUs)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].ID < pods[j].ID })
+	c.JSON(http.StatusOK, pods)
+}
 
-	// Schedule the pod
-	nodeID, err := SchedulePod(newPod, nm.Nodes, request.Algorithm)
+// API Handler to describe a single node
+func (nm *NodeManager) DescribeNodeHandler(c *gin.Context) {
+	desc, exists := nm.DescribeNode(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+		return
+	}
+	c.JSON(http.StatusOK, desc)
+}
+
+// API Handler to describe a single pod, including why it is pending
+func (nm *NodeManager) DescribePodHandler(c *gin.Context) {
+	desc, exists := nm.DescribePod(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pod not found"})
+		return
+	}
+	c.JSON(http.StatusOK, desc)
+}
+
+// API Handler to mark a node unschedulable
+func (nm *NodeManager) CordonNodeHandler(c *gin.Context) {
+	if err := nm.Cordon(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node cordoned", "node_id": c.Param("id")})
+}
+
+// API Handler to mark a node schedulable again
+func (nm *NodeManager) UncordonNodeHandler(c *gin.Context) {
+	if err := nm.Uncordon(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node uncordoned", "node_id": c.Param("id")})
+}
+
+// API Handler to cordon a node and evict all of its pods
+func (nm *NodeManager) DrainNodeHandler(c *gin.Context) {
+	request := struct {
+		TimeoutSeconds     int  `json:"timeout_seconds"`
+		Force              bool `json:"force"`
+		DryRun             bool `json:"dry_run"`
+		GracePeriodSeconds int  `json:"grace_period_seconds"`
+	}{GracePeriodSeconds: -1}
+	// An empty body drains with the defaults.
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	result, err := nm.Drain(c.Param("id"), DrainOptions{
+		Timeout:            time.Duration(request.TimeoutSeconds) * time.Second,
+		Force:              request.Force,
+		DryRun:             request.DryRun,
+		GracePeriodSeconds: request.GracePeriodSeconds,
+	})
 	if err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, ErrNodeNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Node drained", "node_id": result.NodeID, "result": result})
+}
+
+// API Handler to evict a pod, honoring disruption budgets
+func (nm *NodeManager) EvictPodHandler(c *gin.Context) {
+	request := struct {
+		GracePeriodSeconds int `json:"grace_period_seconds"`
+	}{GracePeriodSeconds: -1}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	podID := c.Param("id")
+	newNodeID, err := nm.EvictPod(podID, request.GracePeriodSeconds)
+	var budgetErr *DisruptionBudgetError
+	switch {
+	case errors.As(err, &budgetErr):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "budget": budgetErr.Budget})
+		return
+	case errors.Is(err, ErrPodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pod not found"})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pod evicted", "pod_id": podID, "node_id": newNodeID})
+}
 
-	// Update the pod's NodeID
-	newPod.NodeID = nodeID
-	newPod.Status = "Running" // Update pod status
-	log.Printf("Pod scheduled: pod_id=%s, assigned_node=%s", newPod.ID, nodeID)
-	nm.Pods[newPod.ID] = newPod
-	c.JSON(http.StatusOK, gin.H{"message": "Pod scheduled", "node_id": nodeID, "pod_id": newPod.ID})
+// API Handler to create or replace a PodDisruptionBudget
+func (nm *NodeManager) AddPDBHandler(c *gin.Context) {
+	var budget policy.PodDisruptionBudget
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := nm.SetPDB(budget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedulerLog.InfoContext(c.Request.Context(), "PodDisruptionBudget set", "pdb", budget.Name, "selector", budget.Selector)
+	c.JSON(http.StatusOK, gin.H{"message": "PodDisruptionBudget saved", "name": budget.Name})
+}
+
+// API Handler to list PodDisruptionBudgets with their current status
+func (nm *NodeManager) ListPDBsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.ListPDBs())
+}
+
+// API Handler to delete a PodDisruptionBudget
+func (nm *NodeManager) DeletePDBHandler(c *gin.Context) {
+	if !nm.DeletePDB(c.Param("name")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PodDisruptionBudget not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "PodDisruptionBudget deleted", "name": c.Param("name")})
+}
+
+// API Handler to create a persistent volume claim
+func (nm *NodeManager) AddPVCHandler(c *gin.Context) {
+	var claim storage.PersistentVolumeClaim
+	if err := c.ShouldBindJSON(&claim); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	claim.Owner = ""
+	if err := nm.CreateClaim(claim); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "PersistentVolumeClaim created", "name": claim.Name})
+}
+
+// API Handler to list persistent volume claims and the pods using them
+func (nm *NodeManager) ListPVCsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.ListClaims())
+}
+
+// API Handler to delete a persistent volume claim no pod is using
+func (nm *NodeManager) DeletePVCHandler(c *gin.Context) {
+	if err := nm.DeleteClaim(c.Param("name")); err != nil {
+		if errors.Is(err, ErrClaimNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "PersistentVolumeClaim not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "PersistentVolumeClaim deleted", "name": c.Param("name")})
+}
+
+// API Handler to create a persistent volume
+func (nm *NodeManager) AddPVHandler(c *gin.Context) {
+	var volume storage.PersistentVolume
+	if err := c.ShouldBindJSON(&volume); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := nm.CreateVolume(volume); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "PersistentVolume created", "name": volume.Name})
+}
+
+// API Handler to list persistent volumes
+func (nm *NodeManager) ListPVsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.ListVolumes())
+}
+
+// API Handler to delete a persistent volume that is not bound
+func (nm *NodeManager) DeletePVHandler(c *gin.Context) {
+	if err := nm.DeleteVolume(c.Param("name")); err != nil {
+		if errors.Is(err, ErrVolumeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "PersistentVolume not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "PersistentVolume deleted", "name": c.Param("name")})
+}
+
+// API Handler to create or replace a storage class
+func (nm *NodeManager) AddStorageClassHandler(c *gin.Context) {
+	var class storage.StorageClass
+	if err := c.ShouldBindJSON(&class); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := nm.SetStorageClass(class); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "StorageClass saved", "name": class.Name})
+}
+
+// API Handler to create or replace a node template
+func (nm *NodeManager) AddNodeTemplateHandler(c *gin.Context) {
+	var template NodeTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := nm.SetNodeTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "NodeTemplate saved", "name": template.Name})
+}
+
+// API Handler to list node templates
+func (nm *NodeManager) ListNodeTemplatesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.ListNodeTemplates())
+}
+
+// API Handler to show a node template
+func (nm *NodeManager) GetNodeTemplateHandler(c *gin.Context) {
+	template, err := nm.GetNodeTemplate(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// API Handler to delete a node template
+func (nm *NodeManager) DeleteNodeTemplateHandler(c *gin.Context) {
+	if err := nm.DeleteNodeTemplate(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "NodeTemplate deleted", "name": c.Param("name")})
+}
+
+// API Handler to list storage classes
+func (nm *NodeManager) ListStorageClassesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.ListStorageClasses())
+}
+
+// API Handler to delete a storage class
+func (nm *NodeManager) DeleteStorageClassHandler(c *gin.Context) {
+	if err := nm.DeleteStorageClass(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "StorageClass not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "StorageClass deleted", "name": c.Param("name")})
 }
 
 func (nm *NodeManager) RestartNodeHandler(c *gin.Context) {
@@ -115,7 +477,7 @@ func (nm *NodeManager) RestartNodeHandler(c *gin.Context) {
 		return
 	}
 
-	if err := nm.RestartNode(request.NodeID); err != nil {
+	if err := nm.RestartNode(c.Request.Context(), request.NodeID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -131,48 +493,107 @@ func (nm *NodeManager) DeleteNodeHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	// Create a Docker client.
-	// Remove the container.
-	if err := DeleteNodeContainer(request.NodeID); err != nil {
+	if err := nm.RemoveNode(c.Request.Context(), request.NodeID); err != nil {
+		if errors.Is(err, ErrNodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	log.Printf("Docker container %s removed", request.NodeID)
-
-	nm.Mu.Lock()
-	nodeObj, exists := nm.Nodes[request.NodeID]
-	if !exists {
-		nm.Mu.Unlock()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
-		return
-	}
-	delete(nm.Nodes, request.NodeID)
-	nm.totalCPUs -= nodeObj.CPUs
-	nm.Mu.Unlock()
-
-	log.Printf("Node %s deleted", request.NodeID)
-	nm.reschedulePods(request.NodeID)
 	c.JSON(http.StatusOK, gin.H{"message": "Node deleted and pods rescheduled", "node_id": request.NodeID})
 }
-func (nm *NodeManager) ShutdownHandler(srv *http.Server) {
+func (nm *NodeManager) ShutdownHandler(srv *http.Server, timeout time.Duration) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit // Wait for shutdown signal
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
 	// Create a context with timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// Gracefully stop the HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		logging.Fatal(slog.Default(), "Server forced to shutdown", logging.Err(err))
 	}
 
 	// Shutdown all nodes before exiting
-	log.Println("Shutting down nodes...")
+	slog.Info("Shutting down nodes")
 	nm.ShutdownNodes()
 
-	log.Println("Server exited cleanly.")
+	slog.Info("Server exited cleanly")
+}
+
+// API Handler to show the network model and the rules applied to each node
+func (nm *NodeManager) NetworkStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, nm.NetworkStatus())
+}
+
+// API Handler to partition, delay or make lossy the link between two endpoints
+func (nm *NodeManager) SetLinkHandler(c *gin.Context) {
+	var link network.Link
+	if err := c.ShouldBindJSON(&link); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := nm.SetLink(link); err != nil {
+		if errors.Is(err, ErrNodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	saved := nm.Network.Link(link.A, link.B)
+	c.JSON(http.StatusOK, gin.H{"message": "Link saved", "name": saved.A + "<->" + saved.B, "link": saved})
+}
+
+// API Handler to make the link between two endpoints healthy again
+func (nm *NodeManager) HealLinkHandler(c *gin.Context) {
+	a, b := c.Param("a"), c.Param("b")
+	if !nm.Network.Heal(a, b) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link is already healthy"})
+		return
+	}
+	networkLog.InfoContext(c.Request.Context(), "Network link healed", "a", a, "b", b)
+	c.JSON(http.StatusOK, gin.H{"message": "Link healed", "name": a + "<->" + b})
+}
+
+// API Handler to cut a group of nodes off from the rest of the cluster
+func (nm *NodeManager) PartitionHandler(c *gin.Context) {
+	var request struct {
+		Nodes []string `json:"nodes"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	cut, err := nm.Partition(request.Nodes)
+	switch {
+	case errors.Is(err, ErrNodeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Nodes partitioned", "nodes": strings.Join(request.Nodes, ","), "links": cut})
+}
+
+// API Handler to make every link healthy again
+func (nm *NodeManager) HealNetworkHandler(c *gin.Context) {
+	healed := nm.Network.HealAll()
+	networkLog.InfoContext(c.Request.Context(), "Network healed", "links", healed)
+	c.JSON(http.StatusOK, gin.H{"message": "Network healed", "healed": healed})
+}
+
+// API Handler to show the cluster CIDR and how much of each node's pod CIDR is in use
+func (nm *NodeManager) IPAMHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"cluster_cidr":       nm.IPAM.ClusterCIDR(),
+		"addresses_per_node": nm.IPAM.Capacity(),
+		"nodes":              nm.IPAM.Usage(),
+	})
 }
@@ -1,28 +1,84 @@
 package node
 import (
+	"cluster-sim/internal/auth"
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/ipam"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/network"
 	"cluster-sim/internal/pod"
+	"cluster-sim/internal/policy"
+	"cluster-sim/internal/storage"
+	"cluster-sim/internal/tracing"
 	"context"
-	"log"
+	"errors"
+	"sort"
 	"sync"
 	"time"
   "fmt"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
+// ErrNodeNotFound is returned when an operation names a node that does not exist
+var ErrNodeNotFound = errors.New("node not found")
+
+// ErrPodNotFound is returned when an operation names a pod that does not exist
+var ErrPodNotFound = errors.New("pod not found")
+
+// ErrPodExists is returned when a pod is created with the name of an existing pod
+var ErrPodExists = errors.New("pod already exists")
+
+// ErrPodRejected is returned when admission control refuses to create a pod
+var ErrPodRejected = errors.New("pod rejected by admission")
+
+// ErrUnknownAlgorithm is returned when a pod asks for an algorithm that is
+// neither built in nor a scheduler profile
+var ErrUnknownAlgorithm = errors.New("unknown scheduling algorithm or profile")
+
+// PodAdmitter reviews a pod before it is created. It may change the spec,
+// e.g. to fill in defaults, and rejects the pod by returning an error. user
+// is who asked for the pod, or nil for pods created by controllers.
+type PodAdmitter interface {
+    AdmitPod(spec *PodSpec, user *auth.User) error
+}
+
 // NodeManager manages the nodes in the cluster
 type NodeManager struct {
     Nodes map[string]Node
     Pods map[string]pod.Pod
     Mu    sync.Mutex // Protects concurrent access to the nodes map
+    PDBs map[string]policy.PodDisruptionBudget // Disruption budgets by name
+    Claims map[string]storage.PersistentVolumeClaim // Persistent volume claims by name
+    Volumes map[string]storage.PersistentVolume // Persistent volumes by name
+    StorageClasses map[string]storage.StorageClass // Storage classes by name
+    Templates map[string]NodeTemplate // Node templates by name
+    Events *events.Recorder // Records significant node and pod occurrences
+    Network *network.Model // Links between the control plane and nodes that are partitioned, delayed or lossy
+    IPAM *ipam.Allocator // Pod CIDRs of the nodes and the addresses of their pods
+    Admission PodAdmitter // Reviews every pod before it is created; nil admits all
     totalCPUs int //Simulate resource pool
+    started map[string]*podRun // Pod ID -> current run of a pod that runs to completion
+    enforcer *networkEnforcer // Set by StartNetworkEnforcer
 }
 
 // NewNodeManager creates a new NodeManager
 func NewNodeManager() *NodeManager {
+    // The defaults are always valid.
+    allocator, _ := ipam.New(ipam.DefaultClusterCIDR, ipam.DefaultNodeMaskSize)
     return &NodeManager{
         Nodes: make(map[string]Node),
         Pods:  make(map[string]pod.Pod),
+        PDBs:  make(map[string]policy.PodDisruptionBudget),
+        Claims: make(map[string]storage.PersistentVolumeClaim),
+        Volumes: make(map[string]storage.PersistentVolume),
+        StorageClasses: make(map[string]storage.StorageClass),
+        Templates: make(map[string]NodeTemplate),
+        Events: events.NewRecorder(events.DefaultTTL),
+        Network: network.NewModel(),
+        IPAM: allocator,
         totalCPUs: 0,
+        started: make(map[string]*podRun),
     }
 }
 
@@ -30,10 +86,327 @@ func NewNodeManager() *NodeManager {
 func (nm *NodeManager) AddNode(node Node) {
     nm.Mu.Lock()
     defer nm.Mu.Unlock()
+    if node.LastHeartbeat.IsZero() {
+        node.LastHeartbeat = time.Now()
+    }
     nm.Nodes[node.ID] = node
     nm.totalCPUs += node.CPUs // Simulate resource allocation
 }
 
+// NodeSpec describes a node to create.
+type NodeSpec struct {
+    CPUs   int               `json:"cpus"`
+    Labels map[string]string `json:"labels"`
+    Taints []Taint           `json:"taints"`
+    Template string          `json:"template,omitempty"` // Node template to start from
+}
+
+// CreateNode starts a node container, registers the node and tries to place
+// any pods that are waiting for capacity on it. A node created from a
+// template registers once the template's startup delay has passed.
+func (nm *NodeManager) CreateNode(ctx context.Context, spec NodeSpec) (_ Node, err error) {
+    spec, template, err := nm.applyTemplate(spec)
+    if err != nil {
+        return Node{}, err
+    }
+    ctx, span := tracer.Start(ctx, "CreateNode", trace.WithAttributes(attribute.Int("node.cpus", spec.CPUs), attribute.String("node.template", template.Name)))
+    defer func() { tracing.End(span, err) }()
+
+    id, err := CreateNodeContainer(ctx, template.ContainerSpec)
+    if err != nil {
+        return Node{}, err
+    }
+    span.SetAttributes(attribute.String("node.id", id))
+    if err := waitForStartup(ctx, time.Duration(template.StartupDelayMillis)*time.Millisecond); err != nil {
+        if err := DeleteNodeContainer(context.WithoutCancel(ctx), id); err != nil {
+            runtimeLog.ErrorContext(ctx, "Error removing container", logging.NodeID(id), logging.Err(err))
+        }
+        return Node{}, err
+    }
+    podCIDR, err := nm.IPAM.AssignNode(id)
+    if err != nil {
+        // Without a pod CIDR the node could not run any pod.
+        if err := DeleteNodeContainer(context.WithoutCancel(ctx), id); err != nil {
+            runtimeLog.ErrorContext(ctx, "Error removing container", logging.NodeID(id), logging.Err(err))
+        }
+        return Node{}, err
+    }
+    newNode := Node{
+        ID:        id,
+        CPUs:      spec.CPUs,
+        UsedCPUs:  0,
+        Status:    "Running",
+        Pods:      []string{},
+        CreatedAt: time.Now(),
+        Labels:    spec.Labels,
+        Taints:    spec.Taints,
+        PodCIDR:   podCIDR,
+        MaxPods:   nm.IPAM.Capacity(),
+        MemoryMiB: template.MemoryMiB,
+        Template:  template.Name,
+        Container: template.ContainerSpec,
+    }
+    nm.AddNode(newNode)
+    runtimeLog.InfoContext(ctx, "Node created", logging.NodeID(id), "cpus", spec.CPUs, "template", template.Name, "pod_cidr", podCIDR)
+    nm.Events.Eventf(events.KindNode, id, events.TypeNormal, "RegisteredNode", "Node registered with %d CPUs", spec.CPUs)
+    nm.schedulePendingPods(ctx)
+    return newNode, nil
+}
+
+// RemoveNode deletes a node's container, forgets the node and reschedules
+// the pods that were running on it.
+func (nm *NodeManager) RemoveNode(ctx context.Context, nodeID string) (err error) {
+    ctx, span := tracer.Start(ctx, "RemoveNode", trace.WithAttributes(attribute.String("node.id", nodeID)))
+    defer func() { tracing.End(span, err) }()
+
+    nm.Mu.Lock()
+    _, exists := nm.Nodes[nodeID]
+    nm.Mu.Unlock()
+    if !exists {
+        return ErrNodeNotFound
+    }
+
+    if err := DeleteNodeContainer(ctx, nodeID); err != nil {
+        return err
+    }
+    runtimeLog.DebugContext(ctx, "Docker container removed", logging.NodeID(nodeID))
+
+    nm.Mu.Lock()
+    nodeObj, exists := nm.Nodes[nodeID]
+    if !exists {
+        nm.Mu.Unlock()
+        return ErrNodeNotFound
+    }
+    delete(nm.Nodes, nodeID)
+    nm.totalCPUs -= nodeObj.CPUs
+    nm.Mu.Unlock()
+
+    nm.Network.Forget(nodeID)
+    nm.IPAM.ReleaseNode(nodeID)
+    runtimeLog.InfoContext(ctx, "Node deleted", logging.NodeID(nodeID))
+    nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "Deleted", "Node deleted")
+    nm.reschedulePods(ctx, nodeID)
+    return nil
+}
+
+// PodSpec describes a pod to create.
+type PodSpec struct {
+    Name               string            `json:"name"` // Stable pod ID; a random one is generated when empty
+    Namespace          string            `json:"namespace"`
+    CPUs               int               `json:"cpus"`
+    Algorithm          string            `json:"algorithm"`
+    Labels             map[string]string `json:"labels"`
+    GracePeriodSeconds int               `json:"termination_grace_period_seconds"`
+    Tolerations        []pod.Toleration  `json:"tolerations"`
+    RunSeconds         int               `json:"run_seconds"`
+    Command            []string          `json:"command"`
+    FailureRate        float64           `json:"failure_rate"`
+    NodeName           string            `json:"node_name"`
+    Claims             []string          `json:"claims"`
+    Owner              string            `json:"-"`
+}
+
+// CreatePod creates a pod and schedules it. If no node can take the pod it is
+// kept Pending and the scheduling error is returned along with it. A named
+// pod is refused with ErrPodExists while a pod of that name exists, and one
+// admission rejects with ErrPodRejected.
+func (nm *NodeManager) CreatePod(spec PodSpec) (pod.Pod, error) {
+    return nm.CreatePodFor(context.Background(), spec, nil)
+}
+
+// CreatePodFor is CreatePod for a pod a user asked for, so admission can
+// take the user into account. Its spans join the trace of ctx.
+func (nm *NodeManager) CreatePodFor(ctx context.Context, spec PodSpec, user *auth.User) (_ pod.Pod, err error) {
+    ctx, span := tracer.Start(ctx, "CreatePod", trace.WithAttributes(
+        attribute.String("pod.name", spec.Name), attribute.String("pod.namespace", spec.Namespace), attribute.Int("pod.cpus", spec.CPUs)))
+    defer func() { tracing.End(span, err) }()
+
+    if spec.Algorithm != "" && !knownAlgorithm(spec.Algorithm) {
+        return pod.Pod{}, fmt.Errorf("%w %q", ErrUnknownAlgorithm, spec.Algorithm)
+    }
+
+    // Admission may call webhooks, so it runs without the lock.
+    if nm.Admission != nil {
+        _, admission := tracer.Start(ctx, "AdmitPod")
+        err := nm.Admission.AdmitPod(&spec, user)
+        tracing.End(admission, err)
+        if err != nil {
+            schedulerLog.InfoContext(ctx, "Pod rejected by admission", "pod", spec.Name, logging.Err(err))
+            return pod.Pod{}, fmt.Errorf("%w: %v", ErrPodRejected, err)
+        }
+    }
+
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+
+    if _, exists := nm.Pods[spec.Name]; exists {
+        return pod.Pod{}, ErrPodExists
+    }
+    newPod := pod.CreatePod(spec.CPUs)
+    if spec.Name != "" {
+        newPod.ID = spec.Name
+    }
+    newPod.Namespace = spec.Namespace
+    newPod.Labels = spec.Labels
+    newPod.GracePeriodSeconds = spec.GracePeriodSeconds
+    newPod.Tolerations = spec.Tolerations
+    newPod.Algorithm = spec.Algorithm
+    newPod.Owner = spec.Owner
+    newPod.RunSeconds = spec.RunSeconds
+    newPod.Command = spec.Command
+    newPod.FailureRate = spec.FailureRate
+    newPod.NodeName = spec.NodeName
+    newPod.Claims = spec.Claims
+    span.SetAttributes(attribute.String("pod.id", newPod.ID))
+    schedulerLog.DebugContext(ctx, "Pod created (pending)", logging.PodID(newPod.ID), "cpus", spec.CPUs)
+
+    nodeID, err := nm.schedulePodLocked(ctx, newPod, spec.Algorithm)
+    if err != nil {
+        // Keep the pod around as Pending so it can be described and explained.
+        nm.Pods[newPod.ID] = newPod
+        nm.Events.Eventf(events.KindPod, newPod.ID, events.TypeWarning, "FailedScheduling", "%v", err)
+        return newPod, err
+    }
+
+    nm.bindPodLocked(&newPod, nodeID)
+    schedulerLog.InfoContext(ctx, "Pod scheduled", logging.PodID(newPod.ID), logging.NodeID(nodeID))
+    nm.Events.Eventf(events.KindPod, newPod.ID, events.TypeNormal, "Scheduled", "Successfully assigned pod to node %s", nodeID)
+    nm.Pods[newPod.ID] = newPod
+    return newPod, nil
+}
+
+// DeletePod removes a pod, frees its CPUs and gives the capacity to any
+// pending pods.
+func (nm *NodeManager) DeletePod(podID string) error {
+    nm.Mu.Lock()
+    p, exists := nm.Pods[podID]
+    if !exists {
+        nm.Mu.Unlock()
+        return ErrPodNotFound
+    }
+    nm.unbindPodLocked(p)
+    delete(nm.Pods, podID)
+    nm.Mu.Unlock()
+
+    schedulerLog.Info("Pod deleted", logging.PodID(podID))
+    nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Killing", "Pod deleted")
+    if p.NodeID != "" {
+        nm.SchedulePendingPods()
+    }
+    return nil
+}
+
+// namespaceOf returns the namespace of a pod, which is "default" for pods
+// created before admission filled one in.
+func namespaceOf(p pod.Pod) string {
+    if p.Namespace == "" {
+        return auth.DefaultNamespace
+    }
+    return p.Namespace
+}
+
+// PodNamespace returns the namespace of a pod, for authorizing requests
+// that name it.
+func (nm *NodeManager) PodNamespace(podID string) (string, bool) {
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+    p, exists := nm.Pods[podID]
+    if !exists {
+        return "", false
+    }
+    return namespaceOf(p), true
+}
+
+// SetPodUtilization records a pod's simulated CPU utilization.
+func (nm *NodeManager) SetPodUtilization(podID string, utilization float64) error {
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+    p, exists := nm.Pods[podID]
+    if !exists {
+        return ErrPodNotFound
+    }
+    p.Utilization = utilization
+    nm.Pods[podID] = p
+    return nil
+}
+
+// PodsOwnedBy returns the pods managed by owner, oldest first.
+func (nm *NodeManager) PodsOwnedBy(owner string) []pod.Pod {
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+    var owned []pod.Pod
+    for _, p := range nm.Pods {
+        if p.Owner == owner {
+            owned = append(owned, p)
+        }
+    }
+    sort.Slice(owned, func(i, j int) bool {
+        if !owned[i].CreatedAt.Equal(owned[j].CreatedAt) {
+            return owned[i].CreatedAt.Before(owned[j].CreatedAt)
+        }
+        return owned[i].ID < owned[j].ID
+    })
+    return owned
+}
+
+// PendingPods returns the pods waiting for a node.
+func (nm *NodeManager) PendingPods() []pod.Pod {
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+    return nm.pendingPodsLocked()
+}
+
+// pendingPodsLocked returns the pending pods oldest first. nm.Mu must be held.
+func (nm *NodeManager) pendingPodsLocked() []pod.Pod {
+    var pending []pod.Pod
+    for _, p := range nm.Pods {
+        if p.Status == "Pending" && p.NodeID == "" {
+            pending = append(pending, p)
+        }
+    }
+    sort.Slice(pending, func(i, j int) bool {
+        if !pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+            return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+        }
+        return pending[i].ID < pending[j].ID
+    })
+    return pending
+}
+
+// SchedulePendingPods retries every pending pod, oldest first, and returns
+// how many were placed.
+func (nm *NodeManager) SchedulePendingPods() int {
+    return nm.schedulePendingPods(context.Background())
+}
+
+// schedulePendingPods is SchedulePendingPods as part of the trace of ctx.
+func (nm *NodeManager) schedulePendingPods(ctx context.Context) int {
+    nm.Mu.Lock()
+    defer nm.Mu.Unlock()
+
+    pending := nm.pendingPodsLocked()
+    if len(pending) == 0 {
+        return 0
+    }
+    ctx, span := tracer.Start(ctx, "SchedulePendingPods", trace.WithAttributes(attribute.Int("pods.pending", len(pending))))
+    defer span.End()
+
+    scheduled := 0
+    for _, p := range pending {
+        nodeID, err := nm.schedulePodLocked(ctx, p, p.Algorithm)
+        if err != nil {
+            continue
+        }
+        nm.bindPodLocked(&p, nodeID)
+        nm.Pods[p.ID] = p
+        scheduled++
+        schedulerLog.InfoContext(ctx, "Pending pod scheduled", logging.PodID(p.ID), logging.NodeID(nodeID))
+        nm.Events.Eventf(events.KindPod, p.ID, events.TypeNormal, "Scheduled", "Successfully assigned pod to node %s", nodeID)
+    }
+    span.SetAttributes(attribute.Int("pods.scheduled", scheduled))
+    return scheduled
+}
+
 // GetNodes returns all nodes in the cluster
 func (nm *NodeManager) GetNodes() map[string]Node {
     nm.Mu.Lock()
@@ -41,14 +414,16 @@ func (nm *NodeManager) GetNodes() map[string]Node {
     return nm.Nodes
 }
 
-// checkNodeHealth checks if a node's container is running
-func checkNodeHealth(containerID string) (bool, error) {
+// CheckNodeHealth checks if a node's container is running
+func CheckNodeHealth(ctx context.Context, containerID string) (bool, error) {
     cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
     if err != nil {
         return false, err
     }
 
-    inspect, err := cli.ContainerInspect(context.Background(), containerID)
+    ctx, done := startDockerCall(ctx, "container_inspect", attribute.String("container.name", containerID))
+    inspect, err := cli.ContainerInspect(ctx, containerID)
+    done(err)
     if err != nil {
         return false, err
     }
@@ -57,34 +432,42 @@ func checkNodeHealth(containerID string) (bool, error) {
 }
 
 
-func (nm *NodeManager) RestartNode(nodeID string) error {
-    // nm.Mu.Lock()
-    _, exists := nm.Nodes[nodeID]
-    log.Print("stuff")
-    // defer nm.Mu.Unlock()
+func (nm *NodeManager) RestartNode(ctx context.Context, nodeID string) (err error) {
+    ctx, span := tracer.Start(ctx, "RestartNode", trace.WithAttributes(attribute.String("node.id", nodeID)))
+    defer func() { tracing.End(span, err) }()
+
+    // The lock is not held across the restart: it waits for the node and
+    // may reschedule its pods, which needs the lock.
+    nm.Mu.Lock()
+    nodeObj, exists := nm.Nodes[nodeID]
+    nm.Mu.Unlock()
     if !exists {
-        return fmt.Errorf("node not found")
+        return ErrNodeNotFound
     }
-    log.Print("here")
 
     cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
     if err != nil {
         return err
     }
 
-    if err :=RestartNodeContainer(nodeID);  err != nil {
+    if err :=RestartNodeContainer(ctx, nodeID, nodeObj.Container);  err != nil {
         return err
     }
-    log.Print("done")
 
-    log.Printf("Node %s restarted", nodeID)
-    time.Sleep(5 * time.Second)
+    runtimeLog.InfoContext(ctx, "Node restarted", logging.NodeID(nodeID))
+    nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "Restarted", "Node container restarted")
+    _, wait := tracer.Start(ctx, "WaitForRestart")
+    time.Sleep(currentRuntimeOptions().RestartWait)
+    wait.End()
 
-    healthy, err := checkNodeHealth(nodeID)
+    healthy, err := CheckNodeHealth(ctx, nodeID)
     if err != nil || !healthy {
-        log.Printf("Node %s still unhealthy, removing and rescheduling...", nodeID)
-        _ = cli.ContainerRemove(context.Background(), nodeID, container.RemoveOptions{Force: true})
-        nm.reschedulePods(nodeID)
+        runtimeLog.WarnContext(ctx, "Node still unhealthy after restart, removing it and rescheduling its pods", logging.NodeID(nodeID))
+        nm.Events.Eventf(events.KindNode, nodeID, events.TypeWarning, "RestartFailed", "Node still unhealthy after restart, removing it")
+        callCtx, done := startDockerCall(ctx, "container_remove", attribute.String("container.name", nodeID))
+        err = cli.ContainerRemove(callCtx, nodeID, container.RemoveOptions{Force: true})
+        done(err)
+        nm.reschedulePods(ctx, nodeID)
         return fmt.Errorf("node restart failed and was removed")
     }
 
@@ -95,21 +478,24 @@ func (nm *NodeManager) ShutdownNodes() {
 	nm.Mu.Lock()
 	defer nm.Mu.Unlock()
 
-	log.Println("Stopping all nodes...")
+	runtimeLog.Info("Stopping all nodes")
 
 	for id := range nm.Nodes {
 		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 		if err != nil {
-			log.Printf("Error creating Docker client for node %s: %v", id, err)
+			runtimeLog.Error("Error creating Docker client", logging.NodeID(id), logging.Err(err))
 			continue
 		}
 
-		if err := cli.ContainerStop(context.Background(), id, container.StopOptions{}); err != nil {
-			log.Printf("Error stopping node %s: %v", id, err)
+		ctx, done := startDockerCall(context.Background(), "container_stop", attribute.String("container.name", id))
+		err = cli.ContainerStop(ctx, id, container.StopOptions{})
+		done(err)
+		if err != nil {
+			runtimeLog.Error("Error stopping node", logging.NodeID(id), logging.Err(err))
 		} else {
-			log.Printf("Node %s stopped", id)
+			runtimeLog.Info("Node stopped", logging.NodeID(id))
 		}
 	}
 
-	log.Println("All nodes have been stopped.")
+	runtimeLog.Info("All nodes have been stopped")
 }
@@ -0,0 +1,74 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	nodeCapacityDesc = prometheus.NewDesc(
+		"cluster_sim_node_capacity_cpus",
+		"Number of CPUs the node offers to pods.",
+		[]string{"node"}, nil)
+	nodeUsedDesc = prometheus.NewDesc(
+		"cluster_sim_node_used_cpus",
+		"Number of CPUs allocated to pods on the node.",
+		[]string{"node"}, nil)
+	nodeStatusDesc = prometheus.NewDesc(
+		"cluster_sim_nodes",
+		"Number of nodes by status.",
+		[]string{"status"}, nil)
+	podAddressesDesc = prometheus.NewDesc(
+		"cluster_sim_node_pod_addresses",
+		"Number of addresses in the node's pod CIDR, by whether a pod holds them.",
+		[]string{"node", "state"}, nil)
+	podPhaseDesc = prometheus.NewDesc(
+		"cluster_sim_pods",
+		"Number of pods by phase.",
+		[]string{"phase"}, nil)
+)
+
+// clusterCollector reports node and pod state straight from the NodeManager
+// at scrape time, so the numbers never drift from what the API returns.
+type clusterCollector struct {
+	nm *NodeManager
+}
+
+// Collector returns a Prometheus collector for the state held by nm.
+func (nm *NodeManager) Collector() prometheus.Collector {
+	return &clusterCollector{nm: nm}
+}
+
+func (c *clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodeCapacityDesc
+	ch <- nodeUsedDesc
+	ch <- nodeStatusDesc
+	ch <- podAddressesDesc
+	ch <- podPhaseDesc
+}
+
+func (c *clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	c.nm.Mu.Lock()
+	defer c.nm.Mu.Unlock()
+
+	statuses := make(map[string]int)
+	for _, n := range c.nm.Nodes {
+		ch <- prometheus.MustNewConstMetric(nodeCapacityDesc, prometheus.GaugeValue, float64(n.CPUs), n.ID)
+		ch <- prometheus.MustNewConstMetric(nodeUsedDesc, prometheus.GaugeValue, float64(n.UsedCPUs), n.ID)
+		statuses[n.Status]++
+	}
+	for _, u := range c.nm.IPAM.Usage() {
+		ch <- prometheus.MustNewConstMetric(podAddressesDesc, prometheus.GaugeValue, float64(u.Allocated), u.NodeID, "allocated")
+		ch <- prometheus.MustNewConstMetric(podAddressesDesc, prometheus.GaugeValue, float64(u.Capacity-u.Allocated), u.NodeID, "free")
+	}
+	for status, count := range statuses {
+		ch <- prometheus.MustNewConstMetric(nodeStatusDesc, prometheus.GaugeValue, float64(count), status)
+	}
+
+	phases := make(map[string]int)
+	for _, p := range c.nm.Pods {
+		phases[p.Status]++
+	}
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(podPhaseDesc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
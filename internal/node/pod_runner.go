@@ -0,0 +1,115 @@
+package node
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/pod"
+)
+
+// podRunnerInterval is how often the runner looks for newly scheduled pods
+// that run to completion.
+const podRunnerInterval = time.Second
+
+// podRun is one attempt at running a pod on a node. A pod evicted and
+// placed again gets a new podRun, so a stale run cannot complete it.
+type podRun struct {
+	nodeID string
+}
+
+// StartPodRunner begins a goroutine that plays the part of each node's
+// kubelet for pods that run to completion: once such a pod is Running it
+// sleeps for the pod's run time, or runs its command in the node container,
+// and then marks the pod Succeeded or Failed.
+func (nm *NodeManager) StartPodRunner() {
+	go func() {
+		for {
+			nm.startScheduledRuns()
+			time.Sleep(podRunnerInterval)
+		}
+	}()
+}
+
+// startScheduledRuns starts every Running batch pod whose run has not begun
+// on its current node. A pod moved to another node starts over there.
+func (nm *NodeManager) startScheduledRuns() {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	for id, run := range nm.started {
+		if p, exists := nm.Pods[id]; !exists || p.NodeID != run.nodeID || p.Status != "Running" {
+			delete(nm.started, id)
+		}
+	}
+	for id, p := range nm.Pods {
+		if p.Status != "Running" || !p.RunsToCompletion() {
+			continue
+		}
+		if _, started := nm.started[id]; started {
+			continue
+		}
+		run := &podRun{nodeID: p.NodeID}
+		nm.started[id] = run
+		now := time.Now()
+		p.StartedAt = &now
+		nm.Pods[id] = p
+		nm.Events.Eventf(events.KindPod, id, events.TypeNormal, "Started", "Started on node %s", p.NodeID)
+		go nm.runPod(p, run)
+	}
+}
+
+// runPod runs one pod to completion and records how it exited.
+func (nm *NodeManager) runPod(p pod.Pod, run *podRun) {
+	exitCode := 0
+	if len(p.Command) > 0 {
+		code, err := ExecInNode(context.Background(), p.NodeID, p.Command)
+		if err != nil {
+			runtimeLog.Warn("Failed to run pod command", logging.PodID(p.ID), logging.NodeID(p.NodeID), "command", p.Command, logging.Err(err))
+			code = 1
+		}
+		exitCode = code
+	} else {
+		time.Sleep(time.Duration(p.RunSeconds) * time.Second)
+		if rand.Float64() < p.FailureRate {
+			exitCode = 1
+		}
+	}
+	nm.completePod(p.ID, run, exitCode)
+}
+
+// completePod marks a pod Succeeded or Failed and frees its CPUs, unless the
+// run was superseded because the pod was moved or deleted while it ran.
+func (nm *NodeManager) completePod(podID string, run *podRun, exitCode int) {
+	nodeID := run.nodeID
+	nm.Mu.Lock()
+	p, exists := nm.Pods[podID]
+	if !exists || p.Status != "Running" || nm.started[podID] != run {
+		nm.Mu.Unlock()
+		return
+	}
+	nm.unbindPodLocked(p)
+	now := time.Now()
+	p.FinishedAt = &now
+	p.ExitCode = &exitCode
+	p.Utilization = 0
+	p.IP = ""
+	if exitCode == 0 {
+		p.Status = "Succeeded"
+	} else {
+		p.Status = "Failed"
+	}
+	nm.Pods[podID] = p
+	nm.Mu.Unlock()
+
+	if exitCode == 0 {
+		runtimeLog.Info("Pod succeeded", logging.PodID(podID), logging.NodeID(nodeID))
+		nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Completed", "Pod succeeded on node %s", nodeID)
+	} else {
+		runtimeLog.Warn("Pod failed", logging.PodID(podID), logging.NodeID(nodeID), "exit_code", exitCode)
+		nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "Failed", "Pod failed on node %s with exit code %d", nodeID, exitCode)
+	}
+	// The freed CPUs may fit a pod that is waiting.
+	nm.SchedulePendingPods()
+}
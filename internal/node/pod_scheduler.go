@@ -1,14 +1,156 @@
 package node
 
 import (
+    "context"
     "fmt"
-    // "time"
+    "time"
     "sort"
+    "strings"
+    "sync"
+    "cluster-sim/internal/events"
+    "cluster-sim/internal/logging"
+    "cluster-sim/internal/metrics"
     "cluster-sim/internal/pod"
+    "cluster-sim/internal/tracing"
     // "github.com/google/uuid"
-    "log"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
 )
 
+// FitPredicate returns the reason pod p cannot run on node n, or "" if it can.
+type FitPredicate func(p pod.Pod, n Node) string
+
+// predicates are the filters every scheduling algorithm applies before it
+// compares the remaining nodes.
+var predicates = []FitPredicate{nodeNameMatches, nodeIsRunning, nodeIsSchedulable, nodeTaintsTolerated, nodeHasCPU, nodeHasPodAddress}
+
+// IsDaemonSetPod reports whether p is managed by a DaemonSet. Such pods are
+// bound to their node: they tolerate cordons, are left alone by drains and go
+// away with the node.
+func IsDaemonSetPod(p pod.Pod) bool {
+    return strings.HasPrefix(p.Owner, events.KindDaemonSet+"/")
+}
+
+func nodeNameMatches(p pod.Pod, n Node) string {
+    if p.NodeName != "" && p.NodeName != n.ID {
+        return "node does not match the pod's node name"
+    }
+    return ""
+}
+
+func nodeIsRunning(p pod.Pod, n Node) string {
+    if n.Status != "Running" {
+        return fmt.Sprintf("node is %s", n.Status)
+    }
+    return ""
+}
+
+func nodeIsSchedulable(p pod.Pod, n Node) string {
+    if n.Unschedulable && !IsDaemonSetPod(p) {
+        return "node is cordoned"
+    }
+    return ""
+}
+
+func nodeTaintsTolerated(p pod.Pod, n Node) string {
+    for _, taint := range n.Taints {
+        if taint.Effect == "PreferNoSchedule" {
+            continue
+        }
+        tolerated := false
+        for _, t := range p.Tolerations {
+            if t.Tolerates(taint.Key, taint.Value, taint.Effect) {
+                tolerated = true
+                break
+            }
+        }
+        if !tolerated {
+            return fmt.Sprintf("node has untolerated taint {%s=%s:%s}", taint.Key, taint.Value, taint.Effect)
+        }
+    }
+    return ""
+}
+
+// NodeTaintsTolerated returns the first taint on node n that pod p does not
+// tolerate, or "" if it tolerates them all.
+func NodeTaintsTolerated(p pod.Pod, n Node) string {
+    return nodeTaintsTolerated(p, n)
+}
+
+func nodeHasCPU(p pod.Pod, n Node) string {
+    if available := n.CPUs - n.UsedCPUs; available < p.CPUs {
+        return fmt.Sprintf("insufficient CPU (requested %d, available %d)", p.CPUs, available)
+    }
+    return ""
+}
+
+// nodeHasPodAddress rejects a node whose pod CIDR has no address left.
+func nodeHasPodAddress(p pod.Pod, n Node) string {
+    if n.MaxPods > 0 && len(n.Pods) >= n.MaxPods {
+        return fmt.Sprintf("no free pod addresses (%d in use in %s)", len(n.Pods), n.PodCIDR)
+    }
+    return ""
+}
+
+// podFitsNode runs all predicates and returns the first rejection reason.
+func podFitsNode(p pod.Pod, n Node) string {
+    for _, fits := range predicates {
+        if reason := fits(p, n); reason != "" {
+            return reason
+        }
+    }
+    return ""
+}
+
+// PodFitsNode returns why pod p cannot run on node n, or "" if it can. It
+// lets other components simulate placement on nodes that do not exist yet.
+func PodFitsNode(p pod.Pod, n Node) string {
+    return podFitsNode(p, n)
+}
+
+// ExplainPod reports, for every node, why the scheduler would reject pod p
+// there ("" means the node fits).
+func ExplainPod(p pod.Pod, nodes map[string]Node) map[string]string {
+    return explainPodFor(p, nodes, podFitsNode)
+}
+
+func explainPodFor(p pod.Pod, nodes map[string]Node, fits FitPredicate) map[string]string {
+    reasons := make(map[string]string, len(nodes))
+    for id, n := range nodes {
+        reasons[id] = fits(p, n)
+    }
+    return reasons
+}
+
+// unschedulableError summarises why no node accepted pod p, e.g.
+// "0/3 nodes are available: 2 insufficient CPU, 1 node is Stopped".
+func unschedulableError(p pod.Pod, nodes map[string]Node) error {
+    return unschedulableErrorFor(p, nodes, podFitsNode)
+}
+
+// unschedulableErrorFor is unschedulableError with the rejection reasons
+// given by fits.
+func unschedulableErrorFor(p pod.Pod, nodes map[string]Node, fits FitPredicate) error {
+    if len(nodes) == 0 {
+        return fmt.Errorf("no available nodes with sufficient resources")
+    }
+    counts := make(map[string]int)
+    for _, n := range nodes {
+        reason := fits(p, n)
+        // Drop the per-node numbers so identical rejections group together.
+        if i := strings.Index(reason, " ("); i >= 0 {
+            reason = reason[:i]
+        }
+        counts[reason]++
+    }
+    summary := make([]string, 0, len(counts))
+    for reason, count := range counts {
+        summary = append(summary, fmt.Sprintf("%d %s", count, reason))
+    }
+    sort.Strings(summary)
+    return fmt.Errorf("0/%d nodes are available: %s", len(nodes), strings.Join(summary, ", "))
+}
+
 // SchedulePodBestFit implements best-fit scheduling: chooses the node with the smallest leftover capacity.
 func SchedulePodBestFit(pod pod.Pod, nodes map[string]Node) (string, error) {
     nodeList := make([]Node, 0, len(nodes))
@@ -24,7 +166,7 @@ func SchedulePodBestFit(pod pod.Pod, nodes map[string]Node) (string, error) {
     minLeftover := int(^uint(0) >> 1) // max int
     for _, n := range nodeList {
         available := n.CPUs - n.UsedCPUs
-        if available >= pod.CPUs {
+        if podFitsNode(pod, n) == "" {
             leftover := available - pod.CPUs
             if leftover < minLeftover {
                 minLeftover = leftover
@@ -33,7 +175,7 @@ func SchedulePodBestFit(pod pod.Pod, nodes map[string]Node) (string, error) {
         }
     }
     if selectedID == "" {
-        return "", fmt.Errorf("no available nodes with sufficient resources")
+        return "", unschedulableError(pod, nodes)
     }
     n := nodes[selectedID]
     n.Pods = append(n.Pods, pod.ID)
@@ -56,8 +198,7 @@ func SchedulePodFirstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
 
     // Iterate over the sorted nodes.
     for _, n := range nodeList {
-        available := n.CPUs - n.UsedCPUs
-        if available >= pod.CPUs {
+        if podFitsNode(pod, n) == "" {
             // Update node in the nodes map.
             id := n.ID
             updatedNode := nodes[id]
@@ -67,7 +208,7 @@ func SchedulePodFirstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
             return id, nil
         }
     }
-    return "", fmt.Errorf("no available nodes with sufficient resources")
+    return "", unschedulableError(pod, nodes)
 }
 // SchedulePodWorstFit implements worst-fit scheduling: chooses the node with the largest leftover capacity.
 func SchedulePodWorstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
@@ -84,7 +225,7 @@ func SchedulePodWorstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
     maxLeftover := -1
     for _, n := range nodeList {
         available := n.CPUs - n.UsedCPUs
-        if available >= pod.CPUs {
+        if podFitsNode(pod, n) == "" {
             leftover := available - pod.CPUs
             if leftover > maxLeftover {
                 maxLeftover = leftover
@@ -93,7 +234,7 @@ func SchedulePodWorstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
         }
     }
     if selectedID == "" {
-        return "", fmt.Errorf("no available nodes with sufficient resources")
+        return "", unschedulableError(pod, nodes)
     }
     n := nodes[selectedID]
     n.Pods = append(n.Pods, pod.ID)
@@ -101,20 +242,126 @@ func SchedulePodWorstFit(pod pod.Pod, nodes map[string]Node) (string, error) {
     nodes[selectedID] = n
     return selectedID, nil
 }
-func SchedulePod(pod pod.Pod, nodes map[string]Node, algorithm string) (string, error) {
-    switch algorithm {
+// scheduleFunc is the signature shared by the scheduling algorithms above.
+type scheduleFunc func(pod.Pod, map[string]Node) (string, error)
+
+// Algorithms are the scheduling algorithms, the first being the default.
+var Algorithms = []string{"first_fit", "best_fit", "worst_fit"}
+
+// SchedulerProfile lets pods ask for an algorithm by the profile's name.
+type SchedulerProfile struct {
+    Name      string `json:"name"`
+    Algorithm string `json:"algorithm"`
+}
+
+// schedulerProfiles resolve the algorithm a pod asks for. Pods that ask for
+// none get the default, itself a profile or an algorithm.
+var schedulerProfiles struct {
+    sync.Mutex
+    byName         map[string]string
+    defaultProfile string
+}
+
+// SetSchedulerProfiles replaces the profiles and the default; pods
+// scheduled from now on use them.
+func SetSchedulerProfiles(profiles []SchedulerProfile, defaultProfile string) {
+    byName := make(map[string]string, len(profiles))
+    for _, p := range profiles {
+        byName[p.Name] = p.Algorithm
+    }
+    schedulerProfiles.Lock()
+    defer schedulerProfiles.Unlock()
+    schedulerProfiles.byName = byName
+    schedulerProfiles.defaultProfile = defaultProfile
+}
+
+// knownAlgorithm reports whether pods may ask for name.
+func knownAlgorithm(name string) bool {
+    for _, a := range Algorithms {
+        if a == name {
+            return true
+        }
+    }
+    schedulerProfiles.Lock()
+    defer schedulerProfiles.Unlock()
+    _, ok := schedulerProfiles.byName[name]
+    return ok
+}
+
+// resolveAlgorithm returns the algorithm a pod asking for name is placed
+// with: the algorithm of the profile of that name, or name itself.
+func resolveAlgorithm(name string) string {
+    schedulerProfiles.Lock()
+    defer schedulerProfiles.Unlock()
+    if name == "" {
+        name = schedulerProfiles.defaultProfile
+    }
+    if algorithm, ok := schedulerProfiles.byName[name]; ok {
+        return algorithm
+    }
+    return name
+}
+
+// SchedulePod runs one scheduling cycle: the predicates filter out the nodes
+// that cannot take the pod, then the algorithm scores the rest and picks one.
+// The cycle is traced as part of ctx, with a span for each phase.
+func SchedulePod(ctx context.Context, pod pod.Pod, nodes map[string]Node, algorithm string) (nodeID string, err error) {
+    var schedule scheduleFunc
+    switch algorithm = resolveAlgorithm(algorithm); algorithm {
     case "best_fit":
-        return SchedulePodBestFit(pod, nodes)
+        schedule = SchedulePodBestFit
     case "worst_fit":
-        return SchedulePodWorstFit(pod, nodes)
+        schedule = SchedulePodWorstFit
     case "first_fit":
         fallthrough
     default:
-        return SchedulePodFirstFit(pod, nodes)
+        algorithm = "first_fit"
+        schedule = SchedulePodFirstFit
+    }
+
+    ctx, span := tracer.Start(ctx, "SchedulePod", trace.WithAttributes(
+        attribute.String("pod.id", pod.ID), attribute.Int("pod.cpus", pod.CPUs),
+        attribute.String("scheduler.algorithm", algorithm), attribute.Int("scheduler.nodes", len(nodes))))
+    start := time.Now()
+    defer func() {
+        metrics.SchedulingLatency.WithLabelValues(algorithm).Observe(time.Since(start).Seconds())
+        metrics.SchedulingAttempts.WithLabelValues(algorithm).Inc()
+        if err != nil {
+            metrics.SchedulingFailures.WithLabelValues(algorithm).Inc()
+        } else {
+            span.SetAttributes(attribute.String("node.id", nodeID))
+        }
+        tracing.End(span, err)
+    }()
+
+    _, filter := tracer.Start(ctx, "Filter")
+    feasible := make(map[string]Node, len(nodes))
+    for id, n := range nodes {
+        if podFitsNode(pod, n) == "" {
+            feasible[id] = n
+        }
+    }
+    filter.SetAttributes(attribute.Int("scheduler.feasible_nodes", len(feasible)))
+    filter.End()
+    if len(feasible) == 0 {
+        return "", unschedulableError(pod, nodes)
     }
+
+    _, score := tracer.Start(ctx, "Score")
+    nodeID, err = schedule(pod, feasible)
+    score.End()
+    if err != nil {
+        return "", err
+    }
+    // The algorithm booked the pod on its copy of the node.
+    nodes[nodeID] = feasible[nodeID]
+    return nodeID, nil
 }
 
-func (nm *NodeManager) reschedulePods(failedNodeID string) {
+func (nm *NodeManager) reschedulePods(ctx context.Context, failedNodeID string) {
+    ctx, span := tracer.Start(ctx, "ReschedulePods", trace.WithAttributes(attribute.String("node.id", failedNodeID)))
+    defer span.End()
+
     nm.Mu.Lock()
     var podsToReschedule []string
     if nodeObj, exists := nm.Nodes[failedNodeID]; exists {
@@ -133,39 +380,47 @@ func (nm *NodeManager) reschedulePods(failedNodeID string) {
     for _, podID := range podsToReschedule {
         nm.Mu.Lock()
         p, exists := nm.Pods[podID]
-        if !exists {
+        if !exists || p.Finished() {
             nm.Mu.Unlock()
             continue
         }
-        // Clear current assignment
+        if IsDaemonSetPod(p) {
+            // Bound to the lost node, so there is nowhere to move it.
+            nm.unbindPodLocked(p)
+            delete(nm.Pods, podID)
+            nm.Mu.Unlock()
+            schedulerLog.InfoContext(ctx, "Pod deleted with its node", logging.PodID(podID), logging.NodeID(failedNodeID))
+            nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Killing", "Node %s is no longer available", failedNodeID)
+            continue
+        }
+        // Clear current assignment; a node that failed to restart is
+        // still listed and would otherwise keep the pod's CPUs.
+        nm.unbindPodLocked(p)
         p.NodeID = ""
+        p.IP = ""
         p.Status = "Pending"
         nm.Pods[podID] = p
         nm.Mu.Unlock()
+        nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "Evicted", "Node %s is no longer available", failedNodeID)
 
         nm.Mu.Lock()
-        newNodeID, err := SchedulePod(p, nm.Nodes, "first_fit")
+        candidates := make(map[string]Node, len(nm.Nodes))
+        for id, n := range nm.Nodes {
+            if id != failedNodeID {
+                candidates[id] = n
+            }
+        }
+        newNodeID, err := nm.schedulePodAmongLocked(ctx, p, candidates, p.Algorithm)
         if err == nil {
-            p.NodeID = newNodeID
-            p.Status = "Running"
+            nm.bindPodLocked(&p, newNodeID)
             nm.Pods[podID] = p
-            // Also update the destination node.
-            // nodeUpdate := nm.Nodes[newNodeID]
-            // alreadyPresent:=false;
-            // for _,id:=range nodeUpdate.Pods{
-            //     if id==podID {
-            //         alreadyPresent=true;
-            //         break;
-            //     }
-            // }
-            // if !alreadyPresent{
-            //     nodeUpdate.Pods = append(nodeUpdate.Pods, podID)
-            // }
-            // nodeUpdate.UsedCPUs += p.CPUs
-            // nm.Nodes[newNodeID] = nodeUpdate
-            log.Printf("Pod %s rescheduled to node %s", podID, newNodeID)
+            metrics.PodsRescheduled.WithLabelValues("success").Inc()
+            schedulerLog.InfoContext(ctx, "Pod rescheduled", logging.PodID(podID), logging.NodeID(newNodeID))
+            nm.Events.Eventf(events.KindPod, podID, events.TypeNormal, "Scheduled", "Successfully assigned pod to node %s", newNodeID)
         } else {
-            log.Printf("Failed to reschedule pod %s: %v", podID, err)
+            metrics.PodsRescheduled.WithLabelValues("failure").Inc()
+            schedulerLog.WarnContext(ctx, "Failed to reschedule pod", logging.PodID(podID), logging.Err(err))
+            nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "FailedScheduling", "%v", err)
         }
         nm.Mu.Unlock()
     }
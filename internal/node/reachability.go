@@ -0,0 +1,180 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/network"
+)
+
+// RecordHeartbeat notes that a node's heartbeat reached the control plane.
+// An Unreachable node is Running again and kills the copies of the pods
+// that were moved away while it was cut off.
+func (nm *NodeManager) RecordHeartbeat(nodeID string, at time.Time) {
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists || at.Before(n.LastHeartbeat) {
+		nm.Mu.Unlock()
+		return
+	}
+	n.LastHeartbeat = at
+	recovered := n.Status == "Unreachable"
+	var stale []string
+	if recovered {
+		n.Status = "Running"
+		stale, n.StalePods = n.StalePods, nil
+	}
+	nm.Nodes[nodeID] = n
+	nm.Mu.Unlock()
+
+	if !recovered {
+		return
+	}
+	healthLog.Info("Node is reachable again", logging.NodeID(nodeID))
+	nm.Events.Eventf(events.KindNode, nodeID, events.TypeNormal, "NodeReady", "Node is reachable again")
+	for _, podID := range stale {
+		nm.Events.Eventf(events.KindNode, nodeID, events.TypeWarning, "StalePodKilled",
+			"Killed its copy of pod %s, which was moved while the node was unreachable", podID)
+	}
+	nm.SchedulePendingPods()
+}
+
+// MarkUnreachable marks a Running node whose heartbeats stopped arriving as
+// Unreachable, so nothing new is scheduled onto it, and reports whether it
+// was Running.
+func (nm *NodeManager) MarkUnreachable(nodeID string) bool {
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists || n.Status != "Running" {
+		nm.Mu.Unlock()
+		return false
+	}
+	n.Status = "Unreachable"
+	nm.Nodes[nodeID] = n
+	nm.Mu.Unlock()
+
+	silence := time.Since(n.LastHeartbeat).Round(time.Second)
+	healthLog.Warn("Node is unreachable", logging.NodeID(nodeID), "no_heartbeat_for", silence)
+	nm.Events.Eventf(events.KindNode, nodeID, events.TypeWarning, "NodeNotReady", "Node stopped posting heartbeats %s ago", silence)
+	return true
+}
+
+// EvictUnreachableNode reschedules the pods of an Unreachable node. The
+// node may well still be running them, so they are kept in its StalePods
+// until it reports back: a pod placed elsewhere meanwhile runs twice.
+// DaemonSet pods are bound to the node and stay. It returns how many pods
+// were moved.
+func (nm *NodeManager) EvictUnreachableNode(nodeID string) int {
+	nm.Mu.Lock()
+	n, exists := nm.Nodes[nodeID]
+	if !exists || n.Status != "Unreachable" {
+		nm.Mu.Unlock()
+		return 0
+	}
+	var moved []string
+	for _, podID := range append([]string(nil), n.Pods...) {
+		p, exists := nm.Pods[podID]
+		if !exists || p.Finished() || IsDaemonSetPod(p) {
+			continue
+		}
+		nm.unbindPodLocked(p)
+		p.NodeID = ""
+		p.IP = ""
+		p.Status = "Pending"
+		nm.Pods[podID] = p
+		moved = append(moved, podID)
+	}
+	n = nm.Nodes[nodeID]
+	n.StalePods = append(n.StalePods, moved...)
+	nm.Nodes[nodeID] = n
+	nm.Mu.Unlock()
+
+	if len(moved) == 0 {
+		return 0
+	}
+	schedulerLog.Info("Evicting pods from unreachable node", logging.NodeID(nodeID), "pods", len(moved))
+	for _, podID := range moved {
+		nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "Evicted", "Node %s is unreachable", nodeID)
+	}
+	nm.SchedulePendingPods()
+
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	for _, podID := range moved {
+		if p, exists := nm.Pods[podID]; exists && p.NodeID != "" {
+			nm.Events.Eventf(events.KindPod, podID, events.TypeWarning, "SplitBrain",
+				"Pod runs on node %s and may still be running on unreachable node %s", p.NodeID, nodeID)
+		}
+	}
+	return len(moved)
+}
+
+// checkEndpointLocked fails unless end is the control plane or a known node.
+// nm.Mu must be held.
+func (nm *NodeManager) checkEndpointLocked(end string) error {
+	if end == network.ControlPlane {
+		return nil
+	}
+	if _, exists := nm.Nodes[end]; !exists {
+		return fmt.Errorf("%w: %s", ErrNodeNotFound, end)
+	}
+	return nil
+}
+
+// SetLink changes the state of the link between two nodes, or a node and
+// the control plane.
+func (nm *NodeManager) SetLink(l network.Link) error {
+	nm.Mu.Lock()
+	for _, end := range []string{l.A, l.B} {
+		if err := nm.checkEndpointLocked(end); err != nil {
+			nm.Mu.Unlock()
+			return err
+		}
+	}
+	nm.Mu.Unlock()
+	if err := nm.Network.SetLink(l); err != nil {
+		return err
+	}
+	networkLog.Info("Network link set", "a", l.A, "b", l.B, "partitioned", l.Partitioned, "latency_ms", l.LatencyMillis, "loss", l.LossRate)
+	return nil
+}
+
+// Partition cuts the given nodes off from the control plane and every other
+// node, leaving links within the group alone. It returns the links cut.
+func (nm *NodeManager) Partition(nodeIDs []string) ([]network.Link, error) {
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("at least one node is required")
+	}
+	group := make(map[string]bool, len(nodeIDs))
+	nm.Mu.Lock()
+	for _, id := range nodeIDs {
+		if err := nm.checkEndpointLocked(id); err != nil || id == network.ControlPlane {
+			nm.Mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, id)
+		}
+		group[id] = true
+	}
+	others := []string{network.ControlPlane}
+	for id := range nm.Nodes {
+		if !group[id] {
+			others = append(others, id)
+		}
+	}
+	nm.Mu.Unlock()
+
+	var cut []network.Link
+	for id := range group {
+		for _, other := range others {
+			l := nm.Network.Link(id, other)
+			l.Partitioned = true
+			if err := nm.Network.SetLink(l); err != nil {
+				return cut, err
+			}
+			cut = append(cut, l)
+		}
+	}
+	networkLog.Info("Network partitioned", "nodes", nodeIDs, "other_nodes", len(others)-1)
+	return cut, nil
+}
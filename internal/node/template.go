@@ -0,0 +1,209 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNodeTemplateNotFound is returned when an operation names an unknown node template.
+var ErrNodeTemplateNotFound = errors.New("node template not found")
+
+// MaxNodeCount bounds how many nodes a single request may create.
+const MaxNodeCount = 100
+
+// createParallelism is how many nodes CreateNodes starts at once, so a large
+// request does not flood the Docker daemon.
+const createParallelism = 8
+
+// ContainerResources are the limits Docker enforces on a node container.
+// Zero leaves a resource unlimited.
+type ContainerResources struct {
+	CPULimit       float64 `json:"cpu_limit,omitempty"` // in CPUs, e.g. 1.5
+	MemoryLimitMiB int     `json:"memory_limit_mib,omitempty"`
+}
+
+// ContainerSpec is what a node container runs. An empty image or command
+// is taken from the runtime options when the container is created.
+type ContainerSpec struct {
+	Image     string             `json:"image,omitempty"`
+	Command   []string           `json:"command,omitempty"`
+	Resources ContainerResources `json:"resources,omitempty"`
+}
+
+// NodeTemplate is a named machine type: the capacity, labels and taints of
+// the nodes created from it and what their containers run.
+type NodeTemplate struct {
+	Name string `json:"name"`
+	ContainerSpec
+	CPUs               int               `json:"cpus"`
+	MemoryMiB          int               `json:"memory_mib,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Taints             []Taint           `json:"taints,omitempty"`
+	StartupDelayMillis int               `json:"startup_delay_ms,omitempty"` // before a new node registers
+}
+
+// Validate checks the template is well formed.
+func (t NodeTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.CPUs <= 0 {
+		return fmt.Errorf("cpus must be positive")
+	}
+	if t.MemoryMiB < 0 {
+		return fmt.Errorf("memory_mib must not be negative")
+	}
+	if t.Resources.CPULimit < 0 || t.Resources.MemoryLimitMiB < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	if t.StartupDelayMillis < 0 {
+		return fmt.Errorf("startup_delay_ms must not be negative")
+	}
+	for _, taint := range t.Taints {
+		if taint.Key == "" {
+			return fmt.Errorf("taint key is required")
+		}
+		switch taint.Effect {
+		case "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return fmt.Errorf("invalid taint effect %q", taint.Effect)
+		}
+	}
+	return nil
+}
+
+// SetNodeTemplate creates or replaces a node template. Nodes already created
+// from it keep the settings they were created with.
+func (nm *NodeManager) SetNodeTemplate(t NodeTemplate) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	nm.Mu.Lock()
+	_, replaced := nm.Templates[t.Name]
+	nm.Templates[t.Name] = t
+	nm.Mu.Unlock()
+	runtimeLog.Info("Node template saved", "template", t.Name, "cpus", t.CPUs, "memory_mib", t.MemoryMiB, "replaced", replaced)
+	return nil
+}
+
+// DeleteNodeTemplate removes a node template. Its nodes keep running.
+func (nm *NodeManager) DeleteNodeTemplate(name string) error {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	if _, exists := nm.Templates[name]; !exists {
+		return ErrNodeTemplateNotFound
+	}
+	delete(nm.Templates, name)
+	return nil
+}
+
+// GetNodeTemplate returns the named node template.
+func (nm *NodeManager) GetNodeTemplate(name string) (NodeTemplate, error) {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	t, exists := nm.Templates[name]
+	if !exists {
+		return NodeTemplate{}, ErrNodeTemplateNotFound
+	}
+	return t, nil
+}
+
+// ListNodeTemplates returns every node template, sorted by name.
+func (nm *NodeManager) ListNodeTemplates() []NodeTemplate {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	result := make([]NodeTemplate, 0, len(nm.Templates))
+	for _, t := range nm.Templates {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// applyTemplate fills in spec from the template it names, if any. CPUs given
+// in the spec win over the template's, labels are merged with the spec's
+// winning, and the spec's taints are added to the template's. It also
+// returns the template, which is empty when none is named. The template's
+// containers are limited to its memory unless it sets a lower limit.
+func (nm *NodeManager) applyTemplate(spec NodeSpec) (NodeSpec, NodeTemplate, error) {
+	if spec.CPUs < 0 {
+		return spec, NodeTemplate{}, fmt.Errorf("cpus must not be negative")
+	}
+	if spec.Template == "" {
+		return spec, NodeTemplate{}, nil
+	}
+	t, err := nm.GetNodeTemplate(spec.Template)
+	if err != nil {
+		return spec, t, fmt.Errorf("%w: %s", err, spec.Template)
+	}
+	if spec.CPUs == 0 {
+		spec.CPUs = t.CPUs
+	}
+	if len(t.Labels) > 0 {
+		merged := make(map[string]string, len(t.Labels)+len(spec.Labels))
+		for k, v := range t.Labels {
+			merged[k] = v
+		}
+		for k, v := range spec.Labels {
+			merged[k] = v
+		}
+		spec.Labels = merged
+	}
+	spec.Taints = append(append([]Taint(nil), t.Taints...), spec.Taints...)
+	// A machine cannot use more memory than it has.
+	if t.MemoryMiB > 0 && (t.Resources.MemoryLimitMiB == 0 || t.Resources.MemoryLimitMiB > t.MemoryMiB) {
+		t.Resources.MemoryLimitMiB = t.MemoryMiB
+	}
+	return spec, t, nil
+}
+
+// waitForStartup plays the part of a machine booting before its kubelet
+// registers. It returns early with ctx's error when ctx is done.
+func waitForStartup(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CreateNodes creates count nodes from spec, several at a time. It returns
+// the nodes that were created and the errors of those that were not, each
+// in the order the creations finished.
+func (nm *NodeManager) CreateNodes(ctx context.Context, spec NodeSpec, count int) ([]Node, []error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		created []Node
+		failed  []error
+	)
+	slots := make(chan struct{}, createParallelism)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			n, err := nm.CreateNode(ctx, spec)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, err)
+				return
+			}
+			created = append(created, n)
+		}()
+	}
+	wg.Wait()
+	return created, failed
+}
@@ -0,0 +1,435 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/pod"
+	"cluster-sim/internal/storage"
+)
+
+// volumeBinderInterval is how often the binder retries claims that could
+// not be bound when they were created.
+const volumeBinderInterval = 5 * time.Second
+
+// ErrClaimNotFound is returned when an operation names an unknown claim.
+var ErrClaimNotFound = errors.New("persistentvolumeclaim not found")
+
+// ErrVolumeNotFound is returned when an operation names an unknown volume.
+var ErrVolumeNotFound = errors.New("persistentvolume not found")
+
+// ErrStorageClassNotFound is returned when an operation names an unknown storage class.
+var ErrStorageClassNotFound = errors.New("storageclass not found")
+
+// ClaimWithStatus is a claim together with the pods that mount it.
+type ClaimWithStatus struct {
+	storage.PersistentVolumeClaim
+	UsedBy []string `json:"used_by"`
+}
+
+// EnsureClaim creates a claim unless one of that name exists, and returns
+// the claim now stored. An existing claim is never modified, so a pod
+// recreated under the same name keeps the storage it had.
+func (nm *NodeManager) EnsureClaim(claim storage.PersistentVolumeClaim) (storage.PersistentVolumeClaim, error) {
+	nm.Mu.Lock()
+	existing, exists := nm.Claims[claim.Name]
+	nm.Mu.Unlock()
+	if exists {
+		return existing, nil
+	}
+	if err := nm.CreateClaim(claim); err != nil {
+		return storage.PersistentVolumeClaim{}, err
+	}
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	return nm.Claims[claim.Name], nil
+}
+
+// CreateClaim adds a claim and binds it right away unless its storage class
+// waits for the first pod using it.
+func (nm *NodeManager) CreateClaim(claim storage.PersistentVolumeClaim) error {
+	if err := claim.Validate(); err != nil {
+		return err
+	}
+	nm.Mu.Lock()
+	if _, exists := nm.Claims[claim.Name]; exists {
+		nm.Mu.Unlock()
+		return fmt.Errorf("persistentvolumeclaim %q already exists", claim.Name)
+	}
+	claim.Phase = storage.ClaimPending
+	claim.VolumeName = ""
+	claim.CreatedAt = time.Now()
+	nm.Claims[claim.Name] = claim
+	bound := nm.bindImmediateClaimsLocked()
+	nm.Mu.Unlock()
+
+	storageLog.Info("PersistentVolumeClaim created", "claim", claim.Name, "storage_gib", claim.StorageGiB, "storage_class", claim.StorageClass)
+	nm.Events.Eventf(events.KindPersistentVolumeClaim, claim.Name, events.TypeNormal, "Created", "Claim for %dGiB created", claim.StorageGiB)
+	if bound > 0 {
+		nm.SchedulePendingPods()
+	}
+	return nil
+}
+
+// DeleteClaim removes a claim that no pod mounts. Its volume is released,
+// or deleted if its reclaim policy says so.
+func (nm *NodeManager) DeleteClaim(name string) error {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	claim, exists := nm.Claims[name]
+	if !exists {
+		return ErrClaimNotFound
+	}
+	if users := nm.claimUsersLocked(name); len(users) > 0 {
+		return fmt.Errorf("persistentvolumeclaim %q is in use by pods %v", name, users)
+	}
+	delete(nm.Claims, name)
+
+	if v, bound := nm.Volumes[claim.VolumeName]; bound && v.ClaimRef == name {
+		if v.ReclaimPolicy == storage.ReclaimDelete {
+			delete(nm.Volumes, v.Name)
+			storageLog.Info("PersistentVolume deleted with its claim", "volume", v.Name, "claim", name)
+		} else {
+			v.Phase = storage.VolumeReleased
+			nm.Volumes[v.Name] = v
+			nm.Events.Eventf(events.KindPersistentVolume, v.Name, events.TypeNormal, "Released", "Claim %s was deleted", name)
+		}
+	}
+	return nil
+}
+
+// ListClaims returns every claim with the pods mounting it, sorted by name.
+func (nm *NodeManager) ListClaims() []ClaimWithStatus {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	result := make([]ClaimWithStatus, 0, len(nm.Claims))
+	for name, claim := range nm.Claims {
+		result = append(result, ClaimWithStatus{PersistentVolumeClaim: claim, UsedBy: nm.claimUsersLocked(name)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// claimUsersLocked returns the IDs of the pods mounting a claim. nm.Mu must be held.
+func (nm *NodeManager) claimUsersLocked(name string) []string {
+	users := []string{}
+	for id, p := range nm.Pods {
+		for _, claim := range p.Claims {
+			if claim == name {
+				users = append(users, id)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// CreateVolume adds a volume and offers it to the claims waiting for one.
+func (nm *NodeManager) CreateVolume(v storage.PersistentVolume) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	nm.Mu.Lock()
+	if _, exists := nm.Volumes[v.Name]; exists {
+		nm.Mu.Unlock()
+		return fmt.Errorf("persistentvolume %q already exists", v.Name)
+	}
+	if v.Node != "" {
+		if _, exists := nm.Nodes[v.Node]; !exists {
+			nm.Mu.Unlock()
+			return fmt.Errorf("node %q of local volume not found", v.Node)
+		}
+	}
+	v.Phase = storage.VolumeAvailable
+	v.ClaimRef = ""
+	v.CreatedAt = time.Now()
+	nm.Volumes[v.Name] = v
+	bound := nm.bindImmediateClaimsLocked()
+	nm.Mu.Unlock()
+
+	storageLog.Info("PersistentVolume created", "volume", v.Name, "capacity_gib", v.CapacityGiB, "storage_class", v.StorageClass, logging.NodeID(v.Node))
+	// Pods waiting on a WaitForFirstConsumer claim may now fit somewhere.
+	if bound > 0 || v.Node != "" || v.StorageClass != "" {
+		nm.SchedulePendingPods()
+	}
+	return nil
+}
+
+// RestoreVolumes puts back volumes and claims saved by an earlier server,
+// bound as they were. A local volume is kept even though its node is gone,
+// and a claim whose volume is missing waits for another one.
+func (nm *NodeManager) RestoreVolumes(volumes []storage.PersistentVolume, claims []storage.PersistentVolumeClaim) error {
+	var errs []error
+	nm.Mu.Lock()
+	for _, v := range volumes {
+		if err := v.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("persistentvolume %s: %v", v.Name, err))
+			continue
+		}
+		nm.Volumes[v.Name] = v
+	}
+	for _, claim := range claims {
+		if err := claim.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("persistentvolumeclaim %s: %v", claim.Name, err))
+			continue
+		}
+		if v, exists := nm.Volumes[claim.VolumeName]; claim.Phase != storage.ClaimBound || !exists || v.ClaimRef != claim.Name {
+			claim.Phase, claim.VolumeName = storage.ClaimPending, ""
+		}
+		nm.Claims[claim.Name] = claim
+	}
+	for name, v := range nm.Volumes {
+		if claim, exists := nm.Claims[v.ClaimRef]; v.Phase == storage.VolumeBound && (!exists || claim.VolumeName != name) {
+			v.Phase = storage.VolumeReleased
+			nm.Volumes[name] = v
+		}
+	}
+	nm.bindImmediateClaimsLocked()
+	nm.Mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// DeleteVolume removes a volume that is not bound to a claim.
+func (nm *NodeManager) DeleteVolume(name string) error {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	v, exists := nm.Volumes[name]
+	if !exists {
+		return ErrVolumeNotFound
+	}
+	if v.Phase == storage.VolumeBound {
+		return fmt.Errorf("persistentvolume %q is bound to claim %q", name, v.ClaimRef)
+	}
+	delete(nm.Volumes, name)
+	return nil
+}
+
+// ListVolumes returns every volume, sorted by name.
+func (nm *NodeManager) ListVolumes() []storage.PersistentVolume {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	result := make([]storage.PersistentVolume, 0, len(nm.Volumes))
+	for _, v := range nm.Volumes {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// SetStorageClass creates or replaces a storage class.
+func (nm *NodeManager) SetStorageClass(class storage.StorageClass) error {
+	if err := class.Validate(); err != nil {
+		return err
+	}
+	nm.Mu.Lock()
+	nm.StorageClasses[class.Name] = class
+	bound := nm.bindImmediateClaimsLocked()
+	nm.Mu.Unlock()
+	if bound > 0 {
+		nm.SchedulePendingPods()
+	}
+	return nil
+}
+
+// DeleteStorageClass removes a storage class. Its volumes and claims are kept.
+func (nm *NodeManager) DeleteStorageClass(name string) error {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	if _, exists := nm.StorageClasses[name]; !exists {
+		return ErrStorageClassNotFound
+	}
+	delete(nm.StorageClasses, name)
+	return nil
+}
+
+// ListStorageClasses returns every storage class, sorted by name.
+func (nm *NodeManager) ListStorageClasses() []storage.StorageClass {
+	nm.Mu.Lock()
+	defer nm.Mu.Unlock()
+	result := make([]storage.StorageClass, 0, len(nm.StorageClasses))
+	for _, class := range nm.StorageClasses {
+		result = append(result, class)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// StartVolumeBinder begins a goroutine that binds pending claims of
+// Immediate storage classes to matching volumes and retries pending pods
+// whose claims became bound.
+func (nm *NodeManager) StartVolumeBinder() {
+	go func() {
+		for {
+			nm.Mu.Lock()
+			bound := nm.bindImmediateClaimsLocked()
+			nm.Mu.Unlock()
+			if bound > 0 {
+				nm.SchedulePendingPods()
+			}
+			time.Sleep(volumeBinderInterval)
+		}
+	}()
+}
+
+// waitsForConsumerLocked reports whether a claim is only bound once a pod
+// using it is scheduled. Claims of unknown classes bind immediately.
+// nm.Mu must be held.
+func (nm *NodeManager) waitsForConsumerLocked(claim storage.PersistentVolumeClaim) bool {
+	class, exists := nm.StorageClasses[claim.StorageClass]
+	return exists && class.VolumeBindingMode == storage.BindingWaitForFirstConsumer
+}
+
+// bindImmediateClaimsLocked binds every pending claim that does not wait
+// for a consumer, oldest first, and returns how many were bound.
+// nm.Mu must be held.
+func (nm *NodeManager) bindImmediateClaimsLocked() int {
+	pending := make([]storage.PersistentVolumeClaim, 0)
+	for _, claim := range nm.Claims {
+		if claim.Phase == storage.ClaimPending && !nm.waitsForConsumerLocked(claim) {
+			pending = append(pending, claim)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+			return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+		}
+		return pending[i].Name < pending[j].Name
+	})
+
+	bound := 0
+	for _, claim := range pending {
+		if v, ok := nm.bestVolumeLocked(claim, ""); ok {
+			nm.bindLocked(claim, v)
+			bound++
+		}
+	}
+	return bound
+}
+
+// bestVolumeLocked returns the smallest available volume that satisfies the
+// claim, restricted to volumes usable from nodeID when it is set.
+// nm.Mu must be held.
+func (nm *NodeManager) bestVolumeLocked(claim storage.PersistentVolumeClaim, nodeID string) (storage.PersistentVolume, bool) {
+	var best storage.PersistentVolume
+	found := false
+	for _, v := range nm.Volumes {
+		if v.CanBind(claim) != "" || (nodeID != "" && !v.AvailableOn(nodeID)) {
+			continue
+		}
+		if !found || v.CapacityGiB < best.CapacityGiB || (v.CapacityGiB == best.CapacityGiB && v.Name < best.Name) {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
+
+// bindLocked binds a claim to a volume. nm.Mu must be held.
+func (nm *NodeManager) bindLocked(claim storage.PersistentVolumeClaim, v storage.PersistentVolume) {
+	claim.Phase, claim.VolumeName = storage.ClaimBound, v.Name
+	v.Phase, v.ClaimRef = storage.VolumeBound, claim.Name
+	nm.Claims[claim.Name] = claim
+	nm.Volumes[v.Name] = v
+	storageLog.Info("PersistentVolumeClaim bound", "claim", claim.Name, "volume", v.Name)
+	nm.Events.Eventf(events.KindPersistentVolumeClaim, claim.Name, events.TypeNormal, "Bound", "Bound to volume %s", v.Name)
+}
+
+// volumesFitLocked returns why pod p's claims rule out node n, or "" if
+// they allow it: every claim must exist, a bound claim's volume must be
+// usable from n, a waiting claim must have an available volume usable from
+// n, and a ReadWriteOnce claim cannot be used from two nodes at once.
+// nm.Mu must be held.
+func (nm *NodeManager) volumesFitLocked(p pod.Pod, n Node) string {
+	for _, name := range p.Claims {
+		claim, exists := nm.Claims[name]
+		if !exists {
+			return fmt.Sprintf("persistentvolumeclaim %q not found", name)
+		}
+		if claim.Phase == storage.ClaimBound {
+			if v, ok := nm.Volumes[claim.VolumeName]; !ok || !v.AvailableOn(n.ID) {
+				return "volume node affinity conflict"
+			}
+		} else if !nm.waitsForConsumerLocked(claim) {
+			return "pod has unbound immediate PersistentVolumeClaims"
+		} else if _, ok := nm.bestVolumeLocked(claim, n.ID); !ok {
+			return fmt.Sprintf("no persistent volume available for claim %q", name)
+		}
+
+		if len(claim.AccessModes) == 1 && claim.AccessModes[0] == storage.ReadWriteOnce {
+			for id, other := range nm.Pods {
+				if id == p.ID || other.NodeID == "" || other.NodeID == n.ID || other.Finished() {
+					continue
+				}
+				for _, c := range other.Claims {
+					if c == name {
+						return fmt.Sprintf("volume of claim %q is in use on node %s", name, other.NodeID)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// VolumesFitNodeLocked returns why pod p's claims rule out node n, or "" if
+// they allow it. It lets other components account for volumes when they
+// simulate placement. nm.Mu must be held.
+func (nm *NodeManager) VolumesFitNodeLocked(p pod.Pod, n Node) string {
+	return nm.volumesFitLocked(p, n)
+}
+
+// podFitsNodeLocked runs the scheduler predicates and then the volume
+// checks. nm.Mu must be held.
+func (nm *NodeManager) podFitsNodeLocked(p pod.Pod, n Node) string {
+	if reason := podFitsNode(p, n); reason != "" {
+		return reason
+	}
+	return nm.volumesFitLocked(p, n)
+}
+
+// schedulePodLocked places p with the given algorithm on a node its claims
+// allow, and binds the claims that waited for the pod to volumes on that
+// node. nm.Mu must be held.
+func (nm *NodeManager) schedulePodLocked(ctx context.Context, p pod.Pod, algorithm string) (string, error) {
+	return nm.schedulePodAmongLocked(ctx, p, nm.Nodes, algorithm)
+}
+
+// schedulePodAmongLocked is schedulePodLocked limited to nodes, a subset of
+// nm.Nodes. The chosen node is updated in nm.Nodes.
+func (nm *NodeManager) schedulePodAmongLocked(ctx context.Context, p pod.Pod, nodes map[string]Node, algorithm string) (string, error) {
+	if len(p.Claims) == 0 {
+		nodeID, err := SchedulePod(ctx, p, nodes, algorithm)
+		if err != nil {
+			return "", err
+		}
+		nm.Nodes[nodeID] = nodes[nodeID]
+		return nodeID, nil
+	}
+	candidates := make(map[string]Node, len(nodes))
+	for id, n := range nodes {
+		if nm.volumesFitLocked(p, n) == "" {
+			candidates[id] = n
+		}
+	}
+	nodeID, err := SchedulePod(ctx, p, candidates, algorithm)
+	if err != nil {
+		return "", unschedulableErrorFor(p, nodes, nm.podFitsNodeLocked)
+	}
+	nm.Nodes[nodeID] = candidates[nodeID]
+
+	for _, name := range p.Claims {
+		claim := nm.Claims[name]
+		if claim.Phase != storage.ClaimPending {
+			continue
+		}
+		if v, ok := nm.bestVolumeLocked(claim, nodeID); ok {
+			nm.bindLocked(claim, v)
+		}
+	}
+	return nodeID, nil
+}
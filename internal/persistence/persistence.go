@@ -0,0 +1,203 @@
+// Package persistence saves the objects of a cluster to a state file and
+// puts them back when the server starts again. Each component registers a
+// section: how to list the objects it holds and how to restore them.
+// Nodes, pods and other runtime state are not saved; controllers create
+// pods again from the objects that own them.
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/logging"
+)
+
+var logger = logging.For("persistence")
+
+// fileVersion is the format of the state files this server writes.
+const fileVersion = 1
+
+// stateFile is the content of a state file.
+type stateFile struct {
+	Version  int                        `json:"version"`
+	SavedAt  time.Time                  `json:"saved_at"`
+	Sections map[string]json.RawMessage `json:"sections"`
+}
+
+// Section is one kind of object kept in the state file.
+type Section struct {
+	Name string
+	// Save returns the objects to save, encoded as JSON.
+	Save func() interface{}
+	// Restore puts back the objects read from the file. The objects it
+	// could restore stay, even if it returns an error for others.
+	Restore func(data json.RawMessage) error
+}
+
+// List returns a section saved as a list of objects, each put back with
+// one call of restore. Objects are named by name in errors.
+func List[T any](section string, save func() []T, restore func(T) error, name func(T) string) Section {
+	return Section{
+		Name: section,
+		Save: func() interface{} {
+			if objects := save(); objects != nil {
+				return objects
+			}
+			return []T{}
+		},
+		Restore: func(data json.RawMessage) error {
+			var objects []T
+			if err := json.Unmarshal(data, &objects); err != nil {
+				return err
+			}
+			var errs []error
+			for _, object := range objects {
+				if err := restore(object); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %v", name(object), err))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// Store saves the registered sections to a file and restores them from it.
+type Store struct {
+	path     string
+	sections []Section
+
+	mu   sync.Mutex // Serializes saves
+	last []byte     // sections last written, so saves that change nothing are skipped
+}
+
+// NewStore returns a store for the state file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Register adds a section. Sections are restored in the order they were
+// registered, so objects others refer to should come first.
+func (s *Store) Register(section Section) {
+	s.sections = append(s.sections, section)
+}
+
+// Restore puts back the objects of the state file. A missing file is an
+// empty cluster. A file that cannot be read is an error, so the server
+// does not start and overwrite it; objects that cannot be restored are
+// logged and left out.
+func (s *Store) Restore() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Info("No saved state, starting empty", "file", s.path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	if state.Version != fileVersion {
+		return fmt.Errorf("%s: unsupported version %d", s.path, state.Version)
+	}
+
+	known := make(map[string]bool, len(s.sections))
+	for _, section := range s.sections {
+		known[section.Name] = true
+		data, exists := state.Sections[section.Name]
+		if !exists {
+			continue
+		}
+		if err := section.Restore(data); err != nil {
+			logger.Error("Objects not restored", "section", section.Name, logging.Err(err))
+		}
+	}
+	for name := range state.Sections {
+		if !known[name] {
+			logger.Warn("Unknown section in state file ignored", "section", name)
+		}
+	}
+	logger.Info("State restored", "file", s.path, "saved_at", state.SavedAt.Format(time.RFC3339))
+	return nil
+}
+
+// Save writes every section to the state file, unless nothing changed
+// since the last save. The file is replaced as a whole, so a crash while
+// saving leaves the previous one.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sections := make(map[string]json.RawMessage, len(s.sections))
+	for _, section := range s.sections {
+		data, err := json.Marshal(section.Save())
+		if err != nil {
+			return fmt.Errorf("section %s: %v", section.Name, err)
+		}
+		sections[section.Name] = data
+	}
+	current, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, s.last) {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(stateFile{Version: fileVersion, SavedAt: time.Now(), Sections: sections}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(s.path, data); err != nil {
+		return err
+	}
+	s.last = current
+	logger.Debug("State saved", "file", s.path)
+	return nil
+}
+
+// Start saves the state every interval in a goroutine.
+func (s *Store) Start(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			if err := s.Save(); err != nil {
+				logger.Error("State not saved", "file", s.path, logging.Err(err))
+			}
+		}
+	}()
+}
+
+// writeFile writes data to a temporary file next to path and renames it
+// over path. The file is only readable by its owner, as it holds the
+// RBAC policy.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
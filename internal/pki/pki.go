@@ -0,0 +1,251 @@
+// Package pki is a small certificate authority for serving the API over TLS
+// without outside tooling. It keeps a CA, the server certificate and client
+// certificates as PEM files in one directory and creates whatever is
+// missing, so a cluster works offline with certificates of its own.
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Files in a PKI directory.
+const (
+	CACertFile     = "ca.crt"
+	CAKeyFile      = "ca.key"
+	ServerCertFile = "server.crt"
+	ServerKeyFile  = "server.key"
+	AdminCertFile  = "admin.crt"
+	AdminKeyFile   = "admin.key"
+)
+
+const (
+	// AdminUser is the common name of the client certificate Bootstrap
+	// issues. It is in the system:masters group, which RBAC lets do anything.
+	AdminUser  = "admin"
+	AdminGroup = "system:masters"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+	// renewBefore is how long before it expires a server or admin
+	// certificate is replaced by Bootstrap.
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// CA signs server and client certificates.
+type CA struct {
+	Cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// LoadCA reads the CA certificate and key from dir.
+func LoadCA(dir string) (*CA, error) {
+	cert, err := readCert(filepath.Join(dir, CACertFile))
+	if err != nil {
+		return nil, err
+	}
+	key, err := readKey(filepath.Join(dir, CAKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, key: key}, nil
+}
+
+// LoadOrCreateCA reads the CA from dir, or creates a new one there if dir
+// has none yet.
+func LoadOrCreateCA(dir string) (*CA, error) {
+	if _, err := os.Stat(filepath.Join(dir, CACertFile)); err == nil {
+		return LoadCA(dir)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newTemplate("cluster-sim-ca", nil, caValidity)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePair(dir, CACertFile, CAKeyFile, der, key); err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, key: key}, nil
+}
+
+func newTemplate(commonName string, organizations []string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: organizations},
+		// Allow for clocks that are a little behind.
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(validity),
+	}, nil
+}
+
+// issue signs a new key for template and writes both to dir.
+func (ca *CA) issue(dir, certFile, keyFile string, template *x509.Certificate) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.BasicConstraintsValid = true
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.key)
+	if err != nil {
+		return err
+	}
+	return writePair(dir, certFile, keyFile, der, key)
+}
+
+// IssueServer writes a server certificate for hosts, DNS names or IP
+// addresses, to dir.
+func (ca *CA) IssueServer(dir, certFile, keyFile string, hosts []string) error {
+	template, err := newTemplate("cluster-sim-apiserver", nil, certValidity)
+	if err != nil {
+		return err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	return ca.issue(dir, certFile, keyFile, template)
+}
+
+// IssueClient writes a client certificate for user, a member of groups, to
+// dir. The API server authenticates it as that user.
+func (ca *CA) IssueClient(dir, certFile, keyFile, user string, groups []string) error {
+	template, err := newTemplate(user, groups, certValidity)
+	if err != nil {
+		return err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return ca.issue(dir, certFile, keyFile, template)
+}
+
+// Bootstrap makes sure dir holds a CA, a server certificate valid for hosts
+// and an admin client certificate, creating the ones that are missing.
+// Certificates that are about to expire are replaced, and so is a server
+// certificate that lacks one of the hosts.
+func Bootstrap(dir string, hosts []string) error {
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		return fmt.Errorf("CA: %v", err)
+	}
+	if !serverCertValid(filepath.Join(dir, ServerCertFile), ca, hosts) {
+		if err := ca.IssueServer(dir, ServerCertFile, ServerKeyFile, hosts); err != nil {
+			return fmt.Errorf("server certificate: %v", err)
+		}
+	}
+	if !certValid(filepath.Join(dir, AdminCertFile), ca) {
+		if err := ca.IssueClient(dir, AdminCertFile, AdminKeyFile, AdminUser, []string{AdminGroup}); err != nil {
+			return fmt.Errorf("admin certificate: %v", err)
+		}
+	}
+	return nil
+}
+
+// certValid reports whether the certificate at path was signed by ca and is
+// good for a while longer.
+func certValid(path string, ca *CA) bool {
+	cert, err := readCert(path)
+	if err != nil {
+		return false
+	}
+	return cert.CheckSignatureFrom(ca.Cert) == nil && time.Until(cert.NotAfter) >= renewBefore
+}
+
+// serverCertValid reports whether the certificate at path is valid as by
+// certValid and covers every host.
+func serverCertValid(path string, ca *CA, hosts []string) bool {
+	if !certValid(path, ca) {
+		return false
+	}
+	cert, err := readCert(path)
+	if err != nil {
+		return false
+	}
+	for _, h := range hosts {
+		if cert.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writePair writes a DER certificate and its key as PEM files. The key is
+// only readable by its owner.
+func writePair(dir, certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, keyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, certFile), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+func readCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no PEM certificate found", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func readKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key found", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type", path)
+	}
+	return signer, nil
+}
@@ -0,0 +1,246 @@
+package pki
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// verify checks the certificate at path chains to ca for usage, and
+// returns it.
+func verify(t *testing.T, ca *CA, path string, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	cert, err := readCert(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{usage}}); err != nil {
+		t.Fatalf("%s does not verify: %v", path, err)
+	}
+	return cert
+}
+
+func TestIssue(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Cert.IsCA {
+		t.Fatalf("CA certificate is not a CA")
+	}
+
+	t.Run("server", func(t *testing.T) {
+		hosts := []string{"localhost", "api.example.com", "127.0.0.1", "::1"}
+		if err := ca.IssueServer(dir, "s.crt", "s.key", hosts); err != nil {
+			t.Fatal(err)
+		}
+		cert := verify(t, ca, filepath.Join(dir, "s.crt"), x509.ExtKeyUsageServerAuth)
+		for _, h := range hosts {
+			if err := cert.VerifyHostname(h); err != nil {
+				t.Errorf("VerifyHostname(%s): %v", h, err)
+			}
+		}
+		if err := cert.VerifyHostname("other.example.com"); err == nil {
+			t.Errorf("certificate is valid for a host it was not issued for")
+		}
+		if _, err := readKey(filepath.Join(dir, "s.key")); err != nil {
+			t.Errorf("reading key: %v", err)
+		}
+	})
+
+	clients := []struct {
+		name   string
+		user   string
+		groups []string
+	}{
+		{"user without groups", "alice", nil},
+		{"user with groups", "bob", []string{"dev", "ops"}},
+	}
+	for _, tt := range clients {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ca.IssueClient(dir, tt.user+".crt", tt.user+".key", tt.user, tt.groups); err != nil {
+				t.Fatal(err)
+			}
+			cert := verify(t, ca, filepath.Join(dir, tt.user+".crt"), x509.ExtKeyUsageClientAuth)
+			if cert.Subject.CommonName != tt.user {
+				t.Errorf("common name = %q, want %q", cert.Subject.CommonName, tt.user)
+			}
+			if !reflect.DeepEqual(cert.Subject.Organization, tt.groups) {
+				t.Errorf("organizations = %v, want %v", cert.Subject.Organization, tt.groups)
+			}
+			if left := time.Until(cert.NotAfter); left < certValidity-time.Minute || left > certValidity {
+				t.Errorf("certificate is valid for %v, want %v", left, certValidity)
+			}
+			info, err := os.Stat(filepath.Join(dir, tt.user+".key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("key file mode = %v, want 0600", perm)
+			}
+		})
+	}
+
+	t.Run("reloaded CA signs the same", func(t *testing.T) {
+		again, err := LoadOrCreateCA(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !again.Cert.Equal(ca.Cert) {
+			t.Fatalf("LoadOrCreateCA created a new CA over an existing one")
+		}
+		if err := again.IssueClient(dir, "carol.crt", "carol.key", "carol", nil); err != nil {
+			t.Fatal(err)
+		}
+		verify(t, ca, filepath.Join(dir, "carol.crt"), x509.ExtKeyUsageClientAuth)
+	})
+}
+
+// issueExpiring replaces a certificate of dir with one that expires in
+// validity, signed by ca.
+func issueExpiring(t *testing.T, ca *CA, dir, certFile, keyFile string, usage x509.ExtKeyUsage, validity time.Duration) {
+	t.Helper()
+	template, err := newTemplate("expiring", []string{AdminGroup}, validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	template.DNSNames = []string{"localhost"}
+	if err := ca.issue(dir, certFile, keyFile, template); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBootstrapRenewal(t *testing.T) {
+	hosts := []string{"localhost", "127.0.0.1"}
+	tests := []struct {
+		name       string
+		prepare    func(t *testing.T, dir string, ca *CA) // after a first Bootstrap
+		hosts      []string                               // of the second Bootstrap
+		wantServer bool                                   // server certificate replaced
+		wantAdmin  bool                                   // admin certificate replaced
+	}{
+		{
+			name:    "valid certificates are kept",
+			prepare: func(*testing.T, string, *CA) {},
+			hosts:   hosts,
+		},
+		{
+			name: "expiring server certificate",
+			prepare: func(t *testing.T, dir string, ca *CA) {
+				issueExpiring(t, ca, dir, ServerCertFile, ServerKeyFile, x509.ExtKeyUsageServerAuth, renewBefore-time.Hour)
+			},
+			hosts:      []string{"localhost"},
+			wantServer: true,
+		},
+		{
+			name: "expiring admin certificate",
+			prepare: func(t *testing.T, dir string, ca *CA) {
+				issueExpiring(t, ca, dir, AdminCertFile, AdminKeyFile, x509.ExtKeyUsageClientAuth, renewBefore-time.Hour)
+			},
+			hosts:     hosts,
+			wantAdmin: true,
+		},
+		{
+			name: "admin certificate not yet due",
+			prepare: func(t *testing.T, dir string, ca *CA) {
+				issueExpiring(t, ca, dir, AdminCertFile, AdminKeyFile, x509.ExtKeyUsageClientAuth, renewBefore+time.Hour)
+			},
+			hosts: hosts,
+		},
+		{
+			name:       "server certificate lacks a host",
+			prepare:    func(*testing.T, string, *CA) {},
+			hosts:      append([]string{"api.example.com"}, hosts...),
+			wantServer: true,
+		},
+		{
+			name: "certificates of another CA",
+			prepare: func(t *testing.T, dir string, _ *CA) {
+				other, err := LoadOrCreateCA(t.TempDir())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := other.IssueServer(dir, ServerCertFile, ServerKeyFile, hosts); err != nil {
+					t.Fatal(err)
+				}
+				if err := other.IssueClient(dir, AdminCertFile, AdminKeyFile, AdminUser, []string{AdminGroup}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			hosts:      hosts,
+			wantServer: true,
+			wantAdmin:  true,
+		},
+		{
+			name: "missing certificates",
+			prepare: func(t *testing.T, dir string, _ *CA) {
+				for _, f := range []string{ServerCertFile, AdminCertFile} {
+					if err := os.Remove(filepath.Join(dir, f)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			hosts:      hosts,
+			wantServer: true,
+			wantAdmin:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := Bootstrap(dir, hosts); err != nil {
+				t.Fatal(err)
+			}
+			ca, err := LoadCA(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.prepare(t, dir, ca)
+			before := map[string][]byte{}
+			for _, f := range []string{ServerCertFile, AdminCertFile} {
+				before[f], _ = os.ReadFile(filepath.Join(dir, f))
+			}
+
+			if err := Bootstrap(dir, tt.hosts); err != nil {
+				t.Fatal(err)
+			}
+			for f, want := range map[string]bool{ServerCertFile: tt.wantServer, AdminCertFile: tt.wantAdmin} {
+				after, err := os.ReadFile(filepath.Join(dir, f))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if replaced := string(after) != string(before[f]); replaced != want {
+					t.Errorf("%s replaced = %v, want %v", f, replaced, want)
+				}
+			}
+
+			again, err := LoadCA(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !again.Cert.Equal(ca.Cert) {
+				t.Errorf("Bootstrap replaced the CA")
+			}
+			server := verify(t, ca, filepath.Join(dir, ServerCertFile), x509.ExtKeyUsageServerAuth)
+			for _, h := range tt.hosts {
+				if err := server.VerifyHostname(h); err != nil {
+					t.Errorf("server certificate: %v", err)
+				}
+			}
+			admin := verify(t, ca, filepath.Join(dir, AdminCertFile), x509.ExtKeyUsageClientAuth)
+			if time.Until(admin.NotAfter) < renewBefore {
+				t.Errorf("admin certificate expires at %v, within the renewal window", admin.NotAfter)
+			}
+			if tt.wantAdmin && (admin.Subject.CommonName != AdminUser || !reflect.DeepEqual(admin.Subject.Organization, []string{AdminGroup})) {
+				t.Errorf("renewed admin certificate is for %q in %v", admin.Subject.CommonName, admin.Subject.Organization)
+			}
+		})
+	}
+}
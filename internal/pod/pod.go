@@ -2,14 +2,65 @@ package pod
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 )
 
 type Pod struct {
 	ID     string `json:"id"`
+	Namespace string `json:"namespace,omitempty"` //Namespace for admission and quota; pod IDs are unique across namespaces
 	CPUs   int    `json:"cpus"`
 	NodeID string `json:"node_id"` //ID of the node it is scheduled on
 	Status string `json:"status"`  //e.g., Pending, Running, Failed
+	Labels map[string]string `json:"labels,omitempty"`
+	GracePeriodSeconds int `json:"termination_grace_period_seconds"` //Time given to shut down when evicted
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"` //Scheduling algorithm requested for the pod
+	CreatedAt time.Time `json:"created_at"`
+	Owner string `json:"owner,omitempty"` //Controller that manages the pod, e.g. PodGroup/web
+	Utilization float64 `json:"utilization"` //Simulated CPU use as a fraction of the requested CPUs
+	RunSeconds int `json:"run_seconds,omitempty"` //Simulated run time of a pod that runs to completion
+	Command []string `json:"command,omitempty"` //Command run in the node container instead of a simulated run
+	FailureRate float64 `json:"failure_rate,omitempty"` //Chance that a simulated run fails
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode *int `json:"exit_code,omitempty"`
+	NodeName string `json:"node_name,omitempty"` //Node the pod is bound to, as DaemonSet pods are
+	Claims []string `json:"claims,omitempty"` //Persistent volume claims the pod mounts
+	IP string `json:"ip,omitempty"` //Address from the node's pod CIDR while the pod is bound to it
+}
+
+// RunsToCompletion reports whether the pod exits on its own, as batch pods
+// do, rather than running until it is deleted.
+func (p Pod) RunsToCompletion() bool {
+	return p.RunSeconds > 0 || len(p.Command) > 0
+}
+
+// Finished reports whether the pod has exited.
+func (p Pod) Finished() bool {
+	return p.Status == "Succeeded" || p.Status == "Failed"
+}
+
+// Toleration lets a pod be scheduled onto nodes with a matching taint.
+// Operator is "Equal" (the default) or "Exists"; an empty Key with Exists
+// tolerates every taint, and an empty Effect matches all effects.
+type Toleration struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+// Tolerates reports whether the toleration matches a taint.
+func (t Toleration) Tolerates(key, value, effect string) bool {
+	if t.Effect != "" && t.Effect != effect {
+		return false
+	}
+	if t.Operator == "Exists" {
+		return t.Key == "" || t.Key == key
+	}
+	return t.Key == key && t.Value == value
 }
 
 // CreatePod function to create a pod
@@ -19,5 +70,6 @@ func CreatePod(cpus int) Pod {
 		ID:     podID,
 		CPUs:   cpus,
 		Status: "Pending", // Initial status
+		CreatedAt: time.Now(),
 	}
 }
@@ -0,0 +1,149 @@
+// Package policy implements PodDisruptionBudgets, which limit how many pods
+// of a group may be voluntarily disrupted at once.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"cluster-sim/internal/labels"
+)
+
+// IntOrPercent is an absolute pod count ("2") or a percentage of the
+// expected pods ("50%"). In JSON it is either a number or a string.
+type IntOrPercent struct {
+	Value     int
+	IsPercent bool
+}
+
+// UnmarshalJSON accepts 2, "2" and "50%".
+func (v *IntOrPercent) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*v = IntOrPercent{Value: n}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("expected a number or a percentage, got %s", string(data))
+	}
+	parsed, err := ParseIntOrPercent(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// MarshalJSON writes numbers as numbers and percentages as "N%".
+func (v IntOrPercent) MarshalJSON() ([]byte, error) {
+	if v.IsPercent {
+		return json.Marshal(v.String())
+	}
+	return json.Marshal(v.Value)
+}
+
+func (v IntOrPercent) String() string {
+	if v.IsPercent {
+		return strconv.Itoa(v.Value) + "%"
+	}
+	return strconv.Itoa(v.Value)
+}
+
+// ParseIntOrPercent parses "2" or "50%".
+func ParseIntOrPercent(s string) (IntOrPercent, error) {
+	s = strings.TrimSpace(s)
+	isPercent := strings.HasSuffix(s, "%")
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < 0 {
+		return IntOrPercent{}, fmt.Errorf("invalid value %q, expected a non-negative number or percentage", s)
+	}
+	return IntOrPercent{Value: n, IsPercent: isPercent}, nil
+}
+
+// resolve converts the value to a pod count out of total, rounding percentages up.
+func (v IntOrPercent) resolve(total int) int {
+	if !v.IsPercent {
+		return v.Value
+	}
+	return int(math.Ceil(float64(v.Value) * float64(total) / 100))
+}
+
+// PodDisruptionBudget limits voluntary disruptions of the pods matching Selector.
+// Exactly one of MinAvailable and MaxUnavailable must be set.
+type PodDisruptionBudget struct {
+	Name           string        `json:"name"`
+	Selector       string        `json:"selector"`
+	MinAvailable   *IntOrPercent `json:"min_available,omitempty"`
+	MaxUnavailable *IntOrPercent `json:"max_unavailable,omitempty"`
+}
+
+// Status is the computed state of a budget against the current pods.
+type Status struct {
+	ExpectedPods       int `json:"expected_pods"`
+	CurrentHealthy     int `json:"current_healthy"`
+	DesiredHealthy     int `json:"desired_healthy"`
+	DisruptionsAllowed int `json:"disruptions_allowed"`
+}
+
+// PodInfo is what a budget needs to know about a pod.
+type PodInfo struct {
+	Labels  map[string]string
+	Healthy bool
+}
+
+// Validate checks the budget is well formed.
+func (b PodDisruptionBudget) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if (b.MinAvailable == nil) == (b.MaxUnavailable == nil) {
+		return fmt.Errorf("exactly one of min_available and max_unavailable must be set")
+	}
+	if _, err := labels.Parse(b.Selector); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Matches reports whether the budget covers a pod with the given labels.
+// An empty selector covers no pods, as in Kubernetes' policy/v1.
+func (b PodDisruptionBudget) Matches(podLabels map[string]string) bool {
+	sel, err := labels.Parse(b.Selector)
+	if err != nil || sel.Empty() {
+		return false
+	}
+	return sel.Matches(podLabels)
+}
+
+// Compute evaluates the budget against every pod in the cluster.
+func (b PodDisruptionBudget) Compute(pods []PodInfo) Status {
+	var st Status
+	for _, p := range pods {
+		if !b.Matches(p.Labels) {
+			continue
+		}
+		st.ExpectedPods++
+		if p.Healthy {
+			st.CurrentHealthy++
+		}
+	}
+
+	if b.MinAvailable != nil {
+		st.DesiredHealthy = b.MinAvailable.resolve(st.ExpectedPods)
+	} else if b.MaxUnavailable != nil {
+		st.DesiredHealthy = st.ExpectedPods - b.MaxUnavailable.resolve(st.ExpectedPods)
+		if st.DesiredHealthy < 0 {
+			st.DesiredHealthy = 0
+		}
+	}
+
+	st.DisruptionsAllowed = st.CurrentHealthy - st.DesiredHealthy
+	if st.DisruptionsAllowed < 0 {
+		st.DisruptionsAllowed = 0
+	}
+	return st
+}
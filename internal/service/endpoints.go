@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"cluster-sim/internal/events"
+	"cluster-sim/internal/labels"
+	"cluster-sim/internal/logging"
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/node"
+)
+
+// endpointsSyncInterval is how often each service's endpoints are rebuilt
+// from the pods it selects and the nodes hosting them are probed.
+const endpointsSyncInterval = time.Second
+
+var logger = logging.For("service")
+
+// Endpoint is a pod selected by a service. Only ready endpoints, Running
+// pods on Running nodes, receive traffic.
+type Endpoint struct {
+	PodID  string `json:"pod_id"`
+	NodeID string `json:"node_id"`
+	Ready  bool   `json:"ready"`
+}
+
+// PodStats counts the requests a service sent to one pod. They are kept
+// after the pod leaves the endpoints so failures remain visible.
+type PodStats struct {
+	PodID             string `json:"pod_id"`
+	NodeID            string `json:"node_id"`
+	Requests          int64  `json:"requests"`
+	Failures          int64  `json:"failures"`
+	ActiveConnections int    `json:"active_connections"`
+}
+
+// RequestStats counts every request sent to a service.
+type RequestStats struct {
+	Total     int64            `json:"total"`
+	Succeeded int64            `json:"succeeded"`
+	Failed    int64            `json:"failed"`
+	InFlight  int              `json:"in_flight"`
+	Failures  map[string]int64 `json:"failures"` // by reason
+}
+
+// ServiceStatus is a service together with its endpoints and traffic stats.
+type ServiceStatus struct {
+	Service
+	Endpoints      []Endpoint   `json:"endpoints"`
+	ReadyEndpoints int          `json:"ready_endpoints"`
+	Requests       RequestStats `json:"requests"`
+	Pods           []PodStats   `json:"pods"`
+}
+
+// serviceState is everything the controller keeps about one service.
+type serviceState struct {
+	svc       Service
+	endpoints []Endpoint // sorted by pod ID
+	requests  RequestStats
+	pods      map[string]*PodStats
+	inFlight  []request
+	next      int     // round robin position
+	pending   float64 // fraction of a request carried over to the next tick
+}
+
+// ready returns the endpoints that may receive traffic.
+func (st *serviceState) ready() []Endpoint {
+	var ready []Endpoint
+	for _, ep := range st.endpoints {
+		if ep.Ready {
+			ready = append(ready, ep)
+		}
+	}
+	return ready
+}
+
+// Controller keeps the endpoints of every service up to date and runs their
+// load balancers.
+type Controller struct {
+	nm *node.NodeManager
+
+	mu         sync.Mutex // Protects everything below
+	services   map[string]*serviceState
+	clusterIPs map[string]string // cluster IP to service name
+	// containerUp records whether the container of each node hosting an
+	// endpoint was running when last probed. Requests use it rather than
+	// the node status, which lags until the HealthManager notices.
+	containerUp map[string]bool
+}
+
+// NewController creates a service controller for the cluster managed by nm.
+func NewController(nm *node.NodeManager) *Controller {
+	return &Controller{
+		nm:          nm,
+		services:    make(map[string]*serviceState),
+		clusterIPs:  make(map[string]string),
+		containerUp: make(map[string]bool),
+	}
+}
+
+// Start runs the endpoints and load balancer loops in goroutines.
+func (c *Controller) Start() {
+	go func() {
+		for {
+			for _, name := range c.names() {
+				c.syncEndpoints(name)
+			}
+			c.probeNodes()
+			time.Sleep(endpointsSyncInterval)
+		}
+	}()
+	go func() {
+		last := time.Now()
+		for {
+			time.Sleep(loadBalancerTick)
+			now := time.Now()
+			c.balance(now, now.Sub(last))
+			last = now
+		}
+	}()
+}
+
+func (c *Controller) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.services))
+	for name := range c.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set creates or replaces a service and syncs its endpoints right away. A
+// replaced service keeps its cluster IP, unless another is asked for, and
+// its request stats.
+func (c *Controller) Set(svc Service) (Service, error) {
+	if err := svc.Validate(); err != nil {
+		return Service{}, err
+	}
+	c.mu.Lock()
+	st, exists := c.services[svc.Name]
+	if svc.ClusterIP == "" && exists {
+		svc.ClusterIP = st.svc.ClusterIP
+	}
+	ip, err := c.allocateIPLocked(svc.Name, svc.ClusterIP)
+	if err != nil {
+		c.mu.Unlock()
+		return Service{}, err
+	}
+	if exists && st.svc.ClusterIP != ip {
+		delete(c.clusterIPs, st.svc.ClusterIP)
+	}
+	svc.ClusterIP = ip
+	if !exists {
+		st = &serviceState{pods: make(map[string]*PodStats), requests: RequestStats{Failures: make(map[string]int64)}}
+		c.services[svc.Name] = st
+	}
+	st.svc = svc
+	c.mu.Unlock()
+
+	logger.Info("Service set", "service", svc.Name, "selector", svc.Selector, "cluster_ip", svc.ClusterIP, "load_balancing", svc.LoadBalancing, "rps", svc.Traffic.RequestsPerSecond)
+	c.syncEndpoints(svc.Name)
+	return svc, nil
+}
+
+// allocateIPLocked reserves want for the service, or the lowest free
+// address in the service range when want is empty.
+func (c *Controller) allocateIPLocked(name, want string) (string, error) {
+	if want != "" {
+		if owner, taken := c.clusterIPs[want]; taken && owner != name {
+			return "", fmt.Errorf("cluster_ip %s is already used by service %s", want, owner)
+		}
+		c.clusterIPs[want] = name
+		return want, nil
+	}
+	base := serviceCIDR.IP.To4()
+	// Skip the network address and .1, which is kept for the API server by
+	// convention, and stop before the broadcast address.
+	for i := 2; i < 1<<16-1; i++ {
+		ip := net.IPv4(base[0], base[1], byte(i>>8), byte(i)).String()
+		if _, taken := c.clusterIPs[ip]; !taken {
+			c.clusterIPs[ip] = name
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("no free cluster IP in %s", serviceCIDR)
+}
+
+// SetTraffic changes the synthetic load sent to a service.
+func (c *Controller) SetTraffic(name string, traffic Traffic) error {
+	if err := traffic.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.services[name]
+	if !exists {
+		return ErrServiceNotFound
+	}
+	st.svc.Traffic = traffic
+	logger.Info("Service traffic set", "service", name, "rps", traffic.RequestsPerSecond, "duration_ms", traffic.DurationMillis)
+	return nil
+}
+
+// ResetStats clears a service's request counters, dropping the stats of
+// pods that are no longer endpoints.
+func (c *Controller) ResetStats(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.services[name]
+	if !exists {
+		return ErrServiceNotFound
+	}
+	st.requests = RequestStats{InFlight: len(st.inFlight), Failures: make(map[string]int64)}
+	active := make(map[string]int)
+	for _, r := range st.inFlight {
+		active[r.podID]++
+	}
+	current := make(map[string]bool)
+	for _, ep := range st.endpoints {
+		current[ep.PodID] = true
+	}
+	for podID, ps := range st.pods {
+		if !current[podID] && active[podID] == 0 {
+			delete(st.pods, podID)
+			continue
+		}
+		*ps = PodStats{PodID: ps.PodID, NodeID: ps.NodeID, ActiveConnections: active[podID]}
+	}
+	return nil
+}
+
+// Get returns a service by name.
+func (c *Controller) Get(name string) (Service, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.services[name]
+	if !exists {
+		return Service{}, false
+	}
+	return st.svc, true
+}
+
+// Delete removes a service, dropping its in-flight requests and stats.
+func (c *Controller) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.services[name]
+	if !exists {
+		return ErrServiceNotFound
+	}
+	delete(c.clusterIPs, st.svc.ClusterIP)
+	delete(c.services, name)
+	metrics.ServiceEndpoints.DeleteLabelValues(name)
+	return nil
+}
+
+// List returns every service with its status, by name.
+func (c *Controller) List() []ServiceStatus {
+	list := []ServiceStatus{}
+	for _, name := range c.names() {
+		if st, ok := c.Status(name); ok {
+			list = append(list, st)
+		}
+	}
+	return list
+}
+
+// Status returns a service with its endpoints and request stats.
+func (c *Controller) Status(name string) (ServiceStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.services[name]
+	if !exists {
+		return ServiceStatus{}, false
+	}
+	status := ServiceStatus{
+		Service:        st.svc,
+		Endpoints:      append([]Endpoint{}, st.endpoints...),
+		ReadyEndpoints: len(st.ready()),
+		Requests:       st.requests,
+		Pods:           []PodStats{},
+	}
+	status.Requests.Failures = make(map[string]int64, len(st.requests.Failures))
+	for reason, n := range st.requests.Failures {
+		status.Requests.Failures[reason] = n
+	}
+	for _, ps := range st.pods {
+		status.Pods = append(status.Pods, *ps)
+	}
+	sort.Slice(status.Pods, func(i, j int) bool { return status.Pods[i].PodID < status.Pods[j].PodID })
+	return status, true
+}
+
+// syncEndpoints rebuilds a service's endpoints from the pods its selector
+// matches. Pods that have exited are left out; the rest are ready when
+// they and their node are Running.
+func (c *Controller) syncEndpoints(name string) {
+	svc, exists := c.Get(name)
+	if !exists {
+		return
+	}
+	sel, err := labels.Parse(svc.Selector)
+	if err != nil {
+		return
+	}
+
+	c.nm.Mu.Lock()
+	endpoints := []Endpoint{}
+	for _, p := range c.nm.Pods {
+		if !sel.Matches(p.Labels) || p.Finished() || p.NodeID == "" {
+			continue
+		}
+		n, onNode := c.nm.Nodes[p.NodeID]
+		endpoints = append(endpoints, Endpoint{
+			PodID:  p.ID,
+			NodeID: p.NodeID,
+			Ready:  p.Status == "Running" && onNode && n.Status == "Running",
+		})
+	}
+	c.nm.Mu.Unlock()
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].PodID < endpoints[j].PodID })
+
+	c.mu.Lock()
+	st, exists := c.services[name]
+	if !exists {
+		c.mu.Unlock()
+		return
+	}
+	wasReady := len(st.ready())
+	st.endpoints = endpoints
+	ready := len(st.ready())
+	c.mu.Unlock()
+
+	metrics.ServiceEndpoints.WithLabelValues(name).Set(float64(ready))
+	if ready == 0 && wasReady > 0 {
+		c.nm.Events.Eventf(events.KindService, name, events.TypeWarning, "NoEndpoints", "No ready pods match selector %q", svc.Selector)
+	} else if ready > 0 && wasReady == 0 {
+		c.nm.Events.Eventf(events.KindService, name, events.TypeNormal, "EndpointsReady", "%d ready pods match selector %q", ready, svc.Selector)
+	}
+}
+
+// probeNodes checks the container of every node hosting an endpoint, so
+// requests to a node that died fail from the moment it stops rather than
+// once the HealthManager marks it.
+func (c *Controller) probeNodes() {
+	c.mu.Lock()
+	nodeIDs := make(map[string]bool)
+	for _, st := range c.services {
+		for _, ep := range st.endpoints {
+			nodeIDs[ep.NodeID] = true
+		}
+	}
+	c.mu.Unlock()
+
+	up := make(map[string]bool, len(nodeIDs))
+	for nodeID := range nodeIDs {
+		running, err := node.CheckNodeHealth(context.Background(), nodeID)
+		up[nodeID] = err == nil && running
+	}
+
+	c.mu.Lock()
+	c.containerUp = up
+	c.mu.Unlock()
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"math/rand"
+	"time"
+
+	"cluster-sim/internal/metrics"
+	"cluster-sim/internal/network"
+)
+
+// loadBalancerTick is how often synthetic requests are sent and completed.
+const loadBalancerTick = 100 * time.Millisecond
+
+// Request results. Every result but Success counts as a failure.
+const (
+	ResultSuccess           = "Success"
+	ReasonNoEndpoints       = "NoEndpoints"       // no ready pod to send the request to
+	ReasonConnectionRefused = "ConnectionRefused" // the chosen pod was already unreachable
+	ReasonConnectionReset   = "ConnectionReset"   // the pod became unreachable mid-request
+	ReasonConnectionTimeout = "ConnectionTimeout" // the network partitioned or lost the request
+	ReasonSourceUnavailable = "SourceUnavailable" // the source pod is not Running
+)
+
+// request is a synthetic request holding a connection to a pod until done.
+type request struct {
+	from   string // node of the source pod, or network.ControlPlane
+	podID  string
+	nodeID string
+	done   time.Time
+}
+
+// clusterSnapshot is the part of the cluster state that decides whether a
+// pod can be reached.
+type clusterSnapshot struct {
+	podNode    map[string]string // node of each Running pod
+	nodeStatus map[string]string
+}
+
+func (c *Controller) snapshot() clusterSnapshot {
+	c.nm.Mu.Lock()
+	defer c.nm.Mu.Unlock()
+	snap := clusterSnapshot{podNode: make(map[string]string), nodeStatus: make(map[string]string)}
+	for id, p := range c.nm.Pods {
+		if p.Status == "Running" {
+			snap.podNode[id] = p.NodeID
+		}
+	}
+	for id, n := range c.nm.Nodes {
+		snap.nodeStatus[id] = n.Status
+	}
+	return snap
+}
+
+// reachableLocked reports whether a request to a pod on a node gets through:
+// the pod must still be Running there and the node's container must be up.
+// Nodes not probed yet are trusted if their status is Running.
+func (c *Controller) reachableLocked(snap clusterSnapshot, podID, nodeID string) bool {
+	if snap.podNode[podID] != nodeID {
+		return false
+	}
+	if up, probed := c.containerUp[nodeID]; probed {
+		return up
+	}
+	return snap.nodeStatus[nodeID] == "Running"
+}
+
+// balance completes the requests that are due and sends the ones each
+// service's traffic model asks for over the elapsed time.
+func (c *Controller) balance(now time.Time, elapsed time.Duration) {
+	snap := c.snapshot()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, st := range c.services {
+		c.completeLocked(name, st, snap, now)
+		c.sendLocked(name, st, snap, now, elapsed)
+	}
+}
+
+func (c *Controller) completeLocked(name string, st *serviceState, snap clusterSnapshot, now time.Time) {
+	remaining := st.inFlight[:0]
+	for _, r := range st.inFlight {
+		if r.done.After(now) {
+			remaining = append(remaining, r)
+			continue
+		}
+		ps := st.pods[r.podID]
+		ps.ActiveConnections--
+		st.requests.InFlight--
+		if c.reachableLocked(snap, r.podID, r.nodeID) && c.nm.Network.Reachable(r.from, r.nodeID) {
+			st.requests.Succeeded++
+			metrics.ServiceRequests.WithLabelValues(name, ResultSuccess).Inc()
+		} else {
+			recordFailure(name, st, ps, ReasonConnectionReset)
+		}
+	}
+	st.inFlight = remaining
+}
+
+func (c *Controller) sendLocked(name string, st *serviceState, snap clusterSnapshot, now time.Time, elapsed time.Duration) {
+	st.pending += st.svc.Traffic.RequestsPerSecond * elapsed.Seconds()
+	count := int(st.pending)
+	st.pending -= float64(count)
+
+	ready := st.ready()
+	duration := time.Duration(st.svc.Traffic.DurationMillis) * time.Millisecond
+	from, sourceUp := network.ControlPlane, true
+	if source := st.svc.Traffic.SourcePod; source != "" {
+		from, sourceUp = snap.podNode[source]
+	}
+	for i := 0; i < count; i++ {
+		st.requests.Total++
+		if !sourceUp {
+			recordFailure(name, st, nil, ReasonSourceUnavailable)
+			continue
+		}
+		if len(ready) == 0 {
+			recordFailure(name, st, nil, ReasonNoEndpoints)
+			continue
+		}
+		ep := st.pick(ready)
+		ps := st.statsFor(ep)
+		ps.Requests++
+		if !c.reachableLocked(snap, ep.PodID, ep.NodeID) {
+			recordFailure(name, st, ps, ReasonConnectionRefused)
+			continue
+		}
+		latency, delivered := c.nm.Network.Deliver(from, ep.NodeID)
+		if !delivered {
+			recordFailure(name, st, ps, ReasonConnectionTimeout)
+			continue
+		}
+		ps.ActiveConnections++
+		st.requests.InFlight++
+		st.inFlight = append(st.inFlight, request{from: from, podID: ep.PodID, nodeID: ep.NodeID, done: now.Add(duration + 2*latency)})
+	}
+}
+
+// pick chooses the endpoint for the next request.
+func (st *serviceState) pick(ready []Endpoint) Endpoint {
+	switch st.svc.LoadBalancing {
+	case Random:
+		return ready[rand.Intn(len(ready))]
+	case LeastConnections:
+		// Fewest active connections, then fewest requests so far, so idle
+		// endpoints still share the load evenly.
+		best := ready[0]
+		for _, ep := range ready[1:] {
+			a, b := st.pods[ep.PodID], st.pods[best.PodID]
+			if a == nil || b == nil {
+				if a == nil && b != nil {
+					best = ep
+				}
+				continue
+			}
+			if a.ActiveConnections < b.ActiveConnections ||
+				(a.ActiveConnections == b.ActiveConnections && a.Requests < b.Requests) {
+				best = ep
+			}
+		}
+		return best
+	default:
+		st.next %= len(ready)
+		ep := ready[st.next]
+		st.next++
+		return ep
+	}
+}
+
+// statsFor returns the stats of an endpoint's pod, following it to a new node.
+func (st *serviceState) statsFor(ep Endpoint) *PodStats {
+	ps, exists := st.pods[ep.PodID]
+	if !exists {
+		ps = &PodStats{PodID: ep.PodID}
+		st.pods[ep.PodID] = ps
+	}
+	ps.NodeID = ep.NodeID
+	return ps
+}
+
+// recordFailure counts a failed request against the service and, when one
+// was chosen, the pod.
+func recordFailure(name string, st *serviceState, ps *PodStats, reason string) {
+	st.requests.Failed++
+	st.requests.Failures[reason]++
+	if ps != nil {
+		ps.Failures++
+	}
+	metrics.ServiceRequests.WithLabelValues(name, reason).Inc()
+}
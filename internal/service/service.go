@@ -0,0 +1,164 @@
+// Package service gives a stable address to a group of pods. The endpoints
+// controller tracks which pods a Service selects are ready, and a simulated
+// load balancer spreads synthetic requests over them so the effect of node
+// failures on clients can be measured.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"cluster-sim/internal/labels"
+)
+
+// DefaultNamespace is the namespace of services that do not name one. Pods
+// have no namespace of their own and are all treated as part of it.
+const DefaultNamespace = "default"
+
+// ErrServiceNotFound is returned when an operation names an unknown service.
+var ErrServiceNotFound = errors.New("service not found")
+
+// Load balancing algorithms
+const (
+	RoundRobin       = "round_robin"
+	LeastConnections = "least_connections"
+	Random           = "random"
+)
+
+// serviceCIDR is the range cluster IPs are allocated from.
+var serviceCIDR = &net.IPNet{IP: net.IPv4(10, 96, 0, 0).To4(), Mask: net.CIDRMask(16, 32)}
+
+// defaultRequestDurationMillis is how long a request holds its connection
+// when the traffic model does not say.
+const defaultRequestDurationMillis = 100
+
+// maxRequestsPerSecond bounds the simulated traffic of one service.
+const maxRequestsPerSecond = 10000
+
+// Port maps a port of the service to a port of its pods.
+type Port struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol,omitempty"` // TCP (the default) or UDP
+	Port       int    `json:"port"`
+	TargetPort int    `json:"target_port,omitempty"` // defaults to Port
+}
+
+// Traffic is the synthetic load sent to a service. Each request holds a
+// connection to one endpoint for DurationMillis, plus the latency of the
+// link there and back, and fails if the endpoint becomes unreachable before
+// it completes. Requests come from SourcePod, or from outside the cluster
+// through the control plane when it is empty.
+type Traffic struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	DurationMillis    int     `json:"duration_ms,omitempty"`
+	SourcePod         string  `json:"source_pod,omitempty"`
+}
+
+// Validate fills in defaults and checks the traffic model is well formed.
+func (t *Traffic) Validate() error {
+	if t.RequestsPerSecond < 0 || t.RequestsPerSecond > maxRequestsPerSecond {
+		return fmt.Errorf("requests_per_second must be between 0 and %d", maxRequestsPerSecond)
+	}
+	if t.DurationMillis == 0 {
+		t.DurationMillis = defaultRequestDurationMillis
+	}
+	if t.DurationMillis < 0 {
+		return fmt.Errorf("duration_ms must be positive")
+	}
+	return nil
+}
+
+// Service selects pods by label and balances requests over the ready ones.
+// The namespace only qualifies its DNS name; service names are unique
+// across namespaces.
+type Service struct {
+	Name          string  `json:"name"`
+	Namespace     string  `json:"namespace,omitempty"`
+	Selector      string  `json:"selector"`
+	Ports         []Port  `json:"ports"`
+	LoadBalancing string  `json:"load_balancing,omitempty"` // round_robin (the default), least_connections or random
+	Traffic       Traffic `json:"traffic"`
+	// ClusterIP is allocated from 10.96.0.0/16 unless one is asked for.
+	ClusterIP string `json:"cluster_ip,omitempty"`
+}
+
+// Validate fills in defaults and checks the service is well formed.
+func (s *Service) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !isDNSLabel(s.Name) {
+		return fmt.Errorf("name must be a DNS label: lowercase letters, digits and '-'")
+	}
+	if s.Namespace == "" {
+		s.Namespace = DefaultNamespace
+	}
+	if !isDNSLabel(s.Namespace) {
+		return fmt.Errorf("namespace must be a DNS label: lowercase letters, digits and '-'")
+	}
+	sel, err := labels.Parse(s.Selector)
+	if err != nil {
+		return err
+	}
+	if sel.Empty() {
+		return fmt.Errorf("selector is required")
+	}
+	if len(s.Ports) == 0 {
+		return fmt.Errorf("at least one port is required")
+	}
+	names := make(map[string]bool)
+	for i := range s.Ports {
+		p := &s.Ports[i]
+		if p.Port < 1 || p.Port > 65535 {
+			return fmt.Errorf("port %d is out of range", p.Port)
+		}
+		if p.TargetPort == 0 {
+			p.TargetPort = p.Port
+		}
+		if p.TargetPort < 1 || p.TargetPort > 65535 {
+			return fmt.Errorf("target_port %d is out of range", p.TargetPort)
+		}
+		switch p.Protocol {
+		case "":
+			p.Protocol = "TCP"
+		case "TCP", "UDP":
+		default:
+			return fmt.Errorf("protocol must be TCP or UDP")
+		}
+		if len(s.Ports) > 1 && p.Name == "" {
+			return fmt.Errorf("ports must be named when there is more than one")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate port name %q", p.Name)
+		}
+		names[p.Name] = true
+	}
+	switch s.LoadBalancing {
+	case "":
+		s.LoadBalancing = RoundRobin
+	case RoundRobin, LeastConnections, Random:
+	default:
+		return fmt.Errorf("unknown load balancing algorithm %q, expected round_robin, least_connections or random", s.LoadBalancing)
+	}
+	if s.ClusterIP != "" {
+		ip := net.ParseIP(s.ClusterIP)
+		if ip == nil || !serviceCIDR.Contains(ip) {
+			return fmt.Errorf("cluster_ip must be an address in %s", serviceCIDR)
+		}
+	}
+	return s.Traffic.Validate()
+}
+
+// isDNSLabel reports whether s can be used as one label of a DNS name.
+func isDNSLabel(s string) bool {
+	if len(s) == 0 || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
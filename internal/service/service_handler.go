@@ -0,0 +1,79 @@
+// All the gin handlers are here for service package
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API Handler to create or replace a service
+func (c *Controller) AddServiceHandler(ctx *gin.Context) {
+	var svc Service
+	if err := ctx.ShouldBindJSON(&svc); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	svc, err := c.Set(svc)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Service saved", "name": svc.Name, "cluster_ip": svc.ClusterIP})
+}
+
+// API Handler to list services with their endpoints and request stats
+func (c *Controller) ListServicesHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.List())
+}
+
+// API Handler to show one service
+func (c *Controller) GetServiceHandler(ctx *gin.Context) {
+	st, exists := c.Status(ctx.Param("name"))
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, st)
+}
+
+// API Handler to delete a service
+func (c *Controller) DeleteServiceHandler(ctx *gin.Context) {
+	if err := c.Delete(ctx.Param("name")); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Service deleted", "name": ctx.Param("name")})
+}
+
+// API Handler to set the synthetic traffic sent to a service
+func (c *Controller) SetTrafficHandler(ctx *gin.Context) {
+	var traffic Traffic
+	if err := ctx.ShouldBindJSON(&traffic); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := c.SetTraffic(ctx.Param("name"), traffic); err != nil {
+		serviceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Service traffic updated", "name": ctx.Param("name")})
+}
+
+// API Handler to clear a service's request stats
+func (c *Controller) ResetStatsHandler(ctx *gin.Context) {
+	if err := c.ResetStats(ctx.Param("name")); err != nil {
+		serviceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Service stats reset", "name": ctx.Param("name")})
+}
+
+func serviceError(ctx *gin.Context, err error) {
+	if errors.Is(err, ErrServiceNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
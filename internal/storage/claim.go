@@ -0,0 +1,43 @@
+// Package storage implements simulated persistent volumes and the claims
+// that bind them to pods, giving pods storage that outlives them.
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Access modes a claim can request
+const (
+	ReadWriteOnce = "ReadWriteOnce"
+	ReadOnlyMany  = "ReadOnlyMany"
+	ReadWriteMany = "ReadWriteMany"
+)
+
+// PersistentVolumeClaim is a request for storage. A claim is kept when the
+// pods using it go away, so a pod recreated under the same name finds its
+// data again.
+type PersistentVolumeClaim struct {
+	Name         string    `json:"name"`
+	StorageClass string    `json:"storage_class,omitempty"`
+	AccessModes  []string  `json:"access_modes,omitempty"`
+	StorageGiB   int       `json:"storage_gib"`
+	Owner        string    `json:"owner,omitempty"` // Controller that created the claim, e.g. StatefulSet/db
+	Phase        string    `json:"phase"`
+	VolumeName   string    `json:"volume_name,omitempty"` // volume the claim is bound to
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Validate fills in defaults and checks the claim is well formed.
+func (c *PersistentVolumeClaim) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.StorageGiB <= 0 {
+		return fmt.Errorf("storage_gib must be positive")
+	}
+	if len(c.AccessModes) == 0 {
+		c.AccessModes = []string{ReadWriteOnce}
+	}
+	return validateAccessModes(c.AccessModes)
+}
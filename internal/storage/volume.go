@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Volume phases
+const (
+	VolumeAvailable = "Available" // not yet bound to a claim
+	VolumeBound     = "Bound"
+	VolumeReleased  = "Released" // its claim was deleted; kept for its data
+)
+
+// Claim phases
+const (
+	ClaimPending = "Pending"
+	ClaimBound   = "Bound"
+)
+
+// Reclaim policies decide what happens to a volume whose claim is deleted.
+const (
+	ReclaimRetain = "Retain"
+	ReclaimDelete = "Delete"
+)
+
+// Volume binding modes of a storage class
+const (
+	BindingImmediate            = "Immediate"
+	BindingWaitForFirstConsumer = "WaitForFirstConsumer"
+)
+
+// PersistentVolume is a piece of simulated storage. A local volume (Node
+// set) only exists on one node, so pods using it must run there.
+type PersistentVolume struct {
+	Name          string    `json:"name"`
+	CapacityGiB   int       `json:"capacity_gib"`
+	AccessModes   []string  `json:"access_modes,omitempty"`
+	StorageClass  string    `json:"storage_class,omitempty"`
+	Node          string    `json:"node,omitempty"`           // node a local volume lives on
+	ReclaimPolicy string    `json:"reclaim_policy,omitempty"` // Retain (default) or Delete
+	Phase         string    `json:"phase"`
+	ClaimRef      string    `json:"claim_ref,omitempty"` // name of the claim bound to the volume
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Validate fills in defaults and checks the volume is well formed.
+func (v *PersistentVolume) Validate() error {
+	if v.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if v.CapacityGiB <= 0 {
+		return fmt.Errorf("capacity_gib must be positive")
+	}
+	if len(v.AccessModes) == 0 {
+		v.AccessModes = []string{ReadWriteOnce}
+	}
+	if err := validateAccessModes(v.AccessModes); err != nil {
+		return err
+	}
+	switch v.ReclaimPolicy {
+	case "":
+		v.ReclaimPolicy = ReclaimRetain
+	case ReclaimRetain, ReclaimDelete:
+	default:
+		return fmt.Errorf("reclaim_policy must be Retain or Delete")
+	}
+	return nil
+}
+
+// CanBind reports why the volume cannot satisfy the claim, or "" if it can:
+// it must be available, of the claim's storage class, large enough and offer
+// every access mode the claim asks for.
+func (v PersistentVolume) CanBind(c PersistentVolumeClaim) string {
+	switch {
+	case v.Phase != VolumeAvailable:
+		return fmt.Sprintf("volume is %s", v.Phase)
+	case v.StorageClass != c.StorageClass:
+		return "storage class differs"
+	case v.CapacityGiB < c.StorageGiB:
+		return "capacity too small"
+	}
+	for _, mode := range c.AccessModes {
+		if !contains(v.AccessModes, mode) {
+			return fmt.Sprintf("access mode %s not offered", mode)
+		}
+	}
+	return ""
+}
+
+// AvailableOn reports whether pods on the given node can use the volume.
+func (v PersistentVolume) AvailableOn(nodeID string) bool {
+	return v.Node == "" || v.Node == nodeID
+}
+
+// StorageClass groups volumes and decides when their claims are bound.
+// WaitForFirstConsumer claims stay Pending until a pod using them is
+// scheduled, so a local volume on that pod's node can be picked.
+type StorageClass struct {
+	Name              string `json:"name"`
+	VolumeBindingMode string `json:"volume_binding_mode,omitempty"` // Immediate (default) or WaitForFirstConsumer
+}
+
+// Validate fills in defaults and checks the storage class is well formed.
+func (s *StorageClass) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch s.VolumeBindingMode {
+	case "":
+		s.VolumeBindingMode = BindingImmediate
+	case BindingImmediate, BindingWaitForFirstConsumer:
+	default:
+		return fmt.Errorf("volume_binding_mode must be Immediate or WaitForFirstConsumer")
+	}
+	return nil
+}
+
+func validateAccessModes(modes []string) error {
+	for _, mode := range modes {
+		switch mode {
+		case ReadWriteOnce, ReadOnlyMany, ReadWriteMany:
+		default:
+			return fmt.Errorf("unknown access mode %q, expected ReadWriteOnce, ReadOnlyMany or ReadWriteMany", mode)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
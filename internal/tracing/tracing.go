@@ -0,0 +1,172 @@
+// Package tracing sets up OpenTelemetry tracing for the simulator. Spans of
+// API requests, scheduling cycles, Docker calls and health checks are
+// exported over OTLP/HTTP or written to a file as JSON, and log lines carry
+// the ID of the trace they belong to.
+package tracing
+
+import (
+	"cluster-sim/internal/logging"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the service spans are reported under.
+const ServiceName = "cluster-sim"
+
+// Config says where spans go. With neither an endpoint nor a file tracing
+// is off and spans cost next to nothing.
+type Config struct {
+	Endpoint    string  // OTLP/HTTP collector, e.g. localhost:4318 or https://otel:4318
+	File        string  // JSON file to write spans to, "-" for standard output
+	SampleRatio float64 // share of new traces to record, 0 to 1
+}
+
+// Enabled reports whether spans are exported anywhere.
+func (cfg Config) Enabled() bool {
+	return cfg.Endpoint != "" || cfg.File != ""
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator, so traces continue across the API and into the Docker client.
+// The returned function flushes the spans still buffered; it does nothing
+// when tracing is off.
+func Setup(cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var options []sdktrace.TracerProviderOption
+	var file *os.File
+	if cfg.Endpoint != "" {
+		exporter, err := otlptracehttp.New(context.Background(), endpointOptions(cfg.Endpoint)...)
+		if err != nil {
+			return nil, fmt.Errorf("OTLP exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	if cfg.File != "" {
+		var out io.Writer = os.Stdout
+		if cfg.File != "-" {
+			var err error
+			if file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+				return nil, fmt.Errorf("trace file: %v", err)
+			}
+			out = file
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, fmt.Errorf("trace file exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))
+	options = append(options,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	logger := logging.For("tracing")
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error("Tracing error", logging.Err(err))
+	}))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}, nil
+}
+
+// endpointOptions turns an endpoint given as host:port or as a URL into
+// exporter options; a bare host:port is sent to over plain HTTP.
+func endpointOptions(endpoint string) []otlptracehttp.Option {
+	if strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	}
+	return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure()}
+}
+
+// Tracer returns the tracer of a component, named after its package.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the ID of the recorded trace ctx belongs to, or "" when
+// ctx is not part of one.
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() && sc.IsSampled() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+var tracer = Tracer("cluster-sim/api")
+
+// Middleware starts a server span for every API request, continuing the
+// trace of a client that sent a traceparent header. The trace ID is
+// returned in the X-Trace-Id header.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		if id := TraceID(ctx); id != "" {
+			c.Header("X-Trace-Id", id)
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.SetAttributes(attribute.String("error.message", c.Errors.String()))
+		}
+	}
+}
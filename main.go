@@ -2,16 +2,21 @@ package main
 
 import (
 
+    "flag"
     "os"
     "cluster-sim/api"
+    "cluster-sim/internal/config"
 )
 
 func main() {
-	// Get port from environment variable or default to 8080
-	port := "8080"
-	if len(os.Args) > 1 {
-		port = os.Args[1] // Use the first command-line argument as the port
+	var flags config.Flags
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.Register(fs)
+	fs.Parse(os.Args[1:])
+	// A lone argument is the port, as before the flags existed
+	if fs.NArg() > 0 {
+		flags.SetPort(fs.Arg(0))
 	}
 
-	api.StartServer(port)
+	api.StartServer(&flags)
 }